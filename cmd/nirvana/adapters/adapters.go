@@ -0,0 +1,107 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapters
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/caicloud/nirvana/cmd/nirvana/buildutils"
+	"github.com/caicloud/nirvana/log"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// fileName is the name of the generated file.
+const fileName = "zz_generated.adapters.go"
+
+func newAdaptersCommand() *cobra.Command {
+	options := &adaptersOptions{}
+	cmd := &cobra.Command{
+		Use:   "adapters /path/to/apis",
+		Short: "Generate typed adapters to call API functions without reflection",
+		Long:  options.Manuals(),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := options.Validate(cmd, args); err != nil {
+				log.Fatalln(err)
+			}
+			if err := options.Run(cmd, args); err != nil {
+				log.Fatalln(err)
+			}
+		},
+	}
+	options.Install(cmd.PersistentFlags())
+	return cmd
+}
+
+type adaptersOptions struct {
+	Output  string
+	Package string
+}
+
+func (o *adaptersOptions) Install(flags *pflag.FlagSet) {
+	flags.StringVar(&o.Output, "output", "./pkg/adapters", "Output directory for generated adapters")
+	flags.StringVar(&o.Package, "package", "", "Package name of generated adapters, defaults to the name of output directory")
+}
+
+func (o *adaptersOptions) Validate(cmd *cobra.Command, args []string) error {
+	if o.Output == "" {
+		return fmt.Errorf("must specify generated adapters path")
+	}
+	return nil
+}
+
+func (o *adaptersOptions) Run(cmd *cobra.Command, args []string) error {
+	if len(args) <= 0 {
+		defaultAPIsPath := "pkg"
+		args = append(args, defaultAPIsPath)
+		log.Infof("No packages are specified, defaults to %s", defaultAPIsPath)
+	}
+	pkg := o.Package
+	if pkg == "" {
+		dir, err := filepath.Abs(o.Output)
+		if err != nil {
+			return err
+		}
+		pkg = filepath.Base(dir)
+	}
+
+	_, adapters, err := buildutils.BuildAdapters(pkg, args...)
+	if err != nil {
+		return err
+	}
+	for _, f := range adapters.Skipped {
+		log.Warningf("Function %s can't be adapted and is called by reflection", f)
+	}
+
+	if err := os.MkdirAll(o.Output, 0775); err != nil {
+		return fmt.Errorf("can't create directory %s: %v", o.Output, err)
+	}
+	path := filepath.Join(o.Output, fileName)
+	if err := ioutil.WriteFile(path, []byte(adapters.Code), 0664); err != nil {
+		return err
+	}
+	log.Infof("Generated adapters %s, import the package in your main package to use them", path)
+	return nil
+}
+
+func (o *adaptersOptions) Manuals() string {
+	return ""
+}
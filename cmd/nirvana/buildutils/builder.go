@@ -23,6 +23,7 @@ import (
 	"strings"
 
 	"github.com/caicloud/nirvana/log"
+	"github.com/caicloud/nirvana/service"
 	"github.com/caicloud/nirvana/utils/api"
 	"github.com/caicloud/nirvana/utils/builder"
 	"github.com/caicloud/nirvana/utils/project"
@@ -32,6 +33,46 @@ import (
 // no project config, default config is used (default root path is current path).
 // All paths should be under root path.
 func Build(paths ...string) (*project.Config, *api.Definitions, error) {
+	config, builder, err := newBuilder(paths...)
+	if err != nil {
+		return nil, nil, err
+	}
+	definitions, err := builder.Build()
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, definitions, nil
+}
+
+// BuildRoutes finds project config like Build and returns routes of the
+// service in the order of matching.
+func BuildRoutes(paths ...string) (*project.Config, []service.Route, error) {
+	config, builder, err := newBuilder(paths...)
+	if err != nil {
+		return nil, nil, err
+	}
+	routes, err := builder.BuildRoutes()
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, routes, nil
+}
+
+// BuildAdapters finds project config like Build and generates typed adapters
+// of definition functions in package pkg.
+func BuildAdapters(pkg string, paths ...string) (*project.Config, *builder.Adapters, error) {
+	config, builder, err := newBuilder(paths...)
+	if err != nil {
+		return nil, nil, err
+	}
+	adapters, err := builder.BuildAdapters(pkg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, adapters, nil
+}
+
+func newBuilder(paths ...string) (*project.Config, *builder.APIBuilder, error) {
 	var config *project.Config
 	var err error
 	for _, path := range paths {
@@ -77,10 +118,5 @@ func Build(paths ...string) (*project.Config, *api.Definitions, error) {
 			return nil, nil, fmt.Errorf("path %s is not in root dir %s", path, config.Root)
 		}
 	}
-	builder := builder.NewAPIBuilder(config.Root, project.Subdirectories(false, paths...)...)
-	definitions, err := builder.Build()
-	if err != nil {
-		return nil, nil, err
-	}
-	return config, definitions, nil
+	return config, builder.NewAPIBuilder(config.Root, project.Subdirectories(false, paths...)...), nil
 }
@@ -17,9 +17,11 @@ limitations under the License.
 package main
 
 import (
+	"github.com/caicloud/nirvana/cmd/nirvana/adapters"
 	"github.com/caicloud/nirvana/cmd/nirvana/api"
 	"github.com/caicloud/nirvana/cmd/nirvana/client"
 	"github.com/caicloud/nirvana/cmd/nirvana/project"
+	"github.com/caicloud/nirvana/cmd/nirvana/routes"
 	"github.com/caicloud/nirvana/log"
 
 	"github.com/spf13/cobra"
@@ -35,6 +37,8 @@ func main() {
 	project.Register(root)
 	api.Register(root)
 	client.Register(root)
+	routes.Register(root)
+	adapters.Register(root)
 	if err := root.Execute(); err != nil {
 		log.Fatalln(err)
 	}
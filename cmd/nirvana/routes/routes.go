@@ -0,0 +1,132 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/caicloud/nirvana/cmd/nirvana/buildutils"
+	"github.com/caicloud/nirvana/log"
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/utils/printer"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func newRoutesCommand() *cobra.Command {
+	options := &routesOptions{}
+	cmd := &cobra.Command{
+		Use:   "routes /path/to/apis",
+		Short: "Print the route table of your project",
+		Long:  options.Manuals(),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := options.Validate(cmd, args); err != nil {
+				log.Fatalln(err)
+			}
+			if err := options.Run(cmd, args); err != nil {
+				log.Fatalln(err)
+			}
+		},
+	}
+	options.Install(cmd.PersistentFlags())
+	return cmd
+}
+
+type routesOptions struct {
+	Output string
+}
+
+func (o *routesOptions) Install(flags *pflag.FlagSet) {
+	flags.StringVarP(&o.Output, "output", "o", "table", "Output format: table or json")
+}
+
+func (o *routesOptions) Validate(cmd *cobra.Command, args []string) error {
+	if o.Output != "table" && o.Output != "json" {
+		return fmt.Errorf("unknown output format %s", o.Output)
+	}
+	return nil
+}
+
+func (o *routesOptions) Run(cmd *cobra.Command, args []string) error {
+	if len(args) <= 0 {
+		defaultAPIsPath := "pkg"
+		args = append(args, defaultAPIsPath)
+		log.Infof("No packages are specified, defaults to %s", defaultAPIsPath)
+	}
+
+	config, routes, err := buildutils.BuildRoutes(args...)
+	if err != nil {
+		return err
+	}
+
+	if o.Output == "json" {
+		data, err := json.MarshalIndent(routes, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	table := printer.NewTable(80)
+	table.AddRow("METHOD", "PATH", "VERSION", "MIDDLEWARES", "FUNCTION", "SHADOWED BY")
+	for _, r := range routes {
+		table.AddRow(r.Method, path(&r), r.Version, r.Middlewares, function(config.Root, &r), strings.Join(r.ShadowedBy, "\n"))
+	}
+	fmt.Print(table.String())
+	return nil
+}
+
+// path returns the host and path of a route. Headers are in following lines.
+func path(r *service.Route) string {
+	lines := []string{r.Host + r.Path}
+	for _, name := range sortedNames(r.Headers) {
+		lines = append(lines, name+": "+r.Headers[name])
+	}
+	return strings.Join(lines, "\n")
+}
+
+func sortedNames(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// function returns the function name and location of a route. The file is
+// relative to project root if possible.
+func function(root string, r *service.Route) string {
+	if r.Function == "" {
+		return ""
+	}
+	file := r.File
+	if rel, err := filepath.Rel(root, file); err == nil && !strings.HasPrefix(rel, "..") {
+		file = rel
+	}
+	return fmt.Sprintf("%s\n%s#%d", r.Function, file, r.Line)
+}
+
+func (o *routesOptions) Manuals() string {
+	return ""
+}
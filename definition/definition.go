@@ -70,6 +70,15 @@ type Operator interface {
 	Operate(ctx context.Context, field string, object interface{}) (interface{}, error)
 }
 
+// ValidatableOperator is an optional interface of Operator. If an operator
+// implements it, Validate is called when a service is built. Operators can
+// report errors which should stop the service from running.
+type ValidatableOperator interface {
+	Operator
+	// Validate checks whether the operator can work.
+	Validate() error
+}
+
 // Method is an alternative of HTTP method. It's more clearer than HTTP method.
 // A definition method binds a certain HTTP method and a success status code.
 type Method string
@@ -107,6 +116,8 @@ type Source string
 const (
 	// Path means value is from URL path.
 	Path Source = "Path"
+	// Host means value is from a key of the host of descriptors.
+	Host Source = "Host"
 	// Query means value is from URL query string.
 	Query Source = "Query"
 	// Header means value is from request header.
@@ -142,6 +153,10 @@ const (
 	Meta Destination = "Meta"
 	// Data means result will be set into the body of response.
 	Data Destination = "Data"
+	// Validators means the result contains validators of the response for
+	// conditional requests. The result type can be string (an entity tag),
+	// time.Time (last modification time) or service.Validators.
+	Validators Destination = "Validators"
 	// Error means the result is an error and should be treated specially.
 	// An error occurs indicates that there is no data to return. So the
 	// error should be treated as data and be writed back to client.
@@ -222,12 +237,33 @@ type Definition struct {
 	// In some cases, successful data and error data should be generated in
 	// different ways.
 	ErrorProduces []string
+	// Version is the version of the API handler. Handlers with the same path and
+	// method are chosen by the version requested by clients. The version is read
+	// from header "Accept-Version", or from a vendor media type in header "Accept"
+	// or "Content-Type" (ex. "application/vnd.acme.widget.v2+json").
+	// A handler without version serves requests without version and requests
+	// for a version that no handler declares.
+	Version string
+	// Host is the host of requests which the handler handles.
+	// It will override parent descriptor's host.
+	Host string
+	// Headers are headers which requests must have.
+	// They are merged with parent descriptor's headers.
+	Headers map[string]string
 	// Function is a function handler. It must be func type.
 	Function interface{}
 	// Parameters describes function parameters.
 	Parameters []Parameter
 	// Results describes function return values.
 	Results []Result
+	// AggregateErrors makes the handler collect errors of all parameters
+	// and respond them in one error, instead of responding the first one.
+	// Each field error contains the path, source, reason and message of
+	// an invalid field.
+	AggregateErrors bool
+	// NoEnvelope makes responses of the handler not wrapped by the envelope
+	// of the server, which is set by nirvana.Envelope.
+	NoEnvelope bool
 	// Summary is a one-line brief description of this definition.
 	Summary string
 	// Description describes the API handler.
@@ -19,6 +19,7 @@ package definition
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"reflect"
 )
 
@@ -39,8 +40,18 @@ const (
 	MIMEOctetStream = "application/octet-stream"
 	MIMEURLEncoded  = "application/x-www-form-urlencoded"
 	MIMEFormData    = "multipart/form-data"
+	// MIMEMergePatch is the content type of JSON merge patch (RFC 7396).
+	MIMEMergePatch = "application/merge-patch+json"
+	// MIMEJSONPatch is the content type of JSON patch (RFC 6902).
+	MIMEJSONPatch = "application/json-patch+json"
+	// MIMEProblemJSON is the content type of problem details (RFC 7807).
+	MIMEProblemJSON = "application/problem+json"
 )
 
+// HeaderIdempotencyKey is the request header which identifies retries of
+// a request with an unsafe method.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
 // DataErrorResults returns the most frequently-used results.
 // Definition function should have two results. The first is
 // any type for data, and the last is error.
@@ -279,3 +290,14 @@ func SimpleRPCDescriptor(path string, f interface{}) RPCDescriptor {
 		},
 	}
 }
+
+// Mount creates a REST descriptor which mounts an http handler on a subtree.
+// The handler handles "prefix" and all paths under it with any methods and
+// content types. For example, a request to "/static/css/app.css" is passed
+// to the handler mounted on "/static" with path "/css/app.css".
+func Mount(prefix string, h http.Handler) Descriptor {
+	return Descriptor{
+		Path:    prefix,
+		Handler: h,
+	}
+}
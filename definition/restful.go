@@ -16,6 +16,8 @@ limitations under the License.
 
 package definition
 
+import "net/http"
+
 // Descriptor describes a descriptor for API definitions.
 type Descriptor struct {
 	// Path is the url path. It will inherit parent's path.
@@ -23,6 +25,21 @@ type Descriptor struct {
 	// If parent path is "/api/v1", current is "/some",
 	// It means current definitions handles "/api/v1/some".
 	Path string
+	// Host is the host of requests which current definitions and child
+	// definitions handle. It will override parent descriptor's host.
+	// Empty means all hosts. A host may contain wildcards and keys:
+	//  api.example.com
+	//  *.example.com
+	//  {tenant}.example.com
+	//  {tenant:slug}.example.com
+	// Values of keys can be got from Source Host. Descriptors with hosts
+	// take precedence over descriptors without hosts. Middlewares of
+	// descriptors without hosts and headers apply to all hosts.
+	Host string
+	// Headers are headers which requests must have. A value "*" matches
+	// any value. They are merged with parent descriptor's headers.
+	// Descriptors with more headers take precedence.
+	Headers map[string]string
 	// Consumes indicates content types that current definitions
 	// and child definitions can consume.
 	// It will override parent descriptor's consumes.
@@ -40,6 +57,11 @@ type Descriptor struct {
 	Definitions []Definition
 	// Children is used to place sub-descriptors.
 	Children []Descriptor
+	// Handler is an http handler which handles all requests to the path
+	// and paths under it, whatever their methods and content types are.
+	// The path of requests is stripped of the prefix before they are
+	// passed to the handler. Middlewares still work. See Mount.
+	Handler http.Handler
 	// Description describes the usage of the path.
 	Description string
 }
@@ -0,0 +1,112 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package definition
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// HeaderAcceptVersion is the request header to ask for a version of definitions.
+const HeaderAcceptVersion = "Accept-Version"
+
+// vendorMediaType matches media types like "application/vnd.acme.widget.v2+json".
+var vendorMediaType = regexp.MustCompile(`^([a-z0-9!#$&^_.-]+)/vnd\.([a-z0-9!#$&^_.+-]+)\.(v[0-9][a-z0-9]*)\+([a-z0-9!#$&^_.-]+)$`)
+
+// ParseVendorMediaType parses a versioned vendor media type. For media type
+// "application/vnd.acme.widget.v2+json", it returns "application/json",
+// "acme.widget" and "v2". ok is false if the media type is not versioned.
+func ParseVendorMediaType(mediaType string) (base string, vendor string, version string, ok bool) {
+	parts := vendorMediaType.FindStringSubmatch(strings.ToLower(strings.TrimSpace(mediaType)))
+	if parts == nil {
+		return "", "", "", false
+	}
+	return parts[1] + "/" + parts[4], parts[2], parts[3], true
+}
+
+// VendorMediaType builds a versioned vendor media type. It's the reverse
+// of ParseVendorMediaType.
+func VendorMediaType(base string, vendor string, version string) string {
+	index := strings.IndexByte(base, '/')
+	if index < 0 {
+		return base
+	}
+	return base[:index] + "/vnd." + vendor + "." + NormalizeVersion(version) + "+" + base[index+1:]
+}
+
+// NormalizeVersion converts a version to lower case and adds a "v" prefix
+// if the version starts with a digit. For example, "2" becomes "v2".
+func NormalizeVersion(version string) string {
+	version = strings.ToLower(strings.TrimSpace(version))
+	if version != "" && version[0] >= '0' && version[0] <= '9' {
+		version = "v" + version
+	}
+	return version
+}
+
+// kubeVersion matches versions like "v2", "v2beta1" and "v1alpha".
+var kubeVersion = regexp.MustCompile(`^v([0-9]+)(?:(alpha|beta)([0-9]*))?$`)
+
+// CompareVersions compares two versions. It returns a negative number if a is
+// older than b, 0 if they are the same and a positive number if a is newer.
+// A stable version is newer than its beta versions, and beta versions are newer
+// than alpha versions. For example: v1alpha1 < v1beta1 < v1 < v2alpha1.
+func CompareVersions(a, b string) int {
+	a, b = NormalizeVersion(a), NormalizeVersion(b)
+	pa, pb := kubeVersion.FindStringSubmatch(a), kubeVersion.FindStringSubmatch(b)
+	if pa == nil || pb == nil {
+		return strings.Compare(a, b)
+	}
+	stability := map[string]int{"alpha": 0, "beta": 1, "": 2}
+	number := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	if r := number(pa[1]) - number(pb[1]); r != 0 {
+		return r
+	}
+	if r := stability[pa[2]] - stability[pb[2]]; r != 0 {
+		return r
+	}
+	return number(pa[3]) - number(pb[3])
+}
+
+// ChooseVersion chooses the version of definitions which handle requests
+// for version. versions are versions of candidate definitions. Candidates
+// without version are the fallback if no candidate has the version. If no
+// version is requested and all candidates have versions, the latest version
+// is chosen.
+func ChooseVersion(versions []string, version string) string {
+	fallback := false
+	for _, v := range versions {
+		if version != "" && v == version {
+			return version
+		}
+		fallback = fallback || v == ""
+	}
+	if fallback || version != "" {
+		return ""
+	}
+	latest := ""
+	for _, v := range versions {
+		if latest == "" || CompareVersions(v, latest) > 0 {
+			latest = v
+		}
+	}
+	return latest
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package definition
+
+import "testing"
+
+func TestParseVendorMediaType(t *testing.T) {
+	base, vendor, version, ok := ParseVendorMediaType("application/vnd.acme.widget.v2+json")
+	if !ok || base != MIMEJSON || vendor != "acme.widget" || version != "v2" {
+		t.Fatalf("Unexpected result: %s %s %s %v", base, vendor, version, ok)
+	}
+	if mt := VendorMediaType(base, vendor, "2"); mt != "application/vnd.acme.widget.v2+json" {
+		t.Fatalf("Unexpected vendor media type: %s", mt)
+	}
+	if _, _, _, ok := ParseVendorMediaType("application/vnd.acme.widget+json"); ok {
+		t.Fatal("Media type without version should not be parsed")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	ordered := []string{"v1alpha1", "v1alpha2", "v1beta1", "1", "v2alpha1", "v2", "v10"}
+	for i := 0; i < len(ordered)-1; i++ {
+		if CompareVersions(ordered[i], ordered[i+1]) >= 0 {
+			t.Fatalf("%s should be older than %s", ordered[i], ordered[i+1])
+		}
+	}
+	if CompareVersions("2", "v2") != 0 {
+		t.Fatal("2 and v2 should be the same version")
+	}
+}
+
+func TestChooseVersion(t *testing.T) {
+	for _, test := range []struct {
+		versions []string
+		version  string
+		expected string
+	}{
+		{[]string{"v1", "v2", ""}, "v1", "v1"},
+		{[]string{"v1", "v2", ""}, "v3", ""},
+		{[]string{"v1", "v2", ""}, "", ""},
+		{[]string{"v1", "v2beta1"}, "v3", ""},
+		{[]string{"v1", "v2beta1"}, "", "v2beta1"},
+		{nil, "", ""},
+	} {
+		if chosen := ChooseVersion(test.versions, test.version); chosen != test.expected {
+			t.Fatalf("Version %q should be chosen from %v for %q, but got %q", test.expected, test.versions, test.version, chosen)
+		}
+	}
+}
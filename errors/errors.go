@@ -83,6 +83,8 @@ type message struct {
 	Message string `json:"message"`
 	// Data is used for i18n.
 	Data dataMap `json:"data,omitempty" xml:",omitempty"`
+	// Fields contains errors of fields in a request.
+	Fields []FieldError `json:"fields,omitempty" xml:",omitempty"`
 }
 
 // Error returns error description.
@@ -17,8 +17,10 @@ limitations under the License.
 package errors
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"testing"
 )
@@ -162,3 +164,57 @@ func TestDerived(t *testing.T) {
 		t.Fatal(e3)
 	}
 }
+
+func TestFieldErrors(t *testing.T) {
+	invalid := BadRequest.Build("Test:InvalidField", "field ${field} is invalid")
+	nested := WithFields(invalid.Error("options"),
+		FieldErrorOf("name", "", invalid.Error("name")),
+		FieldErrorOf("[0]", "Query", fmt.Errorf("out of range")),
+	)
+	fields := FieldErrorsOf("options", "Auto", nested)
+	expected := []FieldError{
+		{Field: "options.name", Source: "Auto", Reason: "Test:InvalidField", Message: "field name is invalid"},
+		{Field: "options[0]", Source: "Query", Message: "out of range"},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("Unexpected field errors: %+v", fields)
+	}
+
+	e := WithFields(BadRequest.Build("Test:InvalidFields", "${count} fields are invalid").Error(2), fields...)
+	data, err := json.Marshal(e.(interface{ Message() interface{} }).Message())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseError(400, DataTypeJSON, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := FieldsOf(parsed); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Field errors should be parsed from %s, but got %+v", data, got)
+	}
+	if FieldsOf(fmt.Errorf("error")) != nil || WithFields(io.EOF, expected...) != io.EOF {
+		t.Fatal("Errors without fields should not have field errors")
+	}
+}
+
+func TestParseProblem(t *testing.T) {
+	data := []byte(`{"type":"Test:NotFound","title":"Not Found","status":404,"detail":"test not found",` +
+		`"instance":"/tests/test","name":"test","count":1,"fields":[{"field":"name","message":"invalid"}]}`)
+	e, err := ParseError(404, DataTypeProblem, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Code() != 404 || e.Reason() != "Test:NotFound" || e.Error() != "test not found" ||
+		!reflect.DeepEqual(e.Data(), map[string]string{"name": "test"}) ||
+		!reflect.DeepEqual(FieldsOf(e), []FieldError{{Field: "name", Message: "invalid"}}) {
+		t.Fatalf("Unexpected error parsed from problem: %+v", e)
+	}
+
+	e, err = ParseError(500, DataTypeProblem, []byte(`{"type":"about:blank","title":"Internal Server Error","status":500}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Reason() != "" || e.Error() != "Internal Server Error" {
+		t.Fatalf("Unexpected error parsed from blank problem: %+v", e)
+	}
+}
@@ -29,6 +29,8 @@ const (
 	DataTypeJSON DataType = "json"
 	// DataTypeXML corresponds to content type "application/xml".
 	DataTypeXML DataType = "xml"
+	// DataTypeProblem corresponds to content type "application/problem+json".
+	DataTypeProblem DataType = "problem"
 	// DataTypePlain indicates there is a plain error message.
 	DataTypePlain DataType = ""
 )
@@ -104,8 +106,62 @@ func ParseError(code int, dt DataType, data []byte) (ExternalError, error) {
 			return e, json.Unmarshal(data, &e.message)
 		case DataTypeXML:
 			return e, xml.Unmarshal(data, &e.message)
+		case DataTypeProblem:
+			return e, e.message.unmarshalProblem(data)
 		}
 		e.message.Message = string(data)
 	}
 	return e, nil
 }
+
+// problemMembers are standard members of problem details (RFC 7807).
+var problemMembers = map[string]bool{
+	"type":     true,
+	"title":    true,
+	"status":   true,
+	"detail":   true,
+	"instance": true,
+}
+
+// unmarshalProblem unmarshals problem details into the message. Member
+// "type" is the reason and "detail" (or "title" if it's empty) is the
+// message. String extension members are put into data.
+func (m *message) unmarshalProblem(data []byte) error {
+	members := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	var typ, title string
+	for key, value := range members {
+		var err error
+		switch {
+		case key == "type":
+			err = json.Unmarshal(value, &typ)
+		case key == "title":
+			err = json.Unmarshal(value, &title)
+		case key == "detail":
+			err = json.Unmarshal(value, &m.Message)
+		case key == "fields":
+			err = json.Unmarshal(value, &m.Fields)
+		case !problemMembers[key]:
+			var v string
+			// Extension members which are not strings can't be kept in data.
+			if json.Unmarshal(value, &v) == nil {
+				if m.Data == nil {
+					m.Data = dataMap{}
+				}
+				m.Data[key] = v
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if typ != "about:blank" {
+		m.Reason = Reason(typ)
+	}
+	if m.Message == "" {
+		m.Message = title
+	}
+	return nil
+}
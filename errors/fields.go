@@ -0,0 +1,106 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package errors
+
+// FieldError describes an error of a field in a request.
+type FieldError struct {
+	// Field is the path of the field, such as "filters[0].name".
+	Field string `json:"field"`
+	// Source is where the field is from, such as "Query" and "Body".
+	Source string `json:"source,omitempty" xml:",omitempty"`
+	// Reason is the reason of the error.
+	Reason Reason `json:"reason,omitempty" xml:",omitempty"`
+	// Message describes the error.
+	Message string `json:"message"`
+}
+
+// FieldErrorOf creates a field error from an error. Reason is set if the
+// error is created by a factory or implements ExternalError.
+func FieldErrorOf(field, source string, e error) FieldError {
+	fe := FieldError{
+		Field:   field,
+		Source:  source,
+		Message: e.Error(),
+	}
+	if external, ok := e.(ExternalError); ok {
+		fe.Reason = Reason(external.Reason())
+	}
+	return fe
+}
+
+// FieldErrorsOf converts an error of a field to field errors. If the error
+// has field errors, they are regarded as errors of nested fields: their
+// paths are prefixed with the field, and empty sources are set to source.
+func FieldErrorsOf(field, source string, e error) []FieldError {
+	nested := FieldsOf(e)
+	if len(nested) <= 0 {
+		return []FieldError{FieldErrorOf(field, source, e)}
+	}
+	result := make([]FieldError, len(nested))
+	for i, fe := range nested {
+		switch {
+		case field == "":
+		case fe.Field == "":
+			fe.Field = field
+		case fe.Field[0] == '[':
+			fe.Field = field + fe.Field
+		default:
+			fe.Field = field + "." + fe.Field
+		}
+		if fe.Source == "" {
+			fe.Source = source
+		}
+		result[i] = fe
+	}
+	return result
+}
+
+// WithFields returns a copy of an error with field errors appended. The
+// error must be created by a factory or parsed by ParseError. Other errors
+// are returned as they are.
+func WithFields(e error, fields ...FieldError) error {
+	switch origin := e.(type) {
+	case *err:
+		result := *origin
+		result.message.Fields = append(append([]FieldError{}, origin.message.Fields...), fields...)
+		return &result
+	case *externalError:
+		result := *origin
+		result.message.Fields = append(append([]FieldError{}, origin.message.Fields...), fields...)
+		return &result
+	}
+	return e
+}
+
+// FieldsOf returns field errors of an error. It returns nil if the error
+// has no field errors.
+func FieldsOf(e error) []FieldError {
+	if f, ok := e.(interface{ Fields() []FieldError }); ok {
+		return f.Fields()
+	}
+	return nil
+}
+
+// Fields returns field errors of the error.
+func (e *err) Fields() []FieldError {
+	return e.message.Fields
+}
+
+// Fields returns field errors of the error.
+func (e *externalError) Fields() []FieldError {
+	return e.message.Fields
+}
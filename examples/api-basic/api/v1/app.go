@@ -18,7 +18,8 @@ package v1
 
 import (
 	"github.com/caicloud/nirvana/definition"
-	"github.com/caicloud/nirvana/examples/api-basic/api/v1/converters"
+	// Register conversions for v1.
+	_ "github.com/caicloud/nirvana/examples/api-basic/api/v1/converters"
 	"github.com/caicloud/nirvana/examples/api-basic/application"
 )
 
@@ -32,27 +33,15 @@ var app = definition.Descriptor{
 	Definitions: []definition.Definition{
 		{
 			Method:      definition.Create,
+			Version:     "v1",
 			Description: "Create Application",
 			Function:    application.CreateApplication,
 			Consumes:    []string{definition.MIMEJSON},
 			Produces:    []string{definition.MIMEJSON},
 			Parameters: []definition.Parameter{
-				{
-					Source: definition.Body,
-					Operators: []definition.Operator{
-						converters.ConvertApplicationV1ToApplication(),
-					},
-				},
-			},
-			Results: []definition.Result{
-				{
-					Destination: definition.Data,
-					Operators: []definition.Operator{
-						converters.ConvertApplicationToApplicationV1(),
-					},
-				},
-				definition.ErrorResult(),
+				definition.BodyParameterFor("Application V1 json object"),
 			},
+			Results: definition.DataErrorResults("Application V1 json object"),
 		},
 	},
 }
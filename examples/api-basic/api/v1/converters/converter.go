@@ -23,34 +23,40 @@ import (
 	"github.com/caicloud/nirvana/operators/converter"
 )
 
-func ConvertApplicationV1ToApplication() converter.Converter {
-	return converter.For(func(ctx context.Context, field string, app *application.ApplicationV1) (*application.Application, error) {
-		return &application.Application{
-			Metadata: application.Metadata{
-				Name:      app.Name,
-				Partition: app.Partition,
-			},
-			Spec: application.ApplicationSpec{
-				Replica:     app.Replica,
-				OtherFields: "Some Default Value",
-			},
-			Status: application.ApplicationStatus{
-				Phase:   app.Phase,
-				Message: app.Message,
-			},
-		}, nil
-	})
+func init() {
+	// Register conversions between v1 and the internal type. The modifier
+	// of converter package inserts them into v1 definitions.
+	if err := converter.Register("v1", ConvertApplicationV1ToApplication, ConvertApplicationToApplicationV1); err != nil {
+		panic(err)
+	}
 }
 
-func ConvertApplicationToApplicationV1() converter.Converter {
-	return converter.For(func(ctx context.Context, field string, app *application.Application) (*application.ApplicationV1, error) {
-		return &application.ApplicationV1{
-			Name:      app.Metadata.Name,
-			Partition: app.Metadata.Partition,
-			Replica:   app.Spec.Replica,
-			// Ignore app.Spec.OtherFields
-			Phase:   app.Status.Phase,
-			Message: app.Status.Message,
-		}, nil
-	})
+// ConvertApplicationV1ToApplication converts v1 application to the internal type.
+func ConvertApplicationV1ToApplication(ctx context.Context, field string, app *application.ApplicationV1) (*application.Application, error) {
+	return &application.Application{
+		Metadata: application.Metadata{
+			Name:      app.Name,
+			Partition: app.Partition,
+		},
+		Spec: application.ApplicationSpec{
+			Replica:     app.Replica,
+			OtherFields: "Some Default Value",
+		},
+		Status: application.ApplicationStatus{
+			Phase:   app.Phase,
+			Message: app.Message,
+		},
+	}, nil
+}
+
+// ConvertApplicationToApplicationV1 converts the internal type to v1 application.
+func ConvertApplicationToApplicationV1(ctx context.Context, field string, app *application.Application) (*application.ApplicationV1, error) {
+	return &application.ApplicationV1{
+		Name:      app.Metadata.Name,
+		Partition: app.Metadata.Partition,
+		Replica:   app.Spec.Replica,
+		// Ignore app.Spec.OtherFields
+		Phase:   app.Status.Phase,
+		Message: app.Status.Message,
+	}, nil
 }
@@ -22,6 +22,7 @@ import (
 	v1 "github.com/caicloud/nirvana/examples/api-basic/api/v1"
 	v2 "github.com/caicloud/nirvana/examples/api-basic/api/v2"
 	"github.com/caicloud/nirvana/log"
+	"github.com/caicloud/nirvana/operators/converter"
 )
 
 func main() {
@@ -29,6 +30,8 @@ func main() {
 	cfg := nirvana.NewDefaultConfig()
 	cfg.Configure(
 		nirvana.Descriptor(v1.Descriptor(), v2.Descriptor()),
+		// Convert v1 objects from and to the internal types.
+		nirvana.Modifier(converter.Modifier()),
 	)
 	if err := cmd.ExecuteWithConfig(cfg); err != nil {
 		log.Fatal(err)
@@ -0,0 +1,56 @@
+#!/bin/bash
+
+# Copyright 2020 Caicloud Authors
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Compares benchmarks of the REST router with a baseline revision.
+#
+# Usage: hack/bench-router.sh <baseline-revision> [count]
+#
+# The baseline revision is checked out into a temporary worktree. The
+# benchmark file of current tree is copied into it, so both trees run the
+# same benchmarks. Results are compared by benchstat if it's installed.
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+ROOT=$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)
+BASELINE=${1:?"Usage: $0 <baseline-revision> [count]"}
+COUNT=${2:-5}
+PKG=./service/rest/router/
+BENCH=BenchmarkMatchRoutes
+
+WORK=$(mktemp -d)
+cleanup() {
+  git -C "${ROOT}" worktree remove --force "${WORK}/baseline" >/dev/null 2>&1 || true
+  rm -rf "${WORK}"
+}
+trap cleanup EXIT
+
+git -C "${ROOT}" worktree add --detach "${WORK}/baseline" "${BASELINE}" >/dev/null
+cp "${ROOT}/service/rest/router/benchmark_test.go" "${WORK}/baseline/service/rest/router/"
+
+export GOFLAGS=-mod=vendor
+(cd "${WORK}/baseline" && go test -run '^$' -bench "${BENCH}" -benchmem -count "${COUNT}" "${PKG}") > "${WORK}/old.txt"
+(cd "${ROOT}" && go test -run '^$' -bench "${BENCH}" -benchmem -count "${COUNT}" "${PKG}") > "${WORK}/new.txt"
+
+if command -v benchstat >/dev/null 2>&1; then
+  benchstat "${WORK}/old.txt" "${WORK}/new.txt"
+else
+  echo "# baseline: ${BASELINE}"
+  grep "^Benchmark" "${WORK}/old.txt"
+  echo "# current"
+  grep "^Benchmark" "${WORK}/new.txt"
+fi
@@ -99,6 +99,23 @@ func TestDefaultLogger(t *testing.T) {
 	tr.testln(t, SeverityInfo, data)
 }
 
+func TestPrefixLogger(t *testing.T) {
+	tr := newTester()
+	l := NewPrefixLogger(newStdLogger(LevelDebug, tr.buf, 0), "[id] ")
+
+	data := []interface{}{"123", 456, false}
+	format := "%s %d-%v"
+
+	l.Info(data)
+	tr.test(t, SeverityInfo, "[id] ", data)
+	l.Warningf(format, data)
+	tr.testf(t, SeverityWarning, "[id] "+format, data)
+	l.Errorln(data)
+	tr.test(t, SeverityError, "[id] ", fmt.Sprintln(data))
+	l.V(1).Info(data)
+	tr.test(t, SeverityInfo, "[id] ", data)
+}
+
 type tester struct {
 	// Use the buffer as logger writer.
 	buf *bytes.Buffer
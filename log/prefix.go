@@ -0,0 +1,149 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package log
+
+import "fmt"
+
+// prefixLogger adds a prefix to messages of a logger.
+type prefixLogger struct {
+	logger Logger
+	prefix string
+}
+
+// NewPrefixLogger creates a logger which adds prefix to messages and
+// writes them to logger. For instance, request-scoped loggers add request
+// ids to messages.
+func NewPrefixLogger(logger Logger, prefix string) Logger {
+	return &prefixLogger{
+		logger: logger.Clone(1),
+		prefix: prefix,
+	}
+}
+
+var _ Logger = &prefixLogger{}
+
+// V reports whether verbosity at the call site is at least the requested level.
+// The returned value is a Verboser, which implements Info, Infof
+// and Infoln. These methods will write to the Info log if called.
+func (l *prefixLogger) V(v Level) Verboser {
+	return &prefixVerboser{l.logger.V(v), l.prefix}
+}
+
+// Info logs to the INFO log.
+// Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
+func (l *prefixLogger) Info(a ...interface{}) {
+	l.logger.Info(l.prefix + fmt.Sprint(a...))
+}
+
+// Infof logs to the INFO log.
+// Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
+func (l *prefixLogger) Infof(format string, a ...interface{}) {
+	l.logger.Info(l.prefix + fmt.Sprintf(format, a...))
+}
+
+// Infoln logs to the INFO log.
+// Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
+func (l *prefixLogger) Infoln(a ...interface{}) {
+	l.logger.Info(l.prefix + fmt.Sprintln(a...))
+}
+
+// Warning logs to the WARNING logs.
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func (l *prefixLogger) Warning(a ...interface{}) {
+	l.logger.Warning(l.prefix + fmt.Sprint(a...))
+}
+
+// Warningf logs to the WARNING logs.
+// Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
+func (l *prefixLogger) Warningf(format string, a ...interface{}) {
+	l.logger.Warning(l.prefix + fmt.Sprintf(format, a...))
+}
+
+// Warningln logs to the WARNING logs.
+// Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
+func (l *prefixLogger) Warningln(a ...interface{}) {
+	l.logger.Warning(l.prefix + fmt.Sprintln(a...))
+}
+
+// Error logs to the ERROR logs.
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func (l *prefixLogger) Error(a ...interface{}) {
+	l.logger.Error(l.prefix + fmt.Sprint(a...))
+}
+
+// Errorf logs to the ERROR logs.
+// Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
+func (l *prefixLogger) Errorf(format string, a ...interface{}) {
+	l.logger.Error(l.prefix + fmt.Sprintf(format, a...))
+}
+
+// Errorln logs to the ERROR logs.
+// Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
+func (l *prefixLogger) Errorln(a ...interface{}) {
+	l.logger.Error(l.prefix + fmt.Sprintln(a...))
+}
+
+// Fatal logs to the FATAL logs, then calls os.Exit(1).
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func (l *prefixLogger) Fatal(a ...interface{}) {
+	l.logger.Fatal(l.prefix + fmt.Sprint(a...))
+}
+
+// Fatalf logs to the FATAL logs, then calls os.Exit(1).
+// Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
+func (l *prefixLogger) Fatalf(format string, a ...interface{}) {
+	l.logger.Fatal(l.prefix + fmt.Sprintf(format, a...))
+}
+
+// Fatalln logs to the FATAL logs, then calls os.Exit(1).
+// Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
+func (l *prefixLogger) Fatalln(a ...interface{}) {
+	l.logger.Fatal(l.prefix + fmt.Sprintln(a...))
+}
+
+// Clone clones current logger with new wrapper.
+// A positive wrapper indicates how many wrappers outside the logger.
+func (l *prefixLogger) Clone(wrapper int) Logger {
+	return &prefixLogger{
+		logger: l.logger.Clone(wrapper),
+		prefix: l.prefix,
+	}
+}
+
+// prefixVerboser adds a prefix to messages of a verboser.
+type prefixVerboser struct {
+	verboser Verboser
+	prefix   string
+}
+
+// Info logs to the INFO log.
+// Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
+func (v *prefixVerboser) Info(a ...interface{}) {
+	v.verboser.Info(v.prefix + fmt.Sprint(a...))
+}
+
+// Infof logs to the INFO log.
+// Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
+func (v *prefixVerboser) Infof(format string, a ...interface{}) {
+	v.verboser.Info(v.prefix + fmt.Sprintf(format, a...))
+}
+
+// Infoln logs to the INFO log.
+// Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
+func (v *prefixVerboser) Infoln(a ...interface{}) {
+	v.verboser.Info(v.prefix + fmt.Sprintln(a...))
+}
@@ -32,6 +32,8 @@ var (
 	requestCount    *prometheus.CounterVec
 	requestDuration *prometheus.HistogramVec
 	responseSize    *prometheus.HistogramVec
+	panicCount      *prometheus.CounterVec
+	phaseDuration   *prometheus.HistogramVec
 )
 
 // Options provide a way to configure the name of the metrics (by setting Namespace and Subsystem) and
@@ -109,6 +111,30 @@ func Install(options *Options) {
 			},
 			httpLabels,
 		)
+
+		panicCount = promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   namespace,
+				Subsystem:   subsystem,
+				Name:        "panic_total",
+				Help:        "Counter of panics when serving requests.",
+				ConstLabels: constLabel,
+			},
+			httpLabels,
+		)
+
+		phaseDuration = promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace:   namespace,
+				Subsystem:   subsystem,
+				Name:        "phase_duration_seconds",
+				Help:        "Duration distribution of phases of executing definitions in seconds.",
+				ConstLabels: constLabel,
+				// From 100µs to about 26s.
+				Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+			},
+			append(httpLabels, "phase"),
+		)
 	})
 }
 
@@ -155,6 +181,50 @@ func RecordRPCRequest(action, version string, code int, contentLength int, durat
 	requestDuration.With(labels).Observe(duration.Seconds())
 }
 
+// RecordRestfulPanic counts a panic when serving a Restful HTTP request.
+func RecordRestfulPanic(path, verb string) {
+	panicCount.With(prometheus.Labels{
+		"verb":    strings.ToUpper(verb),
+		"path":    path,
+		"action":  "",
+		"version": "",
+	}).Inc()
+}
+
+// RecordRPCPanic counts a panic when serving a RPC HTTP request.
+func RecordRPCPanic(action, version string) {
+	panicCount.With(prometheus.Labels{
+		"verb":    "",
+		"path":    "",
+		"action":  action,
+		"version": version,
+	}).Inc()
+}
+
+// RecordRestfulPhase gathers the duration of a phase of executing a Restful HTTP request, such as
+// generating parameters or calling the function.
+func RecordRestfulPhase(path, verb, phase string, duration time.Duration) {
+	phaseDuration.With(prometheus.Labels{
+		"verb":    strings.ToUpper(verb),
+		"path":    path,
+		"action":  "",
+		"version": "",
+		"phase":   phase,
+	}).Observe(duration.Seconds())
+}
+
+// RecordRPCPhase gathers the duration of a phase of executing a RPC HTTP request, such as
+// generating parameters or calling the function.
+func RecordRPCPhase(action, version, phase string, duration time.Duration) {
+	phaseDuration.With(prometheus.Labels{
+		"verb":    "",
+		"path":    "",
+		"action":  action,
+		"version": version,
+		"phase":   phase,
+	}).Observe(duration.Seconds())
+}
+
 var labelRegex = regexp.MustCompile("[^a-z0-9_]+")
 
 // normalizeLabelName convert the given string into a valid label name (or any part of one)
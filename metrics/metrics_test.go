@@ -44,6 +44,8 @@ func TestInstall(t *testing.T) {
 		prometheus.Unregister(requestCount)
 		prometheus.Unregister(requestDuration)
 		prometheus.Unregister(responseSize)
+		prometheus.Unregister(panicCount)
+		prometheus.Unregister(phaseDuration)
 		once = sync.Once{}
 	}()
 	RecordRestfulRequest(path, http.MethodGet, http.StatusOK, 50, time.Millisecond)
@@ -210,6 +212,69 @@ func TestRecordRPCRequest(t *testing.T) {
 	testCases.Test(t)
 }
 
+func TestRecordPanic(t *testing.T) {
+	resetAll()
+	Install(nil)
+	RecordRestfulPanic("/api/v1/messages", http.MethodGet)
+	RecordRestfulPanic("/api/v1/messages", http.MethodGet)
+	RecordRPCPanic("echo", "2020-01-01")
+	var testCases metricsTestCases = map[string]metricsTestCase{
+		"PanicCounter": {
+			Target: panicCount,
+			Want: `
+				# HELP nirvana_panic_total Counter of panics when serving requests.
+				# TYPE nirvana_panic_total counter
+				nirvana_panic_total{action="",path="/api/v1/messages",verb="GET",version=""} 2
+				nirvana_panic_total{action="echo",path="",verb="",version="2020-01-01"} 1
+`,
+		},
+	}
+	testCases.Test(t)
+}
+
+func TestRecordPhase(t *testing.T) {
+	resetAll()
+	Install(nil)
+	RecordRestfulPhase("/api/v1/messages", http.MethodGet, "function", time.Millisecond)
+	RecordRPCPhase("echo", "2020-01-01", "parameter", 50*time.Microsecond)
+	var testCases metricsTestCases = map[string]metricsTestCase{
+		"PhaseDuration": {
+			Target: phaseDuration,
+			Want: `
+				# HELP nirvana_phase_duration_seconds Duration distribution of phases of executing definitions in seconds.
+				# TYPE nirvana_phase_duration_seconds histogram
+				nirvana_phase_duration_seconds_bucket{action="",path="/api/v1/messages",phase="function",verb="GET",version="",le="0.0001"} 0
+				nirvana_phase_duration_seconds_bucket{action="",path="/api/v1/messages",phase="function",verb="GET",version="",le="0.0004"} 0
+				nirvana_phase_duration_seconds_bucket{action="",path="/api/v1/messages",phase="function",verb="GET",version="",le="0.0016"} 1
+				nirvana_phase_duration_seconds_bucket{action="",path="/api/v1/messages",phase="function",verb="GET",version="",le="0.0064"} 1
+				nirvana_phase_duration_seconds_bucket{action="",path="/api/v1/messages",phase="function",verb="GET",version="",le="0.0256"} 1
+				nirvana_phase_duration_seconds_bucket{action="",path="/api/v1/messages",phase="function",verb="GET",version="",le="0.1024"} 1
+				nirvana_phase_duration_seconds_bucket{action="",path="/api/v1/messages",phase="function",verb="GET",version="",le="0.4096"} 1
+				nirvana_phase_duration_seconds_bucket{action="",path="/api/v1/messages",phase="function",verb="GET",version="",le="1.6384"} 1
+				nirvana_phase_duration_seconds_bucket{action="",path="/api/v1/messages",phase="function",verb="GET",version="",le="6.5536"} 1
+				nirvana_phase_duration_seconds_bucket{action="",path="/api/v1/messages",phase="function",verb="GET",version="",le="26.2144"} 1
+				nirvana_phase_duration_seconds_bucket{action="",path="/api/v1/messages",phase="function",verb="GET",version="",le="+Inf"} 1
+				nirvana_phase_duration_seconds_sum{action="",path="/api/v1/messages",phase="function",verb="GET",version=""} 0.001
+				nirvana_phase_duration_seconds_count{action="",path="/api/v1/messages",phase="function",verb="GET",version=""} 1
+				nirvana_phase_duration_seconds_bucket{action="echo",path="",phase="parameter",verb="",version="2020-01-01",le="0.0001"} 1
+				nirvana_phase_duration_seconds_bucket{action="echo",path="",phase="parameter",verb="",version="2020-01-01",le="0.0004"} 1
+				nirvana_phase_duration_seconds_bucket{action="echo",path="",phase="parameter",verb="",version="2020-01-01",le="0.0016"} 1
+				nirvana_phase_duration_seconds_bucket{action="echo",path="",phase="parameter",verb="",version="2020-01-01",le="0.0064"} 1
+				nirvana_phase_duration_seconds_bucket{action="echo",path="",phase="parameter",verb="",version="2020-01-01",le="0.0256"} 1
+				nirvana_phase_duration_seconds_bucket{action="echo",path="",phase="parameter",verb="",version="2020-01-01",le="0.1024"} 1
+				nirvana_phase_duration_seconds_bucket{action="echo",path="",phase="parameter",verb="",version="2020-01-01",le="0.4096"} 1
+				nirvana_phase_duration_seconds_bucket{action="echo",path="",phase="parameter",verb="",version="2020-01-01",le="1.6384"} 1
+				nirvana_phase_duration_seconds_bucket{action="echo",path="",phase="parameter",verb="",version="2020-01-01",le="6.5536"} 1
+				nirvana_phase_duration_seconds_bucket{action="echo",path="",phase="parameter",verb="",version="2020-01-01",le="26.2144"} 1
+				nirvana_phase_duration_seconds_bucket{action="echo",path="",phase="parameter",verb="",version="2020-01-01",le="+Inf"} 1
+				nirvana_phase_duration_seconds_sum{action="echo",path="",phase="parameter",verb="",version="2020-01-01"} 5e-05
+				nirvana_phase_duration_seconds_count{action="echo",path="",phase="parameter",verb="",version="2020-01-01"} 1
+`,
+		},
+	}
+	testCases.Test(t)
+}
+
 // metricsTestCase can be used to unit test a Prometheus Collector implementation. It takes
 // a initialized Prometheus Collector and check if the metric it defines is standard and
 // produces the expected output.
@@ -266,4 +331,10 @@ func resetAll() {
 	if responseSize != nil {
 		responseSize.Reset()
 	}
+	if panicCount != nil {
+		panicCount.Reset()
+	}
+	if phaseDuration != nil {
+		phaseDuration.Reset()
+	}
 }
@@ -0,0 +1,230 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cors provides a middleware for Cross-Origin Resource Sharing.
+// Add it to the root descriptor to apply it to all paths, and add other
+// policies to descriptors to override it:
+//
+//  definition.Descriptor{
+//      Path:        "/public",
+//      Middlewares: []definition.Middleware{cors.New(&cors.Options{})},
+//  }
+//
+// Preflight requests are answered by the router, which sets header "Allow"
+// with methods of the path. The middleware allows those methods. Errors of
+// routing are returned before middlewares run, so add the global policy as a
+// filter too.
+package cors
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/service"
+)
+
+// Headers of CORS.
+const (
+	HeaderOrigin                        = "Origin"
+	HeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
+	HeaderAccessControlRequestHeaders   = "Access-Control-Request-Headers"
+	HeaderAccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	HeaderAccessControlAllowMethods     = "Access-Control-Allow-Methods"
+	HeaderAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	HeaderAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	HeaderAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	HeaderAccessControlMaxAge           = "Access-Control-Max-Age"
+)
+
+// responseHeaders are headers written by the middleware.
+var responseHeaders = []string{
+	HeaderAccessControlAllowOrigin,
+	HeaderAccessControlAllowMethods,
+	HeaderAccessControlAllowHeaders,
+	HeaderAccessControlAllowCredentials,
+	HeaderAccessControlExposeHeaders,
+	HeaderAccessControlMaxAge,
+}
+
+// Options is the CORS policy.
+type Options struct {
+	// AllowedOrigins are origins which can access resources. An origin may
+	// contain wildcards, such as "https://*.example.com". "*" allows all
+	// origins. Empty means all origins.
+	AllowedOrigins []string
+	// AllowedHeaders are request headers which can be used. Empty means
+	// headers asked by preflight requests.
+	AllowedHeaders []string
+	// ExposedHeaders are response headers which can be read by browsers.
+	ExposedHeaders []string
+	// AllowCredentials allows requests with credentials, such as cookies.
+	// It's ignored if all origins are allowed, otherwise any website could
+	// read responses with credentials of users.
+	AllowCredentials bool
+	// MaxAge is the duration that results of preflight requests can be cached.
+	// Zero means browser defaults.
+	MaxAge time.Duration
+}
+
+type policy struct {
+	all         bool
+	origins     []*regexp.Regexp
+	headers     string
+	exposed     string
+	credentials bool
+	maxAge      string
+}
+
+// Default returns a CORS middleware which allows all origins.
+func Default() definition.Middleware {
+	return New(nil)
+}
+
+// New returns a CORS middleware. A policy overrides the policies of outer
+// middlewares, so policies of descriptors override the global one.
+// Requests from disallowed origins are still handled, but browsers can't
+// read their responses.
+func New(options *Options) definition.Middleware {
+	p := newPolicy(options)
+	return func(ctx context.Context, chain definition.Chain) error {
+		httpCtx := service.HTTPContextFrom(ctx)
+		p.write(httpCtx.Request(), httpCtx.ResponseWriter().Header())
+		return chain.Continue(ctx)
+	}
+}
+
+// Filter returns a filter which writes CORS headers before requests are
+// routed. Responses of requests which can't be routed, such as 404, 405, 406
+// and 415 responses, are not handled by middlewares. The filter makes them
+// readable by browsers. Middlewares still override headers written by it.
+func Filter(options *Options) service.Filter {
+	p := newPolicy(options)
+	return func(resp http.ResponseWriter, req *http.Request) bool {
+		p.write(req, resp.Header())
+		return true
+	}
+}
+
+func newPolicy(options *Options) *policy {
+	if options == nil {
+		options = &Options{}
+	}
+	p := &policy{
+		all:     AllowsAllOrigins(options),
+		headers: strings.Join(options.AllowedHeaders, ", "),
+		exposed: strings.Join(options.ExposedHeaders, ", "),
+	}
+	p.credentials = options.AllowCredentials && !p.all
+	if !p.all {
+		for _, origin := range options.AllowedOrigins {
+			// Wildcards don't match slashes, so they only match parts of hosts.
+			exp := strings.Replace(regexp.QuoteMeta(strings.ToLower(origin)), `\*`, `[^/]*`, -1)
+			p.origins = append(p.origins, regexp.MustCompile("^"+exp+"$"))
+		}
+	}
+	if options.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(options.MaxAge / time.Second))
+	}
+	return p
+}
+
+// AllowsAllOrigins checks if options allow all origins.
+func AllowsAllOrigins(options *Options) bool {
+	if options == nil || len(options.AllowedOrigins) <= 0 {
+		return true
+	}
+	for _, origin := range options.AllowedOrigins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *policy) allowed(origin string) bool {
+	if p.all {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, r := range p.origins {
+		if r.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// write writes CORS headers of the request.
+func (p *policy) write(req *http.Request, header http.Header) {
+	for _, key := range responseHeaders {
+		header.Del(key)
+	}
+	origin := req.Header.Get(HeaderOrigin)
+	if !p.all {
+		addVary(header, HeaderOrigin)
+	}
+	if origin == "" || !p.allowed(origin) {
+		return
+	}
+	if p.all {
+		header.Set(HeaderAccessControlAllowOrigin, "*")
+	} else {
+		header.Set(HeaderAccessControlAllowOrigin, origin)
+	}
+	if p.credentials {
+		header.Set(HeaderAccessControlAllowCredentials, "true")
+	}
+	method := req.Header.Get(HeaderAccessControlRequestMethod)
+	if req.Method != http.MethodOptions || method == "" {
+		// Actual requests.
+		if p.exposed != "" {
+			header.Set(HeaderAccessControlExposeHeaders, p.exposed)
+		}
+		return
+	}
+	// Preflight requests.
+	if allow := header.Get("Allow"); allow != "" {
+		header.Set(HeaderAccessControlAllowMethods, allow)
+	} else {
+		header.Set(HeaderAccessControlAllowMethods, method)
+	}
+	if p.headers != "" {
+		header.Set(HeaderAccessControlAllowHeaders, p.headers)
+	} else if headers := req.Header.Get(HeaderAccessControlRequestHeaders); headers != "" {
+		header.Set(HeaderAccessControlAllowHeaders, headers)
+		addVary(header, HeaderAccessControlRequestHeaders)
+	}
+	if p.maxAge != "" {
+		header.Set(HeaderAccessControlMaxAge, p.maxAge)
+	}
+}
+
+// addVary adds a header to "Vary" if it doesn't exist.
+func addVary(header http.Header, key string) {
+	for _, value := range header["Vary"] {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), key) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", key)
+}
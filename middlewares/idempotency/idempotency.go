@@ -0,0 +1,319 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package idempotency
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/errors"
+	"github.com/caicloud/nirvana/service"
+)
+
+// HeaderReplayed is set to "true" in replayed responses.
+const HeaderReplayed = "Idempotent-Replayed"
+
+var (
+	keyReused       = errors.UnprocessableEntity.Build("Nirvana:Idempotency:KeyReused", "idempotency key ${key} has been used by a different request")
+	requestInFlight = errors.Conflict.Build("Nirvana:Idempotency:RequestInFlight", "request with idempotency key ${key} is still in progress")
+	unreadableBody  = errors.BadRequest.Build("Nirvana:Idempotency:UnreadableBody", "can't read request body: ${reason}")
+	bodyTooLarge    = errors.RequestEntityTooLarge.Build("Nirvana:Idempotency:BodyTooLarge", "request body with idempotency key can't be larger than ${size} bytes")
+)
+
+// DefaultMaxBodySize is the default max size of request bodies with
+// idempotency keys.
+const DefaultMaxBodySize = 1 << 20
+
+// Response is a stored response.
+type Response struct {
+	// StatusCode is the status code of the response.
+	StatusCode int
+	// Header is the header of the response. It contains meta of the response.
+	Header http.Header
+	// Body is the body of the response.
+	Body []byte
+}
+
+// Record is the record of an idempotency key.
+type Record struct {
+	// Fingerprint identifies the request which reserved the key.
+	Fingerprint string
+	// Response is the response of the request. It's nil if the
+	// request is still in flight.
+	Response *Response
+}
+
+// Store stores records of idempotency keys.
+type Store interface {
+	// Reserve reserves key for a request. If the key has been reserved,
+	// it returns the existing record and false.
+	Reserve(ctx context.Context, key string, fingerprint string) (*Record, bool, error)
+	// Save saves the response of a reserved key.
+	Save(ctx context.Context, key string, response *Response) error
+	// Release removes a reserved key whose response should not be saved.
+	Release(ctx context.Context, key string) error
+}
+
+type memoryEntry struct {
+	key     string
+	record  *Record
+	expires time.Time
+}
+
+// memoryStore is an in-memory LRU store.
+type memoryStore struct {
+	lock     sync.Mutex
+	capacity int
+	ttl      time.Duration
+	entries  map[string]*list.Element
+	lru      *list.List
+}
+
+// NewMemoryStore creates an in-memory store which keeps at most capacity
+// keys for ttl. The least recently used keys are removed when the store
+// is full. Zero values mean 10000 keys and 24 hours.
+func NewMemoryStore(capacity int, ttl time.Duration) Store {
+	if capacity <= 0 {
+		capacity = 10000
+	}
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &memoryStore{
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+	}
+}
+
+// Reserve reserves key for a request.
+func (s *memoryStore) Reserve(ctx context.Context, key string, fingerprint string) (*Record, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	if elem, ok := s.entries[key]; ok {
+		entry := elem.Value.(*memoryEntry)
+		if now.Before(entry.expires) {
+			s.lru.MoveToFront(elem)
+			record := *entry.record
+			return &record, false, nil
+		}
+		s.remove(elem)
+	}
+	for s.lru.Len() >= s.capacity {
+		s.remove(s.lru.Back())
+	}
+	entry := &memoryEntry{
+		key:     key,
+		record:  &Record{Fingerprint: fingerprint},
+		expires: now.Add(s.ttl),
+	}
+	s.entries[key] = s.lru.PushFront(entry)
+	return nil, true, nil
+}
+
+func (s *memoryStore) remove(elem *list.Element) {
+	s.lru.Remove(elem)
+	delete(s.entries, elem.Value.(*memoryEntry).key)
+}
+
+// Save saves the response of a reserved key.
+func (s *memoryStore) Save(ctx context.Context, key string, response *Response) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if elem, ok := s.entries[key]; ok {
+		entry := elem.Value.(*memoryEntry)
+		entry.record = &Record{Fingerprint: entry.record.Fingerprint, Response: response}
+	}
+	return nil
+}
+
+// Release removes a reserved key.
+func (s *memoryStore) Release(ctx context.Context, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+	return nil
+}
+
+// Options contains configurations of the idempotency middleware.
+type Options struct {
+	// Store stores records of idempotency keys. Defaults to an in-memory store.
+	Store Store
+	// Identity returns the identity of the caller. Keys from different callers
+	// never conflict. Defaults to the "Authorization" header, or the client IP
+	// resolved by prefab "clientIP" if there is no such header.
+	Identity func(ctx context.Context) string
+	// MaxBodySize is the max size of request bodies which are fingerprinted.
+	// Requests with idempotency keys and larger bodies are rejected with 413.
+	// Defaults to DefaultMaxBodySize.
+	MaxBodySize int64
+}
+
+// Default returns an idempotency middleware with an in-memory store.
+func Default() definition.Middleware {
+	return New(nil)
+}
+
+// New returns an idempotency middleware. For requests with unsafe methods and
+// header "Idempotency-Key", the first response is stored and replayed for the
+// retries with the same key. A retry with a different method, path or body is
+// rejected with 422, and a retry arriving before the first request finishes is
+// rejected with 409. Responses with 5xx status codes are not stored. Requests
+// with keys and bodies larger than the max body size are rejected with 413.
+func New(options *Options) definition.Middleware {
+	store := Store(nil)
+	identity := defaultIdentity
+	maxBodySize := int64(DefaultMaxBodySize)
+	if options != nil {
+		store = options.Store
+		if options.Identity != nil {
+			identity = options.Identity
+		}
+		if options.MaxBodySize > 0 {
+			maxBodySize = options.MaxBodySize
+		}
+	}
+	if store == nil {
+		store = NewMemoryStore(0, 0)
+	}
+	return func(ctx context.Context, chain definition.Chain) error {
+		httpCtx := service.HTTPContextFrom(ctx)
+		req := httpCtx.Request()
+		key := req.Header.Get(definition.HeaderIdempotencyKey)
+		if key == "" || isSafe(req.Method) {
+			return chain.Continue(ctx)
+		}
+		fingerprint, err := fingerprintOf(req, maxBodySize)
+		if err != nil {
+			return err
+		}
+		storeKey := identity(ctx) + "\n" + key
+		record, reserved, err := store.Reserve(ctx, storeKey, fingerprint)
+		if err != nil {
+			return err
+		}
+		resp := httpCtx.ResponseWriter()
+		if !reserved {
+			switch {
+			case record.Fingerprint != fingerprint:
+				return keyReused.Error(key)
+			case record.Response == nil:
+				resp.Header().Set("Retry-After", "1")
+				return requestInFlight.Error(key)
+			}
+			return replay(resp, record.Response)
+		}
+
+		saved := false
+		defer func() {
+			if !saved {
+				// Release the key if the request panics or fails before
+				// writing a response. The request can be retried.
+				_ = store.Release(context.Background(), storeKey)
+			}
+		}()
+		resp.SetWrapRespBodyPolicy(true)
+		err = chain.Continue(ctx)
+		if err != nil || resp.HeaderWritable() || resp.StatusCode() >= http.StatusInternalServerError {
+			return err
+		}
+		response := &Response{
+			StatusCode: resp.StatusCode(),
+			Header:     resp.Header().Clone(),
+			Body:       resp.ResponseBody(),
+		}
+		if err := store.Save(ctx, storeKey, response); err != nil {
+			service.LoggerFrom(ctx).Errorf("Can't save response for idempotency key %s: %v", key, err)
+			return nil
+		}
+		saved = true
+		return nil
+	}
+}
+
+// isSafe checks if a method is safe. Safe methods are not handled.
+func isSafe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// fingerprintOf hashes method, url and body of a request. Bodies larger
+// than maxBodySize are rejected. The body is restored for subsequent readers.
+func fingerprintOf(req *http.Request, maxBodySize int64) (string, error) {
+	hash := sha256.New()
+	_, _ = hash.Write([]byte(req.Method + "\n" + req.URL.RequestURI() + "\n"))
+	if req.Body != nil {
+		if req.ContentLength > maxBodySize {
+			return "", bodyTooLarge.Error(maxBodySize)
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxBodySize+1))
+		_ = req.Body.Close()
+		if err != nil {
+			return "", unreadableBody.Error(err.Error())
+		}
+		if int64(len(body)) > maxBodySize {
+			return "", bodyTooLarge.Error(maxBodySize)
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		_, _ = hash.Write(body)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func defaultIdentity(ctx context.Context) string {
+	req := service.HTTPContextFrom(ctx).Request()
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		sum := sha256.Sum256([]byte(auth))
+		return hex.EncodeToString(sum[:])
+	}
+	if prefab := service.PrefabFor("clientIP"); prefab != nil {
+		if ip, err := prefab.Make(ctx); err == nil {
+			if ip, ok := ip.(string); ok {
+				return ip
+			}
+		}
+	}
+	return req.RemoteAddr
+}
+
+// replay writes a stored response.
+func replay(resp service.ResponseWriter, response *Response) error {
+	header := resp.Header()
+	for k, values := range response.Header {
+		header[k] = append([]string(nil), values...)
+	}
+	header.Set(HeaderReplayed, "true")
+	resp.WriteHeader(response.StatusCode)
+	_, err := resp.Write(response.Body)
+	return err
+}
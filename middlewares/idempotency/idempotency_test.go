@@ -0,0 +1,222 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/rest"
+)
+
+func newTestServer(t *testing.T, options *Options, handler func(ctx context.Context, body string) (string, error)) *httptest.Server {
+	builder := rest.NewBuilder()
+	builder.SetModifier(service.FirstContextParameter())
+	err := builder.AddDescriptor(definition.Descriptor{
+		Path:        "/items",
+		Consumes:    []string{definition.MIMEText},
+		Produces:    []string{definition.MIMEText},
+		Middlewares: []definition.Middleware{New(options)},
+		Definitions: []definition.Definition{{
+			Method:     definition.Create,
+			Parameters: []definition.Parameter{definition.BodyParameterFor("")},
+			Results: []definition.Result{
+				definition.MetaResultFor(""),
+				definition.DataResultFor(""),
+				definition.ErrorResult(),
+			},
+			Function: func(ctx context.Context, body string) (map[string]string, string, error) {
+				data, err := handler(ctx, body)
+				return map[string]string{"X-Item": data}, data, err
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(s)
+}
+
+func post(t *testing.T, url string, key string, body string) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", definition.MIMEText)
+	if key != "" {
+		req.Header.Set(definition.HeaderIdempotencyKey, key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestReplay(t *testing.T) {
+	var count int32
+	server := newTestServer(t, nil, func(ctx context.Context, body string) (string, error) {
+		return fmt.Sprintf("%s-%d", body, atomic.AddInt32(&count, 1)), nil
+	})
+	defer server.Close()
+
+	first := post(t, server.URL+"/items", "key", "item")
+	firstBody := readBody(t, first)
+	retry := post(t, server.URL+"/items", "key", "item")
+	retryBody := readBody(t, retry)
+	if first.StatusCode != http.StatusCreated || retry.StatusCode != http.StatusCreated ||
+		firstBody != "item-1" || retryBody != firstBody {
+		t.Fatalf("Unexpected responses: %d %s, %d %s", first.StatusCode, firstBody, retry.StatusCode, retryBody)
+	}
+	if retry.Header.Get("X-Item") != "item-1" || retry.Header.Get(HeaderReplayed) != "true" {
+		t.Fatalf("Unexpected headers of replayed response: %v", retry.Header)
+	}
+
+	// Requests without key are not affected.
+	if body := readBody(t, post(t, server.URL+"/items", "", "item")); body != "item-2" {
+		t.Fatalf("Unexpected response: %s", body)
+	}
+
+	// The same key with a different body is rejected.
+	resp := post(t, server.URL+"/items", "key", "other")
+	if body := readBody(t, resp); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("Unexpected response: %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := newTestServer(t, nil, func(ctx context.Context, body string) (string, error) {
+		close(started)
+		<-release
+		return body, nil
+	})
+	defer server.Close()
+
+	done := make(chan *http.Response)
+	go func() {
+		done <- post(t, server.URL+"/items", "key", "item")
+	}()
+	<-started
+	resp := post(t, server.URL+"/items", "key", "item")
+	if body := readBody(t, resp); resp.StatusCode != http.StatusConflict || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("Unexpected response: %d %s", resp.StatusCode, body)
+	}
+	close(release)
+	resp = <-done
+	if body := readBody(t, resp); resp.StatusCode != http.StatusCreated || body != "item" {
+		t.Fatalf("Unexpected response: %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestServerErrorNotStored(t *testing.T) {
+	var count int32
+	server := newTestServer(t, nil, func(ctx context.Context, body string) (string, error) {
+		if atomic.AddInt32(&count, 1) == 1 {
+			return "", fmt.Errorf("internal error")
+		}
+		return body, nil
+	})
+	defer server.Close()
+
+	resp := post(t, server.URL+"/items", "key", "item")
+	if body := readBody(t, resp); resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Unexpected response: %d %s", resp.StatusCode, body)
+	}
+	resp = post(t, server.URL+"/items", "key", "item")
+	if body := readBody(t, resp); resp.StatusCode != http.StatusCreated || body != "item" {
+		t.Fatalf("Unexpected response: %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	server := newTestServer(t, &Options{MaxBodySize: 4}, func(ctx context.Context, body string) (string, error) {
+		return body, nil
+	})
+	defer server.Close()
+
+	resp := post(t, server.URL+"/items", "small", "item")
+	if body := readBody(t, resp); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Unexpected response: %d %s", resp.StatusCode, body)
+	}
+	resp = post(t, server.URL+"/items", "large", "large item")
+	if body := readBody(t, resp); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Unexpected response: %d %s", resp.StatusCode, body)
+	}
+	// Bodies without content length are limited too.
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/items", ioutil.NopCloser(strings.NewReader("large item")))
+	req.Header.Set("Content-Type", definition.MIMEText)
+	req.Header.Set(definition.HeaderIdempotencyKey, "chunked")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := readBody(t, resp); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Unexpected response: %d %s", resp.StatusCode, body)
+	}
+	// Requests without keys are not limited.
+	resp = post(t, server.URL+"/items", "", "large item")
+	if body := readBody(t, resp); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Unexpected response: %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(2, time.Hour)
+	for _, key := range []string{"a", "b"} {
+		if _, ok, err := store.Reserve(ctx, key, key); err != nil || !ok {
+			t.Fatalf("Can't reserve key %s: %v", key, err)
+		}
+	}
+	// "a" becomes the most recently used key.
+	if record, ok, _ := store.Reserve(ctx, "a", "a"); ok || record.Fingerprint != "a" {
+		t.Fatalf("Key a should have been reserved: %+v", record)
+	}
+	if _, ok, _ := store.Reserve(ctx, "c", "c"); !ok {
+		t.Fatal("Can't reserve key c")
+	}
+	if _, ok, _ := store.Reserve(ctx, "b", "b"); !ok {
+		t.Fatal("Key b should have been evicted")
+	}
+	if _, ok, _ := store.Reserve(ctx, "a", "a"); !ok {
+		t.Fatal("Key a should have been evicted")
+	}
+}
@@ -27,6 +27,21 @@ import (
 	"github.com/caicloud/nirvana/service"
 )
 
+// restfulPanics and rpcPanics count panics of requests handled by the
+// middlewares. They are added to requests, so that panics of other servers
+// and routes are not counted.
+var (
+	restfulPanics = service.CrashReporterFunc(func(ctx context.Context, crash *service.Crash) {
+		metrics.RecordRestfulPanic(crash.RoutePath, crash.Method)
+	})
+	rpcPanics = service.CrashReporterFunc(func(ctx context.Context, crash *service.Crash) {
+		if c := service.HTTPContextFrom(ctx); c != nil {
+			query := c.Request().URL.Query()
+			metrics.RecordRPCPanic(query.Get("Action"), query.Get("Version"))
+		}
+	})
+)
+
 // Default returns a metric Middleware under the namespace "nirvana" for restful descriptor.
 //
 // Once called, the namespace is set and can not be changed. Future attempt to build more Middleware
@@ -39,6 +54,7 @@ func Default() definition.Middleware {
 }
 
 // Restful returns a metrics Middleware for Restful Descriptors built from the given options.
+// Panics when serving requests are counted by routes.
 //
 // Once called, the namespace is set and can not be changed. Future attempt to build more Middleware
 // will result in ones with the same namespace as the first one.
@@ -49,6 +65,7 @@ func Restful(options *metrics.Options) definition.Middleware {
 	metrics.Install(options)
 	return func(ctx context.Context, next definition.Chain) error {
 		startTime := time.Now()
+		service.AddCrashReporter(ctx, restfulPanics)
 		err := next.Continue(ctx)
 		httpCtx := service.HTTPContextFrom(ctx)
 		resp := httpCtx.ResponseWriter()
@@ -61,6 +78,7 @@ func Restful(options *metrics.Options) definition.Middleware {
 }
 
 // RPC returns a metrics Middleware for RCP Descriptors built from the given options.
+// Panics when serving requests are counted by actions.
 //
 // Once called, the namespace is set and can not be changed. Future attempt to build more Middleware
 // will result in ones with the same namespace as the first one.
@@ -71,6 +89,7 @@ func RPC(options *metrics.Options) definition.Middleware {
 	metrics.Install(options)
 	return func(ctx context.Context, next definition.Chain) error {
 		startTime := time.Now()
+		service.AddCrashReporter(ctx, rpcPanics)
 		err := next.Continue(ctx)
 		httpCtx := service.HTTPContextFrom(ctx)
 		resp := httpCtx.ResponseWriter()
@@ -83,6 +102,35 @@ func RPC(options *metrics.Options) definition.Middleware {
 	}
 }
 
+// RestfulObserver returns an observer which records durations of phases of executing Restful
+// Descriptors, such as generating parameters and calling functions. Observers are added to servers
+// by nirvana.Observer.
+//
+// It records metrics under the namespace of the first Restful or RPC Middleware, so it must be used
+// with one of them.
+func RestfulObserver() service.Observer {
+	return service.ObserverFunc(func(ctx context.Context, o *service.Observation) {
+		if httpCtx := service.HTTPContextFrom(ctx); httpCtx != nil {
+			metrics.RecordRestfulPhase(httpCtx.RoutePath(), httpCtx.Request().Method, string(o.Phase), o.Duration)
+		}
+	})
+}
+
+// RPCObserver returns an observer which records durations of phases of executing RPC Descriptors,
+// such as generating parameters and calling functions. Observers are added to servers by
+// nirvana.Observer.
+//
+// It records metrics under the namespace of the first Restful or RPC Middleware, so it must be used
+// with one of them.
+func RPCObserver() service.Observer {
+	return service.ObserverFunc(func(ctx context.Context, o *service.Observation) {
+		if httpCtx := service.HTTPContextFrom(ctx); httpCtx != nil {
+			query := httpCtx.Request().URL.Query()
+			metrics.RecordRPCPhase(query.Get("Action"), query.Get("Version"), string(o.Phase), o.Duration)
+		}
+	})
+}
+
 // Descriptor returns a descriptor for the API; it must be configured to a server in order to serve the
 // metric API.
 func Descriptor(path string) definition.Descriptor {
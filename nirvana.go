@@ -70,6 +70,14 @@ type Config struct {
 	filters []service.Filter
 	// modifiers is definition modifiers
 	modifiers service.DefinitionModifiers
+	// reuseContexts is true if http contexts are reused.
+	reuseContexts bool
+	// observers observe phases of executing definitions.
+	observers []service.Observer
+	// reporters get crashes of all requests.
+	reporters []service.CrashReporter
+	// envelope wraps bodies of responses.
+	envelope service.Envelope
 	// configSet contains all configurations of plugins.
 	configSet map[string]interface{}
 	// locked is for locking current config. If the field
@@ -221,6 +229,26 @@ func (s *server) Builder() (builder service.Builder, cleaner func() error, err e
 	builder.SetLogger(s.config.logger)
 	builder.AddFilter(s.config.filters...)
 	builder.SetModifier(s.config.modifiers.Combine())
+	if reuser, ok := builder.(service.ContextReuser); ok {
+		reuser.SetContextReuse(s.config.reuseContexts)
+	} else if s.config.reuseContexts {
+		s.config.logger.Warningf("Builder %T can't reuse http contexts", builder)
+	}
+	if observable, ok := builder.(service.ObservableBuilder); ok {
+		observable.AddObserver(s.config.observers...)
+	} else if len(s.config.observers) > 0 {
+		s.config.logger.Warningf("Builder %T can't notify observers", builder)
+	}
+	if reporting, ok := builder.(service.CrashReportingBuilder); ok {
+		reporting.AddCrashReporter(s.config.reporters...)
+	} else if len(s.config.reporters) > 0 {
+		s.config.logger.Warningf("Builder %T can't report crashes", builder)
+	}
+	if enveloping, ok := builder.(service.EnvelopeBuilder); ok {
+		enveloping.SetEnvelope(s.config.envelope)
+	} else if s.config.envelope != nil {
+		s.config.logger.Warningf("Builder %T can't wrap responses by envelopes", builder)
+	}
 	if err := builder.AddDescriptor(s.config.descriptors...); err != nil {
 		return nil, nil, err
 	}
@@ -376,6 +404,16 @@ func Logger(logger log.Logger) Configurer {
 	}
 }
 
+// ReuseContexts returns a configurer to set whether http contexts are reused.
+// Reused contexts save allocations, but handlers must not use them or contexts
+// derived from them after requests are served.
+func ReuseContexts(reuse bool) Configurer {
+	return func(c *Config) error {
+		c.reuseContexts = reuse
+		return nil
+	}
+}
+
 // Descriptor returns a configurer to add descriptors into config.
 func Descriptor(descriptors ...interface{}) Configurer {
 	return func(c *Config) error {
@@ -392,6 +430,37 @@ func Filter(filters ...service.Filter) Configurer {
 	}
 }
 
+// Observer returns a configurer to add executor observers into config.
+// Observers are notified of phases of executing definitions, such as
+// generating parameters and calling functions.
+func Observer(observers ...service.Observer) Configurer {
+	return func(c *Config) error {
+		c.observers = append(c.observers, observers...)
+		return nil
+	}
+}
+
+// CrashReporter returns a configurer to add crash reporters into config.
+// Reporters get panics recovered when serving requests.
+func CrashReporter(reporters ...service.CrashReporter) Configurer {
+	return func(c *Config) error {
+		c.reporters = append(c.reporters, reporters...)
+		return nil
+	}
+}
+
+// Envelope returns a configurer to set the envelope of all responses.
+// Definitions can disable it by NoEnvelope. A nil envelope disables
+// envelopes. API packages should also annotate the function which returns
+// the envelope by +nirvana:api=envelope:"FunctionName", so that generators
+// can describe wrapped bodies.
+func Envelope(e service.Envelope) Configurer {
+	return func(c *Config) error {
+		c.envelope = e
+		return nil
+	}
+}
+
 // Modifier returns a configurer to add definition modifiers into config.
 func Modifier(modifiers ...service.DefinitionModifier) Configurer {
 	return func(c *Config) error {
@@ -0,0 +1,319 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/errors"
+	"github.com/caicloud/nirvana/service"
+)
+
+var (
+	unmatchedConversions = errors.InternalServerError.Build("Nirvana:Converter:UnmatchedConversions",
+		"conversions between ${versioned} and ${hub} are not paired")
+	noConversionPath = errors.InternalServerError.Build("Nirvana:Converter:NoConversionPath",
+		"no conversion path from ${from} to ${to}")
+	noVersionedType = errors.InternalServerError.Build("Nirvana:Converter:NoVersionedType",
+		"no type of version ${version} can be converted from and to ${type}")
+	invalidConversion = errors.InternalServerError.Build("Nirvana:Converter:InvalidConversion",
+		"${type} is not a conversion func(context.Context, string, AnyType) (AnyType, error)")
+)
+
+// versionedType is a type registered with its version.
+type versionedType struct {
+	version string
+	typ     reflect.Type
+}
+
+// Hub is a registry of conversions between versioned types and hub types.
+// A hub type is the internal type used by API functions. Conversions are
+// registered in pairs and chained automatically. For example, if v1 is
+// converted to v2 and v2 is converted to the internal type, a v1 object
+// can be converted to the internal type through v2.
+type Hub struct {
+	lock        sync.RWMutex
+	conversions map[reflect.Type]map[reflect.Type]Converter
+	versioned   []versionedType
+}
+
+// NewHub creates an empty conversion hub.
+func NewHub() *Hub {
+	return &Hub{
+		conversions: map[reflect.Type]map[reflect.Type]Converter{},
+	}
+}
+
+var defaultHub = NewHub()
+
+// DefaultHub returns the default conversion hub.
+func DefaultHub() *Hub {
+	return defaultHub
+}
+
+// Register registers conversions to the default hub.
+func Register(version string, toHub interface{}, fromHub interface{}) error {
+	return defaultHub.Register(version, toHub, fromHub)
+}
+
+// Register registers a pair of conversions between a type of version and
+// its hub type. toHub converts the versioned type to the hub type, and
+// fromHub converts it back. They must have signatures:
+//  func toHub(context.Context, string, VersionedType) (HubType, error)
+//  func fromHub(context.Context, string, HubType) (VersionedType, error)
+// The hub type can also be a versioned type of another version.
+func (h *Hub) Register(version string, toHub interface{}, fromHub interface{}) error {
+	for _, f := range []interface{}{toHub, fromHub} {
+		if !isConversion(reflect.TypeOf(f)) {
+			return invalidConversion.Error(reflect.TypeOf(f))
+		}
+	}
+	to, from := For(toHub), For(fromHub)
+	if to.In() != from.Out() || to.Out() != from.In() {
+		return unmatchedConversions.Error(to.In(), to.Out())
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.add(to)
+	h.add(from)
+	h.versioned = append(h.versioned, versionedType{definition.NormalizeVersion(version), to.In()})
+	return nil
+}
+
+// isConversion checks if typ is the type of a conversion func. For panics
+// if it's not.
+func isConversion(typ reflect.Type) bool {
+	return typ != nil && typ.Kind() == reflect.Func &&
+		typ.NumIn() == 3 && typ.In(0) == reflect.TypeOf((*context.Context)(nil)).Elem() && typ.In(1) == reflect.TypeOf("") &&
+		typ.NumOut() == 2 && typ.Out(1) == reflect.TypeOf((*error)(nil)).Elem()
+}
+
+func (h *Hub) add(c Converter) {
+	conversions, ok := h.conversions[c.In()]
+	if !ok {
+		conversions = map[reflect.Type]Converter{}
+		h.conversions[c.In()] = conversions
+	}
+	conversions[c.Out()] = c
+}
+
+// path finds the shortest conversion chain from one type to another.
+func (h *Hub) path(from, to reflect.Type) ([]Converter, bool) {
+	if from == to {
+		return nil, true
+	}
+	previous := map[reflect.Type]Converter{}
+	queue := []reflect.Type{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for next, c := range h.conversions[current] {
+			if _, ok := previous[next]; ok || next == from {
+				continue
+			}
+			previous[next] = c
+			if next == to {
+				chain := []Converter{}
+				for typ := to; typ != from; typ = previous[typ].In() {
+					chain = append([]Converter{previous[typ]}, chain...)
+				}
+				return chain, true
+			}
+			queue = append(queue, next)
+		}
+	}
+	return nil, false
+}
+
+// known checks if a type is registered in the hub.
+func (h *Hub) known(typ reflect.Type) bool {
+	if _, ok := h.conversions[typ]; ok {
+		return true
+	}
+	for _, conversions := range h.conversions {
+		if _, ok := conversions[typ]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Converter creates a converter from one type to another. The chain of
+// conversions is resolved when the converter is validated or used.
+func (h *Hub) Converter(from, to reflect.Type) Converter {
+	return &chain{hub: h, in: from, out: to}
+}
+
+// chain is a converter which chains conversions in a hub.
+type chain struct {
+	hub        *Hub
+	in         reflect.Type
+	out        reflect.Type
+	once       sync.Once
+	converters []Converter
+	err        error
+}
+
+// Kind indicates operator type.
+func (c *chain) Kind() string { return OperatorKind }
+
+// In returns the type of the object to convert.
+func (c *chain) In() reflect.Type { return c.in }
+
+// Out returns the type of the converted object.
+func (c *chain) Out() reflect.Type { return c.out }
+
+// Validate checks if there is a conversion path.
+func (c *chain) Validate() error {
+	c.once.Do(func() {
+		c.hub.lock.RLock()
+		defer c.hub.lock.RUnlock()
+		converters, ok := c.hub.path(c.in, c.out)
+		if !ok {
+			c.err = noConversionPath.Error(c.in, c.out)
+			return
+		}
+		c.converters = converters
+	})
+	return c.err
+}
+
+// Operate converts an object through the conversion chain.
+func (c *chain) Operate(ctx context.Context, field string, object interface{}) (interface{}, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	var err error
+	for _, converter := range c.converters {
+		object, err = converter.Operate(ctx, field, object)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return object, nil
+}
+
+// missing is a converter which reports that no versioned type is found.
+type missing struct {
+	typ     reflect.Type
+	version string
+}
+
+// Kind indicates operator type.
+func (m *missing) Kind() string { return OperatorKind }
+
+// In returns the hub type.
+func (m *missing) In() reflect.Type { return m.typ }
+
+// Out returns the hub type.
+func (m *missing) Out() reflect.Type { return m.typ }
+
+// Validate always returns an error.
+func (m *missing) Validate() error { return noVersionedType.Error(m.version, m.typ) }
+
+// Operate always returns an error.
+func (m *missing) Operate(ctx context.Context, field string, object interface{}) (interface{}, error) {
+	return nil, m.Validate()
+}
+
+// versionedFor finds the type of version which is connected to typ.
+func (h *Hub) versionedFor(version string, typ reflect.Type) (reflect.Type, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	if !h.known(typ) {
+		return nil, false
+	}
+	for _, v := range h.versioned {
+		if v.version != version {
+			continue
+		}
+		if v.typ == typ {
+			return typ, true
+		}
+		_, to := h.path(v.typ, typ)
+		_, from := h.path(typ, v.typ)
+		if to && from {
+			return v.typ, true
+		}
+	}
+	return nil, true
+}
+
+// Modifier returns a definition modifier which inserts converters into versioned
+// definitions. For a definition of version v, if the type of a parameter or
+// a result is registered in the hub, the parameter is converted from the type
+// of version v and the result is converted to the type of version v. If there
+// is no such type, building the service fails.
+//
+// The modifier should be added after the modifiers which add parameters
+// or results, such as service.FirstContextParameter().
+func (h *Hub) Modifier() service.DefinitionModifier {
+	return func(d *definition.Definition) {
+		version := definition.NormalizeVersion(d.Version)
+		if version == "" || d.Function == nil {
+			return
+		}
+		typ := reflect.TypeOf(d.Function)
+		if typ.Kind() != reflect.Func || typ.NumIn() != len(d.Parameters) || typ.NumOut() != len(d.Results) {
+			// Leave invalid definitions to the builder.
+			return
+		}
+		for i := range d.Parameters {
+			p := &d.Parameters[i]
+			target := typ.In(i)
+			if len(p.Operators) > 0 {
+				target = p.Operators[0].In()
+			}
+			if op := h.operatorFor(version, target, true); op != nil {
+				p.Operators = append([]definition.Operator{op}, p.Operators...)
+			}
+		}
+		for i := range d.Results {
+			r := &d.Results[i]
+			source := typ.Out(i)
+			if len(r.Operators) > 0 {
+				source = r.Operators[len(r.Operators)-1].Out()
+			}
+			if op := h.operatorFor(version, source, false); op != nil {
+				r.Operators = append(r.Operators, op)
+			}
+		}
+	}
+}
+
+// operatorFor creates a converter between typ and the type of version.
+func (h *Hub) operatorFor(version string, typ reflect.Type, in bool) definition.Operator {
+	versioned, known := h.versionedFor(version, typ)
+	switch {
+	case !known || versioned == typ:
+		return nil
+	case versioned == nil:
+		return &missing{typ, version}
+	case in:
+		return h.Converter(versioned, typ)
+	default:
+		return h.Converter(typ, versioned)
+	}
+}
+
+// Modifier returns a definition modifier of the default hub.
+func Modifier() service.DefinitionModifier {
+	return defaultHub.Modifier()
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/executor"
+	"github.com/caicloud/nirvana/service/rest"
+)
+
+type objectV1 struct{ Value string }
+type objectV2 struct{ Value int }
+type object struct{ Value int64 }
+
+func newTestHub(t *testing.T) *Hub {
+	hub := NewHub()
+	// v1 <-> v2 <-> internal
+	err := hub.Register("v1",
+		func(ctx context.Context, field string, o *objectV1) (*objectV2, error) {
+			v, err := strconv.Atoi(o.Value)
+			return &objectV2{v}, err
+		},
+		func(ctx context.Context, field string, o *objectV2) (*objectV1, error) {
+			return &objectV1{strconv.Itoa(o.Value)}, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = hub.Register("v2",
+		func(ctx context.Context, field string, o *objectV2) (*object, error) {
+			return &object{int64(o.Value)}, nil
+		},
+		func(ctx context.Context, field string, o *object) (*objectV2, error) {
+			return &objectV2{int(o.Value)}, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hub
+}
+
+func TestHubChain(t *testing.T) {
+	hub := newTestHub(t)
+	c := hub.Converter(reflect.TypeOf(&objectV1{}), reflect.TypeOf(&object{}))
+	result, err := c.Operate(context.Background(), "test", &objectV1{"42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := result.(*object); o.Value != 42 {
+		t.Fatalf("Unexpected result: %+v", o)
+	}
+	c = hub.Converter(reflect.TypeOf(&objectV1{}), reflect.TypeOf(""))
+	if err := c.(definition.ValidatableOperator).Validate(); !noConversionPath.Derived(err) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestInvalidConversions(t *testing.T) {
+	hub := NewHub()
+	valid := func(ctx context.Context, field string, o *object) (*objectV2, error) {
+		return &objectV2{int(o.Value)}, nil
+	}
+	for _, conversion := range []interface{}{
+		nil,
+		"not a function",
+		func(o *objectV2) (*object, error) { return nil, nil },
+		func(ctx context.Context, field string, o *objectV2) *object { return nil },
+		func(ctx context.Context, field string, o *objectV2) (*object, bool) { return nil, false },
+	} {
+		if err := hub.Register("v2", conversion, valid); !invalidConversion.Derived(err) {
+			t.Fatalf("Registering %T should fail, but got: %v", conversion, err)
+		}
+	}
+}
+
+func TestHubModifier(t *testing.T) {
+	hub := newTestHub(t)
+	f := func(ctx context.Context, o *object) (*object, error) {
+		return o, nil
+	}
+	d := definition.Definition{
+		Method:     definition.Create,
+		Version:    "v1",
+		Function:   f,
+		Parameters: []definition.Parameter{{Source: definition.Prefab, Name: "context"}, definition.BodyParameterFor("")},
+		Results:    definition.DataErrorResults(""),
+	}
+	hub.Modifier()(&d)
+	if ops := d.Parameters[1].Operators; len(ops) != 1 || ops[0].In() != reflect.TypeOf(&objectV1{}) {
+		t.Fatalf("Unexpected parameter operators: %+v", ops)
+	}
+	if ops := d.Results[0].Operators; len(ops) != 1 || ops[0].Out() != reflect.TypeOf(&objectV1{}) {
+		t.Fatalf("Unexpected result operators: %+v", ops)
+	}
+	if len(d.Parameters[0].Operators) != 0 || len(d.Results[1].Operators) != 0 {
+		t.Fatal("Unregistered types should not be converted")
+	}
+
+	d.Version = "v3"
+	d.Parameters[1].Operators = nil
+	d.Results[0].Operators = nil
+	builder := rest.NewBuilder()
+	builder.SetModifier(service.DefinitionModifiers{
+		service.ConsumeAllIfConsumesIsEmpty(),
+		service.ProduceAllIfProducesIsEmpty(),
+		hub.Modifier(),
+	}.Combine())
+	if err := builder.AddDescriptor(definition.Descriptor{
+		Path:        "/objects",
+		Definitions: []definition.Definition{d},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := builder.Build(); !executor.InvalidOperatorsForParameter.Derived(err) {
+		t.Fatalf("Build should report the missing version, but got: %v", err)
+	}
+}
@@ -0,0 +1,545 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fields provides partial responses by field selection. A selector
+// is a result operator which reads the field paths from query "fields" and
+// prunes the result before it reaches producers:
+//
+//  definition.Result{
+//      Destination: definition.Data,
+//      Operators:   []definition.Operator{fields.Operator()},
+//  }
+//
+//  GET /applications/demo?fields=name,spec.replicas,spec.containers.image
+package fields
+
+import (
+	"container/list"
+	"context"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/errors"
+	"github.com/caicloud/nirvana/service"
+)
+
+// OperatorKind means operator kind. All operators generated in this package
+// have kind `fields`.
+const OperatorKind = "fields"
+
+// DefaultQuery is the default query name of field selection.
+const DefaultQuery = "fields"
+
+var (
+	invalidSelection = errors.BadRequest.Build("Nirvana:Fields:InvalidSelection", "invalid field selection ${fields}")
+	unknownField     = errors.BadRequest.Build("Nirvana:Fields:UnknownField", "unknown field ${path}")
+)
+
+// Selector is an operator which prunes results by field selection.
+type Selector interface {
+	definition.Operator
+	// Query returns the query name of field selection.
+	Query() string
+}
+
+// Operator returns a selector which reads field selection from query "fields".
+func Operator() Selector {
+	return OperatorFor(DefaultQuery)
+}
+
+// OperatorFor returns a selector which reads field selection from a query.
+// If the query is declared in an Auto parameter too, handlers can get the
+// same selection, such as for database projections.
+func OperatorFor(query string) Selector {
+	return &selector{query: query}
+}
+
+type selector struct {
+	query string
+}
+
+var anyType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// Kind indicates operator type.
+func (s *selector) Kind() string {
+	return OperatorKind
+}
+
+// In returns the type of the only object parameter of operator.
+func (s *selector) In() reflect.Type {
+	return anyType
+}
+
+// Out returns the type of the only object result of operator.
+func (s *selector) Out() reflect.Type {
+	return anyType
+}
+
+// Query returns the query name of field selection.
+func (s *selector) Query() string {
+	return s.query
+}
+
+// Operate prunes object by field selection of current request. Meta of the
+// object is written to response headers as the pruned object loses methods.
+func (s *selector) Operate(ctx context.Context, field string, object interface{}) (interface{}, error) {
+	httpCtx := service.HTTPContextFrom(ctx)
+	if httpCtx == nil || object == nil {
+		return object, nil
+	}
+	values := httpCtx.Request().URL.Query()[s.query]
+	if len(values) <= 0 {
+		return object, nil
+	}
+	selection, err := Parse(strings.Join(values, ","))
+	if err != nil {
+		return nil, err
+	}
+	result, err := Select(object, selection)
+	if err != nil {
+		return nil, err
+	}
+	if provider, ok := object.(service.MetaProvider); ok && httpCtx.ResponseWriter().HeaderWritable() {
+		header := httpCtx.ResponseWriter().Header()
+		for key, value := range provider.Meta() {
+			if value != "" && header.Get(key) == "" {
+				header.Set(key, value)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Selection is a tree of selected fields. A field with nil selection is
+// selected entirely.
+type Selection map[string]Selection
+
+// Parse parses comma separated field paths. Names in a path are separated by
+// dots, and they are names in json tags or field names.
+func Parse(fields string) (Selection, error) {
+	selection := Selection{}
+	for _, path := range strings.Split(fields, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		names := strings.Split(path, ".")
+		current := selection
+		for i, name := range names {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return nil, invalidSelection.Error(fields)
+			}
+			child, ok := current[name]
+			if ok && child == nil {
+				// The field has been selected entirely.
+				break
+			}
+			if i == len(names)-1 {
+				current[name] = nil
+				break
+			}
+			if child == nil {
+				child = Selection{}
+				current[name] = child
+			}
+			current = child
+		}
+	}
+	if len(selection) <= 0 {
+		return nil, invalidSelection.Error(fields)
+	}
+	return selection, nil
+}
+
+// String returns the canonical form of the selection.
+func (s Selection) String() string {
+	names := make([]string, 0, len(s))
+	for name, child := range s {
+		if child == nil {
+			names = append(names, name)
+		} else {
+			names = append(names, name+"("+child.String()+")")
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// Select returns a copy of object which only contains selected fields.
+// Structs are replaced by structs with selected fields, and selections of
+// slices, arrays and maps apply to their elements. Tags of fields are kept,
+// so the copy can be written by any producer. Unknown fields result in
+// 400 errors.
+func Select(object interface{}, selection Selection) (interface{}, error) {
+	if object == nil || selection == nil {
+		return object, nil
+	}
+	value := reflect.ValueOf(object)
+	p, err := planFor(value.Type(), selection)
+	if err != nil {
+		return nil, err
+	}
+	result := reflect.New(p.typ).Elem()
+	if err := p.copy(result, value); err != nil {
+		return nil, err
+	}
+	return result.Interface(), nil
+}
+
+// plan describes how to copy a value to its pruned type.
+type plan struct {
+	// typ is the pruned type.
+	typ reflect.Type
+	// whole means the value is copied entirely.
+	whole bool
+	// elem is the plan of elements of pointers, slices, arrays and maps.
+	elem *plan
+	// fields are plans of selected struct fields.
+	fields []fieldPlan
+	// dynamic is the selection of interface values.
+	dynamic Selection
+}
+
+type fieldPlan struct {
+	// index is the index sequence of the field in the original struct.
+	index []int
+	plan  *plan
+}
+
+type planKey struct {
+	typ       reflect.Type
+	selection string
+}
+
+// maxPlans limits the number of cached plans. Selections come from clients,
+// so the least recently used plans are evicted if there are too many.
+const maxPlans = 4096
+
+// plans caches plans by types and selections.
+var plans = newPlanCache(maxPlans)
+
+type planEntry struct {
+	key  planKey
+	plan *plan
+}
+
+// planCache is an LRU cache of plans.
+type planCache struct {
+	lock     sync.Mutex
+	capacity int
+	entries  map[planKey]*list.Element
+	lru      *list.List
+}
+
+func newPlanCache(capacity int) *planCache {
+	return &planCache{
+		capacity: capacity,
+		entries:  map[planKey]*list.Element{},
+		lru:      list.New(),
+	}
+}
+
+// get returns the plan of key and marks it as recently used.
+func (c *planCache) get(key planKey) (*plan, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*planEntry).plan, true
+}
+
+// add adds the plan of key. The least recently used plans are evicted if
+// the cache is full.
+func (c *planCache) add(key planKey, p *plan) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		return
+	}
+	for c.lru.Len() >= c.capacity {
+		elem := c.lru.Back()
+		c.lru.Remove(elem)
+		delete(c.entries, elem.Value.(*planEntry).key)
+	}
+	c.entries[key] = c.lru.PushFront(&planEntry{key, p})
+}
+
+func planFor(typ reflect.Type, selection Selection) (*plan, error) {
+	key := planKey{typ, selection.String()}
+	if p, ok := plans.get(key); ok {
+		return p, nil
+	}
+	p, err := newPlan(typ, selection, "")
+	if err != nil {
+		return nil, err
+	}
+	plans.add(key, p)
+	return p, nil
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func newPlan(typ reflect.Type, selection Selection, path string) (*plan, error) {
+	if selection == nil {
+		return &plan{typ: typ, whole: true}, nil
+	}
+	switch typ.Kind() {
+	case reflect.Interface:
+		return &plan{typ: typ, dynamic: selection}, nil
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		if marshaler(typ) {
+			break
+		}
+		elem, err := newPlan(typ.Elem(), selection, path)
+		if err != nil {
+			return nil, err
+		}
+		p := &plan{elem: elem}
+		switch typ.Kind() {
+		case reflect.Ptr:
+			p.typ = reflect.PtrTo(elem.typ)
+		case reflect.Slice:
+			p.typ = reflect.SliceOf(elem.typ)
+		case reflect.Array:
+			p.typ = reflect.ArrayOf(typ.Len(), elem.typ)
+		case reflect.Map:
+			p.typ = reflect.MapOf(typ.Key(), elem.typ)
+		}
+		return p, nil
+	case reflect.Struct:
+		if !marshaler(typ) && !marshaler(reflect.PtrTo(typ)) {
+			return newStructPlan(typ, selection, path)
+		}
+	}
+	// Fields of other types can't be selected.
+	for name := range selection {
+		return nil, unknownField.Error(join(path, name))
+	}
+	return nil, nil
+}
+
+// marshaler checks if typ marshals itself. Fields of such types can't be selected.
+func marshaler(typ reflect.Type) bool {
+	return typ.Implements(jsonMarshalerType) || typ.Implements(textMarshalerType)
+}
+
+func newStructPlan(typ reflect.Type, selection Selection, path string) (*plan, error) {
+	all := map[string]reflect.StructField{}
+	collectFields(typ, nil, all, map[reflect.Type]bool{})
+	selected := make([]reflect.StructField, 0, len(selection))
+	for name := range selection {
+		field, ok := all[name]
+		if !ok {
+			return nil, unknownField.Error(join(path, name))
+		}
+		selected = append(selected, field)
+	}
+	if field, ok := typ.FieldByName("XMLName"); ok && len(field.Index) == 1 {
+		// Keep the name of xml elements.
+		selected = append(selected, field)
+	}
+	// Keep the order of fields.
+	sort.Slice(selected, func(i, j int) bool {
+		a, b := selected[i].Index, selected[j].Index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	p := &plan{}
+	fields := make([]reflect.StructField, 0, len(selected))
+	names := map[string]bool{}
+	for i, field := range selected {
+		child, err := newPlan(field.Type, selection[fieldName(field)], join(path, fieldName(field)))
+		if err != nil {
+			return nil, err
+		}
+		p.fields = append(p.fields, fieldPlan{field.Index, child})
+		name := field.Name
+		if names[name] {
+			// Promoted fields may have the same name. Their names in tags
+			// are different.
+			name += strconv.Itoa(i)
+		}
+		names[name] = true
+		fields = append(fields, reflect.StructField{
+			Name: name,
+			Type: child.typ,
+			Tag:  field.Tag,
+		})
+	}
+	p.typ = reflect.StructOf(fields)
+	return p, nil
+}
+
+// collectFields collects exported fields by their names in json. Fields of
+// embedded structs are promoted, and shallower fields take precedence.
+func collectFields(typ reflect.Type, index []int, fields map[string]reflect.StructField, visiting map[reflect.Type]bool) {
+	if visiting[typ] {
+		return
+	}
+	visiting[typ] = true
+	defer delete(visiting, typ)
+	embedded := []reflect.StructField{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		field.Index = append(append([]int(nil), index...), i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || field.Name == "XMLName" {
+			continue
+		}
+		if field.Anonymous && strings.Split(tag, ",")[0] == "" {
+			elem := field.Type
+			if elem.Kind() == reflect.Ptr {
+				elem = elem.Elem()
+			}
+			if elem.Kind() == reflect.Struct {
+				embedded = append(embedded, field)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			// Unexported.
+			continue
+		}
+		name := fieldName(field)
+		if _, ok := fields[name]; !ok {
+			fields[name] = field
+		}
+	}
+	for _, field := range embedded {
+		elem := field.Type
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		promoted := map[string]reflect.StructField{}
+		collectFields(elem, field.Index, promoted, visiting)
+		for name, f := range promoted {
+			if _, ok := fields[name]; !ok {
+				fields[name] = f
+			}
+		}
+	}
+}
+
+// fieldName returns the name of field in json tag. If there is no json tag,
+// field name is returned.
+func fieldName(field reflect.StructField) string {
+	name := strings.TrimSpace(strings.Split(field.Tag.Get("json"), ",")[0])
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// copy copies selected parts of src to dst. dst must be settable and its type
+// must be the pruned type.
+func (p *plan) copy(dst reflect.Value, src reflect.Value) error {
+	if p.whole {
+		dst.Set(src)
+		return nil
+	}
+	switch src.Kind() {
+	case reflect.Interface:
+		if src.IsNil() {
+			return nil
+		}
+		result, err := Select(src.Elem().Interface(), p.dynamic)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(result))
+	case reflect.Ptr:
+		if src.IsNil() {
+			return nil
+		}
+		dst.Set(reflect.New(p.elem.typ))
+		return p.elem.copy(dst.Elem(), src.Elem())
+	case reflect.Slice:
+		if src.IsNil() {
+			return nil
+		}
+		dst.Set(reflect.MakeSlice(p.typ, src.Len(), src.Len()))
+		fallthrough
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			if err := p.elem.copy(dst.Index(i), src.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return nil
+		}
+		dst.Set(reflect.MakeMapWithSize(p.typ, src.Len()))
+		for _, key := range src.MapKeys() {
+			elem := reflect.New(p.elem.typ).Elem()
+			if err := p.elem.copy(elem, src.MapIndex(key)); err != nil {
+				return err
+			}
+			dst.SetMapIndex(key, elem)
+		}
+	case reflect.Struct:
+		for i, field := range p.fields {
+			value, ok := fieldByIndex(src, field.index)
+			if !ok {
+				continue
+			}
+			if err := field.plan.copy(dst.Field(i), value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fieldByIndex returns the nested field of v. It returns false if an
+// embedded pointer is nil.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
@@ -0,0 +1,274 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fields
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/rest"
+)
+
+type ObjectMeta struct {
+	Name    string    `json:"name" xml:"name"`
+	Created time.Time `json:"created" xml:"created"`
+}
+
+type Container struct {
+	Image string `json:"image" xml:"image"`
+	Ports []int  `json:"ports" xml:"ports"`
+}
+
+type Spec struct {
+	Replicas   int                    `json:"replicas" xml:"replicas"`
+	Containers []Container            `json:"containers" xml:"containers"`
+	Labels     map[string]string      `json:"labels" xml:"-"`
+	Extra      map[string]interface{} `json:"extra" xml:"-"`
+}
+
+type Application struct {
+	XMLName xml.Name `json:"-" xml:"application"`
+	*ObjectMeta
+	Spec   *Spec  `json:"spec" xml:"spec"`
+	Status string `json:"status,omitempty" xml:"status"`
+	secret string
+}
+
+func (a *Application) Meta() map[string]string {
+	return map[string]string{"X-Name": a.Name}
+}
+
+func newApplication() *Application {
+	return &Application{
+		ObjectMeta: &ObjectMeta{Name: "demo", Created: time.Unix(0, 0).UTC()},
+		Spec: &Spec{
+			Replicas: 2,
+			Containers: []Container{
+				{Image: "nginx", Ports: []int{80}},
+				{Image: "redis", Ports: []int{6379}},
+			},
+			Labels: map[string]string{"app": "demo"},
+			Extra:  map[string]interface{}{"a": 1},
+		},
+		Status: "Running",
+		secret: "secret",
+	}
+}
+
+func marshal(t *testing.T, object interface{}) string {
+	data, err := json.Marshal(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		fields   string
+		expected string
+	}{
+		{"name", "name"},
+		{" name , spec.replicas,spec.containers.image ", "name,spec(containers(image),replicas)"},
+		{"spec.replicas,spec", "spec"},
+		{"spec,spec.replicas", "spec"},
+		{"a.b.c,a.b.d,a.e", "a(b(c,d),e)"},
+	}
+	for _, test := range tests {
+		selection, err := Parse(test.fields)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", test.fields, err)
+		}
+		if got := selection.String(); got != test.expected {
+			t.Fatalf("Unexpected selection for %q: %s", test.fields, got)
+		}
+	}
+	for _, fields := range []string{"", ",", "a..b", "a.", ".a"} {
+		if _, err := Parse(fields); !invalidSelection.Derived(err) {
+			t.Fatalf("Selection %q should be invalid: %v", fields, err)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		fields   string
+		expected string
+	}{
+		{"name", `{"name":"demo"}`},
+		{"status,name", `{"name":"demo","status":"Running"}`},
+		{"created", `{"created":"1970-01-01T00:00:00Z"}`},
+		{"spec.replicas,spec.containers.image",
+			`{"spec":{"replicas":2,"containers":[{"image":"nginx"},{"image":"redis"}]}}`},
+		{"spec.containers", `{"spec":{"containers":[{"image":"nginx","ports":[80]},{"image":"redis","ports":[6379]}]}}`},
+		{"spec.labels", `{"spec":{"labels":{"app":"demo"}}}`},
+	}
+	for _, test := range tests {
+		selection, err := Parse(test.fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Select twice to use cached plans.
+		for i := 0; i < 2; i++ {
+			result, err := Select(newApplication(), selection)
+			if err != nil {
+				t.Fatalf("Unexpected error for %q: %v", test.fields, err)
+			}
+			if got := marshal(t, result); got != test.expected {
+				t.Fatalf("Unexpected result for %q: %s", test.fields, got)
+			}
+		}
+	}
+
+	// Slices and maps of structs.
+	selection, _ := Parse("name")
+	result, err := Select([]*Application{newApplication(), nil}, selection)
+	if err != nil || marshal(t, result) != `[{"name":"demo"},null]` {
+		t.Fatalf("Unexpected result: %v %v", result, err)
+	}
+	result, err = Select(map[string]Application{"a": *newApplication()}, selection)
+	if err != nil || marshal(t, result) != `{"a":{"name":"demo"}}` {
+		t.Fatalf("Unexpected result: %v %v", result, err)
+	}
+	// Nil embedded pointers.
+	result, err = Select(&Application{Status: "Pending"}, selection)
+	if err != nil || marshal(t, result) != `{"name":""}` {
+		t.Fatalf("Unexpected result: %v %v", result, err)
+	}
+
+	for _, fields := range []string{"secret", "unknown", "spec.unknown", "spec.replicas.value",
+		"created.unix", "spec.labels.app.key", "spec.containers.ports.number"} {
+		selection, _ := Parse(fields)
+		_, err := Select(newApplication(), selection)
+		if !unknownField.Derived(err) {
+			t.Fatalf("Selection %q should be rejected: %v", fields, err)
+		}
+	}
+}
+
+func TestPlanCache(t *testing.T) {
+	cache := newPlanCache(2)
+	typ := reflect.TypeOf(Application{})
+	keys := []planKey{{typ, "name"}, {typ, "status"}, {typ, "spec"}}
+	cache.add(keys[0], &plan{typ: typ})
+	cache.add(keys[1], &plan{typ: typ})
+	// Use the first plan, so that the second one is evicted.
+	if _, ok := cache.get(keys[0]); !ok {
+		t.Fatal("Plan of name should be cached")
+	}
+	cache.add(keys[2], &plan{typ: typ})
+	for i, cached := range []bool{true, false, true} {
+		if _, ok := cache.get(keys[i]); ok != cached {
+			t.Fatalf("Plan of %s should be cached: %v, but got %v", keys[i].selection, cached, ok)
+		}
+	}
+}
+
+func TestSelectXML(t *testing.T) {
+	selection, _ := Parse("name,spec.replicas")
+	result, err := Select(newApplication(), selection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := xml.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<application><name>demo</name><spec><replicas>2</replicas></spec></application>`
+	if string(data) != expected {
+		t.Fatalf("Unexpected xml: %s", data)
+	}
+}
+
+func TestOperator(t *testing.T) {
+	builder := rest.NewBuilder()
+	builder.SetModifier(service.FirstContextParameter())
+	err := builder.AddDescriptor(definition.Descriptor{
+		Path:     "/applications/{name}",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON, definition.MIMEXML},
+		Definitions: []definition.Definition{{
+			Method:     definition.Get,
+			Parameters: []definition.Parameter{definition.PathParameterFor("name", "")},
+			Results: []definition.Result{
+				{Destination: definition.Data, Operators: []definition.Operator{Operator()}},
+				definition.ErrorResult(),
+			},
+			Function: func(ctx context.Context, name string) (*Application, error) {
+				return newApplication(), nil
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	tests := []struct {
+		query    string
+		accept   string
+		code     int
+		expected string
+	}{
+		{"fields=name&fields=spec.replicas", definition.MIMEJSON, http.StatusOK,
+			`{"name":"demo","spec":{"replicas":2}}`},
+		{"fields=status", definition.MIMEXML, http.StatusOK,
+			`<application><status>Running</status></application>`},
+		{"fields=spec.unknown", definition.MIMEJSON, http.StatusBadRequest, ""},
+		{"fields=", definition.MIMEJSON, http.StatusBadRequest, ""},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/applications/demo?"+test.query, nil)
+		req.Header.Set("Accept", test.accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != test.code {
+			t.Fatalf("Unexpected status code for %q: %d %s", test.query, resp.StatusCode, data)
+		}
+		if test.code != http.StatusOK {
+			continue
+		}
+		if strings.TrimSpace(string(data)) != test.expected {
+			t.Fatalf("Unexpected body for %q: %s", test.query, data)
+		}
+		if resp.Header.Get("X-Name") != "demo" {
+			t.Fatalf("Meta of results should be kept: %v", resp.Header)
+		}
+	}
+}
@@ -148,13 +148,18 @@ func Struct(instance interface{}) Validator {
 		in:  reflect.TypeOf(instance),
 		out: reflect.TypeOf(instance),
 		f: func(ctx context.Context, field string, object interface{}) (interface{}, error) {
-			err := std.StructCtx(ctx, object)
-			return object, decorateStructErr(err)
+			return object, ValidateStruct(ctx, object)
 		},
 		category: CategoryStruct,
 	}
 }
 
+// ValidateStruct validates the exposed fields of a struct by their "validate" tags.
+// It's used by Struct() and the Auto parameter generator.
+func ValidateStruct(ctx context.Context, object interface{}) error {
+	return decorateStructErr(std.StructCtx(ctx, object))
+}
+
 // String creates validator for string type.
 func String(tag string) Validator {
 	return varFor(tag, "")
@@ -235,6 +240,18 @@ func varFor(tag string, instance interface{}) Validator {
 	}
 }
 
+// invalidStructField describes an invalid field of a struct.
+var invalidStructField = errors.BadRequest.Build("Nirvana:Validator:InvalidStructField", "value '${value}' on struct field '${field}' cannot pass validator tag '${tag}'")
+
+// fieldPath removes the struct name from the namespace of a field.
+// For instance, "Example.Filters[0].Name" is converted to "Filters[0].Name".
+func fieldPath(namespace string) string {
+	if pos := strings.Index(namespace, "."); pos >= 0 {
+		return namespace[pos+1:]
+	}
+	return namespace
+}
+
 func decorateErr(err error, field string, object interface{}, tag string) error {
 	if err == nil {
 		return nil
@@ -248,10 +265,13 @@ func decorateStructErr(err error) error {
 	}
 	if err, ok := err.(val.ValidationErrors); ok {
 		es := make([]string, 0, len(err))
+		fields := make([]errors.FieldError, 0, len(err))
 		for _, fe := range err {
-			es = append(es, fmt.Sprintf("value '%s' on struct field '%s' cannot pass validator tag '%s'", fe.Value(), fe.Field(), fe.Tag()))
+			e := invalidStructField.Error(fe.Value(), fe.Field(), fe.Tag())
+			es = append(es, e.Error())
+			fields = append(fields, errors.FieldErrorOf(fieldPath(fe.Namespace()), "", e))
 		}
-		return errors.BadRequest.Error("${err}", strings.Join(es, "; "))
+		return errors.WithFields(errors.BadRequest.Error("${err}", strings.Join(es, "; ")), fields...)
 	}
 
 	return errors.BadRequest.Error("${err}", err)
@@ -0,0 +1,240 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package pagination provides pagination options and page results for
+// list definitions. Offset and cursor pagination are both supported:
+//
+//  type ApplicationList struct {
+//      pagination.Page
+//      Items []Application `json:"items"`
+//  }
+//
+//  definition.Definition{
+//      Method:     definition.List,
+//      Parameters: []definition.Parameter{pagination.ParameterFor(20, 100)},
+//      Results:    definition.DataErrorResults("applications"),
+//      Function: func(ctx context.Context, options *pagination.Options) (*ApplicationList, error) {
+//          items, total := list(options.Offset, options.Limit)
+//          return &ApplicationList{
+//              Page:  pagination.OffsetPage(ctx, options, len(items), total),
+//              Items: items,
+//          }, nil
+//      },
+//  }
+package pagination
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/errors"
+	"github.com/caicloud/nirvana/service"
+)
+
+const (
+	// HeaderLink is the response header which contains links of pages (RFC 5988).
+	HeaderLink = "Link"
+	// HeaderTotalCount is the response header which contains the total
+	// number of items.
+	HeaderTotalCount = "X-Total-Count"
+)
+
+const (
+	// DefaultLimit is the default limit of ParameterFor.
+	DefaultLimit = 20
+	// DefaultMaxLimit is the default max limit of ParameterFor.
+	DefaultMaxLimit = 100
+)
+
+// OperatorKind is the kind of the operator which normalizes options.
+const OperatorKind = "pagination"
+
+var (
+	invalidOffset   = errors.BadRequest.Build("Nirvana:Pagination:InvalidOffset", "offset ${offset} should not be negative")
+	invalidLimit    = errors.BadRequest.Build("Nirvana:Pagination:InvalidLimit", "limit ${limit} should not be negative")
+	offsetAndCursor = errors.BadRequest.Build("Nirvana:Pagination:OffsetAndCursor", "offset and cursor can't be used together")
+)
+
+// Options is the pagination options of a list request. It reads query
+// parameters "offset", "limit" and "cursor".
+type Options struct {
+	// Offset is the number of items to skip. It can't be used with cursor.
+	Offset int `source:"Query,offset,optional" json:"offset,omitempty"`
+	// Limit is the max number of items in a page.
+	Limit int `source:"Query,limit,optional" json:"limit,omitempty"`
+	// Cursor is the opaque position of a page. It is returned by the previous page.
+	Cursor string `source:"Query,cursor,optional" json:"cursor,omitempty"`
+}
+
+// Parameter returns an auto parameter of *Options with DefaultLimit and DefaultMaxLimit.
+func Parameter() definition.Parameter {
+	return ParameterFor(DefaultLimit, DefaultMaxLimit)
+}
+
+// ParameterFor returns an auto parameter of *Options. The limit defaults to
+// defaultLimit if it's not specified, and is reduced to maxLimit if it's
+// larger than maxLimit. Negative offsets and limits are rejected.
+func ParameterFor(defaultLimit int, maxLimit int) definition.Parameter {
+	if maxLimit <= 0 {
+		maxLimit = DefaultMaxLimit
+	}
+	if defaultLimit <= 0 || defaultLimit > maxLimit {
+		defaultLimit = maxLimit
+	}
+	typ := reflect.TypeOf(&Options{})
+	return definition.Parameter{
+		Source:      definition.Auto,
+		Description: fmt.Sprintf("pagination options, limit defaults to %d and is at most %d", defaultLimit, maxLimit),
+		Operators: []definition.Operator{definition.NewOperator(OperatorKind, typ, typ,
+			func(ctx context.Context, field string, object interface{}) (interface{}, error) {
+				options := object.(*Options)
+				if err := options.normalize(defaultLimit, maxLimit); err != nil {
+					return nil, err
+				}
+				return options, nil
+			})},
+	}
+}
+
+func (o *Options) normalize(defaultLimit int, maxLimit int) error {
+	switch {
+	case o.Offset < 0:
+		return invalidOffset.Error(o.Offset)
+	case o.Limit < 0:
+		return invalidLimit.Error(o.Limit)
+	case o.Offset > 0 && o.Cursor != "":
+		return offsetAndCursor.Error()
+	case o.Limit == 0:
+		o.Limit = defaultLimit
+	case o.Limit > maxLimit:
+		o.Limit = maxLimit
+	}
+	return nil
+}
+
+// Page contains pagination info of a list result. Embed it into list types.
+// A page writes header "Link" with links to the first, previous, next and
+// last pages, and header "X-Total-Count" if the total count is known.
+type Page struct {
+	// Total is the total number of items. It's nil if the total is unknown.
+	Total *int `json:"total,omitempty"`
+	// Next is the options to get the next page. It's nil on the last page.
+	Next *Options `json:"next,omitempty"`
+	// links are formatted links of the page.
+	links []string
+}
+
+// OffsetPage creates a page for offset pagination. count is the number of
+// items in the page and total is the number of all items. A negative total
+// means the total is unknown, and there is a next page if the page is full.
+func OffsetPage(ctx context.Context, options *Options, count int, total int) Page {
+	page := Page{}
+	limit := options.Limit
+	if total >= 0 {
+		page.Total = &total
+	}
+	if (total >= 0 && options.Offset+count < total) || (total < 0 && limit > 0 && count >= limit) {
+		page.Next = &Options{Offset: options.Offset + count, Limit: limit}
+	}
+	link := linker(ctx)
+	page.links = append(page.links, link("first", &Options{Limit: limit}))
+	if options.Offset > 0 {
+		prev := options.Offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		page.links = append(page.links, link("prev", &Options{Offset: prev, Limit: limit}))
+	}
+	if page.Next != nil {
+		page.links = append(page.links, link("next", page.Next))
+	}
+	if total > 0 && limit > 0 {
+		page.links = append(page.links, link("last", &Options{Offset: (total - 1) / limit * limit, Limit: limit}))
+	}
+	return page
+}
+
+// CursorPage creates a page for cursor pagination. next is the cursor of the
+// next page, and it's empty on the last page. A negative total means the
+// total is unknown.
+func CursorPage(ctx context.Context, options *Options, next string, total int) Page {
+	page := Page{}
+	if total >= 0 {
+		page.Total = &total
+	}
+	if next != "" {
+		page.Next = &Options{Limit: options.Limit, Cursor: next}
+	}
+	link := linker(ctx)
+	page.links = append(page.links, link("first", &Options{Limit: options.Limit}))
+	if page.Next != nil {
+		page.links = append(page.links, link("next", page.Next))
+	}
+	return page
+}
+
+// linker returns a function to format links based on the request URL.
+func linker(ctx context.Context) func(rel string, options *Options) string {
+	var base *url.URL
+	if httpCtx := service.HTTPContextFrom(ctx); httpCtx != nil {
+		base = httpCtx.Request().URL
+	}
+	return func(rel string, options *Options) string {
+		if base == nil {
+			return ""
+		}
+		u := *base
+		query := u.Query()
+		query.Del("offset")
+		query.Del("cursor")
+		query.Del("limit")
+		if options.Offset > 0 {
+			query.Set("offset", strconv.Itoa(options.Offset))
+		}
+		if options.Limit > 0 {
+			query.Set("limit", strconv.Itoa(options.Limit))
+		}
+		if options.Cursor != "" {
+			query.Set("cursor", options.Cursor)
+		}
+		u.RawQuery = query.Encode()
+		return fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel)
+	}
+}
+
+// Meta returns headers "Link" and "X-Total-Count" of the page.
+func (p Page) Meta() map[string]string {
+	links := make([]string, 0, len(p.links))
+	for _, link := range p.links {
+		if link != "" {
+			links = append(links, link)
+		}
+	}
+	meta := map[string]string{
+		HeaderLink: strings.Join(links, ", "),
+	}
+	if p.Total != nil {
+		meta[HeaderTotalCount] = strconv.Itoa(*p.Total)
+	}
+	return meta
+}
+
+var _ service.MetaProvider = Page{}
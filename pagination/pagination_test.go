@@ -0,0 +1,153 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pagination
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/rest"
+)
+
+type itemList struct {
+	Page
+	Items []int `json:"items"`
+}
+
+func newTestServer(t *testing.T, total int) *httptest.Server {
+	items := make([]int, total)
+	for i := range items {
+		items[i] = i
+	}
+	builder := rest.NewBuilder()
+	builder.SetModifier(service.FirstContextParameter())
+	err := builder.AddDescriptor(definition.Descriptor{
+		Path:     "/items",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Definitions: []definition.Definition{
+			{
+				Method:     definition.List,
+				Parameters: []definition.Parameter{ParameterFor(2, 3)},
+				Results:    definition.DataErrorResults(""),
+				Function: func(ctx context.Context, options *Options) (*itemList, error) {
+					start := options.Offset
+					if options.Cursor != "" {
+						start, _ = strconv.Atoi(options.Cursor)
+					}
+					end := start + options.Limit
+					if start > total {
+						start = total
+					}
+					if end > total {
+						end = total
+					}
+					list := &itemList{Items: items[start:end]}
+					if options.Cursor == "" {
+						list.Page = OffsetPage(ctx, options, len(list.Items), total)
+					} else {
+						next := ""
+						if end < total {
+							next = strconv.Itoa(end)
+						}
+						list.Page = CursorPage(ctx, options, next, -1)
+					}
+					return list, nil
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(s)
+}
+
+func get(t *testing.T, url string) (*http.Response, *itemList) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	list := &itemList{}
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(list); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp, list
+}
+
+func TestOffsetPagination(t *testing.T) {
+	server := newTestServer(t, 7)
+	defer server.Close()
+
+	resp, list := get(t, server.URL+"/items?offset=2&limit=10&sort=name")
+	link := `</items?limit=3&sort=name>; rel="first", </items?limit=3&sort=name>; rel="prev", ` +
+		`</items?limit=3&offset=5&sort=name>; rel="next", </items?limit=3&offset=6&sort=name>; rel="last"`
+	if got := resp.Header.Get(HeaderLink); got != link {
+		t.Fatalf("Unexpected links: %s", got)
+	}
+	if resp.Header.Get(HeaderTotalCount) != "7" || len(list.Items) != 3 || *list.Total != 7 ||
+		list.Next.Offset != 5 || list.Next.Limit != 3 {
+		t.Fatalf("Unexpected page: %v %+v", resp.Header, list)
+	}
+
+	// Limit defaults to 2. The last page has no next page.
+	_, list = get(t, server.URL+"/items?offset=6")
+	if len(list.Items) != 1 || list.Next != nil {
+		t.Fatalf("Unexpected page: %+v", list)
+	}
+
+	for _, query := range []string{"offset=-1", "limit=-1", "offset=1&cursor=2"} {
+		if resp, _ := get(t, server.URL+"/items?"+query); resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Query %s should be rejected, but got %d", query, resp.StatusCode)
+		}
+	}
+}
+
+func TestCursorPagination(t *testing.T) {
+	server := newTestServer(t, 5)
+	defer server.Close()
+
+	items := []int{}
+	url := server.URL + "/items?cursor=0"
+	for {
+		resp, list := get(t, url)
+		items = append(items, list.Items...)
+		if list.Total != nil || resp.Header.Get(HeaderTotalCount) != "" {
+			t.Fatalf("Total should be unknown: %+v", list)
+		}
+		if list.Next == nil {
+			break
+		}
+		url = server.URL + "/items?cursor=" + list.Next.Cursor
+	}
+	if len(items) != 5 || items[4] != 4 {
+		t.Fatalf("Unexpected items: %v", items)
+	}
+}
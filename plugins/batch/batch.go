@@ -0,0 +1,321 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/errors"
+	"github.com/caicloud/nirvana/service"
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+var (
+	noService         = errors.InternalServerError.Build("Nirvana:Batch:NoService", "no service to handle sub-requests")
+	nestedBatch       = errors.BadRequest.Build("Nirvana:Batch:NestedBatch", "batch requests can't be nested")
+	tooManyRequests   = errors.RequestEntityTooLarge.Build("Nirvana:Batch:TooManyRequests", "a batch can contain at most ${max} requests, but got ${count}")
+	invalidRequest    = errors.BadRequest.Build("Nirvana:Batch:InvalidRequest", "invalid request ${index}: ${reason}")
+	duplicatedID      = errors.BadRequest.Build("Nirvana:Batch:DuplicatedID", "request id ${id} is duplicated")
+	unknownDependency = errors.BadRequest.Build("Nirvana:Batch:UnknownDependency", "request ${id} depends on ${dependency} which is not declared before it")
+	dependencyFailed  = errors.FailedDependency.Build("Nirvana:Batch:DependencyFailed", "request ${id} is skipped because request ${dependency} failed")
+)
+
+// Request is a sub-request in a batch.
+type Request struct {
+	// ID identifies the request in the batch. It's required if other
+	// requests depend on it.
+	ID string `json:"id,omitempty"`
+	// Method is the HTTP method. Defaults to GET for REST services
+	// and POST for RPC services.
+	Method string `json:"method,omitempty"`
+	// Path is the path of the request. It can contain a query string.
+	Path string `json:"path"`
+	// Action and Version are added to the query string of the path.
+	// They are used to call actions of RPC services.
+	Action  string `json:"action,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Header is the header of the request.
+	Header http.Header `json:"headers,omitempty"`
+	// Body is the body of the request. If the content type is not JSON,
+	// the body should be a JSON string.
+	Body json.RawMessage `json:"body,omitempty"`
+	// DependsOn contains ids of the requests which must succeed before
+	// this request. A request can only depend on the requests before it.
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// Batch contains several sub-requests.
+type Batch struct {
+	// Parallel makes independent requests run concurrently. Otherwise
+	// requests run one by one in order.
+	Parallel bool `json:"parallel,omitempty"`
+	// Requests are sub-requests of the batch.
+	Requests []Request `json:"requests"`
+}
+
+// Response is the response of a sub-request.
+type Response struct {
+	// ID is the id of the request.
+	ID string `json:"id,omitempty"`
+	// Status is the status code of the response.
+	Status int `json:"status"`
+	// Header is the header of the response.
+	Header http.Header `json:"headers,omitempty"`
+	// Body is the body of the response. If the content type is not JSON,
+	// the body is a JSON string.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// contextKeyBatch is a key for context. It marks requests in a batch.
+var contextKeyBatch interface{} = new(byte)
+
+// executor executes batches.
+type executor struct {
+	rpc         bool
+	maxRequests int
+	inherited   []string
+}
+
+// execute runs all requests of a batch via the service which handles the batch.
+func (e *executor) execute(ctx context.Context, batch *Batch) ([]Response, error) {
+	if ctx.Value(contextKeyBatch) != nil {
+		return nil, nestedBatch.Error()
+	}
+	s := service.ServiceFrom(ctx)
+	if s == nil {
+		return nil, noService.Error()
+	}
+	if batch == nil {
+		batch = &Batch{}
+	}
+	if len(batch.Requests) > e.maxRequests {
+		return nil, tooManyRequests.Error(e.maxRequests, len(batch.Requests))
+	}
+	requests := make([]*http.Request, len(batch.Requests))
+	dependencies := make([][]int, len(batch.Requests))
+	indexes := map[string]int{}
+	parent := service.HTTPContextFrom(ctx).Request()
+	// Sub-requests only share the cancellation of the batch request. Values
+	// of the batch request (such as the request id and logger) are not
+	// inherited, so that sub-requests get their own ones.
+	base := context.WithValue(detachedContext{ctx}, contextKeyBatch, true)
+	for i, r := range batch.Requests {
+		req, err := e.newRequest(ctx, base, parent, &r)
+		if err != nil {
+			return nil, invalidRequest.Error(i, err.Error())
+		}
+		requests[i] = req
+		for _, dep := range r.DependsOn {
+			index, ok := indexes[dep]
+			if !ok {
+				return nil, unknownDependency.Error(r.ID, dep)
+			}
+			dependencies[i] = append(dependencies[i], index)
+		}
+		if r.ID != "" {
+			if _, ok := indexes[r.ID]; ok {
+				return nil, duplicatedID.Error(r.ID)
+			}
+			indexes[r.ID] = i
+		}
+	}
+
+	responses := make([]Response, len(requests))
+	done := make([]chan struct{}, len(requests))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	run := func(i int) {
+		defer close(done[i])
+		responses[i].ID = batch.Requests[i].ID
+		for _, dep := range dependencies[i] {
+			<-done[dep]
+			if responses[dep].Status >= http.StatusBadRequest {
+				err := dependencyFailed.Error(responses[i].ID, responses[dep].ID)
+				responses[i].Status = http.StatusFailedDependency
+				responses[i].Header = http.Header{"Content-Type": []string{definition.MIMEJSON}}
+				responses[i].Body, _ = json.Marshal(err.(service.Error).Message())
+				return
+			}
+		}
+		serve(s, requests[i], &responses[i])
+	}
+	if !batch.Parallel {
+		for i := range requests {
+			run(i)
+		}
+		return responses, nil
+	}
+	wg := sync.WaitGroup{}
+	for i := range requests {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			run(i)
+		}(i)
+	}
+	wg.Wait()
+	return responses, nil
+}
+
+// detachedContext is cancelled with its parent but carries no values of it.
+type detachedContext struct {
+	parent context.Context
+}
+
+// Deadline returns the deadline of the parent.
+func (c detachedContext) Deadline() (time.Time, bool) { return c.parent.Deadline() }
+
+// Done returns the done channel of the parent.
+func (c detachedContext) Done() <-chan struct{} { return c.parent.Done() }
+
+// Err returns the error of the parent.
+func (c detachedContext) Err() error { return c.parent.Err() }
+
+// Value returns nil for all keys.
+func (c detachedContext) Value(key interface{}) interface{} { return nil }
+
+// newRequest creates an in-process request with base context. It carries the
+// headers in the inherited list from parent, and the active span of ctx.
+func (e *executor) newRequest(ctx context.Context, base context.Context, parent *http.Request, r *Request) (*http.Request, error) {
+	method := strings.ToUpper(r.Method)
+	if method == "" {
+		method = http.MethodGet
+		if e.rpc {
+			method = http.MethodPost
+		}
+	}
+	u, err := url.Parse(r.Path)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "" || u.Host != "" {
+		return nil, errors.BadRequest.Error("path ${path} should not contain scheme or host", r.Path)
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	if r.Action != "" || r.Version != "" {
+		query := u.Query()
+		query.Set("Action", r.Action)
+		query.Set("Version", r.Version)
+		u.RawQuery = query.Encode()
+	}
+
+	header := http.Header{}
+	for _, key := range e.inherited {
+		if values, ok := parent.Header[http.CanonicalHeaderKey(key)]; ok {
+			header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+		}
+	}
+	for key, values := range r.Header {
+		header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+	}
+	var body []byte
+	if len(r.Body) > 0 {
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", definition.MIMEJSON)
+		}
+		body = r.Body
+		if !isJSON(header.Get("Content-Type")) {
+			text := ""
+			if err := json.Unmarshal(r.Body, &text); err != nil {
+				return nil, errors.BadRequest.Error("body of content type ${type} should be a string", header.Get("Content-Type"))
+			}
+			body = []byte(text)
+		}
+	}
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(base)
+	req.Header = header
+	req.Host = parent.Host
+	req.RemoteAddr = parent.RemoteAddr
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		// Make sub-requests child spans of the batch request.
+		_ = span.Tracer().Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header))
+	}
+	return req, nil
+}
+
+// serve serves req by s and records the response.
+func serve(s service.Service, req *http.Request, response *Response) {
+	recorder := &recorder{header: http.Header{}}
+	s.ServeHTTP(recorder, req)
+	if recorder.code == 0 {
+		recorder.code = http.StatusOK
+	}
+	response.Status = recorder.code
+	if len(recorder.header) > 0 {
+		response.Header = recorder.header
+	}
+	if recorder.body.Len() <= 0 {
+		return
+	}
+	if isJSON(recorder.header.Get("Content-Type")) && json.Valid(recorder.body.Bytes()) {
+		response.Body = recorder.body.Bytes()
+		return
+	}
+	response.Body, _ = json.Marshal(recorder.body.String())
+}
+
+// isJSON checks if a content type is JSON or a JSON based type.
+func isJSON(contentType string) bool {
+	contentType = strings.TrimSpace(strings.Split(contentType, ";")[0])
+	return contentType == definition.MIMEJSON || strings.HasSuffix(contentType, "+json")
+}
+
+// recorder records the response of a sub-request.
+type recorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+var _ http.Flusher = &recorder{}
+
+// Header returns the header of the response.
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+// WriteHeader records the status code.
+func (r *recorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+// Write records the body.
+func (r *recorder) Write(data []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(data)
+}
+
+// Flush does nothing. The response is sent after all sub-requests finish.
+func (r *recorder) Flush() {}
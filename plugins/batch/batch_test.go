@@ -0,0 +1,258 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/caicloud/nirvana"
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/errors"
+	"github.com/caicloud/nirvana/log"
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/builder"
+)
+
+func newTestServer(t *testing.T, style service.APIStyle, descriptors ...interface{}) *httptest.Server {
+	cfg := nirvana.NewConfig()
+	cfg.Configure(MaxRequests(3))
+	b := builder.New(style)
+	b.SetModifier(service.FirstContextParameter())
+	if err := (&batchInstaller{}).Install(b, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddDescriptor(descriptors...); err != nil {
+		t.Fatal(err)
+	}
+	s, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(s)
+}
+
+func doBatch(t *testing.T, url string, batch string) (int, []Response) {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(batch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", definition.MIMEJSON)
+	req.Header.Set("Authorization", "token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	responses := []Response{}
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&responses); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode, responses
+}
+
+func TestRESTBatch(t *testing.T) {
+	lock := sync.Mutex{}
+	routes := []string{}
+	server := newTestServer(t, service.APIStyleREST, definition.Descriptor{
+		Path:     "/items/{name}",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Middlewares: []definition.Middleware{func(ctx context.Context, chain definition.Chain) error {
+			// Each sub-request passes middlewares.
+			lock.Lock()
+			routes = append(routes, service.HTTPContextFrom(ctx).RoutePath())
+			lock.Unlock()
+			return chain.Continue(ctx)
+		}},
+		Definitions: []definition.Definition{
+			{
+				Method: definition.Get,
+				Parameters: []definition.Parameter{
+					definition.PathParameterFor("name", ""),
+					{Source: definition.Header, Name: "Authorization"},
+				},
+				Results: definition.DataErrorResults(""),
+				Function: func(ctx context.Context, name string, auth string) (map[string]string, error) {
+					if name == "missing" {
+						return nil, errors.NotFound.Error("${name} is not found", name)
+					}
+					return map[string]string{"name": name, "auth": auth}, nil
+				},
+			},
+			{
+				Method: definition.Create,
+				Parameters: []definition.Parameter{
+					definition.PathParameterFor("name", ""),
+					definition.BodyParameterFor(""),
+				},
+				Results: definition.DataErrorResults(""),
+				Function: func(ctx context.Context, name string, body string) (string, error) {
+					return name + ":" + body, nil
+				},
+			},
+		},
+	})
+	defer server.Close()
+
+	for _, parallel := range []bool{false, true} {
+		routes = nil
+		batch, _ := json.Marshal(map[string]interface{}{
+			"parallel": parallel,
+			"requests": []map[string]interface{}{
+				{"id": "a", "path": "/items/a"},
+				{"id": "b", "method": "post", "path": "/items/b", "body": "text",
+					"headers": map[string][]string{"Content-Type": {definition.MIMEText}}},
+				{"id": "c", "path": "/items/missing"},
+			},
+		})
+		code, responses := doBatch(t, server.URL+"/batch", string(batch))
+		if code != http.StatusOK || len(responses) != 3 || len(routes) != 3 {
+			t.Fatalf("Unexpected batch result: %d %+v %v", code, responses, routes)
+		}
+		if r := responses[0]; r.ID != "a" || r.Status != http.StatusOK ||
+			!bytes.Contains(r.Body, []byte(`"auth":"token"`)) {
+			t.Fatalf("Unexpected response: %+v %s", r, r.Body)
+		}
+		if r := responses[1]; r.Status != http.StatusCreated || string(r.Body) != `"b:text"` {
+			t.Fatalf("Unexpected response: %+v %s", r, r.Body)
+		}
+		if r := responses[2]; r.Status != http.StatusNotFound {
+			t.Fatalf("Unexpected response: %+v %s", r, r.Body)
+		}
+	}
+
+	// Dependents of failed requests are skipped.
+	code, responses := doBatch(t, server.URL+"/batch", `{"parallel":true,"requests":[
+		{"id":"a","path":"/items/missing"},
+		{"id":"b","path":"/items/b","dependsOn":["a"]},
+		{"id":"c","path":"/items/c"}]}`)
+	if code != http.StatusOK || responses[1].Status != http.StatusFailedDependency || responses[2].Status != http.StatusOK {
+		t.Fatalf("Unexpected batch result: %d %+v", code, responses)
+	}
+
+	for _, batch := range []string{
+		`{"requests":[{"path":"/items/a","dependsOn":["b"]},{"id":"b","path":"/items/b"}]}`,
+		`{"requests":[{"id":"a","path":"/items/a"},{"id":"a","path":"/items/b"}]}`,
+		`{"requests":[{"path":"/batch","method":"POST","body":{}}]}`,
+		`{"requests":[{"path":"http://example.com/items/a"}]}`,
+	} {
+		code, responses = doBatch(t, server.URL+"/batch", batch)
+		if code != http.StatusBadRequest && !(len(responses) == 1 && responses[0].Status == http.StatusBadRequest) {
+			t.Fatalf("Batch %s should be rejected: %d %+v", batch, code, responses)
+		}
+	}
+	code, _ = doBatch(t, server.URL+"/batch", `{"requests":[{"path":"/"},{"path":"/"},{"path":"/"},{"path":"/"}]}`)
+	if code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Unexpected status code: %d", code)
+	}
+}
+
+type echo struct {
+	Name string `json:"name"`
+}
+
+func TestRPCBatch(t *testing.T) {
+	server := newTestServer(t, service.APIStyleRPC, definition.RPCDescriptor{
+		Path:     "/",
+		Consumes: []string{definition.MIMEJSON},
+		Produces: []string{definition.MIMEJSON},
+		Actions: []definition.RPCAction{{
+			Name:       "Echo",
+			Version:    "v1",
+			Parameters: []definition.Parameter{definition.BodyParameterFor("")},
+			Results:    definition.DataErrorResults(""),
+			Function: func(ctx context.Context, body *echo) (*echo, error) {
+				return body, nil
+			},
+		}},
+	})
+	defer server.Close()
+
+	code, responses := doBatch(t, server.URL+"/?Action=Batch", `{"requests":[
+		{"action":"Echo","version":"v1","body":{"name":"a"}},
+		{"path":"/?Action=Echo&Version=v1","body":{"name":"b"}},
+		{"action":"Unknown","version":"v1"}]}`)
+	if code != http.StatusOK || len(responses) != 3 {
+		t.Fatalf("Unexpected batch result: %d %+v", code, responses)
+	}
+	if string(responses[0].Body) != `{"name":"a"}` || string(responses[1].Body) != `{"name":"b"}` ||
+		responses[2].Status != http.StatusMethodNotAllowed {
+		t.Fatalf("Unexpected responses: %+v", responses)
+	}
+}
+
+func TestSubRequestScopes(t *testing.T) {
+	lock := sync.Mutex{}
+	loggers := map[string]log.Logger{}
+	server := newTestServer(t, service.APIStyleREST, definition.Descriptor{
+		Path:     "/",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Middlewares: []definition.Middleware{func(ctx context.Context, chain definition.Chain) error {
+			// Set request ids like plugin reqlog, but only if clients send them.
+			ctx = service.WithRequestID(ctx, service.HTTPContextFrom(ctx).Request().Header.Get("X-Trace-Id"))
+			lock.Lock()
+			loggers[service.RequestIDFrom(ctx)] = service.LoggerFrom(ctx)
+			lock.Unlock()
+			return chain.Continue(ctx)
+		}},
+		Children: []definition.Descriptor{{
+			Path: "/id",
+			Definitions: []definition.Definition{{
+				Method:  definition.Get,
+				Results: definition.DataErrorResults(""),
+				Function: func(ctx context.Context) (string, error) {
+					return service.RequestIDFrom(ctx), nil
+				},
+			}},
+		}},
+	})
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/batch", strings.NewReader(`{"requests":[
+		{"path":"/id","headers":{"X-Trace-Id":["sub"]}},
+		{"path":"/id"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", definition.MIMEJSON)
+	req.Header.Set("X-Trace-Id", "batch")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	responses := []Response{}
+	if err := json.NewDecoder(resp.Body).Decode(&responses); err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 2 || string(responses[0].Body) != `"sub"` || bytes.Contains(responses[1].Body, []byte("batch")) {
+		t.Fatalf("Sub-requests should not inherit the request id of the batch: %+v", responses)
+	}
+	if loggers["sub"] == loggers["batch"] || loggers[""] == loggers["batch"] {
+		t.Fatal("Sub-requests should not inherit the logger of the batch")
+	}
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batch
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/caicloud/nirvana"
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/service"
+)
+
+func init() {
+	nirvana.RegisterConfigInstaller(&batchInstaller{})
+	// A batch always succeeds with 200 even if some sub-requests fail.
+	if err := service.RegisterMethod(Method, http.MethodPost, http.StatusOK); err != nil {
+		panic(err)
+	}
+}
+
+// ExternalConfigName is the external config name of batch.
+const ExternalConfigName = "batch"
+
+// Method is the definition method of the batch endpoint of REST services.
+// It binds to http.MethodPost and code http.StatusOK(200).
+const Method definition.Method = "Batch"
+
+// DefaultInheritedHeaders contains the headers which are copied from a
+// batch request to its sub-requests by default.
+var DefaultInheritedHeaders = []string{"Authorization", "Cookie", "User-Agent", "Accept-Language", "X-Forwarded-For", "X-Real-Ip"}
+
+// config is batch config.
+type config struct {
+	path        string
+	action      string
+	version     string
+	maxRequests int
+	inherited   []string
+}
+
+type batchInstaller struct{}
+
+// Name is the external config name.
+func (i *batchInstaller) Name() string {
+	return ExternalConfigName
+}
+
+// Install installs stuffs before server starting.
+func (i *batchInstaller) Install(builder service.Builder, cfg *nirvana.Config) error {
+	var err error
+	wrapper(cfg, func(c *config) {
+		e := &executor{
+			rpc:         builder.APIStyle() == service.APIStyleRPC,
+			maxRequests: c.maxRequests,
+			inherited:   c.inherited,
+		}
+		parameters := []definition.Parameter{definition.BodyParameterFor("sub-requests")}
+		results := definition.DataErrorResults("responses of sub-requests in order")
+		description := "Execute several requests in one request. The status of the batch is always 200 if the batch is valid. " +
+			"Check the status of each response for results of sub-requests."
+		path := c.path
+		if e.rpc {
+			if path == "" {
+				path = "/"
+			}
+			err = builder.AddDescriptor(definition.RPCDescriptor{
+				Path:     path,
+				Consumes: []string{definition.MIMEJSON},
+				Produces: []string{definition.MIMEJSON},
+				Actions: []definition.RPCAction{{
+					Name:        c.action,
+					Version:     c.version,
+					Description: description,
+					Parameters:  parameters,
+					Results:     results,
+					Function:    e.execute,
+				}},
+			})
+			return
+		}
+		if path == "" {
+			path = "/batch"
+		}
+		err = builder.AddDescriptor(definition.Descriptor{
+			Path:        path,
+			Description: "batch requests",
+			Consumes:    []string{definition.MIMEJSON},
+			Produces:    []string{definition.MIMEJSON},
+			Definitions: []definition.Definition{{
+				Method:      Method,
+				Summary:     "Batch Requests",
+				Description: description,
+				Parameters:  parameters,
+				Results:     results,
+				Function:    e.execute,
+			}},
+		})
+	})
+	return err
+}
+
+// Uninstall uninstalls stuffs after server terminating.
+func (i *batchInstaller) Uninstall(builder service.Builder, cfg *nirvana.Config) error {
+	return nil
+}
+
+// Disable returns a configurer to disable batch.
+func Disable() nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		c.Set(ExternalConfigName, nil)
+		return nil
+	}
+}
+
+// Default returns a configurer to enable batch with default config.
+func Default() nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+		})
+		return nil
+	}
+}
+
+// Path returns a configurer to set the path of the batch endpoint.
+// For RPC services, it's the path of the batch action.
+func Path(path string) nirvana.Configurer {
+	path = "/" + strings.Trim(path, "/")
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+			c.path = path
+		})
+		return nil
+	}
+}
+
+// Action returns a configurer to set the action name and version of the
+// batch action of RPC services.
+func Action(name string, version string) nirvana.Configurer {
+	if name == "" {
+		name = "Batch"
+	}
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+			c.action = name
+			c.version = version
+		})
+		return nil
+	}
+}
+
+// MaxRequests returns a configurer to set the max number of sub-requests in a batch.
+func MaxRequests(max int) nirvana.Configurer {
+	if max <= 0 {
+		max = 20
+	}
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+			c.maxRequests = max
+		})
+		return nil
+	}
+}
+
+// InheritHeaders returns a configurer to set the headers which are copied
+// from a batch request to its sub-requests.
+func InheritHeaders(headers ...string) nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+			c.inherited = headers
+		})
+		return nil
+	}
+}
+
+func wrapper(c *nirvana.Config, f func(c *config)) {
+	conf := c.Config(ExternalConfigName)
+	var cfg *config
+	if conf == nil {
+		// Default config.
+		cfg = &config{
+			action:      "Batch",
+			maxRequests: 20,
+			inherited:   DefaultInheritedHeaders,
+		}
+	} else {
+		// Panic if config type is wrong.
+		cfg = conf.(*config)
+	}
+	f(cfg)
+	c.Set(ExternalConfigName, cfg)
+}
+
+// Option contains basic configurations of batch.
+type Option struct {
+	Path        string `desc:"Path of batch endpoint. Defaults to /batch for REST services and / for RPC services"`
+	MaxRequests int    `desc:"Max number of sub-requests in a batch"`
+}
+
+// NewDefaultOption creates default option.
+func NewDefaultOption() *Option {
+	return &Option{
+		MaxRequests: 20,
+	}
+}
+
+// Name returns plugin name.
+func (p *Option) Name() string {
+	return ExternalConfigName
+}
+
+// Configure configures nirvana config via current options.
+func (p *Option) Configure(cfg *nirvana.Config) error {
+	if p.Path != "" {
+		cfg.Configure(Path(p.Path))
+	}
+	cfg.Configure(MaxRequests(p.MaxRequests))
+	return nil
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cors
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/caicloud/nirvana"
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/middlewares/cors"
+	"github.com/caicloud/nirvana/service"
+)
+
+func init() {
+	nirvana.RegisterConfigInstaller(&corsInstaller{})
+}
+
+// ExternalConfigName is the external config name of cors.
+const ExternalConfigName = "cors"
+
+// config is cors config.
+type config struct {
+	options cors.Options
+}
+
+type corsInstaller struct{}
+
+// Name is the external config name.
+func (i *corsInstaller) Name() string {
+	return ExternalConfigName
+}
+
+// Install installs stuffs before server starting.
+func (i *corsInstaller) Install(builder service.Builder, cfg *nirvana.Config) error {
+	var err error
+	wrapper(cfg, func(c *config) {
+		if builder.APIStyle() == service.APIStyleRPC {
+			err = fmt.Errorf("cors plugin does not support API style %s", builder.APIStyle())
+			return
+		}
+		options := c.options
+		if options.AllowCredentials && cors.AllowsAllOrigins(&options) {
+			err = fmt.Errorf("cors plugin can't allow credentials for all origins, please set allowed origins")
+			return
+		}
+		// The filter writes headers for errors of routing, and the
+		// middleware writes headers after the Allow header is set.
+		builder.AddFilter(cors.Filter(&options))
+		err = builder.AddDescriptor(definition.Descriptor{
+			Path:        "/",
+			Middlewares: []definition.Middleware{cors.New(&options)},
+		})
+	})
+	return err
+}
+
+// Uninstall uninstalls stuffs after server terminating.
+func (i *corsInstaller) Uninstall(builder service.Builder, cfg *nirvana.Config) error {
+	return nil
+}
+
+// Disable returns a configurer to disable cors.
+func Disable() nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		c.Set(ExternalConfigName, nil)
+		return nil
+	}
+}
+
+// Default returns a configurer to enable cors for all origins.
+func Default() nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+		})
+		return nil
+	}
+}
+
+// AllowedOrigins returns a configurer to set allowed origins. Origins may
+// contain wildcards, such as "https://*.example.com".
+func AllowedOrigins(origins ...string) nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+			c.options.AllowedOrigins = origins
+		})
+		return nil
+	}
+}
+
+// AllowedHeaders returns a configurer to set allowed request headers.
+func AllowedHeaders(headers ...string) nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+			c.options.AllowedHeaders = headers
+		})
+		return nil
+	}
+}
+
+// ExposedHeaders returns a configurer to set response headers exposed to browsers.
+func ExposedHeaders(headers ...string) nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+			c.options.ExposedHeaders = headers
+		})
+		return nil
+	}
+}
+
+// AllowCredentials returns a configurer to allow requests with credentials.
+// Allowed origins must be set, credentials can't be allowed for all origins.
+func AllowCredentials(allow bool) nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+			c.options.AllowCredentials = allow
+		})
+		return nil
+	}
+}
+
+// MaxAge returns a configurer to set how long results of preflight requests can be cached.
+func MaxAge(maxAge time.Duration) nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+			c.options.MaxAge = maxAge
+		})
+		return nil
+	}
+}
+
+func wrapper(c *nirvana.Config, f func(c *config)) {
+	conf := c.Config(ExternalConfigName)
+	var cfg *config
+	if conf == nil {
+		// Default config.
+		cfg = &config{}
+	} else {
+		// Panic if config type is wrong.
+		cfg = conf.(*config)
+	}
+	f(cfg)
+	c.Set(ExternalConfigName, cfg)
+}
+
+// Option contains basic configurations of cors.
+type Option struct {
+	AllowedOrigins   []string      `desc:"Origins allowed to make cross-origin requests, wildcards are supported"`
+	AllowedHeaders   []string      `desc:"Request headers allowed in cross-origin requests"`
+	ExposedHeaders   []string      `desc:"Response headers exposed to browsers"`
+	AllowCredentials bool          `desc:"Allow cross-origin requests with credentials, allowed origins must be set"`
+	MaxAge           time.Duration `desc:"Duration to cache results of preflight requests"`
+}
+
+// NewDefaultOption creates default option.
+func NewDefaultOption() *Option {
+	return &Option{
+		AllowedOrigins: []string{"*"},
+	}
+}
+
+// Name returns plugin name.
+func (p *Option) Name() string {
+	return ExternalConfigName
+}
+
+// Configure configures nirvana config via current options.
+func (p *Option) Configure(cfg *nirvana.Config) error {
+	cfg.Configure(
+		AllowedOrigins(p.AllowedOrigins...),
+		AllowedHeaders(p.AllowedHeaders...),
+		ExposedHeaders(p.ExposedHeaders...),
+		AllowCredentials(p.AllowCredentials),
+		MaxAge(p.MaxAge),
+	)
+	return nil
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caicloud/nirvana"
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/middlewares/cors"
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/builder"
+)
+
+func TestCORS(t *testing.T) {
+	cfg := nirvana.NewConfig()
+	cfg.Configure(
+		AllowedOrigins("https://*.example.com"),
+		ExposedHeaders("X-Total-Count"),
+		AllowCredentials(true),
+		MaxAge(time.Hour),
+	)
+	handle := func() (string, error) { return "ok", nil }
+	b := builder.New(service.APIStyleREST)
+	err := b.AddDescriptor(definition.Descriptor{
+		Path:     "/",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Children: []definition.Descriptor{
+			{
+				Path: "/items",
+				Definitions: []definition.Definition{
+					{Method: definition.Get, Function: handle, Results: definition.DataErrorResults("")},
+					{Method: definition.Delete, Function: handle, Results: definition.DataErrorResults("")},
+				},
+			},
+			{
+				Path:        "/public",
+				Middlewares: []definition.Middleware{cors.New(&cors.Options{AllowedHeaders: []string{"X-Custom"}})},
+				Definitions: []definition.Definition{
+					{Method: definition.Get, Function: handle, Results: definition.DataErrorResults("")},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (&corsInstaller{}).Install(b, cfg); err != nil {
+		t.Fatal(err)
+	}
+	s, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	do := func(method, path, origin string) *http.Response {
+		req, _ := http.NewRequest(method, server.URL+path, nil)
+		req.Header.Set(cors.HeaderOrigin, origin)
+		if method == http.MethodOptions {
+			req.Header.Set(cors.HeaderAccessControlRequestMethod, http.MethodDelete)
+			req.Header.Set(cors.HeaderAccessControlRequestHeaders, "Authorization")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		origin string
+		code   int
+		header map[string]string
+	}{
+		{http.MethodOptions, "/items", "https://app.example.com", http.StatusNoContent, map[string]string{
+			cors.HeaderAccessControlAllowOrigin:      "https://app.example.com",
+			cors.HeaderAccessControlAllowMethods:     "DELETE, GET, HEAD, OPTIONS",
+			cors.HeaderAccessControlAllowHeaders:     "Authorization",
+			cors.HeaderAccessControlAllowCredentials: "true",
+			cors.HeaderAccessControlMaxAge:           "3600",
+		}},
+		{http.MethodGet, "/items", "https://app.example.com", http.StatusOK, map[string]string{
+			cors.HeaderAccessControlAllowOrigin:   "https://app.example.com",
+			cors.HeaderAccessControlExposeHeaders: "X-Total-Count",
+			cors.HeaderAccessControlAllowMethods:  "",
+		}},
+		{http.MethodOptions, "/items", "https://example.org", http.StatusNoContent, map[string]string{
+			cors.HeaderAccessControlAllowOrigin:  "",
+			cors.HeaderAccessControlAllowMethods: "",
+		}},
+		{http.MethodOptions, "/public", "https://example.org", http.StatusNoContent, map[string]string{
+			cors.HeaderAccessControlAllowOrigin:      "*",
+			cors.HeaderAccessControlAllowMethods:     "GET, HEAD, OPTIONS",
+			cors.HeaderAccessControlAllowHeaders:     "X-Custom",
+			cors.HeaderAccessControlAllowCredentials: "",
+			cors.HeaderAccessControlMaxAge:           "",
+		}},
+		// Errors of routing are readable by browsers.
+		{http.MethodGet, "/unknown", "https://app.example.com", http.StatusNotFound, map[string]string{
+			cors.HeaderAccessControlAllowOrigin:   "https://app.example.com",
+			cors.HeaderAccessControlExposeHeaders: "X-Total-Count",
+		}},
+		{http.MethodPut, "/items", "https://app.example.com", http.StatusMethodNotAllowed, map[string]string{
+			cors.HeaderAccessControlAllowOrigin: "https://app.example.com",
+		}},
+	}
+	for _, test := range tests {
+		resp := do(test.method, test.path, test.origin)
+		if resp.StatusCode != test.code {
+			t.Fatalf("%s %s from %s should return %d, but got %d", test.method, test.path, test.origin, test.code, resp.StatusCode)
+		}
+		for key, value := range test.header {
+			if got := resp.Header.Get(key); got != value {
+				t.Fatalf("%s %s from %s: header %s should be %q, but got %q", test.method, test.path, test.origin, key, value, got)
+			}
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/items", nil)
+	req.Header.Set(cors.HeaderOrigin, "https://app.example.com")
+	req.Header.Set("Accept", definition.MIMEXML)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotAcceptable || resp.Header.Get(cors.HeaderAccessControlAllowOrigin) != "https://app.example.com" {
+		t.Fatalf("Response 406 should be readable by browsers, but got %d %v", resp.StatusCode, resp.Header)
+	}
+
+	// Credentialed requests from unlisted origins can't be read.
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/items", nil)
+	req.Header.Set(cors.HeaderOrigin, "https://evil.org")
+	req.Header.Set("Cookie", "session=secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get(cors.HeaderAccessControlAllowOrigin) != "" || resp.Header.Get(cors.HeaderAccessControlAllowCredentials) != "" {
+		t.Fatalf("Credentialed request from unlisted origin should not be readable, but got %v", resp.Header)
+	}
+}
+
+func TestCredentialsForAllOrigins(t *testing.T) {
+	cfg := nirvana.NewConfig()
+	cfg.Configure((&Option{AllowedOrigins: []string{"*"}, AllowCredentials: true}).Configure)
+	if err := (&corsInstaller{}).Install(builder.New(service.APIStyleREST), cfg); err == nil {
+		t.Fatal("Credentials should not be allowed for all origins")
+	}
+
+	filter := cors.Filter(&cors.Options{AllowCredentials: true})
+	req, _ := http.NewRequest(http.MethodGet, "/items", nil)
+	req.Header.Set(cors.HeaderOrigin, "https://evil.org")
+	req.Header.Set("Cookie", "session=secret")
+	resp := httptest.NewRecorder()
+	filter(resp, req)
+	if resp.Header().Get(cors.HeaderAccessControlAllowOrigin) != "*" || resp.Header().Get(cors.HeaderAccessControlAllowCredentials) != "" {
+		t.Fatalf("Credentials should be ignored for all origins, but got %v", resp.Header())
+	}
+}
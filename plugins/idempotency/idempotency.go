@@ -0,0 +1,169 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/caicloud/nirvana"
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/middlewares/idempotency"
+	"github.com/caicloud/nirvana/service"
+)
+
+func init() {
+	nirvana.RegisterConfigInstaller(&idempotencyInstaller{})
+}
+
+// ExternalConfigName is the external config name of idempotency.
+const ExternalConfigName = "idempotency"
+
+// config is idempotency config.
+type config struct {
+	store       idempotency.Store
+	identity    func(ctx context.Context) string
+	maxBodySize int64
+}
+
+type idempotencyInstaller struct{}
+
+// Name is the external config name.
+func (i *idempotencyInstaller) Name() string {
+	return ExternalConfigName
+}
+
+// Install installs stuffs before server starting.
+func (i *idempotencyInstaller) Install(builder service.Builder, cfg *nirvana.Config) error {
+	var err error
+	wrapper(cfg, func(c *config) {
+		if builder.APIStyle() == service.APIStyleRPC {
+			err = fmt.Errorf("idempotency plugin does not support API style %s", builder.APIStyle())
+			return
+		}
+		err = builder.AddDescriptor(definition.Descriptor{
+			Path: "/",
+			Middlewares: []definition.Middleware{idempotency.New(&idempotency.Options{
+				Store:       c.store,
+				Identity:    c.identity,
+				MaxBodySize: c.maxBodySize,
+			})},
+		})
+	})
+	return err
+}
+
+// Uninstall uninstalls stuffs after server terminating.
+func (i *idempotencyInstaller) Uninstall(builder service.Builder, cfg *nirvana.Config) error {
+	return nil
+}
+
+// Disable returns a configurer to disable idempotency.
+func Disable() nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		c.Set(ExternalConfigName, nil)
+		return nil
+	}
+}
+
+// Default returns a configurer to enable idempotency with an in-memory store.
+func Default() nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+		})
+		return nil
+	}
+}
+
+// Storage returns a configurer to set the store of idempotency keys.
+func Storage(store idempotency.Store) nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+			c.store = store
+		})
+		return nil
+	}
+}
+
+// Identity returns a configurer to set the function which identifies callers.
+func Identity(identity func(ctx context.Context) string) nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+			c.identity = identity
+		})
+		return nil
+	}
+}
+
+// MaxBodySize returns a configurer to set the max size of request bodies
+// with idempotency keys.
+func MaxBodySize(size int64) nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+			c.maxBodySize = size
+		})
+		return nil
+	}
+}
+
+func wrapper(c *nirvana.Config, f func(c *config)) {
+	conf := c.Config(ExternalConfigName)
+	var cfg *config
+	if conf == nil {
+		// Default config.
+		cfg = &config{}
+	} else {
+		// Panic if config type is wrong.
+		cfg = conf.(*config)
+	}
+	f(cfg)
+	c.Set(ExternalConfigName, cfg)
+}
+
+// Option contains basic configurations of idempotency.
+type Option struct {
+	// Capacity is the max number of keys in the in-memory store.
+	Capacity int `desc:"Max number of idempotency keys kept in memory"`
+	// TTL is the duration to keep a key.
+	TTL time.Duration `desc:"Duration to keep an idempotency key"`
+	// MaxBodySize is the max size of request bodies with idempotency keys.
+	MaxBodySize int64 `desc:"Max size of request bodies with idempotency keys"`
+}
+
+// NewDefaultOption creates default option.
+func NewDefaultOption() *Option {
+	return &Option{
+		Capacity:    10000,
+		TTL:         24 * time.Hour,
+		MaxBodySize: idempotency.DefaultMaxBodySize,
+	}
+}
+
+// Name returns plugin name.
+func (p *Option) Name() string {
+	return ExternalConfigName
+}
+
+// Configure configures nirvana config via current options.
+func (p *Option) Configure(cfg *nirvana.Config) error {
+	cfg.Configure(
+		Storage(idempotency.NewMemoryStore(p.Capacity, p.TTL)),
+		MaxBodySize(p.MaxBodySize),
+	)
+	return nil
+}
@@ -54,6 +54,10 @@ func (i *metricsInstaller) Install(builder service.Builder, cfg *nirvana.Config)
 			Middlewares: []definition.Middleware{metricsmiddleware.Restful(&metrics.Options{NamespaceValue: c.namespace})},
 		}
 		err = builder.AddDescriptor(monitorMiddleware, metricsmiddleware.Descriptor(c.path))
+		// Record durations of phases of executing definitions.
+		if observable, ok := builder.(service.ObservableBuilder); ok {
+			observable.AddObserver(metricsmiddleware.RestfulObserver())
+		}
 	})
 	return err
 }
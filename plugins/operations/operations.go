@@ -0,0 +1,401 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operations
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/errors"
+	"github.com/caicloud/nirvana/rest"
+	"github.com/caicloud/nirvana/service"
+)
+
+// Status is the status of an operation.
+type Status string
+
+const (
+	// StatusRunning means the operation is running.
+	StatusRunning Status = "Running"
+	// StatusSucceeded means the operation finished without error.
+	StatusSucceeded Status = "Succeeded"
+	// StatusFailed means the operation finished with an error.
+	StatusFailed Status = "Failed"
+	// StatusCancelled means the operation was cancelled.
+	StatusCancelled Status = "Cancelled"
+)
+
+var (
+	operationNotFound = errors.NotFound.Build("Nirvana:Operations:OperationNotFound", "operation ${id} is not found")
+	operationFinished = errors.Conflict.Build("Nirvana:Operations:OperationFinished", "operation ${id} has finished with status ${status}")
+	invalidTimeout    = errors.BadRequest.Build("Nirvana:Operations:InvalidTimeout", "invalid timeout ${timeout}")
+	operationPanicked = errors.InternalServerError.Build("Nirvana:Operations:OperationPanicked", "operation panicked: ${reason}")
+)
+
+// Operation describes a long-running operation.
+type Operation struct {
+	// ID is the unique id of the operation.
+	ID string `json:"id"`
+	// SelfLink is the path to get the operation.
+	SelfLink string `json:"selfLink"`
+	// Status is the status of the operation.
+	Status Status `json:"status"`
+	// Result is the JSON result of a succeeded operation.
+	Result json.RawMessage `json:"result,omitempty"`
+	// Error is the error message of a failed operation.
+	Error string `json:"error,omitempty"`
+	// CreationTime is the time when the operation was started.
+	CreationTime time.Time `json:"creationTime"`
+	// UpdateTime is the last time when the operation was updated.
+	UpdateTime time.Time `json:"updateTime"`
+}
+
+// Done checks if the operation has finished.
+func (o *Operation) Done() bool {
+	return o.Status != StatusRunning
+}
+
+// Location returns the path to get the operation. The framework writes
+// it to header "Location" when an operation is returned with 202.
+func (o *Operation) Location() string {
+	return o.SelfLink
+}
+
+// Into unmarshals the result of a succeeded operation into v.
+func (o *Operation) Into(v interface{}) error {
+	if len(o.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(o.Result, v)
+}
+
+func (o *Operation) copy() *Operation {
+	c := *o
+	return &c
+}
+
+// Store stores operations. It should be shared by all instances of
+// a server if clients may reach any of them.
+type Store interface {
+	// Create creates an operation.
+	Create(ctx context.Context, operation *Operation) error
+	// Get gets an operation by id. It returns a NotFound error if
+	// the operation does not exist.
+	Get(ctx context.Context, id string) (*Operation, error)
+	// Update updates an operation.
+	Update(ctx context.Context, operation *Operation) error
+}
+
+// memoryStore is an in-memory store.
+type memoryStore struct {
+	lock       sync.RWMutex
+	operations map[string]*Operation
+	retention  time.Duration
+}
+
+// NewMemoryStore creates an in-memory store. Finished operations are removed
+// after retention. A retention of zero means one hour.
+func NewMemoryStore(retention time.Duration) Store {
+	if retention <= 0 {
+		retention = time.Hour
+	}
+	return &memoryStore{
+		operations: map[string]*Operation{},
+		retention:  retention,
+	}
+}
+
+// Create creates an operation and removes expired operations.
+func (s *memoryStore) Create(ctx context.Context, operation *Operation) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	expiration := time.Now().Add(-s.retention)
+	for id, op := range s.operations {
+		if op.Done() && op.UpdateTime.Before(expiration) {
+			delete(s.operations, id)
+		}
+	}
+	s.operations[operation.ID] = operation.copy()
+	return nil
+}
+
+// Get gets an operation by id.
+func (s *memoryStore) Get(ctx context.Context, id string) (*Operation, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	op, ok := s.operations[id]
+	if !ok {
+		return nil, operationNotFound.Error(id)
+	}
+	return op.copy(), nil
+}
+
+// Update updates an operation.
+func (s *memoryStore) Update(ctx context.Context, operation *Operation) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.operations[operation.ID]; !ok {
+		return operationNotFound.Error(operation.ID)
+	}
+	s.operations[operation.ID] = operation.copy()
+	return nil
+}
+
+// Func is the function of a long-running operation. The result
+// is marshaled to JSON and saved as the result of the operation.
+type Func func(ctx context.Context) (interface{}, error)
+
+// task is an operation running in current process.
+type task struct {
+	// lock serializes the final update of the operation and cancellation.
+	lock   sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// manager runs operations and tracks their status.
+type manager struct {
+	lock         sync.RWMutex
+	path         string
+	store        Store
+	pollInterval time.Duration
+	maxTimeout   time.Duration
+	tasks        map[string]*task
+}
+
+// newManager creates a manager of operations under path.
+func newManager(path string, store Store, maxTimeout time.Duration) *manager {
+	return &manager{
+		path:         path,
+		store:        store,
+		pollInterval: time.Second,
+		maxTimeout:   maxTimeout,
+		tasks:        map[string]*task{},
+	}
+}
+
+// defaultManager manages operations started out of servers with the plugin.
+var defaultManager = newManager("/operations", NewMemoryStore(0), time.Minute)
+
+// contextKeyManager is a key for context. Its value is the manager of the
+// server which serves the request.
+var contextKeyManager interface{} = new(byte)
+
+// managerFrom returns the manager in ctx, or the default manager if there
+// is no manager in ctx.
+func managerFrom(ctx context.Context) *manager {
+	if m, ok := ctx.Value(contextKeyManager).(*manager); ok {
+		return m
+	}
+	return defaultManager
+}
+
+// middleware makes the manager available for Start in requests.
+func (m *manager) middleware(ctx context.Context, chain definition.Chain) error {
+	return chain.Continue(context.WithValue(ctx, contextKeyManager, m))
+}
+
+// Start starts f in background and returns the handle of the operation.
+// A definition with an Async method can return the handle as its data, then
+// the framework answers 202 with header "Location" pointing to the operation:
+//
+//  func CreateApplication(ctx context.Context, app *Application) (*operations.Operation, error) {
+//      return operations.Start(ctx, func(ctx context.Context) (interface{}, error) {
+//          return deploy(ctx, app)
+//      })
+//  }
+//
+// f runs with a new context which is cancelled when the operation is cancelled.
+// The context carries the logger of ctx. The operation is managed by the
+// server which serves ctx.
+func Start(ctx context.Context, f Func) (*Operation, error) {
+	return managerFrom(ctx).start(ctx, f)
+}
+
+func newID() string {
+	data := make([]byte, 16)
+	_, _ = rand.Read(data)
+	return hex.EncodeToString(data)
+}
+
+func (m *manager) task(id string) *task {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.tasks[id]
+}
+
+func (m *manager) start(ctx context.Context, f Func) (*Operation, error) {
+	now := time.Now()
+	id := newID()
+	op := &Operation{
+		ID:           id,
+		SelfLink:     m.path + "/" + id,
+		Status:       StatusRunning,
+		CreationTime: now,
+		UpdateTime:   now,
+	}
+	if err := m.store.Create(ctx, op); err != nil {
+		return nil, err
+	}
+	runCtx, cancel := context.WithCancel(service.WithLogger(context.Background(), service.LoggerFrom(ctx)))
+	t := &task{cancel: cancel, done: make(chan struct{})}
+	m.lock.Lock()
+	m.tasks[id] = t
+	m.lock.Unlock()
+	go m.run(runCtx, op.copy(), t, f)
+	return op, nil
+}
+
+func (m *manager) run(ctx context.Context, op *Operation, t *task, f Func) {
+	defer func() {
+		t.cancel()
+		m.lock.Lock()
+		delete(m.tasks, op.ID)
+		m.lock.Unlock()
+		close(t.done)
+	}()
+	result, err := call(ctx, f)
+	if err == nil {
+		op.Result, err = json.Marshal(result)
+	}
+	// Hold the lock until the operation is updated, so that a cancellation
+	// can't be overwritten.
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if current, e := m.store.Get(context.Background(), op.ID); e == nil && current.Status == StatusCancelled {
+		// The operation has been cancelled.
+		return
+	}
+	op.Status = StatusSucceeded
+	if err != nil {
+		op.Status = StatusFailed
+		op.Result = nil
+		op.Error = err.Error()
+	}
+	op.UpdateTime = time.Now()
+	if err := m.store.Update(context.Background(), op); err != nil {
+		service.LoggerFrom(ctx).Errorf("Can't update operation %s: %v", op.ID, err)
+	}
+}
+
+// call calls f and converts panics to errors.
+func call(ctx context.Context, f Func) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = operationPanicked.Error(fmt.Sprint(r))
+		}
+	}()
+	return f(ctx)
+}
+
+// get gets an operation.
+func (m *manager) get(ctx context.Context, id string) (*Operation, error) {
+	return m.store.Get(ctx, id)
+}
+
+// wait waits until the operation is done or timeout.
+func (m *manager) wait(ctx context.Context, id string, timeout time.Duration) (*Operation, error) {
+	store := m.store
+	if timeout > m.maxTimeout {
+		timeout = m.maxTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		// Wake up immediately if the operation runs in current process.
+		// Otherwise poll the store. The task must be got before the
+		// operation, because the task is removed after the store is updated.
+		var done <-chan struct{}
+		if t := m.task(id); t != nil {
+			done = t.done
+		}
+		op, err := store.Get(ctx, id)
+		if err != nil || op.Done() {
+			return op, err
+		}
+		poll := time.NewTimer(m.pollInterval)
+		select {
+		case <-done:
+		case <-poll.C:
+		case <-timer.C:
+			poll.Stop()
+			return store.Get(ctx, id)
+		case <-ctx.Done():
+			poll.Stop()
+			return op, nil
+		}
+		poll.Stop()
+	}
+}
+
+// cancel cancels an operation.
+func (m *manager) cancel(ctx context.Context, id string) (*Operation, error) {
+	t := m.task(id)
+	if t != nil {
+		// Don't race with the final update of the operation.
+		t.lock.Lock()
+		defer t.lock.Unlock()
+	}
+	op, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if op.Done() {
+		return nil, operationFinished.Error(id, op.Status)
+	}
+	op.Status = StatusCancelled
+	op.UpdateTime = time.Now()
+	if err := m.store.Update(ctx, op); err != nil {
+		return nil, err
+	}
+	if t != nil {
+		t.cancel()
+	}
+	return op, nil
+}
+
+// DefaultWaitTimeout is the timeout of each long-poll request sent by Wait.
+const DefaultWaitTimeout = 30 * time.Second
+
+// Wait waits until the operation is done or ctx is done. It long-polls
+// the operation from the server and returns the latest operation.
+func Wait(ctx context.Context, client *rest.Client, operation *Operation) (*Operation, error) {
+	current := operation
+	for !current.Done() {
+		next := &Operation{}
+		// Use a templated path. The client caches parsed paths.
+		prefix := strings.TrimSuffix(current.SelfLink, "/"+current.ID)
+		err := client.Request(http.MethodGet, http.StatusOK, prefix+"/{operation}/wait").
+			Path("operation", current.ID).
+			Query("timeout", DefaultWaitTimeout.String()).
+			Data(next).
+			Do(ctx)
+		if err != nil {
+			return current, err
+		}
+		current = next
+	}
+	return current, nil
+}
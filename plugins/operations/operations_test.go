@@ -0,0 +1,207 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operations
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caicloud/nirvana"
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/rest"
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/builder"
+	"github.com/caicloud/nirvana/utils/api"
+)
+
+func newTestServer(t *testing.T, f Func) (*httptest.Server, *rest.Client) {
+	cfg := nirvana.NewConfig()
+	cfg.Configure(Path("/ops"), Storage(NewMemoryStore(0)))
+	b := builder.New(service.APIStyleREST)
+	b.SetModifier(service.FirstContextParameter())
+	if err := (&operationsInstaller{}).Install(b, cfg); err != nil {
+		t.Fatal(err)
+	}
+	err := b.AddDescriptor(definition.Descriptor{
+		Path:     "/jobs",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Definitions: []definition.Definition{{
+			Method:  definition.AsyncCreate,
+			Results: definition.DataErrorResults("operation"),
+			Function: func(ctx context.Context) (*Operation, error) {
+				return Start(ctx, f)
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(s)
+	client, err := rest.NewClient(&rest.Config{Host: strings.TrimPrefix(server.URL, "http://")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, client
+}
+
+func TestOperation(t *testing.T) {
+	release := make(chan struct{})
+	server, client := newTestServer(t, func(ctx context.Context) (interface{}, error) {
+		<-release
+		return map[string]string{"name": "job"}, nil
+	})
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/jobs", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	location := resp.Header.Get("Location")
+	if resp.StatusCode != http.StatusAccepted || !strings.HasPrefix(location, "/ops/") {
+		t.Fatalf("Unexpected response: %d %s", resp.StatusCode, location)
+	}
+
+	op := &Operation{}
+	if err := client.Request(http.MethodGet, http.StatusOK, location).Data(op).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if op.Status != StatusRunning || op.Location() != location {
+		t.Fatalf("Unexpected operation: %+v", op)
+	}
+
+	close(release)
+	op, err = Wait(context.Background(), client, op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := map[string]string{}
+	if err := op.Into(&result); err != nil {
+		t.Fatal(err)
+	}
+	if op.Status != StatusSucceeded || result["name"] != "job" {
+		t.Fatalf("Unexpected operation: %+v", op)
+	}
+
+	// A finished operation can't be cancelled.
+	err = client.Request(http.MethodDelete, http.StatusNoContent, location).Do(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "has finished") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestCancelOperation(t *testing.T) {
+	cancelled := make(chan struct{})
+	server, client := newTestServer(t, func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	})
+	defer server.Close()
+
+	op := &Operation{}
+	if err := client.Request(http.MethodPost, http.StatusAccepted, "/jobs").Data(op).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait returns the running operation after timeout.
+	waited := &Operation{}
+	err := client.Request(http.MethodGet, http.StatusOK, op.SelfLink+"/wait").
+		Query("timeout", "10ms").Data(waited).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if waited.Status != StatusRunning {
+		t.Fatalf("Unexpected operation: %+v", waited)
+	}
+
+	if err := client.Request(http.MethodDelete, http.StatusNoContent, op.SelfLink).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("Operation is not cancelled")
+	}
+	op, err = Wait(context.Background(), client, op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Status != StatusCancelled {
+		t.Fatalf("Unexpected operation: %+v", op)
+	}
+
+	err = client.Request(http.MethodGet, http.StatusOK, "/ops/unknown").Do(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestFailedOperation(t *testing.T) {
+	op, err := defaultManager.start(context.Background(), func(ctx context.Context) (interface{}, error) {
+		panic("boom")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	op, err = defaultManager.wait(context.Background(), op.ID, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Status != StatusFailed || !strings.Contains(op.Error, "boom") {
+		t.Fatalf("Unexpected operation: %+v", op)
+	}
+}
+
+func TestServersHaveOwnOperations(t *testing.T) {
+	f := func(ctx context.Context) (interface{}, error) {
+		return nil, nil
+	}
+	first, firstClient := newTestServer(t, f)
+	defer first.Close()
+	second, secondClient := newTestServer(t, f)
+	defer second.Close()
+
+	op := &Operation{}
+	if err := firstClient.Request(http.MethodPost, http.StatusAccepted, "/jobs").Data(op).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Wait(context.Background(), firstClient, op); err != nil {
+		t.Fatal(err)
+	}
+	err := secondClient.Request(http.MethodGet, http.StatusOK, op.SelfLink).Do(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("Operations should not be shared by servers, but got: %v", err)
+	}
+}
+
+func TestOperationTypeName(t *testing.T) {
+	typ := reflect.TypeOf(Operation{})
+	if name := api.TypeName(typ.PkgPath() + "." + typ.Name()); name != api.OperationTypeName {
+		t.Fatalf("api.OperationTypeName should be %s, but got %s", name, api.OperationTypeName)
+	}
+}
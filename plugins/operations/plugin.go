@@ -0,0 +1,232 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/caicloud/nirvana"
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/service"
+)
+
+func init() {
+	nirvana.RegisterConfigInstaller(&operationsInstaller{})
+}
+
+// ExternalConfigName is the external config name of operations.
+const ExternalConfigName = "operations"
+
+// config is operations config.
+type config struct {
+	path       string
+	store      Store
+	maxTimeout time.Duration
+}
+
+type operationsInstaller struct{}
+
+// Name is the external config name.
+func (i *operationsInstaller) Name() string {
+	return ExternalConfigName
+}
+
+// Install installs stuffs before server starting.
+func (i *operationsInstaller) Install(builder service.Builder, cfg *nirvana.Config) error {
+	var err error
+	wrapper(cfg, func(c *config) {
+		if builder.APIStyle() == service.APIStyleRPC {
+			// Async methods only exist in REST style.
+			err = fmt.Errorf("operations plugin does not support API style %s", builder.APIStyle())
+			return
+		}
+		store := c.store
+		if store == nil {
+			store = NewMemoryStore(0)
+		}
+		// Each server has its own operations.
+		m := newManager(c.path, store, c.maxTimeout)
+
+		id := definition.PathParameterFor("operation", "operation id")
+		err = builder.AddDescriptor(definition.Descriptor{
+			Path:        "/",
+			Middlewares: []definition.Middleware{m.middleware},
+		}, definition.Descriptor{
+			Path:        c.path + "/{operation}",
+			Description: "long-running operations",
+			Consumes:    []string{definition.MIMEAll},
+			Produces:    []string{definition.MIMEJSON},
+			Definitions: []definition.Definition{
+				{
+					Method:      definition.Get,
+					Summary:     "Get Operation",
+					Description: "Get the status of an operation.",
+					Parameters:  []definition.Parameter{id},
+					Results:     definition.DataErrorResults("operation"),
+					Function:    m.get,
+				},
+				{
+					Method:      definition.Delete,
+					Summary:     "Cancel Operation",
+					Description: "Cancel a running operation. The operation is still available after cancellation.",
+					Parameters:  []definition.Parameter{id},
+					Results:     []definition.Result{definition.ErrorResult()},
+					Function: func(ctx context.Context, operation string) error {
+						_, err := m.cancel(ctx, operation)
+						return err
+					},
+				},
+			},
+			Children: []definition.Descriptor{{
+				Path: "/wait",
+				Definitions: []definition.Definition{{
+					Method:      definition.Get,
+					Summary:     "Wait Operation",
+					Description: "Wait until an operation is done or timeout, and return the latest status.",
+					Parameters: []definition.Parameter{
+						id,
+						{
+							Source:      definition.Query,
+							Name:        "timeout",
+							Default:     DefaultWaitTimeout.String(),
+							Description: "the duration to wait, such as 30s",
+						},
+					},
+					Results: definition.DataErrorResults("operation"),
+					Function: func(ctx context.Context, operation string, timeout string) (*Operation, error) {
+						duration, err := time.ParseDuration(timeout)
+						if err != nil || duration < 0 {
+							return nil, invalidTimeout.Error(timeout)
+						}
+						return m.wait(ctx, operation, duration)
+					},
+				}},
+			}},
+		})
+	})
+	return err
+}
+
+// Uninstall uninstalls stuffs after server terminating.
+func (i *operationsInstaller) Uninstall(builder service.Builder, cfg *nirvana.Config) error {
+	return nil
+}
+
+// Disable returns a configurer to disable operations.
+func Disable() nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		c.Set(ExternalConfigName, nil)
+		return nil
+	}
+}
+
+// Default returns a configurer to enable operations with default config.
+func Default() nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+		})
+		return nil
+	}
+}
+
+// Path returns a configurer to set the path prefix of operations.
+func Path(path string) nirvana.Configurer {
+	if path == "" {
+		path = "/operations"
+	}
+	path = "/" + strings.Trim(path, "/")
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+			c.path = path
+		})
+		return nil
+	}
+}
+
+// Storage returns a configurer to set the store of operations.
+func Storage(store Store) nirvana.Configurer {
+	if store == nil {
+		store = NewMemoryStore(0)
+	}
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+			c.store = store
+		})
+		return nil
+	}
+}
+
+// MaxWaitTimeout returns a configurer to set the max duration of a wait request.
+func MaxWaitTimeout(timeout time.Duration) nirvana.Configurer {
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+			c.maxTimeout = timeout
+		})
+		return nil
+	}
+}
+
+func wrapper(c *nirvana.Config, f func(c *config)) {
+	conf := c.Config(ExternalConfigName)
+	var cfg *config
+	if conf == nil {
+		// Default config.
+		cfg = &config{
+			path:       "/operations",
+			maxTimeout: time.Minute,
+		}
+	} else {
+		// Panic if config type is wrong.
+		cfg = conf.(*config)
+	}
+	f(cfg)
+	c.Set(ExternalConfigName, cfg)
+}
+
+// Option contains basic configurations of operations.
+type Option struct {
+	Path           string        `desc:"Path prefix of long-running operations"`
+	MaxWaitTimeout time.Duration `desc:"Max duration of a request waiting for an operation"`
+}
+
+// NewDefaultOption creates default option.
+func NewDefaultOption() *Option {
+	return &Option{
+		Path:           "/operations",
+		MaxWaitTimeout: time.Minute,
+	}
+}
+
+// Name returns plugin name.
+func (p *Option) Name() string {
+	return ExternalConfigName
+}
+
+// Configure configures nirvana config via current options.
+func (p *Option) Configure(cfg *nirvana.Config) error {
+	cfg.Configure(
+		Path(p.Path),
+		MaxWaitTimeout(p.MaxWaitTimeout),
+	)
+	return nil
+}
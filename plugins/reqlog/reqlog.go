@@ -18,6 +18,8 @@ package reqlog
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"time"
 
 	"github.com/caicloud/nirvana"
@@ -60,10 +62,21 @@ func (i *reqlogInstaller) Install(builder service.Builder, cfg *nirvana.Config)
 				func(ctx context.Context, next definition.Chain) error {
 					start := time.Now()
 					httpCtx := service.HTTPContextFrom(ctx)
-					begin(httpCtx, nil)
+					// Ids from clients are written to logs, invalid ones
+					// are replaced.
+					id := httpCtx.Request().Header.Get(c.requestKey)
+					if !service.ValidRequestID(id) {
+						id = newRequestID()
+					}
+					ctx = service.WithRequestID(ctx, id)
+					httpCtx.ResponseWriter().Header().Set(c.requestKey, id)
+					begin(httpCtx, map[string]interface{}{
+						requestID: id,
+					})
 
 					err := next.Continue(ctx)
 					end(httpCtx, map[string]interface{}{
+						requestID:        id,
 						intervalDuration: time.Since(start),
 						responseError:    err,
 					})
@@ -75,9 +88,25 @@ func (i *reqlogInstaller) Install(builder service.Builder, cfg *nirvana.Config)
 	return err
 }
 
+// RequestIDFrom gets the request id of current request. It returns an
+// empty string if reqlog is not installed.
+// Handlers can also get it by a parameter like:
+//  definition.PrefabParameterFor("requestID", "")
+func RequestIDFrom(ctx context.Context) string {
+	return service.RequestIDFrom(ctx)
+}
+
+// newRequestID generates an id for requests without valid ones.
+func newRequestID() string {
+	data := make([]byte, 16)
+	_, _ = rand.Read(data)
+	return hex.EncodeToString(data)
+}
+
 type printer func(ctx service.HTTPContext, data map[string]interface{})
 
 const (
+	requestID        = "requestID"
 	intervalDuration = "intervalDuration"
 	responseError    = "responseError"
 )
@@ -115,12 +144,13 @@ func (i *reqlogInstaller) buildPrinters(c *config) (begin printer, end printer)
 	contentLength := func(ctx service.HTTPContext, data map[string]interface{}) interface{} {
 		return ctx.ResponseWriter().ContentLength()
 	}
-	requestID := func(ctx service.HTTPContext, data map[string]interface{}) interface{} {
-		id := ctx.Request().Header.Get(c.requestKey)
-		if id == "" {
-			return nil
+	id := func(ctx service.HTTPContext, data map[string]interface{}) interface{} {
+		if data != nil {
+			if result, ok := data[requestID]; ok {
+				return result
+			}
 		}
-		return id
+		return nil
 	}
 	interval := func(ctx service.HTTPContext, data map[string]interface{}) interface{} {
 		if data != nil {
@@ -143,7 +173,7 @@ func (i *reqlogInstaller) buildPrinters(c *config) (begin printer, end printer)
 	if c.doubleLog {
 		beginning = append(beginning, method, url)
 		if c.requestID {
-			beginning = append(beginning, requestID)
+			beginning = append(beginning, id)
 		}
 		if c.sourceAddr {
 			beginning = append(beginning, clientAddr)
@@ -158,7 +188,7 @@ func (i *reqlogInstaller) buildPrinters(c *config) (begin printer, end printer)
 		url,
 	}
 	if c.requestID {
-		ending = append(ending, requestID)
+		ending = append(ending, id)
 	}
 	if c.sourceAddr {
 		ending = append(ending, clientAddr)
@@ -247,7 +277,8 @@ func RequestID(enable bool) nirvana.Configurer {
 }
 
 // RequestIDKey returns a configurer to set header key
-// of request id.
+// of request id. If a request has no valid id, reqlog
+// generates one and writes it to the header of the response.
 // Defaults to X-Request-Id.
 func RequestIDKey(key string) nirvana.Configurer {
 	if key == "" {
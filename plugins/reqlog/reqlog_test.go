@@ -0,0 +1,109 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reqlog
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/caicloud/nirvana"
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/log"
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/builder"
+)
+
+func TestRequestID(t *testing.T) {
+	cfg := nirvana.NewConfig()
+	cfg.Configure(Logger(&log.SilentLogger{}), RequestID(true))
+	handle := func(ctx context.Context, id string, logger log.Logger) (string, error) {
+		if RequestIDFrom(ctx) != id {
+			t.Errorf("Request id in context should be %q, but got %q", id, RequestIDFrom(ctx))
+		}
+		if logger != service.LoggerFrom(ctx) {
+			t.Errorf("Logger should be the request-scoped logger in context")
+		}
+		return id, nil
+	}
+	b := builder.New(service.APIStyleREST)
+	err := b.AddDescriptor(definition.Descriptor{
+		Path:     "/",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Definitions: []definition.Definition{
+			{
+				Method:   definition.Get,
+				Function: handle,
+				Parameters: []definition.Parameter{
+					definition.PrefabParameterFor("context", ""),
+					definition.PrefabParameterFor("requestID", ""),
+					definition.PrefabParameterFor("logger", ""),
+				},
+				Results: definition.DataErrorResults(""),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (&reqlogInstaller{}).Install(b, cfg); err != nil {
+		t.Fatal(err)
+	}
+	s, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	do := func(id string) (string, string) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/", nil)
+		if id != "" {
+			req.Header.Set("X-Request-Id", id)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body), resp.Header.Get("X-Request-Id")
+	}
+
+	if body, header := do("abc"); body != "abc" || header != "abc" {
+		t.Fatalf("Request id should be abc, but got %q in body and %q in header", body, header)
+	}
+	body, header := do("")
+	if body == "" || body != header {
+		t.Fatalf("Request id should be generated, but got %q in body and %q in header", body, header)
+	}
+	if another, _ := do(""); another == body {
+		t.Fatalf("Generated request ids should be unique, but got %q twice", body)
+	}
+	for _, id := range []string{"bad id", "bad\tid", strings.Repeat("a", 129)} {
+		if body, header := do(id); body == id || body == "" || body != header {
+			t.Fatalf("Invalid request id %q should be replaced, but got %q in body and %q in header", id, body, header)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+
+	"github.com/caicloud/nirvana"
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/errors"
+	"github.com/caicloud/nirvana/service"
+)
+
+func init() {
+	nirvana.RegisterConfigInstaller(&routesInstaller{})
+}
+
+// ExternalConfigName is the external config name of routes.
+const ExternalConfigName = "routes"
+
+var noRoutes = errors.NotImplemented.Build("Nirvana:Routes:NoRoutes", "the service can't list routes")
+
+// config is routes config.
+type config struct {
+	path string
+}
+
+type routesInstaller struct{}
+
+// Name is the external config name.
+func (i *routesInstaller) Name() string {
+	return ExternalConfigName
+}
+
+// Install installs stuffs before server starting.
+func (i *routesInstaller) Install(builder service.Builder, cfg *nirvana.Config) error {
+	var err error
+	wrapper(cfg, func(c *config) {
+		if builder.APIStyle() == service.APIStyleRPC {
+			err = fmt.Errorf("routes plugin does not support API style %s", builder.APIStyle())
+			return
+		}
+		err = builder.AddDescriptor(definition.Descriptor{
+			Path:     c.path,
+			Consumes: []string{definition.MIMEAll},
+			Produces: []string{definition.MIMEJSON, definition.MIMEHTML},
+			Definitions: []definition.Definition{{
+				Method:  definition.Get,
+				Summary: "List routes",
+				Results: definition.DataErrorResults("Routes in the order of matching"),
+				Function: func(ctx context.Context) (Table, error) {
+					lister, ok := service.ServiceFrom(ctx).(service.RouteLister)
+					if !ok {
+						return nil, noRoutes.Error()
+					}
+					return lister.Routes(), nil
+				},
+			}},
+		})
+	})
+	return err
+}
+
+// Uninstall uninstalls stuffs after server terminating.
+func (i *routesInstaller) Uninstall(builder service.Builder, cfg *nirvana.Config) error {
+	return nil
+}
+
+// Table contains routes. It's rendered as a HTML page for "text/html".
+type Table []service.Route
+
+// String renders the table as a HTML page.
+func (t Table) String() string {
+	buf := bytes.NewBuffer(nil)
+	if err := tableTmpl.Execute(buf, t); err != nil {
+		return err.Error()
+	}
+	return buf.String()
+}
+
+var tableTmpl = template.Must(template.New("routes").Parse(`<html>
+<head>
+<title>Routes</title>
+<style>
+td{padding:0 1rem 0 0;vertical-align:top;}
+.shadowed{color:#b00;}
+</style>
+</head>
+<body>
+<table>
+<thead><td>Method</td><td>Path</td><td>Version</td><td>Consumes</td><td>Produces</td><td>Middlewares</td><td>Function</td><td>Shadowed By</td></thead>
+{{range .}}
+	<tr{{if .ShadowedBy}} class="shadowed"{{end}}>
+	<td>{{.Method}}</td>
+	<td>{{.Host}}{{.Path}}{{range $name, $value := .Headers}}<br>{{$name}}: {{$value}}{{end}}</td>
+	<td>{{.Version}}</td>
+	<td>{{range .Consumes}}{{.}}<br>{{end}}</td>
+	<td>{{range .Produces}}{{.}}<br>{{end}}</td>
+	<td>{{.Middlewares}}</td>
+	<td>{{.Function}}<br>{{.File}}#{{.Line}}</td>
+	<td>{{range .ShadowedBy}}{{.}}<br>{{end}}</td>
+	</tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
+
+// Disable returns a configurer to disable routes.
+func Disable() nirvana.Configurer {
+	return func(c *nirvana.Config) error {
+		c.Set(ExternalConfigName, nil)
+		return nil
+	}
+}
+
+// Path returns a configurer to set the path of the route table.
+func Path(path string) nirvana.Configurer {
+	if path == "" {
+		path = "/routes"
+	}
+	return func(c *nirvana.Config) error {
+		wrapper(c, func(c *config) {
+			c.path = path
+		})
+		return nil
+	}
+}
+
+func wrapper(c *nirvana.Config, f func(c *config)) {
+	conf := c.Config(ExternalConfigName)
+	var cfg *config
+	if conf == nil {
+		// Default config.
+		cfg = &config{
+			path: "/routes",
+		}
+	} else {
+		// Panic if config type is wrong.
+		cfg = conf.(*config)
+	}
+	f(cfg)
+	c.Set(ExternalConfigName, cfg)
+}
+
+// Option contains basic configurations of routes.
+type Option struct {
+	// Path is the path of the route table.
+	Path string `desc:"Route table path"`
+}
+
+// NewDefaultOption creates default option.
+func NewDefaultOption() *Option {
+	return &Option{
+		Path: "/routes",
+	}
+}
+
+// Name returns plugin name.
+func (p *Option) Name() string {
+	return ExternalConfigName
+}
+
+// Configure configures nirvana config via current options.
+func (p *Option) Configure(cfg *nirvana.Config) error {
+	cfg.Configure(
+		Path(p.Path),
+	)
+	return nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/caicloud/nirvana"
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/builder"
+)
+
+func TestRoutes(t *testing.T) {
+	cfg := nirvana.NewConfig()
+	cfg.Configure(Path("/debug/routes"))
+	handle := func(ctx context.Context) (string, error) { return "ok", nil }
+	b := builder.New(service.APIStyleREST)
+	b.SetModifier(service.FirstContextParameter())
+	err := b.AddDescriptor(definition.Descriptor{
+		Path:     "/items",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Children: []definition.Descriptor{
+			{
+				Path: "/{id}",
+				Definitions: []definition.Definition{
+					{Method: definition.Get, Function: handle, Results: definition.DataErrorResults("")},
+				},
+			},
+			{
+				Path: "/new",
+				Definitions: []definition.Definition{
+					{Method: definition.Get, Function: handle, Results: definition.DataErrorResults("")},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (&routesInstaller{}).Install(b, cfg); err != nil {
+		t.Fatal(err)
+	}
+	s, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	get := func(accept string) (*http.Response, []byte) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/debug/routes", nil)
+		req.Header.Set("Accept", accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", resp.StatusCode, data)
+		}
+		return resp, data
+	}
+
+	_, data := get(definition.MIMEJSON)
+	routes := Table{}
+	if err := json.Unmarshal(data, &routes); err != nil {
+		t.Fatal(err)
+	}
+	shadowed := map[string]int{}
+	for _, r := range routes {
+		shadowed[r.String()] = len(r.ShadowedBy)
+	}
+	expected := map[string]int{
+		"GET /debug/routes": 0,
+		"GET /items/new":    0,
+		"GET /items/{id}":   1,
+	}
+	for route, n := range expected {
+		got, ok := shadowed[route]
+		if !ok {
+			t.Fatalf("route %s is not listed: %v", route, shadowed)
+		}
+		if got != n {
+			t.Fatalf("route %s is shadowed by %d routes, expected %d", route, got, n)
+		}
+	}
+
+	resp, data := get(definition.MIMEHTML)
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, definition.MIMEHTML) {
+		t.Fatalf("unexpected content type %s", ct)
+	}
+	if !strings.Contains(string(data), `class="shadowed"`) || !strings.Contains(string(data), "/items/{id}") {
+		t.Fatalf("unexpected page: %s", data)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+
+	"github.com/caicloud/nirvana/service"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	tlog "github.com/opentracing/opentracing-go/log"
+)
+
+// observer creates child spans of the request span for phases of executing
+// definitions. Spans are created after phases finish, with their start and
+// finish time.
+type observer struct{}
+
+// ParameterGenerated creates a span for generating a parameter.
+func (o *observer) ParameterGenerated(ctx context.Context, ob *service.Observation) {
+	span := o.span(ctx, ob, "parameter "+ob.Source+":"+ob.Name)
+	if span == nil {
+		return
+	}
+	span.SetTag("nirvana.parameter", ob.Name)
+	span.SetTag("nirvana.source", ob.Source)
+	o.finish(span, ob)
+}
+
+// OperatorApplied creates a span for applying an operator.
+func (o *observer) OperatorApplied(ctx context.Context, ob *service.Observation) {
+	span := o.span(ctx, ob, "operator "+ob.Operator+":"+ob.Name)
+	if span == nil {
+		return
+	}
+	span.SetTag("nirvana.operator", ob.Operator)
+	o.finish(span, ob)
+}
+
+// FunctionCalled creates a span for calling the function.
+func (o *observer) FunctionCalled(ctx context.Context, ob *service.Observation) {
+	span := o.span(ctx, ob, "function "+ob.Name)
+	if span == nil {
+		return
+	}
+	span.SetTag("nirvana.function", ob.Name)
+	o.finish(span, ob)
+}
+
+// ResultHandled creates a span for handling a result.
+func (o *observer) ResultHandled(ctx context.Context, ob *service.Observation) {
+	span := o.span(ctx, ob, "result "+ob.Name)
+	if span == nil {
+		return
+	}
+	span.SetTag("nirvana.destination", ob.Name)
+	o.finish(span, ob)
+}
+
+// span starts a child span of the request span. It returns nil if there
+// is no request span in ctx.
+func (o *observer) span(ctx context.Context, ob *service.Observation, name string) opentracing.Span {
+	parent := opentracing.SpanFromContext(ctx)
+	if parent == nil {
+		return nil
+	}
+	span := parent.Tracer().StartSpan(name, opentracing.ChildOf(parent.Context()), opentracing.StartTime(ob.Start))
+	span.SetTag("nirvana.phase", string(ob.Phase))
+	return span
+}
+
+// finish marks the error and finishes a span at the end of the phase.
+func (o *observer) finish(span opentracing.Span, ob *service.Observation) {
+	if ob.Err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(tlog.Error(ob.Err))
+	}
+	span.FinishWithOptions(opentracing.FinishOptions{FinishTime: ob.Start.Add(ob.Duration)})
+}
@@ -19,6 +19,7 @@ package tracing
 import (
 	"context"
 	"io"
+	"reflect"
 	"time"
 
 	"github.com/caicloud/nirvana"
@@ -33,6 +34,9 @@ import (
 
 func init() {
 	nirvana.RegisterConfigInstaller(&tracingInstaller{})
+	if err := service.RegisterPrefab(&spanPrefab{}); err != nil {
+		panic(err)
+	}
 }
 
 // ExternalConfigName is the external config name of tracing.
@@ -79,6 +83,10 @@ func (i *tracingInstaller) Install(builder service.Builder, cfg *nirvana.Config)
 			}
 		}
 		err = builder.AddDescriptor(descriptor(c.tracer, c.hook))
+		// Create child spans for phases of executing definitions.
+		if observable, ok := builder.(service.ObservableBuilder); ok {
+			observable.AddObserver(&observer{})
+		}
 	})
 	return err
 
@@ -214,6 +222,30 @@ func middleware(tracer opentracing.Tracer, hook Hook) definition.Middleware {
 	}
 }
 
+// spanPrefab returns the active span of current request. If tracing is not
+// installed, a noop span is returned.
+// Handlers can get it by a parameter like:
+//  definition.PrefabParameterFor("span", "")
+type spanPrefab struct{}
+
+// Name returns prefab name.
+func (p *spanPrefab) Name() string {
+	return "span"
+}
+
+// Type is type of opentracing.Span.
+func (p *spanPrefab) Type() reflect.Type {
+	return reflect.TypeOf((*opentracing.Span)(nil)).Elem()
+}
+
+// Make returns the span from context.
+func (p *spanPrefab) Make(ctx context.Context) (interface{}, error) {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		return span, nil
+	}
+	return opentracing.NoopTracer{}.StartSpan(""), nil
+}
+
 type loggerAdapter struct {
 	logger log.Logger
 }
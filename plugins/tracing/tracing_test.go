@@ -21,10 +21,13 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/caicloud/nirvana"
 	"github.com/caicloud/nirvana/definition"
+	"github.com/opentracing/opentracing-go/mocktracer"
 )
 
 var test = definition.Descriptor{
@@ -81,3 +84,53 @@ func TestMiddleware(t *testing.T) {
 		t.Fatalf(`response string expected "success" but got "%s"`, string(b))
 	}
 }
+
+func TestObserver(t *testing.T) {
+	tracer := mocktracer.New()
+	config := nirvana.NewDefaultConfig().
+		Configure(
+			CustomTracer(tracer),
+			nirvana.Descriptor(test),
+		)
+	build, cleaner, err := nirvana.NewServer(config).Builder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = cleaner(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	service, err := build.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	spans := tracer.FinishedSpans()
+	root := spans[len(spans)-1]
+	var names []string
+	for _, span := range spans[:len(spans)-1] {
+		if span.ParentID != root.SpanContext.SpanID {
+			t.Fatalf("Span %s should be a child of the request span", span.OperationName)
+		}
+		if span.StartTime.Before(root.StartTime) || span.FinishTime.After(root.FinishTime) {
+			t.Fatalf("Span %s should be in the time range of the request span", span.OperationName)
+		}
+		name := span.OperationName
+		if strings.HasPrefix(name, "function ") {
+			// Names of anonymous functions depend on the compiler.
+			name = "function"
+		}
+		names = append(names, name)
+	}
+	expected := []string{
+		"parameter Prefab:context",
+		"function",
+		"result Error",
+		"result Data",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Phases should be traced as %v, but got %v", expected, names)
+	}
+}
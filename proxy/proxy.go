@@ -0,0 +1,341 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package proxy forwards requests under a path prefix to upstream services:
+//
+//  proxy.Descriptor("/legacy", proxy.Config{
+//      Upstreams: []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"},
+//      Rewrite:   []project.PathRule{{Prefix: "/legacy/v1", Replacement: "/api/v1"}},
+//      Timeout:   10 * time.Second,
+//      Retries:   2,
+//  })
+//
+// The proxy is mounted by definition.Mount, so middlewares of descriptors
+// (auth, metrics, tracing, reqlog, etc.) still run in front of it.
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/errors"
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/utils/project"
+)
+
+// Config describes how to forward requests.
+type Config struct {
+	// Upstreams are URLs of upstream services, such as "http://10.0.0.1:8080/base".
+	// Paths of requests are appended to paths of upstreams.
+	Upstreams []string
+	// Resolver resolves upstreams for every request. It's used if Upstreams
+	// is empty.
+	Resolver Resolver
+	// Rewrite contains rules to rewrite paths of requests. The first rule
+	// which matches the full path of a request replaces it. If no rule
+	// matches, the path under the prefix of the descriptor is forwarded.
+	Rewrite []project.PathRule
+	// SetHeaders contains headers which are set into forwarded requests.
+	SetHeaders map[string]string
+	// RemoveHeaders contains headers which are removed from forwarded requests.
+	RemoveHeaders []string
+	// Timeout limits the time to wait for response headers of each attempt.
+	// Zero means no timeout.
+	Timeout time.Duration
+	// Retries is the max number of retries when upstreams can't be reached.
+	// Only requests without bodies and with idempotent methods or header
+	// "Idempotency-Key" are retried. Each retry uses the next upstream.
+	Retries int
+	// Transport is used to send requests to upstreams. If it's nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+}
+
+// Proxy is an http handler which forwards requests to upstreams.
+type Proxy struct {
+	// next is the counter for round-robin load balancing. It's the first
+	// field to be 64-bit aligned for atomic operations.
+	next          uint64
+	resolver      Resolver
+	rewrite       []project.PathRule
+	setHeaders    map[string]string
+	removeHeaders []string
+	timeout       time.Duration
+	retries       int
+	transport     http.RoundTripper
+	reverse       *httputil.ReverseProxy
+}
+
+// New creates a proxy.
+func New(c Config) (*Proxy, error) {
+	p := &Proxy{
+		resolver:      c.Resolver,
+		setHeaders:    make(map[string]string, len(c.SetHeaders)),
+		removeHeaders: make([]string, len(c.RemoveHeaders)),
+		timeout:       c.Timeout,
+		retries:       c.Retries,
+		transport:     c.Transport,
+	}
+	if len(c.Upstreams) > 0 {
+		resolver, err := Static(c.Upstreams...)
+		if err != nil {
+			return nil, err
+		}
+		p.resolver = resolver
+	}
+	if p.resolver == nil {
+		return nil, noUpstreams.Error()
+	}
+	p.rewrite = make([]project.PathRule, len(c.Rewrite))
+	copy(p.rewrite, c.Rewrite)
+	for i := range p.rewrite {
+		if err := p.rewrite[i].Validate(); err != nil {
+			return nil, invalidRewriteRule.Error(p.rewrite[i].Regexp, err.Error())
+		}
+	}
+	for name, value := range c.SetHeaders {
+		p.setHeaders[textproto.CanonicalMIMEHeaderKey(name)] = value
+	}
+	for i, name := range c.RemoveHeaders {
+		p.removeHeaders[i] = textproto.CanonicalMIMEHeaderKey(name)
+	}
+	if p.retries < 0 {
+		p.retries = 0
+	}
+	if p.transport == nil {
+		p.transport = http.DefaultTransport
+	}
+	p.reverse = &httputil.ReverseProxy{
+		Director:  p.direct,
+		Transport: roundTripperFunc(p.roundTrip),
+		// Flush immediately to stream response bodies.
+		FlushInterval: -1,
+		ErrorHandler:  p.handleError,
+	}
+	return p, nil
+}
+
+// Descriptor creates a REST descriptor which forwards all requests under
+// prefix to upstreams. It panics if the config is invalid.
+func Descriptor(prefix string, c Config) definition.Descriptor {
+	p, err := New(c)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid proxy config for %s: %s", prefix, err.Error()))
+	}
+	return definition.Mount(prefix, p)
+}
+
+// ServeHTTP forwards a request to an upstream.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.reverse.ServeHTTP(w, r)
+}
+
+// direct rewrites the path and headers of a forwarded request. The scheme
+// and host are set by roundTrip for every attempt.
+func (p *Proxy) direct(req *http.Request) {
+	if len(p.rewrite) > 0 {
+		path, escaped := req.URL.Path, req.URL.EscapedPath()
+		if httpCtx := service.HTTPContextFrom(req.Context()); httpCtx != nil {
+			// The request is stripped by the mount. Rules match full paths.
+			path, escaped = httpCtx.Request().URL.Path, httpCtx.Request().URL.EscapedPath()
+		}
+		for i := range p.rewrite {
+			if p.rewrite[i].Check(path) {
+				req.URL.Path = p.rewrite[i].Replace(path)
+				req.URL.RawPath = ""
+				if escaped != path && p.rewrite[i].Check(escaped) {
+					// Keep escaped characters such as "%2F". The raw path is
+					// ignored if it's not an encoding of the path.
+					req.URL.RawPath = p.rewrite[i].Replace(escaped)
+				}
+				break
+			}
+		}
+	}
+	for _, name := range p.removeHeaders {
+		req.Header.Del(name)
+	}
+	for name, value := range p.setHeaders {
+		req.Header.Set(name, value)
+	}
+	if req.Header.Get("X-Forwarded-Host") == "" {
+		req.Header.Set("X-Forwarded-Host", req.Host)
+	}
+	if _, ok := req.Header["User-Agent"]; !ok {
+		// Don't let the transport add the default user agent.
+		req.Header.Set("User-Agent", "")
+	}
+}
+
+// roundTrip sends a request to upstreams in round-robin order. Failed
+// attempts of retryable requests are retried with the next upstream.
+func (p *Proxy) roundTrip(req *http.Request) (*http.Response, error) {
+	upstreams, err := p.resolver.Resolve(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	if len(upstreams) <= 0 {
+		return nil, noUpstreams.Error()
+	}
+	attempts := 1
+	if retryable(req) {
+		attempts += p.retries
+	}
+	for i := 0; ; i++ {
+		upstream := upstreams[atomic.AddUint64(&p.next, 1)%uint64(len(upstreams))]
+		out := req.Clone(req.Context())
+		out.URL.Scheme = upstream.Scheme
+		out.URL.Host = upstream.Host
+		out.URL.Path, out.URL.RawPath = joinURLPath(upstream, req.URL)
+		if upstream.RawQuery != "" && req.URL.RawQuery != "" {
+			out.URL.RawQuery = upstream.RawQuery + "&" + req.URL.RawQuery
+		} else if upstream.RawQuery != "" {
+			out.URL.RawQuery = upstream.RawQuery
+		}
+		out.Host = ""
+		resp, err := p.attempt(out)
+		if err == nil || i+1 >= attempts || req.Context().Err() != nil {
+			return resp, err
+		}
+		service.LoggerFrom(req.Context()).Warningf("Retry request to %s because %s", upstream.Host, err.Error())
+	}
+}
+
+// attempt sends a request to an upstream with the timeout.
+func (p *Proxy) attempt(req *http.Request) (*http.Response, error) {
+	if p.timeout <= 0 {
+		return p.transport.RoundTrip(req)
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	var expired int32
+	timer := time.AfterFunc(p.timeout, func() {
+		atomic.StoreInt32(&expired, 1)
+		cancel()
+	})
+	resp, err := p.transport.RoundTrip(req.WithContext(ctx))
+	if !timer.Stop() && atomic.LoadInt32(&expired) == 1 {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		cancel()
+		return nil, upstreamTimeout.Error(req.URL.Host, p.timeout.String())
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The context is canceled when the body is closed.
+	resp.Body = &cancelableBody{resp.Body, cancel}
+	return resp, nil
+}
+
+// handleError writes errors of forwarding into responses.
+func (p *Proxy) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	if _, ok := err.(errors.ExternalError); !ok {
+		if r.Context().Err() == context.Canceled {
+			// The client has gone away.
+			return
+		}
+		err = badGateway.Error(err.Error())
+	}
+	if service.HTTPContextFrom(r.Context()) == nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	if err := service.WriteError(r.Context(), service.AllProducers(), err); err != nil {
+		service.LoggerFrom(r.Context()).Error(err)
+	}
+}
+
+// retryable checks if a request can be sent again. Requests with bodies
+// can't be retried because the bodies are streamed.
+func retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.ContentLength != 0 {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get(definition.HeaderIdempotencyKey) != ""
+}
+
+// joinURLPath joins paths of urls like singleJoiningSlash. Escaped paths are
+// joined too, so that escaped characters such as "%2F" are kept.
+func joinURLPath(a, b *url.URL) (path, rawpath string) {
+	if a.RawPath == "" && b.RawPath == "" {
+		return singleJoiningSlash(a.Path, b.Path), ""
+	}
+	apath := a.EscapedPath()
+	bpath := b.EscapedPath()
+	aslash := strings.HasSuffix(apath, "/")
+	bslash := strings.HasPrefix(bpath, "/")
+	switch {
+	case aslash && bslash:
+		return a.Path + b.Path[1:], apath + bpath[1:]
+	case !aslash && !bslash:
+		return a.Path + "/" + b.Path, apath + "/" + bpath
+	}
+	return a.Path + b.Path, apath + bpath
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls the function.
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// cancelableBody cancels the context of a request when it's closed.
+type cancelableBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the context.
+func (b *cancelableBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+var (
+	noUpstreams        = errors.ServiceUnavailable.Build("Nirvana:Proxy:NoUpstreams", "no upstream is available")
+	invalidUpstream    = errors.InternalServerError.Build("Nirvana:Proxy:invalidUpstream", "upstream ${url} is invalid")
+	invalidRewriteRule = errors.InternalServerError.Build("Nirvana:Proxy:invalidRewriteRule", "rewrite rule ${regexp} is invalid: ${reason}")
+	badGateway         = errors.BadGateway.Build("Nirvana:Proxy:BadGateway", "can't forward request: ${reason}")
+	upstreamTimeout    = errors.GatewayTimeout.Build("Nirvana:Proxy:UpstreamTimeout", "upstream ${host} does not respond in ${timeout}")
+)
@@ -0,0 +1,179 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/service/rest"
+	"github.com/caicloud/nirvana/utils/project"
+)
+
+func newServer(t *testing.T, descriptor definition.Descriptor) *httptest.Server {
+	builder := rest.NewBuilder()
+	if err := builder.AddDescriptor(descriptor); err != nil {
+		t.Fatal(err)
+	}
+	s, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(s)
+}
+
+func request(t *testing.T, method, url string, header http.Header) (int, string) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+func TestProxy(t *testing.T) {
+	upstreams := make([]string, 2)
+	for i := range upstreams {
+		name := fmt.Sprintf("upstream%d", i)
+		u := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "%s %s %s %s token=%q", name, r.Method, r.URL.RequestURI(), r.Header.Get("X-Tenant"), r.Header.Get("X-Token"))
+		}))
+		defer u.Close()
+		upstreams[i] = u.URL + "/base"
+	}
+	middlewares := 0
+	d := Descriptor("/legacy", Config{
+		Upstreams:     upstreams,
+		Rewrite:       []project.PathRule{{Prefix: "/legacy/v1", Replacement: "/api/v1"}},
+		SetHeaders:    map[string]string{"x-tenant": "acme"},
+		RemoveHeaders: []string{"X-Token"},
+	})
+	d.Middlewares = []definition.Middleware{
+		func(ctx context.Context, chain definition.Chain) error {
+			middlewares++
+			return chain.Continue(ctx)
+		},
+	}
+	s := newServer(t, d)
+	defer s.Close()
+
+	for _, test := range []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/legacy/v1/apps?limit=1", `upstream1 GET /base/api/v1/apps?limit=1 acme token=""`},
+		{http.MethodDelete, "/legacy/files/a.txt", `upstream0 DELETE /base/files/a.txt acme token=""`},
+		{http.MethodGet, "/legacy", `upstream1 GET /base/ acme token=""`},
+		// Escaped slashes are forwarded as they are.
+		{http.MethodGet, "/legacy/v1/apps/a%2Fb", `upstream0 GET /base/api/v1/apps/a%2Fb acme token=""`},
+		{http.MethodGet, "/legacy/files/a%2Fb.txt", `upstream1 GET /base/files/a%2Fb.txt acme token=""`},
+	} {
+		code, body := request(t, test.method, s.URL+test.path, http.Header{"X-Token": {"secret"}})
+		if code != http.StatusOK || body != test.body {
+			t.Fatalf("%s %s should respond %q, but got %d %q", test.method, test.path, test.body, code, body)
+		}
+	}
+	if middlewares != 5 {
+		t.Fatalf("Middlewares should be executed 5 times, but got %d", middlewares)
+	}
+}
+
+func TestProxyRetries(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	down.Close()
+
+	s := newServer(t, Descriptor("/", Config{
+		Upstreams: []string{down.URL, up.URL},
+		Retries:   1,
+	}))
+	defer s.Close()
+	for i := 0; i < 4; i++ {
+		if code, body := request(t, http.MethodGet, s.URL+"/ping", nil); code != http.StatusOK || body != "ok" {
+			t.Fatalf("GET should be retried, but got %d %q", code, body)
+		}
+	}
+	failures := 0
+	for i := 0; i < 4; i++ {
+		if code, _ := request(t, http.MethodPost, s.URL+"/ping", nil); code == http.StatusBadGateway {
+			failures++
+		}
+	}
+	if failures != 2 {
+		t.Fatalf("POST without idempotency key should not be retried, but got %d failures", failures)
+	}
+	for i := 0; i < 4; i++ {
+		header := http.Header{definition.HeaderIdempotencyKey: {fmt.Sprint(i)}}
+		if code, _ := request(t, http.MethodPost, s.URL+"/ping", header); code != http.StatusOK {
+			t.Fatalf("POST with idempotency key should be retried, but got %d", code)
+		}
+	}
+}
+
+func TestProxyTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	s := newServer(t, Descriptor("/", Config{
+		Upstreams: []string{slow.URL},
+		Timeout:   50 * time.Millisecond,
+	}))
+	defer s.Close()
+	code, body := request(t, http.MethodGet, s.URL+"/slow", nil)
+	if code != http.StatusGatewayTimeout || !strings.Contains(body, "UpstreamTimeout") {
+		t.Fatalf("Slow upstream should cause 504, but got %d %q", code, body)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, c := range []Config{
+		{},
+		{Upstreams: []string{"10.0.0.1:8080"}},
+		{Upstreams: []string{"http://10.0.0.1"}, Rewrite: []project.PathRule{{Regexp: "("}}},
+	} {
+		if _, err := New(c); err == nil {
+			t.Fatalf("Config %+v should be invalid", c)
+		}
+	}
+}
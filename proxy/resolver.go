@@ -0,0 +1,52 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"context"
+	"net/url"
+)
+
+// Resolver resolves upstreams of a proxy. It's called for every request, so
+// implementations (such as service discovery clients) should cache results.
+type Resolver interface {
+	// Resolve returns URLs of available upstreams.
+	Resolve(ctx context.Context) ([]*url.URL, error)
+}
+
+// ResolverFunc is a function which implements Resolver.
+type ResolverFunc func(ctx context.Context) ([]*url.URL, error)
+
+// Resolve calls the function.
+func (f ResolverFunc) Resolve(ctx context.Context) ([]*url.URL, error) {
+	return f(ctx)
+}
+
+// Static creates a resolver which always returns the upstreams.
+func Static(upstreams ...string) (Resolver, error) {
+	urls := make([]*url.URL, len(upstreams))
+	for i, upstream := range upstreams {
+		u, err := url.Parse(upstream)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, invalidUpstream.Error(upstream)
+		}
+		urls[i] = u
+	}
+	return ResolverFunc(func(ctx context.Context) ([]*url.URL, error) {
+		return urls, nil
+	}), nil
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// CachedResponse is a response stored in a cache.
+type CachedResponse struct {
+	// StatusCode is the status code of the response.
+	StatusCode int
+	// Header contains response headers. It must contain "ETag" or "Last-Modified".
+	Header http.Header
+	// Body is the response body.
+	Body []byte
+}
+
+// Cache stores responses of GET requests. Cached responses are revalidated
+// by conditional requests.
+type Cache interface {
+	// Get gets a response by key.
+	Get(key string) (*CachedResponse, bool)
+	// Set stores a response with key.
+	Set(key string, resp *CachedResponse)
+}
+
+// memoryCache is a cache in memory. The oldest response is evicted when
+// the cache is full.
+type memoryCache struct {
+	lock      sync.Mutex
+	capacity  int
+	keys      []string
+	responses map[string]*CachedResponse
+}
+
+// NewMemoryCache creates a cache in memory which stores at most capacity responses.
+func NewMemoryCache(capacity int) Cache {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &memoryCache{
+		capacity:  capacity,
+		responses: make(map[string]*CachedResponse),
+	}
+}
+
+// Get gets a response by key.
+func (c *memoryCache) Get(key string) (*CachedResponse, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	resp, ok := c.responses[key]
+	return resp, ok
+}
+
+// Set stores a response with key.
+func (c *memoryCache) Set(key string, resp *CachedResponse) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.responses[key]; !ok {
+		if len(c.keys) >= c.capacity {
+			delete(c.responses, c.keys[0])
+			c.keys = c.keys[1:]
+		}
+		c.keys = append(c.keys, key)
+	}
+	c.responses[key] = resp
+}
+
+// conditionalExecutor sends conditional GET requests with validators of
+// cached responses and serves cached responses for status code 304.
+type conditionalExecutor struct {
+	executor RequestExecutor
+	cache    Cache
+}
+
+func (e *conditionalExecutor) key(req *http.Request) string {
+	return req.URL.String() + "\n" + req.Header.Get("Accept") + "\n" + req.Header.Get("Accept-Version")
+}
+
+// Do executes a request.
+func (e *conditionalExecutor) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		// Conditional requests set by users are not touched.
+		return e.executor.Do(req)
+	}
+	key := e.key(req)
+	cached, ok := e.cache.Get(key)
+	if ok {
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := cached.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+	resp, err := e.executor.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if ok && resp.StatusCode == http.StatusNotModified {
+		_ = resp.Body.Close()
+		header := http.Header{}
+		for k, v := range cached.Header {
+			header[k] = v
+		}
+		// Headers in 304 response override cached ones.
+		for k, v := range resp.Header {
+			header[k] = v
+		}
+		resp.StatusCode = cached.StatusCode
+		resp.Status = http.StatusText(cached.StatusCode)
+		resp.Header = header
+		resp.ContentLength = int64(len(cached.Body))
+		resp.Body = ioutil.NopCloser(bytes.NewReader(cached.Body))
+		return resp, nil
+	}
+	if resp.StatusCode == http.StatusOK && (resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "") {
+		body, err := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		e.cache.Set(key, &CachedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       body,
+		})
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return resp, nil
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConditionalGet(t *testing.T) {
+	notModified := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"test"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(&Config{
+		Host:  strings.TrimPrefix(server.URL, "http://"),
+		Cache: NewMemoryCache(10),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		result := map[string]string{}
+		if err := client.Request(http.MethodGet, http.StatusOK, "/test").Data(&result).Do(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if result["name"] != "test" {
+			t.Fatalf("Unexpected result: %v", result)
+		}
+	}
+	if notModified != 1 {
+		t.Fatalf("The second request should be revalidated, but got %d not modified responses", notModified)
+	}
+}
@@ -34,6 +34,15 @@ type Config struct {
 	// Executor is used to execute http requests.
 	// If it is empty, http.DefaultClient is used.
 	Executor RequestExecutor
+	// Cache enables conditional GET requests. Responses with "ETag" or
+	// "Last-Modified" are cached and revalidated by "If-None-Match" and
+	// "If-Modified-Since". If it is empty, responses are not cached.
+	Cache Cache
+	// Retries is the number of times to retry a request failed with network
+	// errors. POST and PATCH requests are sent with a generated "Idempotency-Key"
+	// header if they don't have one, and all retries share the key. So servers
+	// with idempotency support process them only once.
+	Retries int
 }
 
 // DeepCopy returns a new config copied from the current one.
@@ -81,6 +81,7 @@ func (u *parsedURL) deepCopyQueries() map[string][]string {
 type Client struct {
 	endpoint       string
 	config         *Config
+	executor       RequestExecutor
 	lock           sync.RWMutex
 	parsedURLCache map[string]parsedURL
 }
@@ -94,8 +95,15 @@ func NewClient(cfg *Config) (*Client, error) {
 	client := &Client{
 		endpoint:       fmt.Sprintf("%s://%s/", cfg.Scheme, strings.TrimRight(cfg.Host, "/\\")),
 		config:         cfg,
+		executor:       cfg.Executor,
 		parsedURLCache: make(map[string]parsedURL),
 	}
+	if cfg.Retries > 0 {
+		client.executor = &retryExecutor{executor: client.executor, retries: cfg.Retries}
+	}
+	if cfg.Cache != nil {
+		client.executor = &conditionalExecutor{executor: client.executor, cache: cfg.Cache}
+	}
 	return client, nil
 }
 
@@ -164,7 +172,7 @@ func (c *Client) Request(method string, code int, url string) *Request {
 		code:     code,
 		endpoint: c.endpoint,
 		path:     path,
-		client:   c.config.Executor,
+		client:   c.executor,
 		paths:    map[string]string{},
 		queries:  queries,
 		headers:  map[string][]string{},
@@ -192,6 +200,9 @@ type Request struct {
 	bodyContentType string
 	meta            map[string]string
 	data            interface{}
+	// envelope is the field which contains data or error messages in
+	// wrapped bodies. Bodies are not wrapped if it's empty.
+	envelope string
 }
 
 func toString(value interface{}) string {
@@ -242,7 +253,13 @@ func (r *Request) File(name string, file interface{}) *Request {
 	return r
 }
 
-// Body sets body parameter.
+// contentTyper is implemented by body values which carry their own content type.
+type contentTyper interface {
+	ContentType() string
+}
+
+// Body sets body parameter. If value has a method "ContentType() string"
+// returning a non-empty content type, the content type is used instead.
 func (r *Request) Body(contentType string, value interface{}) *Request {
 	r.body = value
 	r.bodyContentType = contentType
@@ -269,6 +286,13 @@ func (r *Request) TOPRPCData(value interface{}) *Request {
 	return r
 }
 
+// Envelope unwraps JSON and XML bodies of responses. field is the name of
+// the field which contains data or error messages in wrapped bodies.
+func (r *Request) Envelope(field string) *Request {
+	r.envelope = field
+	return r
+}
+
 // Data sets body result. value must be a pointer.
 func (r *Request) Data(value interface{}) *Request {
 	r.data = value
@@ -314,6 +338,10 @@ func (r *Request) request(ctx context.Context) (*http.Request, error) {
 		path += "?" + urlVal.Encode()
 	}
 	contentType := r.bodyContentType
+	if typed, ok := r.body.(contentTyper); ok && typed.ContentType() != "" {
+		// Body such as a patch knows its content type.
+		contentType = typed.ContentType()
+	}
 	buf := bytes.NewBuffer(nil)
 	reader := io.Reader(buf)
 	if r.body != nil {
@@ -322,7 +350,7 @@ func (r *Request) request(ctx context.Context) (*http.Request, error) {
 		} else {
 			// Write body to buffer.
 			switch contentType {
-			case definition.MIMEJSON:
+			case definition.MIMEJSON, definition.MIMEMergePatch, definition.MIMEJSONPatch:
 				err = json.NewEncoder(buf).Encode(r.body)
 			case definition.MIMEXML:
 				err = xml.NewEncoder(buf).Encode(r.body)
@@ -427,6 +455,9 @@ func (r *Request) finish(_ context.Context, _ *http.Request, resp *http.Response
 			if err != nil {
 				return invalidContentType.Error(ct, r.path.String(), err.Error())
 			}
+			if base, _, _, ok := definition.ParseVendorMediaType(contentType); ok {
+				contentType = base
+			}
 			switch target := r.data.(type) {
 			case *io.Reader:
 				*target = reader
@@ -445,6 +476,29 @@ func (r *Request) finish(_ context.Context, _ *http.Request, resp *http.Response
 				}
 				*target = string(data)
 			default:
+				if r.envelope != "" && (contentType == definition.MIMEJSON || contentType == definition.MIMEXML) {
+					data, err := ioutil.ReadAll(reader)
+					if err != nil {
+						return unreadableBody.Error(r.path.String(), err.Error())
+					}
+					data, err = unwrap(contentType, data, r.envelope)
+					if err != nil {
+						return unreadableBody.Error(r.path.String(), err.Error())
+					}
+					if data == nil {
+						// The envelope has no data.
+						return nil
+					}
+					if contentType == definition.MIMEXML {
+						err = xml.Unmarshal(data, r.data)
+					} else {
+						err = json.Unmarshal(data, r.data)
+					}
+					if err != nil {
+						return unreadableBody.Error(r.path.String(), err.Error())
+					}
+					return nil
+				}
 				switch contentType {
 				case definition.MIMEJSON:
 					if err := json.NewDecoder(reader).Decode(r.data); err != nil {
@@ -464,17 +518,29 @@ func (r *Request) finish(_ context.Context, _ *http.Request, resp *http.Response
 		if err != nil {
 			return invalidContentType.Error(ct, r.path.String(), err.Error())
 		}
+		if base, _, _, ok := definition.ParseVendorMediaType(contentType); ok {
+			contentType = base
+		}
 		// Unmarshal body to error.
 		data, err := ioutil.ReadAll(reader)
 		if err != nil {
 			return unreadableBody.Error(r.path.String(), err.Error())
 		}
+		if r.envelope != "" {
+			// Errors which are not wrapped (such as errors from proxies)
+			// are parsed as they are.
+			if msg, err := unwrap(contentType, data, r.envelope); err == nil && msg != nil {
+				data = msg
+			}
+		}
 		dt := errors.DataTypePlain
 		switch contentType {
 		case definition.MIMEJSON:
 			dt = errors.DataTypeJSON
 		case definition.MIMEXML:
 			dt = errors.DataTypeXML
+		case definition.MIMEProblemJSON:
+			dt = errors.DataTypeProblem
 		}
 		e, err := errors.ParseError(resp.StatusCode, dt, data)
 		if err != nil {
@@ -485,6 +551,51 @@ func (r *Request) finish(_ context.Context, _ *http.Request, resp *http.Response
 	return nil
 }
 
+// rawField keeps an element of a wrapped XML body as it is.
+type rawField struct {
+	name string
+	data []byte
+}
+
+// UnmarshalXML keeps the xml of the field, including the field element.
+func (f *rawField) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var inner struct {
+		Data []byte `xml:",innerxml"`
+	}
+	if err := d.DecodeElement(&inner, &start); err != nil {
+		return err
+	}
+	f.name = start.Name.Local
+	f.data = []byte("<" + start.Name.Local + ">" + string(inner.Data) + "</" + start.Name.Local + ">")
+	return nil
+}
+
+// unwrap returns a field of a wrapped JSON or XML body. It returns nil if
+// the body has no such field. XML fields include their elements.
+func unwrap(contentType string, data []byte, field string) ([]byte, error) {
+	switch contentType {
+	case definition.MIMEJSON:
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		return fields[field], nil
+	case definition.MIMEXML:
+		var body struct {
+			Fields []rawField `xml:",any"`
+		}
+		if err := xml.Unmarshal(data, &body); err != nil {
+			return nil, err
+		}
+		for _, f := range body.Fields {
+			if f.name == field {
+				return f.data, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
 type autocloser struct {
 	io.ReadCloser
 }
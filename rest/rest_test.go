@@ -17,8 +17,16 @@ limitations under the License.
 package rest
 
 import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/service"
 )
 
 func TestClient_parseURL(t *testing.T) {
@@ -117,3 +125,114 @@ func TestClient_parseURL(t *testing.T) {
 		}
 	}
 }
+
+func TestPatchBody(t *testing.T) {
+	var contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		data, _ := ioutil.ReadAll(r.Body)
+		body = strings.TrimSpace(string(data))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(&Config{
+		Host: strings.TrimPrefix(server.URL, "http://"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch, err := service.NewJSONPatch(service.PatchOperation{Op: service.PatchOperationRemove, Path: "/name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Request(http.MethodPatch, http.StatusOK, "/test").
+		Body(definition.MIMEMergePatch, patch).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != definition.MIMEJSONPatch || body != `[{"op":"remove","path":"/name"}]` {
+		t.Fatalf("Unexpected patch request: %s %s", contentType, body)
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", definition.MIMEJSON)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":200,"message":"OK","data":{"name":"test"}}`))
+		case "/xml":
+			w.Header().Set("Content-Type", definition.MIMEXML)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`<response><code>200</code><message>OK</message><data><name>test</name></data></response>`))
+		default:
+			w.Header().Set("Content-Type", definition.MIMEJSON)
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":404,"message":"test not found","data":{"reason":"Test:NotFound","message":"test not found"}}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(&Config{
+		Host: strings.TrimPrefix(server.URL, "http://"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type item struct {
+		Name string `json:"name" xml:"name"`
+	}
+	for _, path := range []string{"/json", "/xml"} {
+		result := item{}
+		err = client.Request(http.MethodGet, http.StatusOK, path).
+			Envelope("data").
+			Data(&result).
+			Do(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Name != "test" {
+			t.Fatalf("%s should be unwrapped, but got %+v", path, result)
+		}
+	}
+	err = client.Request(http.MethodGet, http.StatusOK, "/error").
+		Envelope("data").
+		Data(&item{}).
+		Do(context.Background())
+	e, ok := err.(interface {
+		Code() int
+		Reason() string
+	})
+	if !ok || e.Code() != http.StatusNotFound || e.Reason() != "Test:NotFound" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestProblemError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", definition.MIMEProblemJSON)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"type":"Test:NotFound","title":"Not Found","status":404,"detail":"test not found","name":"test"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(&Config{
+		Host: strings.TrimPrefix(server.URL, "http://"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Request(http.MethodGet, http.StatusOK, "/test").Do(context.Background())
+	e, ok := err.(interface {
+		Code() int
+		Reason() string
+		Data() map[string]string
+	})
+	if !ok || e.Code() != http.StatusNotFound || e.Reason() != "Test:NotFound" ||
+		err.Error() != "test not found" || e.Data()["name"] != "test" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/caicloud/nirvana/definition"
+)
+
+// retryInterval is the interval before the first retry. It's doubled
+// for each subsequent retry.
+var retryInterval = 100 * time.Millisecond
+
+// retryExecutor retries requests failed with network errors. Requests with
+// non-idempotent methods get an "Idempotency-Key" header, so that servers
+// supporting idempotency keys can recognize the retries.
+type retryExecutor struct {
+	executor RequestExecutor
+	retries  int
+}
+
+func newIdempotencyKey() string {
+	data := make([]byte, 16)
+	_, _ = rand.Read(data)
+	return hex.EncodeToString(data)
+}
+
+// Do executes a request.
+func (e *retryExecutor) Do(req *http.Request) (*http.Response, error) {
+	keyed := req.Header.Get(definition.HeaderIdempotencyKey) != ""
+	if !keyed && (req.Method == http.MethodPost || req.Method == http.MethodPatch) {
+		req.Header.Set(definition.HeaderIdempotencyKey, newIdempotencyKey())
+		keyed = true
+	}
+	interval := retryInterval
+	for i := 0; ; i++ {
+		resp, err := e.executor.Do(req)
+		if i >= e.retries || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+		switch {
+		case err != nil:
+		case keyed && resp.StatusCode == http.StatusConflict && resp.Header.Get("Retry-After") != "":
+			// The first request with the same key is still in flight.
+			_, _ = ioutil.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+		default:
+			return resp, nil
+		}
+		if req.GetBody != nil {
+			body, e := req.GetBody()
+			if e != nil {
+				return nil, e
+			}
+			req.Body = body
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		interval *= 2
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/caicloud/nirvana/definition"
+)
+
+// flakyExecutor fails the first request with a network error.
+type flakyExecutor struct {
+	failed bool
+	keys   []string
+	bodies []string
+}
+
+func (e *flakyExecutor) Do(req *http.Request) (*http.Response, error) {
+	data, _ := ioutil.ReadAll(req.Body)
+	e.keys = append(e.keys, req.Header.Get(definition.HeaderIdempotencyKey))
+	e.bodies = append(e.bodies, string(data))
+	if !e.failed {
+		e.failed = true
+		return nil, fmt.Errorf("connection reset")
+	}
+	req.Body = ioutil.NopCloser(strings.NewReader(string(data)))
+	return http.DefaultClient.Do(req)
+}
+
+func TestRetryWithIdempotencyKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	executor := &flakyExecutor{}
+	client, err := NewClient(&Config{
+		Host:     strings.TrimPrefix(server.URL, "http://"),
+		Executor: executor,
+		Retries:  2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Request(http.MethodPost, http.StatusCreated, "/items").
+		Body(definition.MIMEJSON, map[string]string{"name": "item"}).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(executor.keys) != 2 || executor.keys[0] == "" || executor.keys[0] != executor.keys[1] {
+		t.Fatalf("Retries should share a generated key: %v", executor.keys)
+	}
+	if executor.bodies[0] == "" || executor.bodies[0] != executor.bodies[1] {
+		t.Fatalf("Retries should send the same body: %v", executor.bodies)
+	}
+}
@@ -0,0 +1,319 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1" // #nosec
+	"encoding/hex"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/caicloud/nirvana/definition"
+)
+
+// Validators contains validators of a response for conditional requests.
+type Validators struct {
+	// ETag is an entity tag. It can be a quoted tag like `"xyz"` or `W/"xyz"`.
+	// Unquoted tags are quoted automatically.
+	ETag string
+	// LastModified is the last modification time of the resource.
+	LastModified time.Time
+}
+
+// set sets validators into headers.
+func (v *Validators) set(headers http.Header) {
+	if v.ETag != "" {
+		headers.Set("ETag", quoteETag(v.ETag))
+	}
+	if !v.LastModified.IsZero() {
+		headers.Set("Last-Modified", v.LastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// quoteETag quotes an entity tag if it's not quoted.
+func quoteETag(etag string) string {
+	if strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// parseETags parses a list of entity tags in header "If-Match" or "If-None-Match".
+func parseETags(value string) []string {
+	var etags []string
+	for {
+		value = strings.TrimLeft(value, " \t,")
+		if value == "" {
+			return etags
+		}
+		if value[0] == '*' {
+			etags = append(etags, "*")
+			value = value[1:]
+			continue
+		}
+		start := 0
+		if strings.HasPrefix(value, "W/") {
+			start = 2
+		}
+		if len(value) <= start || value[start] != '"' {
+			// Invalid entity tag. Skip to next one.
+			index := strings.IndexByte(value, ',')
+			if index < 0 {
+				return etags
+			}
+			value = value[index:]
+			continue
+		}
+		end := strings.IndexByte(value[start+1:], '"')
+		if end < 0 {
+			return etags
+		}
+		end += start + 2
+		etags = append(etags, value[:end])
+		value = value[end:]
+	}
+}
+
+// matchETag checks if etag matches one of etags. Weak comparison ignores
+// the weak indicator, and strong comparison requires that both are strong.
+func matchETag(etags []string, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	for _, e := range etags {
+		if e == "*" {
+			return true
+		}
+		if weak {
+			if strings.TrimPrefix(e, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if !strings.HasPrefix(e, "W/") && e == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Precondition contains conditional headers of a request. Handlers can get
+// it by prefab "precondition" and check it against current validators of
+// the target resource.
+type Precondition struct {
+	// IfMatch contains entity tags in header "If-Match".
+	IfMatch []string
+	// IfNoneMatch contains entity tags in header "If-None-Match".
+	IfNoneMatch []string
+	// IfModifiedSince is the time in header "If-Modified-Since".
+	IfModifiedSince time.Time
+	// IfUnmodifiedSince is the time in header "If-Unmodified-Since".
+	IfUnmodifiedSince time.Time
+}
+
+// PreconditionFor parses the precondition of a request.
+func PreconditionFor(req *http.Request) *Precondition {
+	p := &Precondition{
+		IfMatch:     parseETags(req.Header.Get("If-Match")),
+		IfNoneMatch: parseETags(req.Header.Get("If-None-Match")),
+	}
+	if t, err := http.ParseTime(req.Header.Get("If-Modified-Since")); err == nil {
+		p.IfModifiedSince = t
+	}
+	if t, err := http.ParseTime(req.Header.Get("If-Unmodified-Since")); err == nil {
+		p.IfUnmodifiedSince = t
+	}
+	return p
+}
+
+// Empty checks if there is no conditional header in the request.
+func (p *Precondition) Empty() bool {
+	return len(p.IfMatch) <= 0 && len(p.IfNoneMatch) <= 0 &&
+		p.IfModifiedSince.IsZero() && p.IfUnmodifiedSince.IsZero()
+}
+
+// Check evaluates "If-Match" and "If-Unmodified-Since" against current
+// validators of the resource. It returns an error with status code 412 if
+// the precondition fails. Handlers of Update, Patch and Delete should call
+// it before changing the resource. lastModified can be zero if the resource
+// has no modification time.
+//
+// The framework can't evaluate these headers for unsafe methods because
+// validators are only known after the resource is changed. Requests with
+// them are rejected with status code 412 if the function of the definition
+// doesn't get prefab "precondition", so they are never ignored silently.
+func (p *Precondition) Check(etag string, lastModified time.Time) error {
+	if len(p.IfMatch) > 0 {
+		if !matchETag(p.IfMatch, quoteETagIfAny(etag), false) {
+			return preconditionFailed.Error("If-Match")
+		}
+		return nil
+	}
+	if !p.IfUnmodifiedSince.IsZero() && !lastModified.IsZero() &&
+		lastModified.Truncate(time.Second).After(p.IfUnmodifiedSince) {
+		return preconditionFailed.Error("If-Unmodified-Since")
+	}
+	return nil
+}
+
+// NotModified evaluates "If-None-Match" and "If-Modified-Since" against current
+// validators of the resource. Get and List handlers can use it to skip loading data.
+// Responses of GET requests are checked automatically.
+func (p *Precondition) NotModified(etag string, lastModified time.Time) bool {
+	if len(p.IfNoneMatch) > 0 {
+		return matchETag(p.IfNoneMatch, quoteETagIfAny(etag), true)
+	}
+	return !p.IfModifiedSince.IsZero() && !lastModified.IsZero() &&
+		!lastModified.Truncate(time.Second).After(p.IfModifiedSince)
+}
+
+func quoteETagIfAny(etag string) string {
+	if etag == "" {
+		return ""
+	}
+	return quoteETag(etag)
+}
+
+// notModified checks if the response of a GET or HEAD request is not modified.
+func notModified(req *http.Request, headers http.Header) bool {
+	lastModified, _ := http.ParseTime(headers.Get("Last-Modified"))
+	return PreconditionFor(req).NotModified(headers.Get("ETag"), lastModified)
+}
+
+// contextKeyComputedETag is a key for context. It marks that ETags of
+// responses are computed from produced data.
+var contextKeyComputedETag interface{} = new(byte)
+
+// WithComputedETag returns a copy of ctx in which ETags of responses to GET
+// and HEAD requests are computed from produced data if handlers don't
+// provide one. Executors of Get and List definitions enable it.
+func WithComputedETag(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKeyComputedETag, true)
+}
+
+// computedETag checks if ETags of responses should be computed.
+func computedETag(ctx context.Context) bool {
+	computed, _ := ctx.Value(contextKeyComputedETag).(bool)
+	return computed
+}
+
+// writeConditionalData writes data for GET and HEAD requests. If the
+// request precondition says that the client has the same data, status code
+// 304 is written without body. If there is no ETag in response headers and
+// ctx enables computed ETags, data is buffered and the ETag is computed from
+// it. Otherwise data is streamed. body is the data wrapped by an envelope,
+// or nil if the data is not wrapped. The ETag doesn't depend on envelopes,
+// which may contain per-request fields.
+func writeConditionalData(ctx context.Context, resp ResponseWriter, req *http.Request, producer Producer, code int, data interface{}, body interface{}) error {
+	headers := resp.Header()
+	if _, ok := data.(io.Reader); ok || headers.Get("ETag") != "" || !computedETag(ctx) {
+		if notModified(req, headers) {
+			resp.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+		resp.WriteHeader(code)
+		if body != nil {
+			return producer.Produce(resp, body)
+		}
+		return producer.Produce(resp, data)
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := producer.Produce(buf, data); err != nil {
+		return err
+	}
+	sum := sha1.Sum(buf.Bytes()) // #nosec
+	headers.Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	if body != nil {
+		buf.Reset()
+		if err := producer.Produce(buf, body); err != nil {
+			return err
+		}
+	}
+	if notModified(req, headers) {
+		resp.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	resp.WriteHeader(code)
+	_, err := resp.Write(buf.Bytes())
+	return err
+}
+
+// ValidatorsDestinationHandler writes validators into response headers. The
+// value type should be string (an entity tag), time.Time or Validators.
+type ValidatorsDestinationHandler struct{}
+
+// Destination returns definition.Destination which the destination handler can handle.
+func (h *ValidatorsDestinationHandler) Destination() definition.Destination {
+	return definition.Validators
+}
+
+// Priority returns priority of the type handler.
+func (h *ValidatorsDestinationHandler) Priority() int { return MediumPriority }
+
+// Validate validates whether the type handler can handle the target type.
+func (h *ValidatorsDestinationHandler) Validate(target reflect.Type) error {
+	switch target {
+	case reflect.TypeOf(""), reflect.TypeOf(time.Time{}),
+		reflect.TypeOf(Validators{}), reflect.TypeOf(&Validators{}):
+		return nil
+	}
+	return invalidValidatorsType.Error(target)
+}
+
+// Handle handles a value. If the handler has something wrong, it should return an error.
+func (h *ValidatorsDestinationHandler) Handle(ctx context.Context, producers []Producer, code int, value interface{}) (goon bool, err error) {
+	var validators *Validators
+	switch v := value.(type) {
+	case string:
+		validators = &Validators{ETag: v}
+	case time.Time:
+		validators = &Validators{LastModified: v}
+	case Validators:
+		validators = &v
+	case *Validators:
+		validators = v
+	}
+	if validators != nil {
+		validators.set(HTTPContextFrom(ctx).ResponseWriter().Header())
+	}
+	return true, nil
+}
+
+// PreconditionPrefab returns the precondition of current request.
+type PreconditionPrefab struct{}
+
+// Name returns prefab name.
+func (p *PreconditionPrefab) Name() string {
+	return "precondition"
+}
+
+// Type is type of *Precondition.
+func (p *PreconditionPrefab) Type() reflect.Type {
+	return reflect.TypeOf(&Precondition{})
+}
+
+// Make parses the precondition from the request.
+func (p *PreconditionPrefab) Make(ctx context.Context) (interface{}, error) {
+	httpCtx := HTTPContextFrom(ctx)
+	if httpCtx == nil {
+		return nil, NoContext.Error()
+	}
+	return PreconditionFor(httpCtx.Request()), nil
+}
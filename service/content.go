@@ -54,6 +54,8 @@ var consumers = map[string]Consumer{
 	definition.MIMEOctetStream: NewSimpleSerializer(definition.MIMEOctetStream),
 	definition.MIMEURLEncoded:  &URLEncodedConsumer{},
 	definition.MIMEFormData:    &FormDataConsumer{},
+	definition.MIMEMergePatch:  NewPatchConsumer(definition.MIMEMergePatch),
+	definition.MIMEJSONPatch:   NewPatchConsumer(definition.MIMEJSONPatch),
 }
 
 var producers = map[string]Producer{
@@ -63,6 +65,7 @@ var producers = map[string]Producer{
 	definition.MIMEXML:         &XMLSerializer{},
 	definition.MIMEOctetStream: NewSimpleSerializer(definition.MIMEOctetStream),
 	definition.MIMEHTML:        NewSimpleSerializer(definition.MIMEHTML),
+	definition.MIMEProblemJSON: &ProblemSerializer{},
 }
 
 // AllConsumers returns all consumers.
@@ -334,7 +337,13 @@ type Prefab interface {
 }
 
 var prefabs = map[string]Prefab{
-	"context": &ContextPrefab{},
+	"context":        &ContextPrefab{},
+	"request":        &RequestPrefab{},
+	"responseWriter": &ResponseWriterPrefab{},
+	"logger":         &LoggerPrefab{},
+	"requestID":      &RequestIDPrefab{},
+	"clientIP":       &ClientIPPrefab{},
+	"precondition":   &PreconditionPrefab{},
 }
 
 // PrefabFor gets a prefab by name.
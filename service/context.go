@@ -25,6 +25,9 @@ import (
 	"net/http"
 	"net/textproto"
 	"net/url"
+	"sync"
+
+	"github.com/caicloud/nirvana/log"
 )
 
 var (
@@ -40,26 +43,98 @@ type HTTPCtx struct {
 	container container
 	response  response
 	path      string
+	version   string
+	// logger and service are kept in the context instead of derived
+	// contexts, so that serving a request doesn't allocate them.
+	logger  log.Logger
+	service Service
+	// reporters get crashes of the request.
+	reporters []CrashReporter
+	// envelope wraps bodies of responses.
+	envelope Envelope
 }
 
 // NewHTTPContext generates the http context from ResponseWriter and Request.
 func NewHTTPContext(resp http.ResponseWriter, request *http.Request) *HTTPCtx {
 	ctx := &HTTPCtx{}
-	ctx.Context = request.Context()
-	ctx.container.request = request
-	ctx.container.params = make([]param, 0, 5)
-	ctx.response.writer = resp
+	ctx.reset(resp, request)
+	return ctx
+}
+
+var contexts = sync.Pool{
+	New: func() interface{} {
+		return &HTTPCtx{}
+	},
+}
+
+// AcquireHTTPContext gets an http context from a pool and resets it with
+// ResponseWriter and Request. The context should be put back by
+// ReleaseHTTPContext after the request is served.
+func AcquireHTTPContext(resp http.ResponseWriter, request *http.Request) *HTTPCtx {
+	ctx := contexts.Get().(*HTTPCtx)
+	ctx.reset(resp, request)
 	return ctx
 }
 
-// Value returns itself when key is contextKeyUnderlyingHTTPContext.
+// ReleaseHTTPContext puts an http context back to the pool. The context and
+// its ResponseWriter and ValueContainer must not be used after releasing.
+func ReleaseHTTPContext(ctx *HTTPCtx) {
+	*ctx = HTTPCtx{}
+	contexts.Put(ctx)
+}
+
+// reset resets the context with ResponseWriter and Request.
+func (c *HTTPCtx) reset(resp http.ResponseWriter, request *http.Request) {
+	c.Context = request.Context()
+	c.container.request = request
+	c.container.params = c.container.buffer[:0]
+	c.response.writer = resp
+	c.response.discardBody = request.Method == http.MethodHead
+}
+
+// Value returns itself when key is contextKeyUnderlyingHTTPContext. The
+// logger and the service set in the context are returned for their keys.
 func (c *HTTPCtx) Value(key interface{}) interface{} {
-	if key == contextKeyUnderlyingHTTPContext {
+	switch key {
+	case contextKeyUnderlyingHTTPContext:
 		return c
+	case contextKeyLogger:
+		if c.logger != nil {
+			return c.logger
+		}
+	case contextKeyService:
+		if c.service != nil {
+			return c.service
+		}
 	}
 	return c.Context.Value(key)
 }
 
+// SetLogger sets the logger of the request. It's the same as WithLogger()
+// but doesn't derive a context. LoggerFrom() gets it.
+func (c *HTTPCtx) SetLogger(logger log.Logger) {
+	c.logger = logger
+}
+
+// AddCrashReporter adds crash reporters which get crashes of the request.
+func (c *HTTPCtx) AddCrashReporter(reporters ...CrashReporter) {
+	// Reporters are always copied, so that slices of builders are not
+	// shared by requests.
+	c.reporters = append(c.reporters[:len(c.reporters):len(c.reporters)], reporters...)
+}
+
+// SetEnvelope sets the envelope which wraps bodies of responses of the
+// request. A nil envelope disables envelopes.
+func (c *HTTPCtx) SetEnvelope(e Envelope) {
+	c.envelope = e
+}
+
+// SetService sets the service which handles the request. It's the same as
+// WithService() but doesn't derive a context. ServiceFrom() gets it.
+func (c *HTTPCtx) SetService(s Service) {
+	c.service = s
+}
+
 // ValueContainer contains values from a request.
 type ValueContainer interface {
 	// Set sets path parameter key-value pairs.
@@ -83,16 +158,35 @@ type ValueContainer interface {
 	Body() (reader io.ReadCloser, contentType string, ok bool)
 }
 
+// HostValueContainer is implemented by value containers which contain values
+// of host parameters. It's not a part of ValueContainer, so that other
+// implementations of ValueContainer don't have to support it.
+type HostValueContainer interface {
+	// SetHost sets host parameter key-value pairs.
+	SetHost(key, value string)
+	// Host returns host value by key.
+	Host(key string) (string, bool)
+}
+
 type param struct {
 	key   string
 	value string
 }
 
+// maxBufferedParams is the count of path parameters which can be saved
+// without allocations.
+const maxBufferedParams = 8
+
+var _ HostValueContainer = &container{}
+
 // container implements ValueContainer and provides methods to get values.
 type container struct {
 	request *http.Request
 	params  []param
+	hosts   []param
 	query   url.Values
+	// buffer backs params.
+	buffer [maxBufferedParams]param
 }
 
 // Set sets path parameter key-value pairs.
@@ -116,6 +210,22 @@ func (c *container) Path(key string) (string, bool) {
 	return c.Get(key)
 }
 
+// SetHost sets host parameter key-value pairs.
+func (c *container) SetHost(key, value string) {
+	c.hosts = append(c.hosts, param{key, value})
+}
+
+// Host returns host value by key.
+func (c *container) Host(key string) (string, bool) {
+	for i := len(c.hosts) - 1; i >= 0; i-- {
+		p := c.hosts[i]
+		if p.key == key {
+			return p.value, true
+		}
+	}
+	return "", false
+}
+
 // Query returns value from query string.
 func (c *container) Query(key string) ([]string, bool) {
 	if c.query == nil {
@@ -188,6 +298,8 @@ type response struct {
 	hijacked       bool
 	ifWrapRespBody bool
 	respBody       []byte
+	// discardBody discards response bodies of HEAD requests.
+	discardBody bool
 }
 
 // Header For http.HTTPResponseWriter and HTTPResponseInfo
@@ -200,6 +312,10 @@ func (c *response) Write(data []byte) (int, error) {
 	if c.statusCode <= 0 {
 		c.WriteHeader(200)
 	}
+	if c.discardBody {
+		c.contentLength += len(data)
+		return len(data), nil
+	}
 	length, err := c.writer.Write(data)
 	c.contentLength += length
 	// Append the data to the response cache for the special purpose of users.
@@ -275,6 +391,18 @@ type HTTPContext interface {
 	SetRoutePath(path string)
 }
 
+// VersionedContext is implemented by http contexts which carry versions of
+// definitions. It's not a part of HTTPContext, so that other implementations
+// of HTTPContext don't have to support it.
+type VersionedContext interface {
+	// Version is the version of the definition which handles the request.
+	Version() string
+	// SetVersion sets the version of the definition which handles the request.
+	SetVersion(version string)
+}
+
+var _ VersionedContext = &HTTPCtx{}
+
 // HTTPContextFrom get http context from context.
 func HTTPContextFrom(ctx context.Context) HTTPContext {
 	value := ctx.Value(contextKeyUnderlyingHTTPContext)
@@ -287,6 +415,25 @@ func HTTPContextFrom(ctx context.Context) HTTPContext {
 	return nil
 }
 
+// contextKeyService is a key for context. It points to the service which
+// handles current request.
+var contextKeyService interface{} = new(byte)
+
+// WithService returns a copy of ctx which carries s.
+func WithService(ctx context.Context, s Service) context.Context {
+	return context.WithValue(ctx, contextKeyService, s)
+}
+
+// ServiceFrom gets the service which handles current request. Handlers can
+// use it to dispatch requests in-process. It returns nil if there is no
+// service in ctx.
+func ServiceFrom(ctx context.Context) Service {
+	if s, ok := ctx.Value(contextKeyService).(Service); ok {
+		return s
+	}
+	return nil
+}
+
 // Request gets http.Request.
 func (c *HTTPCtx) Request() *http.Request {
 	return c.container.request
@@ -311,3 +458,13 @@ func (c *HTTPCtx) RoutePath() string {
 func (c *HTTPCtx) SetRoutePath(path string) {
 	c.path = path
 }
+
+// Version is the version of the definition which handles the request.
+func (c *HTTPCtx) Version() string {
+	return c.version
+}
+
+// SetVersion sets the version of the definition which handles the request.
+func (c *HTTPCtx) SetVersion(version string) {
+	c.version = version
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/caicloud/nirvana/errors"
+)
+
+// Crashed means a panic occurs when serving a request. Details of the panic
+// are only written to logs and crash reporters, clients get the incident id.
+var Crashed = errors.InternalServerError.Build("Nirvana:Service:Crashed", "internal server error, incident ${incident}")
+
+// Crash describes a panic which occurs when serving a request.
+type Crash struct {
+	// IncidentID identifies the crash. It's in the data of the error
+	// responded to the client.
+	IncidentID string
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+	// Time is when the panic is recovered.
+	Time time.Time
+	// Method is the method of the request.
+	Method string
+	// URL is the request URI of the request.
+	URL string
+	// RoutePath is the abstract path which matches the request. It's empty
+	// if the request is not routed.
+	RoutePath string
+	// RemoteAddr is the network address which sent the request.
+	RemoteAddr string
+}
+
+// CrashReporter reports crashes, e.g. to an error tracking system.
+type CrashReporter interface {
+	// ReportCrash reports a crash. It's called in the goroutine which
+	// serves the request, before the error is responded.
+	ReportCrash(ctx context.Context, crash *Crash)
+}
+
+// CrashReporterFunc is a function which implements CrashReporter.
+type CrashReporterFunc func(ctx context.Context, crash *Crash)
+
+// ReportCrash calls the function.
+func (f CrashReporterFunc) ReportCrash(ctx context.Context, crash *Crash) {
+	f(ctx, crash)
+}
+
+// AddCrashReporter adds crash reporters for the request in ctx. They only
+// get crashes of the request. Middlewares can use it to get crashes of
+// requests they handle. Reporters of builders are added before routing.
+func AddCrashReporter(ctx context.Context, reporters ...CrashReporter) {
+	if c, ok := ctx.Value(contextKeyUnderlyingHTTPContext).(*HTTPCtx); ok {
+		c.AddCrashReporter(reporters...)
+	}
+}
+
+// Recover converts a value recovered from a panic to an error of Crashed.
+// The stack trace and a summary of the request are written to the logger
+// in ctx and reported to crash reporters of the request. http.ErrAbortHandler is not a
+// crash and it panics again, so that net/http aborts the response.
+func Recover(ctx context.Context, v interface{}) error {
+	if v == http.ErrAbortHandler {
+		panic(v)
+	}
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	crash := &Crash{
+		IncidentID: hex.EncodeToString(id),
+		Value:      v,
+		Stack:      debug.Stack(),
+		Time:       time.Now(),
+	}
+	var reporters []CrashReporter
+	if c, ok := ctx.Value(contextKeyUnderlyingHTTPContext).(*HTTPCtx); ok {
+		reporters = c.reporters
+		req := c.Request()
+		crash.Method = req.Method
+		crash.URL = req.URL.RequestURI()
+		crash.RoutePath = c.RoutePath()
+		crash.RemoteAddr = req.RemoteAddr
+	}
+	LoggerFrom(ctx).Errorf("Panic when serving %s %s (route: %q, remote: %s, incident: %s): %v\n%s",
+		crash.Method, crash.URL, crash.RoutePath, crash.RemoteAddr, crash.IncidentID, v, crash.Stack)
+	for _, r := range reporters {
+		r.ReportCrash(ctx, crash)
+	}
+	return Crashed.Error(crash.IncidentID)
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/caicloud/nirvana/definition"
+)
+
+// Envelope wraps bodies of responses written by WriteData and WriteError.
+// Only bodies produced by JSON and XML producers are wrapped. Raw data
+// (string, []byte and io.Reader) is never wrapped, but error messages are
+// always wrapped.
+type Envelope interface {
+	// WrapData wraps data of a success response.
+	WrapData(ctx context.Context, code int, data interface{}) interface{}
+	// WrapError wraps an error response. err is the error passed to
+	// WriteError and msg is the body which is written without envelopes.
+	WrapError(ctx context.Context, code int, err interface{}, msg interface{}) interface{}
+	// Schema returns the type of wrapped bodies and the json name of the
+	// field which contains data or error messages. Generators use them to
+	// describe wrapped bodies in API docs and unwrap bodies in clients.
+	Schema() (typ reflect.Type, field string)
+}
+
+// contextKeyNoEnvelope is a key for context. It marks that responses
+// should not be wrapped.
+var contextKeyNoEnvelope interface{} = new(byte)
+
+// WithoutEnvelope returns a copy of ctx in which responses are written
+// without envelopes.
+func WithoutEnvelope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKeyNoEnvelope, true)
+}
+
+// envelopeFor returns the envelope to wrap a body written by producer. It
+// returns nil if the body should not be wrapped.
+func envelopeFor(ctx context.Context, producer Producer, code int) Envelope {
+	c, ok := ctx.Value(contextKeyUnderlyingHTTPContext).(*HTTPCtx)
+	if !ok || c.envelope == nil || code == http.StatusNoContent || code == http.StatusNotModified {
+		return nil
+	}
+	if ct := producer.ContentType(); ct != definition.MIMEJSON && ct != definition.MIMEXML {
+		return nil
+	}
+	if disabled, _ := ctx.Value(contextKeyNoEnvelope).(bool); disabled {
+		return nil
+	}
+	return c.envelope
+}
+
+var (
+	stringType = reflect.TypeOf("")
+	bytesType  = reflect.TypeOf([]byte(nil))
+	readerType = reflect.TypeOf((*io.Reader)(nil)).Elem()
+)
+
+// RawType checks if data of typ is written as it is by producers, without
+// envelopes. Raw types are string, []byte and types implementing io.Reader.
+// Named types of other kinds, such as "type Token string", are marshaled
+// and wrapped. Generators use it to describe wrapped bodies.
+func RawType(typ reflect.Type) bool {
+	return typ == stringType || typ == bytesType || typ.Implements(readerType)
+}
+
+// raw checks if data is written as it is by producers.
+func raw(data interface{}) bool {
+	return data != nil && RawType(reflect.TypeOf(data))
+}
+
+// StandardBody is the body wrapped by StandardEnvelope.
+type StandardBody struct {
+	XMLName xml.Name `json:"-" xml:"response"`
+	// Code is the status code of the response.
+	Code int `json:"code" xml:"code"`
+	// Message is the status text of a success response, or the message of
+	// an error.
+	Message string `json:"message" xml:"message"`
+	// Data is the data of a success response, or the error message of an
+	// error response.
+	Data interface{} `json:"data,omitempty" xml:"data,omitempty"`
+	// RequestID identifies the request.
+	RequestID string `json:"requestId,omitempty" xml:"requestId,omitempty"`
+}
+
+// StandardEnvelope wraps bodies as StandardBody, for instance,
+// {"code": 200, "message": "OK", "data": ..., "requestId": "..."}.
+type StandardEnvelope struct {
+	// RequestID returns the id of the request in ctx. If it's nil, the id
+	// stored by WithRequestID() is used, then header "X-Request-Id" of the
+	// request.
+	RequestID func(ctx context.Context) string
+}
+
+// WrapData wraps data of a success response.
+func (e *StandardEnvelope) WrapData(ctx context.Context, code int, data interface{}) interface{} {
+	return &StandardBody{
+		Code:      code,
+		Message:   http.StatusText(code),
+		Data:      data,
+		RequestID: e.requestID(ctx),
+	}
+}
+
+// WrapError wraps an error response. The message of the error is in data.
+func (e *StandardEnvelope) WrapError(ctx context.Context, code int, err interface{}, msg interface{}) interface{} {
+	body := &StandardBody{
+		Code:      code,
+		Message:   http.StatusText(code),
+		Data:      msg,
+		RequestID: e.requestID(ctx),
+	}
+	if e, ok := err.(error); ok {
+		body.Message = e.Error()
+	}
+	return body
+}
+
+// Schema returns the type of StandardBody and its data field.
+func (e *StandardEnvelope) Schema() (reflect.Type, string) {
+	return reflect.TypeOf(StandardBody{}), "data"
+}
+
+func (e *StandardEnvelope) requestID(ctx context.Context) string {
+	if e.RequestID != nil {
+		return e.RequestID(ctx)
+	}
+	if id := RequestIDFrom(ctx); id != "" {
+		return id
+	}
+	if c := HTTPContextFrom(ctx); c != nil {
+		return c.Request().Header.Get("X-Request-Id")
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"reflect"
+)
+
+// Adapter calls a definition function without reflection. Arguments and
+// results are in the order of parameters and results of the function. A nil
+// argument means the zero value of its parameter type.
+type Adapter func(args []interface{}) []interface{}
+
+// AdapterFactory creates an adapter for a function. The function always has
+// the type which the factory is registered for.
+type AdapterFactory func(f interface{}) Adapter
+
+var adapters = map[reflect.Type]AdapterFactory{}
+
+// RegisterAdapter registers an adapter factory for a function type. Executors
+// of functions with the type call functions by adapters. Adapters are usually
+// generated by "nirvana adapters". New factory overrides old one.
+func RegisterAdapter(typ reflect.Type, factory AdapterFactory) {
+	adapters[typ] = factory
+}
+
+// AdapterFor creates an adapter for a function. It returns nil if there is
+// no adapter factory for the type of the function.
+func AdapterFor(f interface{}) Adapter {
+	factory, ok := adapters[reflect.TypeOf(f)]
+	if !ok {
+		return nil
+	}
+	return factory(f)
+}
+
+// adaptable checks if an argument can be passed to an adapter. Adapters use
+// type assertions, so arguments must have the exact types of parameters.
+// Reflection accepts assignable types.
+func (p *parameter) adaptable(arg interface{}) bool {
+	return arg == nil || p.targetType.Kind() == reflect.Interface || reflect.TypeOf(arg) == p.targetType
+}
+
+// call calls the function of the executor by reflection.
+func (e *executor) call(args []interface{}) []interface{} {
+	values := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		if arg == nil {
+			values[i] = reflect.New(e.parameters[i].targetType).Elem()
+		} else {
+			values[i] = reflect.ValueOf(arg)
+		}
+	}
+	results := e.function.Call(values)
+	data := make([]interface{}, len(results))
+	for i, v := range results {
+		data[i] = v.Interface()
+	}
+	return data
+}
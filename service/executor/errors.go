@@ -65,7 +65,10 @@ var (
 )
 
 var (
+	noName                 = errors.InternalServerError.Build("Nirvana:Service:noName", "${source} must have a name")
+	unassignableType       = errors.InternalServerError.Build("Nirvana:Service:unassignableType", "type ${typeA} can't assign to ${typeB}")
 	requiredField          = errors.InternalServerError.Build("Nirvana:Service:RequiredField", "required field ${field} in ${source} but got empty")
 	invalidOperatorInType  = errors.InternalServerError.Build("Nirvana:Service:invalidOperatorInType", "the type ${type} is not compatible to the in type of the ${index} operator")
+	uncheckedPrecondition  = errors.PreconditionFailed.Build("Nirvana:Service:UncheckedPrecondition", "precondition in header ${header} can't be checked by the ${method} handler")
 	invalidOperatorOutType = errors.InternalServerError.Build("Nirvana:Service:invalidOperatorOutType", "the out type of the ${index} operator is not compatible to the type ${type}")
 )
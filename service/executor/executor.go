@@ -25,8 +25,10 @@ import (
 	"reflect"
 	"runtime"
 	"sort"
+	"time"
 
 	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/errors"
 	"github.com/caicloud/nirvana/service"
 )
 
@@ -39,8 +41,34 @@ type Executor interface {
 	Producible([]string) bool
 }
 
-// DefinitionToExecutor generates a Executor for the Definition.
-func DefinitionToExecutor(urlPath string, d definition.Definition, customCode int) (Executor, error) {
+// VersionedExecutor is implemented by executors which know versions of their
+// definitions. It's not a part of Executor, so that other implementations of
+// Executor don't have to support it.
+type VersionedExecutor interface {
+	// Version returns the normalized version of the definition.
+	Version() string
+}
+
+// VersionOf returns the version of the definition of an executor. It's empty
+// if the executor doesn't know the version.
+func VersionOf(e Executor) string {
+	if versioned, ok := e.(VersionedExecutor); ok {
+		return versioned.Version()
+	}
+	return ""
+}
+
+// FunctionalExecutor is implemented by executors which know functions of
+// their definitions. It's not a part of Executor, so that other
+// implementations of Executor don't have to support it.
+type FunctionalExecutor interface {
+	// Function returns the name and file position of the function of the definition.
+	Function() (name string, file string, line int)
+}
+
+// DefinitionToExecutor generates a Executor for the Definition. Observers are
+// notified of phases of executing the definition.
+func DefinitionToExecutor(urlPath string, d definition.Definition, customCode int, observers ...service.Observer) (Executor, error) {
 	var method string
 	if d.Method == definition.Any {
 		method = string(definition.Any)
@@ -70,9 +98,18 @@ func DefinitionToExecutor(urlPath string, d definition.Definition, customCode in
 		customCode = service.HTTPCodeFor(d.Method)
 	}
 	c := &executor{
-		method:   method,
-		code:     customCode,
-		function: value,
+		method:    method,
+		version:   definition.NormalizeVersion(d.Version),
+		code:      customCode,
+		function:  value,
+		adapter:   AdapterFor(d.Function),
+		aggregate: d.AggregateErrors,
+		unwrapped: d.NoEnvelope,
+		etag:      d.Method == definition.Get || d.Method == definition.List,
+		unchecked: unsafeMethod(method) && !takesPrecondition(d.Parameters),
+	}
+	if len(observers) > 0 {
+		c.observer = service.Observers(append([]service.Observer{}, observers...))
 	}
 	consumeAll := false
 	consumes := map[string]bool{}
@@ -95,6 +132,7 @@ func DefinitionToExecutor(urlPath string, d definition.Definition, customCode in
 				c.consumers = append(c.consumers, consumer)
 			}
 		}
+		c.acceptAll = !readsBody(d.Parameters)
 	}
 	produceAll := false
 	produces := map[string]bool{}
@@ -148,6 +186,7 @@ func DefinitionToExecutor(urlPath string, d definition.Definition, customCode in
 	// 2. Anonymous function: api.glob..func1(create.go#30)
 	//    Anonymous function names are generated by go. Don't explore their meaning.
 	funcName := fmt.Sprintf("%s(%s#%d)", path.Base(f.Name()), path.Base(file), line)
+	c.funcName, c.file, c.line = f.Name(), file, line
 	ps, err := generateParameters(urlPath, funcName, value.Type(), d.Parameters)
 	if err != nil {
 		return nil, err
@@ -161,11 +200,42 @@ func DefinitionToExecutor(urlPath string, d definition.Definition, customCode in
 	return c, nil
 }
 
+// readsBody checks if any parameter is generated from request body.
+func readsBody(ps []definition.Parameter) bool {
+	for _, p := range ps {
+		switch p.Source {
+		case definition.Body, definition.Form, definition.File, definition.Auto:
+			return true
+		}
+	}
+	return false
+}
+
+// unsafeMethod checks if requests of the HTTP method may change resources.
+func unsafeMethod(method string) bool {
+	switch method {
+	case string(definition.Any), http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	return true
+}
+
+// takesPrecondition checks if the function gets prefab "precondition".
+func takesPrecondition(ps []definition.Parameter) bool {
+	for _, p := range ps {
+		if p.Source == definition.Prefab && p.Name == "precondition" {
+			return true
+		}
+	}
+	return false
+}
+
 func generateParameters(path, funcName string, typ reflect.Type, ps []definition.Parameter) ([]parameter, error) {
 	if typ.NumIn() != len(ps) {
 		return nil, DefinitionUnmatchedParameters.Error(funcName, typ.NumIn(), len(ps), path)
 	}
 	parameters := make([]parameter, 0, len(ps))
+	patterns := service.PathPatterns(path)
 	for index, p := range ps {
 		generator := service.ParameterGeneratorFor(p.Source)
 		if generator == nil {
@@ -184,7 +254,11 @@ func generateParameters(path, funcName string, typ reflect.Type, ps []definition
 		} else {
 			param.targetType = p.Operators[0].In()
 		}
-		if err := generator.Validate(param.name, param.defaultValue, param.targetType); err != nil {
+		if pattern := patterns[p.Name]; p.Source == definition.Path && pattern != nil && pattern.Type == param.targetType {
+			// Values of named patterns are converted by converters of patterns.
+			param.generator = &patternGenerator{generator, pattern}
+		}
+		if err := param.generator.Validate(param.name, param.defaultValue, param.targetType); err != nil {
 			// Order from 0 is odd. So index+1.
 			return nil, InvalidParameter.Error(order(index+1), funcName, err.Error())
 		}
@@ -236,6 +310,7 @@ func generateResults(path, funcName string, typ reflect.Type, rs []definition.Re
 //   operators[0].Out() -> operators[1].In()
 //   ...
 //   operators[N].Out() -> out
+// Operators which implement definition.ValidatableOperator are validated too.
 func validateOperators(in, out reflect.Type, operators []definition.Operator) error {
 	if len(operators) <= 0 {
 		return nil
@@ -247,6 +322,11 @@ func validateOperators(in, out reflect.Type, operators []definition.Operator) er
 			// The out type of operator[index-1] is not compatible to operator[index].
 			return invalidOperatorInType.Error(in, order(index+1))
 		}
+		if v, ok := operator.(definition.ValidatableOperator); ok {
+			if err := v.Validate(); err != nil {
+				return err
+			}
+		}
 		in = operator.Out()
 	}
 	typ := operators[index-1].Out()
@@ -257,8 +337,12 @@ func validateOperators(in, out reflect.Type, operators []definition.Operator) er
 	return nil
 }
 
+var _ VersionedExecutor = &executor{}
+var _ FunctionalExecutor = &executor{}
+
 type executor struct {
 	method         string
+	version        string
 	code           int
 	consumers      []service.Consumer
 	producers      []service.Producer
@@ -266,6 +350,27 @@ type executor struct {
 	parameters     []parameter
 	results        []result
 	function       reflect.Value
+	funcName       string
+	file           string
+	line           int
+	// adapter calls the function without reflection if it's not nil.
+	adapter Adapter
+	// acceptAll is true if the executor consumes all content types and
+	// does not read request body by consumers. Such an executor accepts
+	// content types without consumers (ex. mounted http handlers).
+	acceptAll bool
+	// aggregate is true if errors of all parameters are responded in one
+	// error of service.InvalidFields.
+	aggregate bool
+	// unwrapped is true if responses are not wrapped by envelopes.
+	unwrapped bool
+	// etag is true if ETags of responses are computed from data.
+	etag bool
+	// unchecked is true if the definition changes resources but its function
+	// can't check preconditions of requests. Such requests are rejected.
+	unchecked bool
+	// observer is notified of phases of execution if it's not nil.
+	observer service.Observer
 }
 
 type parameter struct {
@@ -277,6 +382,44 @@ type parameter struct {
 	optional     bool
 }
 
+// fieldErrors converts an error of the parameter to field errors. Fields
+// of auto parameters are named by their own paths.
+func (p *parameter) fieldErrors(err error) []errors.FieldError {
+	field := p.name
+	if p.generator.Source() == definition.Auto {
+		field = ""
+	}
+	return errors.FieldErrorsOf(field, string(p.generator.Source()), err)
+}
+
+// patternGenerator generates path values by the converter of a pattern.
+type patternGenerator struct {
+	service.ParameterGenerator
+	pattern *service.Pattern
+}
+
+// Validate validates whether defaultValue and target type is valid. Target
+// doesn't need a converter because values are converted by the pattern.
+func (g *patternGenerator) Validate(name string, defaultValue interface{}, target reflect.Type) error {
+	if name == "" {
+		return noName.Error(g.Source())
+	}
+	if defaultValue != nil && !reflect.TypeOf(defaultValue).AssignableTo(target) {
+		return unassignableType.Error(reflect.TypeOf(defaultValue), target)
+	}
+	return nil
+}
+
+// Generate generates an object by data from value container.
+func (g *patternGenerator) Generate(ctx context.Context, vc service.ValueContainer, consumers []service.Consumer,
+	name string, target reflect.Type) (interface{}, error) {
+	data, ok := vc.Path(name)
+	if !ok || len(data) <= 0 {
+		return nil, nil
+	}
+	return g.pattern.Converter(ctx, []string{data})
+}
+
 type result struct {
 	index     int
 	handler   service.DestinationHandler
@@ -313,6 +456,9 @@ func (e *executor) check(producers []service.Producer, ats []string) bool {
 }
 
 func (e *executor) Acceptable(ct string) bool {
+	if e.acceptAll {
+		return true
+	}
 	for _, c := range e.consumers {
 		if c.ContentType() == ct {
 			return true
@@ -325,6 +471,14 @@ func (e *executor) Producible(ats []string) bool {
 	return e.check(e.producers, ats) && e.check(e.errorProducers, ats)
 }
 
+func (e *executor) Version() string {
+	return e.version
+}
+
+func (e *executor) Function() (name string, file string, line int) {
+	return e.funcName, e.file, e.line
+}
+
 func (e *executor) ContentTypeMap() map[string][]string {
 	result := map[string][]string{}
 	for _, c := range e.consumers {
@@ -336,17 +490,69 @@ func (e *executor) ContentTypeMap() map[string][]string {
 	return result
 }
 
+// uncheckablePrecondition returns the conditional header which only
+// handlers can evaluate for unsafe requests.
+func uncheckablePrecondition(req *http.Request) string {
+	p := service.PreconditionFor(req)
+	switch {
+	case len(p.IfMatch) > 0:
+		return "If-Match"
+	case !p.IfUnmodifiedSince.IsZero():
+		return "If-Unmodified-Since"
+	}
+	return ""
+}
+
 // Execute executes with context.
 func (e *executor) Execute(ctx context.Context) (err error) {
 	c := service.HTTPContextFrom(ctx)
 	if c == nil {
 		return service.NoContext.Error()
 	}
-	paramValues := make([]reflect.Value, 0, len(e.parameters))
+	if e.unwrapped {
+		ctx = service.WithoutEnvelope(ctx)
+	}
+	if e.etag {
+		ctx = service.WithComputedETag(ctx)
+	}
+	if e.unchecked {
+		// The function would change the resource without checking the
+		// precondition. Reject it rather than ignore it silently.
+		if header := uncheckablePrecondition(c.Request()); header != "" {
+			return service.WriteError(ctx, e.errorProducers, uncheckedPrecondition.Error(header, e.method))
+		}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			// The crash has been logged. It's responded if the response
+			// is not written.
+			crash := service.Recover(ctx, r)
+			if c.ResponseWriter().HeaderWritable() {
+				err = service.WriteError(ctx, e.errorProducers, crash)
+			}
+		}
+	}()
+	args := make([]interface{}, 0, len(e.parameters))
+	adapter := e.adapter
+	// fields contains errors of parameters if errors are aggregated.
+	var fields []errors.FieldError
+	gctx := ctx
+	if e.aggregate {
+		gctx = service.WithAggregateErrors(ctx)
+	}
 	for _, p := range e.parameters {
-		result, err := p.generator.Generate(ctx, c.ValueContainer(), e.consumers, p.name, p.targetType)
+		start := e.now()
+		result, err := p.generator.Generate(gctx, c.ValueContainer(), e.consumers, p.name, p.targetType)
+		if e.observer != nil {
+			e.observe(ctx, service.Observation{Phase: service.PhaseParameter, Name: p.name,
+				Source: string(p.generator.Source()), Start: start, Err: err})
+		}
 		if err != nil {
-			return service.WriteError(ctx, e.errorProducers, err)
+			if !e.aggregate {
+				return service.WriteError(ctx, e.errorProducers, err)
+			}
+			fields = append(fields, p.fieldErrors(err)...)
+			continue
 		}
 		if result == nil {
 			if p.defaultValue != nil {
@@ -356,14 +562,31 @@ func (e *executor) Execute(ctx context.Context) (err error) {
 			}
 		}
 		for _, operator := range p.operators {
+			start := e.now()
 			result, err = operator.Operate(ctx, p.name, result)
+			if e.observer != nil {
+				e.observe(ctx, service.Observation{Phase: service.PhaseOperator, Name: p.name,
+					Source: string(p.generator.Source()), Operator: operator.Kind(), Start: start, Err: err})
+			}
 			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			if !e.aggregate {
 				return service.WriteError(ctx, e.errorProducers, err)
 			}
+			fields = append(fields, p.fieldErrors(err)...)
+			continue
 		}
 
 		if result == nil && !p.optional {
-			return service.WriteError(ctx, e.errorProducers, requiredField.Error(p.name, p.generator.Source()))
+			err := requiredField.Error(p.name, p.generator.Source())
+			if !e.aggregate {
+				return service.WriteError(ctx, e.errorProducers, err)
+			}
+			fields = append(fields, p.fieldErrors(err)...)
+			continue
 		}
 
 		if closer, ok := result.(io.Closer); ok {
@@ -375,11 +598,13 @@ func (e *executor) Execute(ctx context.Context) (err error) {
 			}()
 		}
 
-		if result == nil {
-			paramValues = append(paramValues, reflect.New(p.targetType).Elem())
-		} else {
-			paramValues = append(paramValues, reflect.ValueOf(result))
+		if adapter != nil && !p.adaptable(result) {
+			adapter = nil
 		}
+		args = append(args, result)
+	}
+	if len(fields) > 0 {
+		return service.WriteError(ctx, e.errorProducers, errors.WithFields(service.InvalidFields.Error(len(fields)), fields...))
 	}
 
 	code := e.code
@@ -394,12 +619,25 @@ func (e *executor) Execute(ctx context.Context) (err error) {
 		}
 	}
 
-	resultValues := e.function.Call(paramValues)
+	var results []interface{}
+	start := e.now()
+	if adapter != nil {
+		results = adapter(args)
+	} else {
+		results = e.call(args)
+	}
+	if e.observer != nil {
+		e.observe(ctx, service.Observation{Phase: service.PhaseFunction, Name: e.funcName, Start: start})
+	}
 	for _, r := range e.results {
-		v := resultValues[r.index]
-		data := v.Interface()
+		data := results[r.index]
 		for _, operator := range r.operators {
+			start := e.now()
 			newData, err := operator.Operate(ctx, string(r.handler.Destination()), data)
+			if e.observer != nil {
+				e.observe(ctx, service.Observation{Phase: service.PhaseOperator, Name: string(r.handler.Destination()),
+					Operator: operator.Kind(), Start: start, Err: err})
+			}
 			if err != nil {
 				return err
 			}
@@ -420,7 +658,12 @@ func (e *executor) Execute(ctx context.Context) (err error) {
 			// Select correct producers to produce error.
 			producers = e.errorProducers
 		}
+		start := e.now()
 		goon, err := r.handler.Handle(ctx, producers, code, data)
+		if e.observer != nil {
+			e.observe(ctx, service.Observation{Phase: service.PhaseResult, Name: string(r.handler.Destination()),
+				Start: start, Err: err})
+		}
 		if err != nil {
 			return err
 		}
@@ -435,6 +678,30 @@ func (e *executor) Execute(ctx context.Context) (err error) {
 	return nil
 }
 
+// now returns the current time if phases are observed. Otherwise it
+// returns zero time to avoid the cost of reading the clock.
+func (e *executor) now() time.Time {
+	if e.observer == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// observe notifies the observer of a finished phase.
+func (e *executor) observe(ctx context.Context, o service.Observation) {
+	o.Duration = time.Since(o.Start)
+	switch o.Phase {
+	case service.PhaseParameter:
+		e.observer.ParameterGenerated(ctx, &o)
+	case service.PhaseOperator:
+		e.observer.OperatorApplied(ctx, &o)
+	case service.PhaseFunction:
+		e.observer.FunctionCalled(ctx, &o)
+	case service.PhaseResult:
+		e.observer.ResultHandled(ctx, &o)
+	}
+}
+
 func order(i int) string {
 	switch i % 10 {
 	case 1:
@@ -0,0 +1,340 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package executor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/errors"
+	"github.com/caicloud/nirvana/service"
+)
+
+// Echo is the function of the echo example in getting-started.
+func Echo(ctx context.Context, msg string) (string, error) {
+	return msg, nil
+}
+
+// Message is the data of the message example in getting-started.
+type Message struct {
+	ID      int    `json:"id"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// GetMessage is the function of the message example in getting-started.
+func GetMessage(ctx context.Context, id int, title string) (*Message, error) {
+	return &Message{ID: id, Title: title, Content: "This is a message"}, nil
+}
+
+// Adapters are the same as adapters generated by "nirvana adapters".
+var testAdapters = map[reflect.Type]AdapterFactory{
+	reflect.TypeOf((func(context.Context, string) (string, error))(nil)): func(f interface{}) Adapter {
+		fn := f.(func(context.Context, string) (string, error))
+		return func(args []interface{}) []interface{} {
+			a0, _ := args[0].(context.Context)
+			a1, _ := args[1].(string)
+			r0, r1 := fn(a0, a1)
+			return []interface{}{r0, r1}
+		}
+	},
+	reflect.TypeOf((func(context.Context, int, string) (*Message, error))(nil)): func(f interface{}) Adapter {
+		fn := f.(func(context.Context, int, string) (*Message, error))
+		return func(args []interface{}) []interface{} {
+			a0, _ := args[0].(context.Context)
+			a1, _ := args[1].(int)
+			a2, _ := args[2].(string)
+			r0, r1 := fn(a0, a1, a2)
+			return []interface{}{r0, r1}
+		}
+	},
+}
+
+var examples = []struct {
+	name       string
+	definition definition.Definition
+	url        string
+	body       string
+}{
+	{
+		"Echo",
+		definition.Definition{
+			Method: definition.Get,
+			Parameters: []definition.Parameter{
+				definition.PrefabParameterFor("context", ""),
+				definition.QueryParameterFor("msg", ""),
+			},
+			Function: Echo,
+		},
+		"/echo?msg=hello",
+		"hello",
+	},
+	{
+		"Message",
+		definition.Definition{
+			Method: definition.Get,
+			Parameters: []definition.Parameter{
+				definition.PrefabParameterFor("context", ""),
+				definition.PathParameterFor("id", ""),
+				{Source: definition.Query, Name: "title", Default: "Untitled"},
+			},
+			Function: GetMessage,
+		},
+		"/messages/1",
+		`{"id":1,"title":"Untitled","content":"This is a message"}`,
+	},
+}
+
+// useAdapters registers or unregisters adapters of examples.
+func useAdapters(adapted bool) {
+	for typ, factory := range testAdapters {
+		if adapted {
+			RegisterAdapter(typ, factory)
+		} else {
+			delete(adapters, typ)
+		}
+	}
+}
+
+// newExecutor creates an executor for a definition and checks if it's adapted.
+func newExecutor(t testing.TB, d definition.Definition, adapted bool) Executor {
+	d.Consumes = []string{definition.MIMEAll}
+	d.Produces = []string{definition.MIMEJSON}
+	d.ErrorProduces = []string{definition.MIMEJSON}
+	d.Results = definition.DataErrorResults("")
+	e, err := DefinitionToExecutor("/", d, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := e.(*executor).adapter != nil; got != adapted {
+		t.Fatalf("Executor should be adapted: %v, but got %v", adapted, got)
+	}
+	return e
+}
+
+func execute(t testing.TB, e Executor, url string) *httptest.ResponseRecorder {
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	ctx := service.NewHTTPContext(resp, req)
+	ctx.ValueContainer().Set("id", "1")
+	if err := e.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestAdapters(t *testing.T) {
+	defer useAdapters(false)
+	for _, example := range examples {
+		for _, adapted := range []bool{false, true} {
+			useAdapters(adapted)
+			resp := execute(t, newExecutor(t, example.definition, adapted), example.url)
+			if body := strings.TrimSpace(resp.Body.String()); body != example.body {
+				t.Fatalf("%s (adapted: %v) should respond %s, but got %s", example.name, adapted, example.body, body)
+			}
+		}
+	}
+}
+
+func TestAdapterFallback(t *testing.T) {
+	type texts []string
+	service.RegisterConverter(reflect.TypeOf(texts{}), service.ConvertToStringSlice)
+	called := false
+	typ := reflect.TypeOf((func(texts) ([]string, error))(nil))
+	RegisterAdapter(typ, func(f interface{}) Adapter {
+		return func(args []interface{}) []interface{} {
+			called = true
+			return []interface{}{nil, nil}
+		}
+	})
+	defer delete(adapters, typ)
+	e := newExecutor(t, definition.Definition{
+		Method: definition.Get,
+		// The default value is assignable but not identical to the parameter type.
+		Parameters: []definition.Parameter{{Source: definition.Query, Name: "t", Default: []string{"default"}}},
+		Function:   func(t texts) ([]string, error) { return t, nil },
+	}, true)
+	if resp := execute(t, e, "/"); called || strings.TrimSpace(resp.Body.String()) != `["default"]` {
+		t.Fatalf("Arguments with assignable types should be passed by reflection, but got %s", resp.Body.String())
+	}
+}
+
+// ListOptions is an auto parameter with validated fields.
+type ListOptions struct {
+	_     struct{} `source:"Auto,,validate"`
+	Start int      `source:"Query,start"`
+	Limit int      `source:"Query,limit" validate:"max=100"`
+}
+
+func TestAggregateErrors(t *testing.T) {
+	d := definition.Definition{
+		Method: definition.List,
+		Parameters: []definition.Parameter{
+			definition.QueryParameterFor("count", ""),
+			definition.AutoParameterFor(""),
+		},
+		Function: func(count int, options *ListOptions) ([]string, error) { return nil, nil },
+	}
+	for _, aggregate := range []bool{false, true} {
+		d.AggregateErrors = aggregate
+		resp := execute(t, newExecutor(t, d, false), "/?count=x&start=y&limit=200")
+		if resp.Code != http.StatusBadRequest {
+			t.Fatalf("Invalid parameters should respond 400, but got %d", resp.Code)
+		}
+		e, err := errors.ParseError(resp.Code, errors.DataTypeJSON, resp.Body.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields := errors.FieldsOf(e)
+		if !aggregate {
+			if fields != nil {
+				t.Fatalf("Errors should not be aggregated, but got %s", resp.Body.String())
+			}
+			continue
+		}
+		expected := []errors.FieldError{
+			{Field: "count", Source: "Query"},
+			{Field: "start", Source: "Query"},
+			{Field: "limit", Source: "Query", Reason: "Nirvana:Validator:InvalidStructField"},
+		}
+		if len(fields) != len(expected) || e.Reason() != "Nirvana:Service:InvalidFields" {
+			t.Fatalf("Errors of %d fields should be aggregated, but got %s", len(expected), resp.Body.String())
+		}
+		for i, f := range fields {
+			if f.Field != expected[i].Field || f.Source != expected[i].Source ||
+				expected[i].Reason != "" && f.Reason != expected[i].Reason {
+				t.Fatalf("Unexpected field error %+v, should be %+v", f, expected[i])
+			}
+		}
+	}
+}
+
+func TestObservers(t *testing.T) {
+	var phases []string
+	observer := service.ObserverFunc(func(ctx context.Context, o *service.Observation) {
+		if o.Start.IsZero() || o.Duration < 0 {
+			t.Fatalf("Phase %s of %s should be timed, but got %+v", o.Phase, o.Name, o)
+		}
+		phases = append(phases, string(o.Phase)+" "+o.Source+o.Operator+":"+o.Name)
+	})
+	d := examples[0].definition
+	d.Consumes = []string{definition.MIMEAll}
+	d.Produces = []string{definition.MIMEJSON}
+	d.ErrorProduces = []string{definition.MIMEJSON}
+	d.Results = definition.DataErrorResults("")
+	e, err := DefinitionToExecutor("/", d, 0, observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	execute(t, e, examples[0].url)
+	expected := []string{
+		"parameter Prefab:context",
+		"parameter Query:msg",
+		"function :github.com/caicloud/nirvana/service/executor.Echo",
+		// Errors are handled before data.
+		"result :Error",
+		"result :Data",
+	}
+	if !reflect.DeepEqual(phases, expected) {
+		t.Fatalf("Phases should be observed in order %v, but got %v", expected, phases)
+	}
+}
+
+// semver is converted only by the converter of pattern "semver".
+type semver struct {
+	Major int
+	Minor int
+}
+
+func TestTypedPattern(t *testing.T) {
+	err := service.RegisterPattern(service.Pattern{
+		Name:       "semver",
+		Expression: "[0-9]+\\.[0-9]+",
+		Type:       reflect.TypeOf(semver{}),
+		Converter: func(ctx context.Context, data []string) (interface{}, error) {
+			v := semver{}
+			_, err := fmt.Sscanf(data[0], "%d.%d", &v.Major, &v.Minor)
+			return v, err
+		},
+		Examples: []string{"1.2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := definition.Definition{
+		Method: definition.Get,
+		Parameters: []definition.Parameter{
+			definition.PrefabParameterFor("context", ""),
+			{Source: definition.Path, Name: "version", Default: semver{Major: 1}},
+		},
+		Function: func(ctx context.Context, v semver) (string, error) {
+			return fmt.Sprintf("%d-%d", v.Major, v.Minor), nil
+		},
+		Consumes:      []string{definition.MIMEAll},
+		Produces:      []string{definition.MIMEText},
+		ErrorProduces: []string{definition.MIMEJSON},
+		Results:       definition.DataErrorResults(""),
+	}
+	// semver has no converter, but it's fine for the path parameter.
+	e, err := DefinitionToExecutor("/versions/{version:semver}", d, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := httptest.NewRecorder()
+	ctx := service.NewHTTPContext(resp, httptest.NewRequest(http.MethodGet, "/versions/1.2", nil))
+	ctx.ValueContainer().Set("version", "1.2")
+	if err := e.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if body := resp.Body.String(); body != "1-2" {
+		t.Fatalf("Response body should be 1-2, but got %s", body)
+	}
+
+	// Other sources still require converters.
+	d.Parameters[1].Source = definition.Query
+	if _, err := DefinitionToExecutor("/versions/{version:semver}", d, 0); err == nil {
+		t.Fatal("Query parameter of type semver should be invalid without a converter")
+	}
+}
+
+func benchmarkExecute(b *testing.B, adapted bool) {
+	useAdapters(adapted)
+	defer useAdapters(false)
+	for _, example := range examples {
+		e := newExecutor(b, example.definition, adapted)
+		b.Run(example.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				execute(b, e, example.url)
+			}
+		})
+	}
+}
+
+func BenchmarkExecuteByReflection(b *testing.B) {
+	benchmarkExecute(b, false)
+}
+
+func BenchmarkExecuteByAdapter(b *testing.B) {
+	benchmarkExecute(b, true)
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package service
 
 import (
+	"context"
 	"fmt"
 	"mime"
 	"net/http"
@@ -121,17 +122,94 @@ func ContentType(req *http.Request) (string, error) {
 	if err != nil {
 		return "", invalidContentType.Error(ct)
 	}
+	if ConsumerFor(result) == nil {
+		// Versioned vendor media types are consumed by consumers for their base types.
+		if base, _, _, ok := definition.ParseVendorMediaType(result); ok {
+			return base, nil
+		}
+	}
 	return result, nil
 }
 
 // AcceptTypes is a util to get accept types from a request.
-// Accept types are sorted by q.
+// Accept types are sorted by q. Versioned vendor media types are replaced
+// by their base types if there is no producer for them.
 func AcceptTypes(req *http.Request) ([]string, error) {
 	ct := req.Header.Get("Accept")
 	if ct == "" {
 		return []string{definition.MIMEAll}, nil
 	}
-	return parseAcceptTypes(ct)
+	ats, err := parseAcceptTypes(ct)
+	if err != nil {
+		return nil, err
+	}
+	for i, at := range ats {
+		if ProducerFor(at) != nil {
+			continue
+		}
+		if base, _, _, ok := definition.ParseVendorMediaType(mediaTypeOf(at)); ok {
+			ats[i] = base
+		}
+	}
+	return ats, nil
+}
+
+// RequestVersion is a util to get the requested version from a request.
+// Header "Accept-Version" has the highest priority, then versioned vendor
+// media types in header "Accept" and "Content-Type". It returns an empty
+// string if the request does not ask for a version.
+func RequestVersion(req *http.Request) string {
+	if version := definition.NormalizeVersion(req.Header.Get(definition.HeaderAcceptVersion)); version != "" {
+		return version
+	}
+	if _, version, ok := requestVendor(req); ok {
+		return version
+	}
+	return ""
+}
+
+// requestVendor finds the first versioned vendor media type in a request.
+func requestVendor(req *http.Request) (vendor string, version string, ok bool) {
+	if accept := req.Header.Get("Accept"); accept != "" {
+		if ats, err := parseAcceptTypes(accept); err == nil {
+			for _, at := range ats {
+				if _, vendor, version, ok := definition.ParseVendorMediaType(mediaTypeOf(at)); ok {
+					return vendor, version, true
+				}
+			}
+		}
+	}
+	if ct, _, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil {
+		if _, vendor, version, ok := definition.ParseVendorMediaType(ct); ok {
+			return vendor, version, true
+		}
+	}
+	return "", "", false
+}
+
+// VersionedContentType returns the content type which carries the version of
+// current request. If the request asks for a vendor media type of the version,
+// the vendor media type is returned. Otherwise the version is set as a
+// parameter of the content type. ex. "application/json; version=v2".
+func VersionedContentType(ctx context.Context, contentType string) string {
+	httpCtx := HTTPContextFrom(ctx)
+	versioned, ok := httpCtx.(VersionedContext)
+	if !ok || versioned.Version() == "" || contentType == definition.MIMENone {
+		return contentType
+	}
+	version := versioned.Version()
+	if vendor, v, ok := requestVendor(httpCtx.Request()); ok && v == version {
+		return definition.VendorMediaType(contentType, vendor, version)
+	}
+	return mime.FormatMediaType(contentType, map[string]string{"version": version})
+}
+
+// mediaTypeOf removes parameters of a media type.
+func mediaTypeOf(v string) string {
+	if index := strings.IndexByte(v, ';'); index >= 0 {
+		v = v[:index]
+	}
+	return strings.TrimSpace(v)
 }
 
 type acceptType struct {
@@ -70,9 +70,10 @@ type DestinationHandler interface {
 }
 
 var handlers = map[definition.Destination]DestinationHandler{
-	definition.Meta:  &MetaDestinationHandler{},
-	definition.Data:  &DataDestinationHandler{},
-	definition.Error: &ErrorDestinationHandler{},
+	definition.Meta:       &MetaDestinationHandler{},
+	definition.Data:       &DataDestinationHandler{},
+	definition.Validators: &ValidatorsDestinationHandler{},
+	definition.Error:      &ErrorDestinationHandler{},
 }
 
 // DestinationHandlerFor gets a type handler for specified type.
@@ -185,10 +186,15 @@ func WriteError(ctx context.Context, producers []Producer, err interface{}) erro
 		// Choose the first producer
 		producer = producers[0]
 	}
+	if producer.ContentType() == definition.MIMEProblemJSON {
+		msg = problemOf(ctx, code, err, msg)
+	} else if envelope := envelopeFor(ctx, producer, code); envelope != nil {
+		msg = envelope.WrapError(ctx, code, err, msg)
+	}
 	resp := httpCtx.ResponseWriter()
 	if resp.HeaderWritable() {
 		// Error always has highest priority. So it can override "Content-Type".
-		resp.Header().Set("Content-Type", producer.ContentType())
+		resp.Header().Set("Content-Type", VersionedContentType(ctx, producer.ContentType()))
 		resp.WriteHeader(code)
 	}
 	return producer.Produce(resp, msg)
@@ -209,18 +215,58 @@ func WriteData(ctx context.Context, producers []Producer, code int, data interfa
 	if producer == nil {
 		return NoProducerToWrite.Error(ats)
 	}
+	// body is the data wrapped by the envelope.
+	var body interface{}
+	if envelope := envelopeFor(ctx, producer, code); envelope != nil && !raw(data) {
+		body = envelope.WrapData(ctx, code, data)
+	}
 	resp := httpCtx.ResponseWriter()
 	if resp.HeaderWritable() {
 		// If "Content-Type" has been set, ignore producer's.
 		ctype := resp.Header().Get("Content-Type")
 		if strings.TrimSpace(ctype) == "" {
-			resp.Header().Set("Content-Type", producer.ContentType())
+			resp.Header().Set("Content-Type", VersionedContentType(ctx, producer.ContentType()))
+		}
+		if code == http.StatusCreated || code == http.StatusAccepted {
+			if locator, ok := data.(Locator); ok && locator.Location() != "" && resp.Header().Get("Location") == "" {
+				resp.Header().Set("Location", locator.Location())
+			}
+		}
+		if provider, ok := data.(MetaProvider); ok {
+			for key, value := range provider.Meta() {
+				if value != "" && resp.Header().Get(key) == "" {
+					resp.Header().Set(key, value)
+				}
+			}
+		}
+		req := httpCtx.Request()
+		if code == http.StatusOK && (req.Method == http.MethodGet || req.Method == http.MethodHead) {
+			return writeConditionalData(ctx, resp, req, producer, code, data, body)
 		}
 		resp.WriteHeader(code)
 	}
+	if body != nil {
+		return producer.Produce(resp, body)
+	}
 	return producer.Produce(resp, data)
 }
 
+// Locator is implemented by data which can be located by a URL, such as a
+// created resource or a long-running operation. For 201 and 202 responses,
+// the URL is written to header "Location".
+type Locator interface {
+	// Location returns the URL of the data.
+	Location() string
+}
+
+// MetaProvider is implemented by data which carries meta of itself, such as
+// links and the total count of a page. The meta is written to response
+// headers unless the headers have been set.
+type MetaProvider interface {
+	// Meta returns the meta of the data.
+	Meta() map[string]string
+}
+
 // ChooseProducer chooses the right producer.
 func ChooseProducer(acceptTypes []string, producers []Producer) Producer {
 	if len(acceptTypes) <= 0 || len(producers) <= 0 {
@@ -59,6 +59,14 @@ func FirstContextParameter() DefinitionModifier {
 	}
 }
 
+// AggregateErrors makes all definitions respond errors of all parameters
+// in one error.
+func AggregateErrors() DefinitionModifier {
+	return func(d *definition.Definition) {
+		d.AggregateErrors = true
+	}
+}
+
 // ConsumeAllIfConsumesIsEmpty adds definition.MIMEAll to consumes if consumes
 // is empty.
 func ConsumeAllIfConsumesIsEmpty() DefinitionModifier {
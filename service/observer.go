@@ -0,0 +1,127 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// Phase is a phase of executing a definition.
+type Phase string
+
+const (
+	// PhaseParameter generates a parameter from the request.
+	PhaseParameter Phase = "parameter"
+	// PhaseOperator applies an operator to a parameter or a result.
+	PhaseOperator Phase = "operator"
+	// PhaseFunction calls the function of the definition.
+	PhaseFunction Phase = "function"
+	// PhaseResult handles a result, such as producing the response body.
+	PhaseResult Phase = "result"
+)
+
+// Observation describes a finished phase of executing a definition.
+type Observation struct {
+	// Phase is the observed phase.
+	Phase Phase
+	// Name is the name of the parameter for PhaseParameter, the name of the
+	// function for PhaseFunction, and the destination of the result for
+	// PhaseResult. For PhaseOperator, it's the parameter name or the result
+	// destination which the operator is applied to.
+	Name string
+	// Source is the source of the parameter. It's set for PhaseParameter and
+	// operators of parameters.
+	Source string
+	// Operator is the kind of the operator for PhaseOperator.
+	Operator string
+	// Start is when the phase starts.
+	Start time.Time
+	// Duration is how long the phase takes.
+	Duration time.Duration
+	// Err is the error returned in the phase. Errors returned by functions
+	// are results and are not reported here.
+	Err error
+}
+
+// Observer observes phases of executing definitions, for instance, to
+// create tracing spans or to record metrics. Callbacks are called in the
+// goroutine serving the request after phases finish, so they should be fast.
+type Observer interface {
+	// ParameterGenerated is called after a parameter is generated.
+	ParameterGenerated(ctx context.Context, o *Observation)
+	// OperatorApplied is called after an operator is applied.
+	OperatorApplied(ctx context.Context, o *Observation)
+	// FunctionCalled is called after the function returns.
+	FunctionCalled(ctx context.Context, o *Observation)
+	// ResultHandled is called after a result is handled.
+	ResultHandled(ctx context.Context, o *Observation)
+}
+
+// ObserverFunc is a function which observes all phases. It implements Observer.
+type ObserverFunc func(ctx context.Context, o *Observation)
+
+// ParameterGenerated calls the function.
+func (f ObserverFunc) ParameterGenerated(ctx context.Context, o *Observation) {
+	f(ctx, o)
+}
+
+// OperatorApplied calls the function.
+func (f ObserverFunc) OperatorApplied(ctx context.Context, o *Observation) {
+	f(ctx, o)
+}
+
+// FunctionCalled calls the function.
+func (f ObserverFunc) FunctionCalled(ctx context.Context, o *Observation) {
+	f(ctx, o)
+}
+
+// ResultHandled calls the function.
+func (f ObserverFunc) ResultHandled(ctx context.Context, o *Observation) {
+	f(ctx, o)
+}
+
+// Observers notifies observers in order. It implements Observer.
+type Observers []Observer
+
+// ParameterGenerated notifies all observers.
+func (os Observers) ParameterGenerated(ctx context.Context, o *Observation) {
+	for _, observer := range os {
+		observer.ParameterGenerated(ctx, o)
+	}
+}
+
+// OperatorApplied notifies all observers.
+func (os Observers) OperatorApplied(ctx context.Context, o *Observation) {
+	for _, observer := range os {
+		observer.OperatorApplied(ctx, o)
+	}
+}
+
+// FunctionCalled notifies all observers.
+func (os Observers) FunctionCalled(ctx context.Context, o *Observation) {
+	for _, observer := range os {
+		observer.FunctionCalled(ctx, o)
+	}
+}
+
+// ResultHandled notifies all observers.
+func (os Observers) ResultHandled(ctx context.Context, o *Observation) {
+	for _, observer := range os {
+		observer.ResultHandled(ctx, o)
+	}
+}
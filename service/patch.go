@@ -0,0 +1,598 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/operators/validator"
+)
+
+// JSON patch operation types. See RFC 6902.
+const (
+	PatchOperationAdd     = "add"
+	PatchOperationRemove  = "remove"
+	PatchOperationReplace = "replace"
+	PatchOperationMove    = "move"
+	PatchOperationCopy    = "copy"
+	PatchOperationTest    = "test"
+)
+
+// PatchOperation is an operation of JSON patch.
+type PatchOperation struct {
+	// Op is the operation type.
+	Op string `json:"op"`
+	// Path is a JSON pointer to the target location.
+	Path string `json:"path"`
+	// From is a JSON pointer to the source location of move and copy.
+	From string `json:"from,omitempty"`
+	// Value is the value for add, replace and test.
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Patch is a body parameter type which carries a JSON merge patch
+// (application/merge-patch+json) or a JSON patch (application/json-patch+json).
+// Handlers can inspect the document and apply it to a Go object:
+//
+//  func PatchApplication(ctx context.Context, name string, patch *service.Patch) error {
+//      app := getApplication(name)
+//      if err := patch.Apply(ctx, app); err != nil {
+//          return err
+//      }
+//      return saveApplication(app)
+//  }
+//
+// Unlike a plain struct body, a patch keeps the difference between an
+// omitted field and a field set to null.
+type Patch struct {
+	contentType string
+	document    json.RawMessage
+}
+
+// NewMergePatch creates a JSON merge patch from a document. The document is
+// marshaled to JSON, and fields with null value remove the fields in target.
+func NewMergePatch(document interface{}) (*Patch, error) {
+	data, err := json.Marshal(document)
+	if err != nil {
+		return nil, invalidPatch.Error(definition.MIMEMergePatch, err.Error())
+	}
+	return newPatch(definition.MIMEMergePatch, data)
+}
+
+// NewJSONPatch creates a JSON patch from operations.
+func NewJSONPatch(operations ...PatchOperation) (*Patch, error) {
+	if operations == nil {
+		operations = []PatchOperation{}
+	}
+	data, err := json.Marshal(operations)
+	if err != nil {
+		return nil, invalidPatch.Error(definition.MIMEJSONPatch, err.Error())
+	}
+	return newPatch(definition.MIMEJSONPatch, data)
+}
+
+// newPatch creates a patch and validates its document.
+func newPatch(contentType string, data []byte) (*Patch, error) {
+	data = bytes.TrimSpace(data)
+	if !json.Valid(data) {
+		return nil, invalidPatch.Error(contentType, "malformed JSON")
+	}
+	switch contentType {
+	case definition.MIMEMergePatch:
+	case definition.MIMEJSONPatch:
+		operations := []PatchOperation{}
+		if err := json.Unmarshal(data, &operations); err != nil {
+			return nil, invalidPatch.Error(contentType, err.Error())
+		}
+		for i, op := range operations {
+			if err := op.validate(); err != nil {
+				return nil, invalidPatch.Error(contentType, fmt.Sprintf("operation %d: %s", i, err.Error()))
+			}
+		}
+	default:
+		return nil, invalidPatch.Error(contentType, "unknown patch type")
+	}
+	return &Patch{contentType: contentType, document: data}, nil
+}
+
+// validate checks if an operation has all required members.
+func (o *PatchOperation) validate() error {
+	if _, err := parsePointer(o.Path); err != nil {
+		return err
+	}
+	switch o.Op {
+	case PatchOperationAdd, PatchOperationReplace, PatchOperationTest:
+		if o.Value == nil {
+			return fmt.Errorf("%s requires a value", o.Op)
+		}
+	case PatchOperationMove, PatchOperationCopy:
+		if _, err := parsePointer(o.From); err != nil {
+			return err
+		}
+	case PatchOperationRemove:
+	default:
+		return fmt.Errorf("unknown op %q", o.Op)
+	}
+	return nil
+}
+
+// ContentType returns the content type of the patch.
+func (p *Patch) ContentType() string {
+	return p.contentType
+}
+
+// Document returns the raw patch document.
+func (p *Patch) Document() json.RawMessage {
+	return p.document
+}
+
+// MarshalJSON returns the patch document.
+func (p *Patch) MarshalJSON() ([]byte, error) {
+	if len(p.document) == 0 {
+		return []byte("null"), nil
+	}
+	return p.document, nil
+}
+
+// UnmarshalJSON stores data as the patch document. An array is treated
+// as a JSON patch and others are treated as a JSON merge patch.
+func (p *Patch) UnmarshalJSON(data []byte) error {
+	contentType := definition.MIMEMergePatch
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+		contentType = definition.MIMEJSONPatch
+	}
+	patch, err := newPatch(contentType, data)
+	if err != nil {
+		return err
+	}
+	*p = *patch
+	return nil
+}
+
+// Operations returns operations of a JSON patch.
+// For a JSON merge patch, it returns nil.
+func (p *Patch) Operations() []PatchOperation {
+	if p.contentType != definition.MIMEJSONPatch {
+		return nil
+	}
+	operations := []PatchOperation{}
+	// The document has been validated.
+	_ = json.Unmarshal(p.document, &operations)
+	return operations
+}
+
+// Lookup gets the value at the JSON pointer in a JSON merge patch. The
+// second result reports whether the field is in the patch. A field with
+// value null means the field should be removed:
+//
+//  value, ok := patch.Lookup("/spec/replicas")
+//  switch {
+//  case !ok:
+//      // Field is omitted.
+//  case string(value) == "null":
+//      // Field is set to null.
+//  }
+func (p *Patch) Lookup(pointer string) (json.RawMessage, bool) {
+	if p.contentType != definition.MIMEMergePatch {
+		return nil, false
+	}
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, false
+	}
+	current := p.document
+	for _, token := range tokens {
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(current, &fields); err != nil {
+			return nil, false
+		}
+		value, ok := fields[token]
+		if !ok {
+			return nil, false
+		}
+		current = value
+	}
+	return current, true
+}
+
+// Apply applies the patch to target and validates target by its
+// validation tags afterwards. target must be a non-nil pointer.
+// target is patched through its JSON representation, so fields which
+// are not marshaled to JSON are reset. If the patch can't be applied
+// or the result is invalid, target is not modified.
+func (p *Patch) Apply(ctx context.Context, target interface{}) error {
+	value := reflect.ValueOf(target)
+	if !value.IsValid() || value.Kind() != reflect.Ptr || value.IsNil() {
+		return invalidPatchTarget.Error(reflect.TypeOf(target))
+	}
+	data, err := json.Marshal(target)
+	if err != nil {
+		return invalidPatchTarget.Error(value.Type())
+	}
+	original, err := decodeJSON(data)
+	if err != nil {
+		return invalidPatchTarget.Error(value.Type())
+	}
+	var result interface{}
+	switch p.contentType {
+	case definition.MIMEMergePatch:
+		patch, err := decodeJSON(p.document)
+		if err != nil {
+			return invalidPatch.Error(p.contentType, err.Error())
+		}
+		result = mergePatch(original, patch)
+	case definition.MIMEJSONPatch:
+		result, err = applyOperations(original, p.Operations())
+		if err != nil {
+			return err
+		}
+	default:
+		return invalidPatch.Error(p.contentType, "unknown patch type")
+	}
+	data, err = json.Marshal(result)
+	if err != nil {
+		return unappliablePatch.Error(err.Error())
+	}
+	patched := reflect.New(value.Type().Elem())
+	if err := json.Unmarshal(data, patched.Interface()); err != nil {
+		return unappliablePatch.Error(err.Error())
+	}
+	if elem := reflect.Indirect(patched.Elem()); elem.Kind() == reflect.Struct {
+		if err := validator.ValidateStruct(ctx, elem.Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	value.Elem().Set(patched.Elem())
+	return nil
+}
+
+// decodeJSON decodes data to a generic value. Numbers are kept as json.Number
+// to avoid losing precision of integers.
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// mergePatch applies a JSON merge patch to target. See RFC 7396.
+func mergePatch(target, patch interface{}) interface{} {
+	fields, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	object, ok := target.(map[string]interface{})
+	if !ok {
+		object = map[string]interface{}{}
+	}
+	for key, value := range fields {
+		if value == nil {
+			delete(object, key)
+			continue
+		}
+		object[key] = mergePatch(object[key], value)
+	}
+	return object
+}
+
+// applyOperations applies JSON patch operations to doc. See RFC 6902.
+func applyOperations(doc interface{}, operations []PatchOperation) (interface{}, error) {
+	var err error
+	for _, op := range operations {
+		path, _ := parsePointer(op.Path)
+		var value interface{}
+		if op.Value != nil {
+			if value, err = decodeJSON(op.Value); err != nil {
+				return nil, invalidPatch.Error(definition.MIMEJSONPatch, err.Error())
+			}
+		}
+		switch op.Op {
+		case PatchOperationAdd:
+			doc, err = addValue(doc, path, value)
+		case PatchOperationRemove:
+			doc, _, err = removeValue(doc, path)
+		case PatchOperationReplace:
+			if len(path) == 0 {
+				doc = value
+			} else if doc, _, err = removeValue(doc, path); err == nil {
+				doc, err = addValue(doc, path, value)
+			}
+		case PatchOperationMove:
+			from, _ := parsePointer(op.From)
+			if len(path) > len(from) && isPrefix(from, path) {
+				return nil, unappliablePatch.Error(fmt.Sprintf("can't move %s to its child %s", op.From, op.Path))
+			}
+			if doc, value, err = removeValue(doc, from); err == nil {
+				doc, err = addValue(doc, path, value)
+			}
+		case PatchOperationCopy:
+			from, _ := parsePointer(op.From)
+			if value, err = getValue(doc, from); err == nil {
+				doc, err = addValue(doc, path, copyValue(value))
+			}
+		case PatchOperationTest:
+			var current interface{}
+			if current, err = getValue(doc, path); err == nil && !equalValue(current, value) {
+				return nil, patchTestFailed.Error(op.Path)
+			}
+		}
+		if err != nil {
+			return nil, unappliablePatch.Error(fmt.Sprintf("%s %s: %s", op.Op, op.Path, err.Error()))
+		}
+	}
+	return doc, nil
+}
+
+// parsePointer parses a JSON pointer to reference tokens. See RFC 6901.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("JSON pointer %q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+func isPrefix(prefix, tokens []string) bool {
+	for i := range prefix {
+		if prefix[i] != tokens[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIndex parses token as an index of an array with specified length.
+func arrayIndex(token string, length int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index >= length || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	return index, nil
+}
+
+func getValue(doc interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("field %q not found", token)
+			}
+			doc = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node))
+			if err != nil {
+				return nil, err
+			}
+			doc = node[index]
+		default:
+			return nil, fmt.Errorf("can't find %q in a non-container value", token)
+		}
+	}
+	return doc, nil
+}
+
+// addValue adds value to doc and returns the new doc.
+func addValue(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	token, last := tokens[0], len(tokens) == 1
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		if last {
+			node[token] = value
+			return node, nil
+		}
+		child, ok := node[token]
+		if !ok {
+			return nil, fmt.Errorf("field %q not found", token)
+		}
+		child, err := addValue(child, tokens[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = child
+		return node, nil
+	case []interface{}:
+		if last {
+			index := len(node)
+			if token != "-" {
+				var err error
+				if index, err = arrayIndex(token, len(node)+1); err != nil {
+					return nil, err
+				}
+			}
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		}
+		index, err := arrayIndex(token, len(node))
+		if err != nil {
+			return nil, err
+		}
+		child, err := addValue(node[index], tokens[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = child
+		return node, nil
+	}
+	return nil, fmt.Errorf("can't add %q to a non-container value", token)
+}
+
+// removeValue removes the value from doc. It returns the new doc and the removed value.
+func removeValue(doc interface{}, tokens []string) (interface{}, interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, nil, fmt.Errorf("can't remove the whole document")
+	}
+	token, last := tokens[0], len(tokens) == 1
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("field %q not found", token)
+		}
+		if last {
+			delete(node, token)
+			return node, child, nil
+		}
+		child, removed, err := removeValue(child, tokens[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		node[token] = child
+		return node, removed, nil
+	case []interface{}:
+		index, err := arrayIndex(token, len(node))
+		if err != nil {
+			return nil, nil, err
+		}
+		if last {
+			removed := node[index]
+			return append(node[:index], node[index+1:]...), removed, nil
+		}
+		child, removed, err := removeValue(node[index], tokens[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		node[index] = child
+		return node, removed, nil
+	}
+	return nil, nil, fmt.Errorf("can't remove %q from a non-container value", token)
+}
+
+// copyValue deeply copies a generic JSON value.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			object[key] = copyValue(value)
+		}
+		return object
+	case []interface{}:
+		array := make([]interface{}, len(v))
+		for i, value := range v {
+			array[i] = copyValue(value)
+		}
+		return array
+	}
+	return value
+}
+
+// equalValue checks if two generic JSON values are equal.
+// Numbers are compared by their values.
+func equalValue(a, b interface{}) bool {
+	switch va := a.(type) {
+	case json.Number:
+		vb, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fa, errA := va.Float64()
+		fb, errB := vb.Float64()
+		return errA == nil && errB == nil && fa == fb
+	case map[string]interface{}:
+		vb, ok := b.(map[string]interface{})
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for key, value := range va {
+			other, ok := vb[key]
+			if !ok || !equalValue(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		vb, ok := b.([]interface{})
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if !equalValue(va[i], vb[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// PatchConsumer implements Consumer for JSON merge patch and JSON patch.
+// It consumes data into a Patch. For other types, the data is decoded as JSON.
+type PatchConsumer struct {
+	RawSerializer
+	contentType string
+}
+
+// NewPatchConsumer creates a patch consumer for specified content type.
+// contentType should be definition.MIMEMergePatch or definition.MIMEJSONPatch.
+func NewPatchConsumer(contentType string) *PatchConsumer {
+	return &PatchConsumer{
+		contentType: contentType,
+	}
+}
+
+// ContentType returns patch MIME type.
+func (s *PatchConsumer) ContentType() string {
+	return s.contentType
+}
+
+// Consume reads a patch document from r into v.
+func (s *PatchConsumer) Consume(r io.Reader, v interface{}) error {
+	if s.CanConsumeData(s.ContentType(), r, v) {
+		return s.ConsumeData(s.ContentType(), r, v)
+	}
+	if target, ok := v.(*Patch); ok {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		patch, err := newPatch(s.ContentType(), data)
+		if err != nil {
+			return err
+		}
+		*target = *patch
+		return nil
+	}
+	err := json.NewDecoder(r).Decode(v)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/caicloud/nirvana/definition"
+)
+
+type patchSpec struct {
+	Replicas int               `json:"replicas" validate:"min=0,max=10"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Ports    []int             `json:"ports,omitempty"`
+}
+
+type patchApp struct {
+	Name        string    `json:"name"`
+	Description *string   `json:"description,omitempty"`
+	Spec        patchSpec `json:"spec"`
+}
+
+func newPatchApp() *patchApp {
+	description := "old"
+	return &patchApp{
+		Name:        "app",
+		Description: &description,
+		Spec: patchSpec{
+			Replicas: 1,
+			Labels:   map[string]string{"a": "1", "b": "2"},
+			Ports:    []int{80, 443},
+		},
+	}
+}
+
+func generatePatch(t *testing.T, contentType string, data string) *Patch {
+	g := &BodyParameterGenerator{}
+	target := reflect.TypeOf(&Patch{})
+	if err := g.Validate("test", nil, target); err != nil {
+		t.Fatal(err)
+	}
+	result, err := g.Generate(context.Background(), &vc2{contentType: contentType, data: data}, AllConsumers(), "test", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch, ok := result.(*Patch)
+	if !ok {
+		t.Fatalf("Result is not a patch: %+v", result)
+	}
+	if patch.ContentType() != contentType {
+		t.Fatalf("Patch has a wrong content type: %s", patch.ContentType())
+	}
+	return patch
+}
+
+func TestMergePatch(t *testing.T) {
+	patch := generatePatch(t, definition.MIMEMergePatch,
+		`{"description":null,"spec":{"replicas":3,"labels":{"a":null,"c":"3"}}}`)
+
+	if value, ok := patch.Lookup("/description"); !ok || string(value) != "null" {
+		t.Fatalf("Description should be set to null: %s, %v", value, ok)
+	}
+	if value, ok := patch.Lookup("/spec/replicas"); !ok || string(value) != "3" {
+		t.Fatalf("Replicas should be 3: %s, %v", value, ok)
+	}
+	if _, ok := patch.Lookup("/name"); ok {
+		t.Fatal("Name should be omitted")
+	}
+
+	app := newPatchApp()
+	if err := patch.Apply(context.Background(), app); err != nil {
+		t.Fatal(err)
+	}
+	expected := &patchApp{
+		Name: "app",
+		Spec: patchSpec{
+			Replicas: 3,
+			Labels:   map[string]string{"b": "2", "c": "3"},
+			Ports:    []int{80, 443},
+		},
+	}
+	if !reflect.DeepEqual(app, expected) {
+		t.Fatalf("Patched object is not correct: %+v", app)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	patch := generatePatch(t, definition.MIMEJSONPatch, `[
+		{"op":"test","path":"/spec/replicas","value":1.0},
+		{"op":"replace","path":"/spec/replicas","value":2},
+		{"op":"remove","path":"/description"},
+		{"op":"add","path":"/spec/ports/1","value":8080},
+		{"op":"add","path":"/spec/ports/-","value":9090},
+		{"op":"move","path":"/spec/labels/c","from":"/spec/labels/a"},
+		{"op":"copy","path":"/spec/labels/d~1e","from":"/name"}
+	]`)
+	if len(patch.Operations()) != 7 {
+		t.Fatalf("Patch has wrong operations: %+v", patch.Operations())
+	}
+
+	app := newPatchApp()
+	if err := patch.Apply(context.Background(), app); err != nil {
+		t.Fatal(err)
+	}
+	expected := &patchApp{
+		Name: "app",
+		Spec: patchSpec{
+			Replicas: 2,
+			Labels:   map[string]string{"b": "2", "c": "1", "d/e": "app"},
+			Ports:    []int{80, 8080, 443, 9090},
+		},
+	}
+	if !reflect.DeepEqual(app, expected) {
+		t.Fatalf("Patched object is not correct: %+v", app)
+	}
+}
+
+func TestInvalidPatch(t *testing.T) {
+	documents := map[string][]string{
+		definition.MIMEMergePatch: {`{"name":`},
+		definition.MIMEJSONPatch: {
+			`{"op":"remove","path":"/name"}`,
+			`[{"op":"unknown","path":"/name"}]`,
+			`[{"op":"add","path":"/name"}]`,
+			`[{"op":"remove","path":"name"}]`,
+			`[{"op":"move","path":"/name","from":"name"}]`,
+		},
+	}
+	for contentType, docs := range documents {
+		for _, doc := range docs {
+			consumer := ConsumerFor(contentType)
+			err := consumer.Consume(&file{[]byte(doc), 0}, &Patch{})
+			if !invalidPatch.Derived(err) {
+				t.Fatalf("Document %s should be invalid, but got: %v", doc, err)
+			}
+		}
+	}
+}
+
+func TestUnappliablePatch(t *testing.T) {
+	cases := []struct {
+		operations []PatchOperation
+		check      func(error) bool
+	}{
+		{
+			[]PatchOperation{{Op: PatchOperationRemove, Path: "/unknown"}},
+			unappliablePatch.Derived,
+		},
+		{
+			[]PatchOperation{{Op: PatchOperationAdd, Path: "/spec/ports/5", Value: []byte("1")}},
+			unappliablePatch.Derived,
+		},
+		{
+			[]PatchOperation{{Op: PatchOperationMove, Path: "/spec/labels/x", From: "/spec"}},
+			unappliablePatch.Derived,
+		},
+		{
+			[]PatchOperation{{Op: PatchOperationReplace, Path: "/name", Value: []byte("1")}},
+			unappliablePatch.Derived,
+		},
+		{
+			[]PatchOperation{{Op: PatchOperationTest, Path: "/name", Value: []byte(`"other"`)}},
+			patchTestFailed.Derived,
+		},
+		{
+			[]PatchOperation{{Op: PatchOperationReplace, Path: "/spec/replicas", Value: []byte("11")}},
+			func(err error) bool { return err != nil },
+		},
+	}
+	for _, c := range cases {
+		patch, err := NewJSONPatch(c.operations...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		app := newPatchApp()
+		err = patch.Apply(context.Background(), app)
+		if !c.check(err) {
+			t.Fatalf("Unexpected error for %+v: %v", c.operations, err)
+		}
+		if !reflect.DeepEqual(app, newPatchApp()) {
+			t.Fatalf("Object should not be modified by %+v: %+v", c.operations, app)
+		}
+	}
+
+	patch, err := NewMergePatch(map[string]interface{}{"name": "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := patch.Apply(context.Background(), patchApp{}); !invalidPatchTarget.Derived(err) {
+		t.Fatalf("Patch should not be applied to a non-pointer: %v", err)
+	}
+}
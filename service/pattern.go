@@ -0,0 +1,190 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Pattern is a named pattern of path parameters. Paths use patterns by names:
+//  /applications/{id:int}
+type Pattern struct {
+	// Name is the name of the pattern.
+	Name string
+	// Expression is the regular expression of the pattern.
+	Expression string
+	// Format is the format of values in API docs, such as "uuid" and "date".
+	Format string
+	// Type is the type which Converter converts values to. Path parameters of
+	// the type are converted by Converter. Parameters of other types are
+	// converted by their converters.
+	Type reflect.Type
+	// Converter converts values to Type.
+	Converter Converter
+	// Examples are values matched by the pattern. They are used to detect
+	// conflicts between patterns.
+	Examples []string
+}
+
+// DateFormat is the layout of values of pattern "date".
+const DateFormat = "2006-01-02"
+
+var patterns = map[string]*Pattern{}
+
+func init() {
+	for _, p := range []Pattern{
+		{
+			Name:       "int",
+			Expression: "-?[0-9]+",
+			Type:       reflect.TypeOf(0),
+			Converter:  ConvertToInt,
+			Examples:   []string{"1", "-1"},
+		},
+		{
+			Name:       "uuid",
+			Expression: "[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}",
+			Format:     "uuid",
+			Examples:   []string{"3d2f0a6c-9a8e-4a5f-8b0e-5f7c2a1d9e4b"},
+		},
+		{
+			Name:       "slug",
+			Expression: "[a-z0-9]+(?:-[a-z0-9]+)*",
+			Examples:   []string{"my-app", "app"},
+		},
+		{
+			Name:       "date",
+			Expression: "[0-9]{4}-[0-9]{2}-[0-9]{2}",
+			Format:     "date",
+			Type:       reflect.TypeOf(time.Time{}),
+			Converter:  ConvertToDate,
+			Examples:   []string{"2020-01-02"},
+		},
+	} {
+		if err := RegisterPattern(p); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterPattern registers a named pattern. New pattern overrides old one.
+// Examples of the pattern must match its expression.
+func RegisterPattern(p Pattern) error {
+	if p.Name == "" || strings.ContainsAny(p.Name, "{}:/") {
+		return invalidPatternName.Error(p.Name)
+	}
+	r, err := regexp.Compile("^(?:" + p.Expression + ")$")
+	if err != nil || hasNamedSubexp(r) {
+		// Named groups conflict with keys in paths.
+		return invalidPattern.Error(p.Name, p.Expression)
+	}
+	for _, example := range p.Examples {
+		if !r.MatchString(example) {
+			return invalidPattern.Error(p.Name, p.Expression)
+		}
+	}
+	if (p.Type == nil) != (p.Converter == nil) {
+		return invalidPatternType.Error(p.Name)
+	}
+	patterns[p.Name] = &p
+	return nil
+}
+
+func hasNamedSubexp(r *regexp.Regexp) bool {
+	for _, name := range r.SubexpNames() {
+		if name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// PatternFor gets a pattern by name. It returns nil if the pattern does not exist.
+func PatternFor(name string) *Pattern {
+	return patterns[name]
+}
+
+// PathExpressions returns expressions of keys in a path. Names of patterns
+// are not replaced. Keys without expressions are not returned.
+//  /segments/{segment:slug}/paths/{path:*} -> {"segment": "slug", "path": "*"}
+func PathExpressions(path string) map[string]string {
+	result := map[string]string{}
+	start := -1
+	depth := 0
+	for i, c := range path {
+		switch c {
+		case '{':
+			if depth == 0 {
+				start = i + 1
+			}
+			depth++
+		case '}':
+			depth--
+			if depth == 0 && start >= 0 {
+				exp := path[start:i]
+				if pos := strings.Index(exp, ":"); pos > 0 {
+					result[exp[:pos]] = exp[pos+1:]
+				}
+				start = -1
+			}
+		}
+	}
+	return result
+}
+
+// PathPatterns returns named patterns of keys in a path.
+func PathPatterns(path string) map[string]*Pattern {
+	result := map[string]*Pattern{}
+	for key, exp := range PathExpressions(path) {
+		if p := PatternFor(exp); p != nil {
+			result[key] = p
+		}
+	}
+	return result
+}
+
+// Overlap checks if values may match both patterns. It's true if an example
+// of a pattern matches the other.
+func (p *Pattern) Overlap(o *Pattern) bool {
+	if p.Name == o.Name {
+		return true
+	}
+	return p.matchesExamplesOf(o) || o.matchesExamplesOf(p)
+}
+
+func (p *Pattern) matchesExamplesOf(o *Pattern) bool {
+	r := regexp.MustCompile("^(?:" + p.Expression + ")$")
+	for _, example := range o.Examples {
+		if r.MatchString(example) {
+			return true
+		}
+	}
+	return false
+}
+
+// ConvertToDate converts []string to time.Time in DateFormat. Only the first data is used.
+func ConvertToDate(ctx context.Context, data []string) (interface{}, error) {
+	origin := data[0]
+	target, err := time.Parse(DateFormat, origin)
+	if err != nil {
+		return nil, invalidConversion.Error(origin, "date")
+	}
+	return target, nil
+}
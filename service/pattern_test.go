@@ -0,0 +1,61 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegisterPattern(t *testing.T) {
+	if err := RegisterPattern(Pattern{Name: "hex", Expression: "[0-9a-f]+", Examples: []string{"ff"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(patterns, "hex")
+	for _, p := range []Pattern{
+		{Name: "", Expression: "[a-z]+"},
+		{Name: "a:b", Expression: "[a-z]+"},
+		{Name: "bad", Expression: "[a-z"},
+		{Name: "bad", Expression: "(?P<name>[a-z]+)"},
+		{Name: "bad", Expression: "[a-z]+", Examples: []string{"1"}},
+		{Name: "bad", Expression: "[a-z]+", Type: reflect.TypeOf("")},
+	} {
+		if err := RegisterPattern(p); err == nil {
+			t.Fatalf("Pattern %+v should be invalid", p)
+		}
+	}
+
+	patterns := PathPatterns("/a/{id:int}/b/{hash:hex}/{name}/{custom:[a-z]+}")
+	if len(patterns) != 2 || patterns["id"].Name != "int" || patterns["hash"].Name != "hex" {
+		t.Fatalf("Unexpected patterns: %v", patterns)
+	}
+	overlaps := []struct {
+		a, b    string
+		overlap bool
+	}{
+		{"int", "hex", true},
+		{"int", "slug", true},
+		{"uuid", "slug", true},
+		{"int", "uuid", false},
+		{"int", "date", false},
+	}
+	for _, o := range overlaps {
+		if PatternFor(o.a).Overlap(PatternFor(o.b)) != o.overlap {
+			t.Fatalf("Overlap of %s and %s should be %v", o.a, o.b, o.overlap)
+		}
+	}
+}
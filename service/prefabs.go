@@ -0,0 +1,280 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/caicloud/nirvana/log"
+)
+
+// RequestPrefab returns the underlying *http.Request of current request.
+type RequestPrefab struct{}
+
+// Name returns prefab name.
+func (p *RequestPrefab) Name() string {
+	return "request"
+}
+
+// Type is type of *http.Request.
+func (p *RequestPrefab) Type() reflect.Type {
+	return reflect.TypeOf((*http.Request)(nil))
+}
+
+// Make returns the request from http context.
+func (p *RequestPrefab) Make(ctx context.Context) (interface{}, error) {
+	httpCtx := HTTPContextFrom(ctx)
+	if httpCtx == nil {
+		return nil, NoContext.Error()
+	}
+	return httpCtx.Request(), nil
+}
+
+// ResponseWriterPrefab returns the ResponseWriter of current request.
+type ResponseWriterPrefab struct{}
+
+// Name returns prefab name.
+func (p *ResponseWriterPrefab) Name() string {
+	return "responseWriter"
+}
+
+// Type is type of ResponseWriter.
+func (p *ResponseWriterPrefab) Type() reflect.Type {
+	return reflect.TypeOf((*ResponseWriter)(nil)).Elem()
+}
+
+// Make returns the response writer from http context.
+func (p *ResponseWriterPrefab) Make(ctx context.Context) (interface{}, error) {
+	httpCtx := HTTPContextFrom(ctx)
+	if httpCtx == nil {
+		return nil, NoContext.Error()
+	}
+	return httpCtx.ResponseWriter(), nil
+}
+
+// contextKeyAggregateErrors is a key for context. It marks that errors of
+// fields should be aggregated.
+var contextKeyAggregateErrors interface{} = new(byte)
+
+// WithAggregateErrors returns a copy of ctx in which parameter generators
+// collect errors of all fields, instead of returning the first one.
+func WithAggregateErrors(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKeyAggregateErrors, true)
+}
+
+// AggregateErrorsFrom checks if errors of fields should be aggregated.
+func AggregateErrorsFrom(ctx context.Context) bool {
+	aggregate, _ := ctx.Value(contextKeyAggregateErrors).(bool)
+	return aggregate
+}
+
+// contextKeyLogger is a key for context. It points to the request-scoped logger.
+var contextKeyLogger interface{} = new(byte)
+
+// WithLogger returns a copy of ctx which carries logger. Middlewares can
+// use it to replace the logger for subsequent handlers.
+func WithLogger(ctx context.Context, logger log.Logger) context.Context {
+	return context.WithValue(ctx, contextKeyLogger, logger)
+}
+
+// LoggerFrom gets the request-scoped logger from ctx. If there is no
+// logger in ctx, the default logger is returned.
+func LoggerFrom(ctx context.Context) log.Logger {
+	if logger, ok := ctx.Value(contextKeyLogger).(log.Logger); ok && logger != nil {
+		return logger
+	}
+	return log.DefaultLogger()
+}
+
+// contextKeyRequestID is a key for context. It points to the request id.
+var contextKeyRequestID interface{} = new(byte)
+
+// maxRequestIDLength is the max length of request ids.
+const maxRequestIDLength = 128
+
+// ValidRequestID checks if id can be used as a request id. Request ids are
+// written to logs, so they can only contain letters, digits, '-', '_', '.'
+// and ':', and can't be longer than 128 bytes. Ids from clients should be
+// checked before they are used.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// WithRequestID returns a copy of ctx which carries the request id. The
+// logger in ctx is replaced with a logger which prefixes messages with the
+// request id, so that logs of a request can be correlated. ctx is returned
+// as it is if the id is invalid (see ValidRequestID).
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if !ValidRequestID(id) {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, contextKeyRequestID, id)
+	return WithLogger(ctx, log.NewPrefixLogger(LoggerFrom(ctx), "["+id+"] "))
+}
+
+// RequestIDFrom gets the request id from ctx. It returns an empty string
+// if ctx has no request id.
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(contextKeyRequestID).(string)
+	return id
+}
+
+// RequestIDPrefab returns the request id of current request. The id is
+// stored by middlewares via WithRequestID(), for instance, plugin reqlog.
+type RequestIDPrefab struct{}
+
+// Name returns prefab name.
+func (p *RequestIDPrefab) Name() string {
+	return "requestID"
+}
+
+// Type is type of string.
+func (p *RequestIDPrefab) Type() reflect.Type {
+	return reflect.TypeOf("")
+}
+
+// Make returns the request id from context.
+func (p *RequestIDPrefab) Make(ctx context.Context) (interface{}, error) {
+	return RequestIDFrom(ctx), nil
+}
+
+// LoggerPrefab returns the request-scoped logger.
+type LoggerPrefab struct{}
+
+// Name returns prefab name.
+func (p *LoggerPrefab) Name() string {
+	return "logger"
+}
+
+// Type is type of log.Logger.
+func (p *LoggerPrefab) Type() reflect.Type {
+	return reflect.TypeOf((*log.Logger)(nil)).Elem()
+}
+
+// Make returns the logger from context.
+func (p *LoggerPrefab) Make(ctx context.Context) (interface{}, error) {
+	return LoggerFrom(ctx), nil
+}
+
+// ClientIPPrefab returns the IP of the client. Headers "X-Forwarded-For" and
+// "X-Real-Ip" are only honoured when the remote address of the connection
+// is a trusted proxy.
+type ClientIPPrefab struct {
+	trusted []*net.IPNet
+}
+
+// NewClientIPPrefab creates a client IP prefab which trusts the proxies in
+// trustedProxies. Each proxy can be an IP or a CIDR.
+// The default prefab trusts nothing. You can register the result to
+// override it:
+//  prefab, err := service.NewClientIPPrefab("10.0.0.0/8")
+//  ...
+//  service.RegisterPrefab(prefab)
+func NewClientIPPrefab(trustedProxies ...string) (*ClientIPPrefab, error) {
+	p := &ClientIPPrefab{}
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, invalidTrustedProxy.Error(proxy)
+		}
+		p.trusted = append(p.trusted, network)
+	}
+	return p, nil
+}
+
+// Name returns prefab name.
+func (p *ClientIPPrefab) Name() string {
+	return "clientIP"
+}
+
+// Type is type of string.
+func (p *ClientIPPrefab) Type() reflect.Type {
+	return reflect.TypeOf("")
+}
+
+// Make returns the client IP.
+func (p *ClientIPPrefab) Make(ctx context.Context) (interface{}, error) {
+	httpCtx := HTTPContextFrom(ctx)
+	if httpCtx == nil {
+		return nil, NoContext.Error()
+	}
+	return p.ClientIP(httpCtx.Request()), nil
+}
+
+// ClientIP resolves the client IP of req.
+func (p *ClientIPPrefab) ClientIP(req *http.Request) string {
+	remote := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if !p.isTrusted(remote) {
+		return remote
+	}
+	// Walk through the proxy chain from right to left. The first
+	// untrusted address is the client.
+	forwarded := strings.Split(strings.Join(req.Header["X-Forwarded-For"], ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(forwarded[i])
+		if ip == "" {
+			continue
+		}
+		if !p.isTrusted(ip) {
+			return ip
+		}
+		remote = ip
+	}
+	if ip := strings.TrimSpace(req.Header.Get("X-Real-Ip")); ip != "" {
+		return ip
+	}
+	return remote
+}
+
+func (p *ClientIPPrefab) isTrusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range p.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
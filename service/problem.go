@@ -0,0 +1,121 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/errors"
+)
+
+// Problem is an error response in the format of problem details for HTTP
+// APIs (RFC 7807). It's marshaled as a flat json object in which extension
+// members sit beside standard members.
+type Problem struct {
+	// Type is the reason of the error. An empty type means "about:blank".
+	Type string
+	// Title is the status text of the status code.
+	Title string
+	// Status is the status code of the response.
+	Status int
+	// Detail is the message of the error.
+	Detail string
+	// Instance is the path of the request.
+	Instance string
+	// Extensions contains data of the error. It can't override standard members.
+	Extensions map[string]interface{}
+}
+
+// MarshalJSON marshals the problem into a flat json object.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+5)
+	for key, value := range p.Extensions {
+		members[key] = value
+	}
+	for key, value := range map[string]string{
+		"type":     p.Type,
+		"title":    p.Title,
+		"detail":   p.Detail,
+		"instance": p.Instance,
+	} {
+		if value != "" {
+			members[key] = value
+		} else {
+			delete(members, key)
+		}
+	}
+	members["status"] = p.Status
+	return json.Marshal(members)
+}
+
+// problemOf converts an error to a problem. msg is the body which is
+// written if the error is not converted.
+func problemOf(ctx context.Context, code int, err interface{}, msg interface{}) *Problem {
+	p := &Problem{
+		Title:  http.StatusText(code),
+		Status: code,
+	}
+	if httpCtx := HTTPContextFrom(ctx); httpCtx != nil {
+		p.Instance = httpCtx.Request().URL.Path
+	}
+	switch e := err.(type) {
+	case errors.ExternalError:
+		p.Type = e.Reason()
+		p.Detail = e.Error()
+		if data := e.Data(); len(data) > 0 {
+			p.Extensions = make(map[string]interface{}, len(data)+1)
+			for key, value := range data {
+				p.Extensions[key] = value
+			}
+		}
+		if fields := errors.FieldsOf(e); len(fields) > 0 {
+			if p.Extensions == nil {
+				p.Extensions = map[string]interface{}{}
+			}
+			p.Extensions["fields"] = fields
+		}
+	case error:
+		p.Detail = e.Error()
+	default:
+		if detail, ok := msg.(string); ok {
+			p.Detail = detail
+		}
+	}
+	return p
+}
+
+// ProblemSerializer implements Producer for content type "application/problem+json".
+// WriteError converts errors to Problem before producing them. Other values
+// are produced as json.
+type ProblemSerializer struct{ RawSerializer }
+
+// ContentType returns problem json MIME type.
+func (s *ProblemSerializer) ContentType() string {
+	return definition.MIMEProblemJSON
+}
+
+// Produce marshals v to json and write to w.
+func (s *ProblemSerializer) Produce(w io.Writer, v interface{}) error {
+	if s.CanProduceData(s.ContentType(), w, v) {
+		return s.ProduceData(s.ContentType(), w, v)
+	}
+	return json.NewEncoder(w).Encode(v)
+}
@@ -17,14 +17,19 @@ limitations under the License.
 package rest
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"net/textproto"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/errors"
 	"github.com/caicloud/nirvana/log"
 	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/executor"
 	"github.com/caicloud/nirvana/service/rest/router"
 )
 
@@ -34,20 +39,33 @@ type binding struct {
 }
 
 type builder struct {
-	bindings map[string]*binding
+	routes   map[string]*route
 	modifier service.DefinitionModifier
 	filters  []service.Filter
 	logger   log.Logger
+	// reuse is true if http contexts are reused.
+	reuse bool
+	// observers observe phases of executing definitions.
+	observers []service.Observer
+	// reporters get crashes of all requests.
+	reporters []service.CrashReporter
+	// envelope wraps bodies of responses.
+	envelope service.Envelope
 }
 
 // NewBuilder creates a service builder.
 func NewBuilder() service.Builder {
 	return &builder{
-		bindings: make(map[string]*binding),
-		logger:   &log.SilentLogger{},
+		routes: make(map[string]*route),
+		logger: &log.SilentLogger{},
 	}
 }
 
+var _ service.ContextReuser = &builder{}
+var _ service.ObservableBuilder = &builder{}
+var _ service.CrashReportingBuilder = &builder{}
+var _ service.EnvelopeBuilder = &builder{}
+
 // Filters returns all request filters.
 func (b *builder) Filters() []service.Filter {
 	result := make([]service.Filter, len(b.filters))
@@ -74,6 +92,52 @@ func (b *builder) SetLogger(logger log.Logger) {
 	}
 }
 
+// ContextReuse returns whether http contexts are reused.
+func (b *builder) ContextReuse() bool {
+	return b.reuse
+}
+
+// SetContextReuse sets whether http contexts are reused by a pool.
+func (b *builder) SetContextReuse(reuse bool) {
+	b.reuse = reuse
+}
+
+// Observers returns all executor observers.
+func (b *builder) Observers() []service.Observer {
+	result := make([]service.Observer, len(b.observers))
+	copy(result, b.observers)
+	return result
+}
+
+// AddObserver adds observers to observe phases of executing definitions.
+func (b *builder) AddObserver(observers ...service.Observer) {
+	b.observers = append(b.observers, observers...)
+}
+
+// CrashReporters returns all crash reporters.
+func (b *builder) CrashReporters() []service.CrashReporter {
+	result := make([]service.CrashReporter, len(b.reporters))
+	copy(result, b.reporters)
+	return result
+}
+
+// AddCrashReporter adds crash reporters which get crashes of all requests
+// served by the service.
+func (b *builder) AddCrashReporter(reporters ...service.CrashReporter) {
+	b.reporters = append(b.reporters, reporters...)
+}
+
+// Envelope returns the envelope of responses.
+func (b *builder) Envelope() service.Envelope {
+	return b.envelope
+}
+
+// SetEnvelope sets the envelope of all responses. Definitions can disable
+// it by NoEnvelope. A nil envelope disables envelopes.
+func (b *builder) SetEnvelope(e service.Envelope) {
+	b.envelope = e
+}
+
 // Modifier returns modifier of builder.
 func (b *builder) Modifier() service.DefinitionModifier {
 	return b.modifier
@@ -91,13 +155,20 @@ func (b *builder) AddDescriptor(descriptors ...interface{}) error {
 		if !ok {
 			return fmt.Errorf("%s is not a definition.Descriptor", reflect.TypeOf(obj).String())
 		}
-		b.addDescriptor("", nil, nil, nil, descriptor)
+		if err := b.addDescriptor("", "", nil, nil, nil, nil, descriptor); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (b *builder) addDescriptor(prefix string, consumes []string, produces []string, tags []string, descriptor definition.Descriptor) {
+func (b *builder) addDescriptor(prefix string, host string, headers map[string]string,
+	consumes []string, produces []string, tags []string, descriptor definition.Descriptor) error {
 	path := strings.Join([]string{prefix, strings.Trim(descriptor.Path, "/")}, "/")
+	if descriptor.Host != "" {
+		host = strings.ToLower(descriptor.Host)
+	}
+	headers = mergeHeaders(headers, descriptor.Headers)
 	if descriptor.Consumes != nil {
 		consumes = descriptor.Consumes
 	}
@@ -107,34 +178,88 @@ func (b *builder) addDescriptor(prefix string, consumes []string, produces []str
 	if descriptor.Tags != nil {
 		tags = descriptor.Tags
 	}
-	if len(descriptor.Middlewares) > 0 || len(descriptor.Definitions) > 0 {
-		bd, ok := b.bindings[path]
-		if !ok {
-			bd = &binding{}
-			b.bindings[path] = bd
+	if len(descriptor.Middlewares) > 0 {
+		bd, err := b.binding(host, headers, path)
+		if err != nil {
+			return err
 		}
-		if len(descriptor.Middlewares) > 0 {
-			bd.middlewares = append(bd.middlewares, descriptor.Middlewares...)
+		bd.middlewares = append(bd.middlewares, descriptor.Middlewares...)
+	}
+	for _, d := range descriptor.Definitions {
+		if err := b.addDefinition(path, host, headers, consumes, produces, tags, d); err != nil {
+			return err
 		}
-		if len(descriptor.Definitions) > 0 {
-			for _, d := range descriptor.Definitions {
-				bd.definitions = append(bd.definitions, *b.copyDefinition(&d, consumes, produces, tags))
+	}
+	if descriptor.Handler != nil {
+		for subpath, d := range mountDefinitions(path, descriptor) {
+			if err := b.addDefinition(subpath, host, headers, consumes, produces, tags, d); err != nil {
+				return err
 			}
 		}
 	}
 	for _, child := range descriptor.Children {
-		b.addDescriptor(strings.TrimRight(path, "/"), consumes, produces, tags, child)
+		if err := b.addDescriptor(strings.TrimRight(path, "/"), host, headers, consumes, produces, tags, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addDefinition adds a definition to the binding of its path.
+func (b *builder) addDefinition(path string, host string, headers map[string]string,
+	consumes []string, produces []string, tags []string, d definition.Definition) error {
+	newOne := b.copyDefinition(&d, consumes, produces, tags)
+	if newOne.Host == "" {
+		newOne.Host = host
+	}
+	newOne.Headers = mergeHeaders(headers, newOne.Headers)
+	bd, err := b.binding(newOne.Host, newOne.Headers, path)
+	if err != nil {
+		return err
+	}
+	bd.definitions = append(bd.definitions, *newOne)
+	return nil
+}
+
+// binding gets the binding of a path in the route of a host and headers.
+func (b *builder) binding(host string, headers map[string]string, path string) (*binding, error) {
+	key := routeKey(host, headers)
+	r, ok := b.routes[key]
+	if !ok {
+		r = &route{
+			host:     host,
+			headers:  headers,
+			bindings: make(map[string]*binding),
+		}
+		if host != "" {
+			matcher, err := newHostMatcher(host)
+			if err != nil {
+				return nil, err
+			}
+			r.matcher = matcher
+		}
+		b.routes[key] = r
 	}
+	bd, ok := r.bindings[path]
+	if !ok {
+		bd = &binding{}
+		r.bindings[path] = bd
+	}
+	return bd, nil
 }
 
 // copyDefinition creates a copy from original definition. Those fields with type interface{} only have shallow copies.
 func (b *builder) copyDefinition(d *definition.Definition, consumes []string, produces []string, tags []string) *definition.Definition {
 	newOne := &definition.Definition{
-		Method:      d.Method,
-		Summary:     d.Summary,
-		Function:    d.Function,
-		Description: d.Description,
-		Example:     d.Example,
+		Method:          d.Method,
+		Version:         d.Version,
+		Host:            strings.ToLower(d.Host),
+		Summary:         d.Summary,
+		Function:        d.Function,
+		Description:     d.Description,
+		Example:         d.Example,
+		AggregateErrors: d.AggregateErrors,
+		NoEnvelope:      d.NoEnvelope,
 	}
 	if len(d.Consumes) > 0 {
 		consumes = d.Consumes
@@ -160,6 +285,13 @@ func (b *builder) copyDefinition(d *definition.Definition, consumes []string, pr
 	newOne.ErrorProduces = make([]string, len(produces))
 	copy(newOne.ErrorProduces, produces)
 
+	if len(d.Headers) > 0 {
+		newOne.Headers = make(map[string]string, len(d.Headers))
+		for name, value := range d.Headers {
+			newOne.Headers[textproto.CanonicalMIMEHeaderKey(name)] = value
+		}
+	}
+
 	newOne.Parameters = make([]definition.Parameter, len(d.Parameters))
 	for i, p := range d.Parameters {
 		newParameter := p
@@ -182,17 +314,15 @@ func (b *builder) copyDefinition(d *definition.Definition, consumes []string, pr
 // original data.
 func (b *builder) Definitions() map[string][]definition.Definition {
 	result := make(map[string][]definition.Definition)
-	for path, bd := range b.bindings {
-		if len(bd.definitions) > 0 {
-			definitions := make([]definition.Definition, len(bd.definitions))
-			for i, d := range bd.definitions {
+	for _, r := range b.sortedRoutes() {
+		for path, bd := range r.bindings {
+			for _, d := range bd.definitions {
 				newCopy := b.copyDefinition(&d, nil, nil, nil)
 				if b.modifier != nil {
 					b.modifier(newCopy)
 				}
-				definitions[i] = *newCopy
+				result[path] = append(result[path], *newCopy)
 			}
-			result[path] = definitions
 		}
 	}
 	return result
@@ -205,11 +335,82 @@ func (b *builder) APIStyle() service.APIStyle {
 
 // Build builds a service to handle request.
 func (b *builder) Build() (service.Service, error) {
-	if len(b.bindings) <= 0 {
+	if len(b.routes) <= 0 {
 		return nil, noRouter.Error()
 	}
+	if err := b.checkConflicts(); err != nil {
+		return nil, err
+	}
+	s := &server{
+		filters:   b.filters,
+		logger:    b.logger,
+		producers: service.AllProducers(),
+		reuse:     b.reuse,
+		reporters: b.CrashReporters(),
+		envelope:  b.envelope,
+	}
+	for _, r := range b.sortedRoutes() {
+		root, err := b.buildRouter(r)
+		if err != nil {
+			return nil, err
+		}
+		s.routes = append(s.routes, &hostRouter{r, root})
+		s.hosts = s.hosts || r.matcher != nil
+	}
+	table, err := routeTable(s.routes)
+	if err != nil {
+		return nil, err
+	}
+	s.table = table
+	return s, nil
+}
+
+// sortedRoutes returns routes in the order of matching.
+func (b *builder) sortedRoutes() []*route {
+	routes := make([]*route, 0, len(b.routes))
+	for _, r := range b.routes {
+		routes = append(routes, r)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].precede(routes[j])
+	})
+	return routes
+}
+
+// buildRouter builds the router of a route. Middlewares of routes which cover
+// the route are added to it. Middlewares of broader routes run first.
+func (b *builder) buildRouter(r *route) (router.Router, error) {
+	bindings := r.bindings
+	// Routes are sorted from narrow to broad, so each covering route puts its
+	// middlewares before those of narrower ones.
+	for _, o := range b.sortedRoutes() {
+		if o == r || !o.covers(r) {
+			continue
+		}
+		merged := make(map[string]*binding, len(bindings))
+		for path, bd := range bindings {
+			merged[path] = bd
+		}
+		for path, bd := range o.bindings {
+			if len(bd.middlewares) <= 0 {
+				continue
+			}
+			current, ok := merged[path]
+			if !ok {
+				current = &binding{}
+			}
+			merged[path] = &binding{
+				middlewares: append(append([]definition.Middleware{}, bd.middlewares...), current.middlewares...),
+				definitions: current.definitions,
+			}
+		}
+		bindings = merged
+	}
+	if r.host != "" || len(r.headers) > 0 {
+		b.logger.V(log.LevelDebug).Infof("Host: %s Headers: %v", r.host, r.headers)
+	}
 	var root router.Router
-	for path, bd := range b.bindings {
+	for path, bd := range bindings {
 		b.logger.V(log.LevelDebug).Infof("Definitions: %d Middlewares: %d Path: %s",
 			len(bd.definitions), len(bd.middlewares), path)
 		top, leaf, err := router.Parse(path)
@@ -224,6 +425,7 @@ func (b *builder) Build() (service.Service, error) {
 				b.logger.Warningf("If RedirectTrailingSlash filter is enabled, following %d definition(s) would not be executed", len(bd.definitions))
 			}
 			inspector := newInspector(path)
+			inspector.observers = b.observers
 			for _, d := range bd.definitions {
 				b.logger.V(log.LevelDebug).Infof("  Method: %s Consumes: %v Produces: %v",
 					d.Method, d.Consumes, d.Produces)
@@ -246,20 +448,24 @@ func (b *builder) Build() (service.Service, error) {
 			return nil, err
 		}
 	}
-	s := &server{
-		root:      root,
-		filters:   b.filters,
-		logger:    b.logger,
-		producers: service.AllProducers(),
-	}
-	return s, nil
+	return root, nil
 }
 
 type server struct {
-	root      router.Router
+	routes []*hostRouter
+	// hosts is true if there are routes with hosts.
+	hosts bool
+	// table contains all routes in the order of matching.
+	table     []service.Route
 	filters   []service.Filter
 	logger    log.Logger
 	producers []service.Producer
+	// reuse is true if http contexts are reused.
+	reuse bool
+	// reporters get crashes of all requests.
+	reporters []service.CrashReporter
+	// envelope wraps bodies of responses.
+	envelope service.Envelope
 }
 
 func (s *server) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
@@ -268,16 +474,29 @@ func (s *server) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 			return
 		}
 	}
-	ctx := service.NewHTTPContext(resp, req)
+	var ctx *service.HTTPCtx
+	if s.reuse {
+		ctx = service.AcquireHTTPContext(resp, req)
+		defer service.ReleaseHTTPContext(ctx)
+	} else {
+		ctx = service.NewHTTPContext(resp, req)
+	}
+	ctx.SetLogger(s.logger)
+	ctx.SetService(s)
+	ctx.AddCrashReporter(s.reporters...)
+	ctx.SetEnvelope(s.envelope)
 
-	executor, err := s.root.Match(ctx, ctx.ValueContainer(), req.URL.EscapedPath())
+	executor, err := s.match(ctx, req)
 	if err != nil {
+		if e, ok := err.(errors.ExternalError); ok && noExecutorForMethod.Derived(err) {
+			resp.Header().Set("Allow", e.Data()["allow"])
+		}
 		if err := service.WriteError(ctx, s.producers, err); err != nil {
 			s.logger.Error(err)
 		}
 		return
 	}
-	err = executor.Execute(ctx)
+	err = s.execute(ctx, executor)
 	if err == nil && ctx.ResponseWriter().HeaderWritable() {
 		err = service.InvalidService.Error()
 	}
@@ -291,3 +510,51 @@ func (s *server) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 		}
 	}
 }
+
+// execute executes an executor. Panics out of executors (e.g. from
+// middlewares) are converted to errors.
+func (s *server) execute(ctx context.Context, e executor.MiddlewareExecutor) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = service.Recover(ctx, r)
+		}
+	}()
+	return e.Execute(ctx)
+}
+
+// Routes returns all routes of the service.
+func (s *server) Routes() []service.Route {
+	result := make([]service.Route, len(s.table))
+	copy(result, s.table)
+	return result
+}
+
+// match finds an executor from routes in order. If a route can't handle the
+// request, the next one is used.
+func (s *server) match(ctx *service.HTTPCtx, req *http.Request) (executor.MiddlewareExecutor, error) {
+	host := ""
+	if s.hosts {
+		host = requestHost(req)
+	}
+	path := req.URL.EscapedPath()
+	var result error
+	for _, r := range s.routes {
+		if !r.matches(host, req, ctx.ValueContainer()) {
+			continue
+		}
+		e, err := r.root.Match(ctx, ctx.ValueContainer(), path)
+		if err == nil {
+			return e, nil
+		}
+		if !router.Unmatched(err) && !noExecutorForMethod.Derived(err) {
+			return nil, err
+		}
+		if result == nil || (router.Unmatched(result) && !router.Unmatched(err)) {
+			result = err
+		}
+	}
+	if result == nil {
+		result = noRouterForHost.Error(host)
+	}
+	return nil, result
+}
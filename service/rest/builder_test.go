@@ -23,11 +23,16 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/caicloud/nirvana/definition"
 	"github.com/caicloud/nirvana/errors"
+	"github.com/caicloud/nirvana/log"
 	"github.com/caicloud/nirvana/service"
 )
 
@@ -521,3 +526,1139 @@ func BenchmarkServer(b *testing.B) {
 		resp.buf = bytes.NewBuffer(resp.buf.Bytes())
 	}
 }
+
+func TestVersionRouting(t *testing.T) {
+	versioned := func(version string) definition.Definition {
+		return definition.Definition{
+			Method:   definition.Get,
+			Version:  version,
+			Produces: []string{definition.MIMEJSON},
+			Function: func() (string, error) {
+				return version, nil
+			},
+			Results: definition.DataErrorResults(""),
+		}
+	}
+	builder := NewBuilder()
+	err := builder.AddDescriptor(definition.Descriptor{
+		Path:        "/widgets",
+		Consumes:    []string{definition.MIMEAll},
+		Definitions: []definition.Definition{versioned("v1"), versioned("v2")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	units := []struct {
+		header      http.Header
+		code        int
+		body        string
+		contentType string
+	}{
+		{http.Header{}, 200, "v2", "application/json; version=v2"},
+		{http.Header{"Accept-Version": []string{"1"}}, 200, "v1", "application/json; version=v1"},
+		{http.Header{"Accept": []string{"application/vnd.acme.widget.v1+json"}}, 200, "v1",
+			"application/vnd.acme.widget.v1+json"},
+		{http.Header{"Accept-Version": []string{"v3"}}, 406, "", ""},
+	}
+	for _, unit := range units {
+		u, _ := url.Parse("/widgets")
+		req := (&http.Request{Method: "GET", URL: u, Header: unit.header}).WithContext(context.Background())
+		resp := newRW()
+		s.ServeHTTP(resp, req)
+		if resp.code != unit.code {
+			t.Fatalf("Response code should be %d, but got: %d", unit.code, resp.code)
+		}
+		if unit.code != 200 {
+			continue
+		}
+		if body := string(bytes.TrimSpace(resp.buf.Bytes())); body != unit.body {
+			t.Fatalf("Response body should be %s, but got: %s", unit.body, body)
+		}
+		if ct := resp.Header().Get("Content-Type"); ct != unit.contentType {
+			t.Fatalf("Content type should be %s, but got: %s", unit.contentType, ct)
+		}
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	etag := `"1"`
+	deleted := false
+	builder := NewBuilder()
+	builder.SetModifier(service.FirstContextParameter())
+	err := builder.AddDescriptor(definition.Descriptor{
+		Path:     "/objects",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Definitions: []definition.Definition{
+			{
+				Method: definition.Get,
+				Function: func(ctx context.Context) (map[string]string, error) {
+					return map[string]string{"name": "test"}, nil
+				},
+				Results: definition.DataErrorResults(""),
+			},
+			{
+				Method: definition.Update,
+				Function: func(ctx context.Context, p *service.Precondition) (string, error) {
+					if err := p.Check(etag, time.Time{}); err != nil {
+						return "", err
+					}
+					etag = `"2"`
+					return etag, nil
+				},
+				Parameters: []definition.Parameter{definition.PrefabParameterFor("precondition", "")},
+				Results:    []definition.Result{{Destination: definition.Validators}, definition.ErrorResult()},
+			},
+			{
+				Method: definition.Delete,
+				Function: func(ctx context.Context) error {
+					deleted = true
+					return nil
+				},
+				Results: []definition.Result{definition.ErrorResult()},
+			},
+		},
+		Children: []definition.Descriptor{
+			{
+				Path: "/streamed",
+				Definitions: []definition.Definition{{
+					Method: definition.Head,
+					Function: func(ctx context.Context) (map[string]string, error) {
+						return map[string]string{"name": "test"}, nil
+					},
+					Results: definition.DataErrorResults(""),
+				}},
+			},
+			{
+				Path: "/validated",
+				Definitions: []definition.Definition{{
+					Method: definition.Head,
+					Function: func(ctx context.Context) (string, map[string]string, error) {
+						return "v", map[string]string{"name": "test"}, nil
+					},
+					Results: []definition.Result{
+						{Destination: definition.Validators},
+						definition.DataResultFor(""),
+						definition.ErrorResult(),
+					},
+				}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(method string, header http.Header, paths ...string) *responseWriter {
+		u, _ := url.Parse(strings.Join(append([]string{"/objects"}, paths...), "/"))
+		req := (&http.Request{Method: method, URL: u, Header: header}).WithContext(context.Background())
+		resp := newRW()
+		s.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve("GET", http.Header{})
+	computed := resp.Header().Get("ETag")
+	if resp.code != 200 || computed == "" {
+		t.Fatalf("GET should return 200 with an ETag, but got %d %q", resp.code, computed)
+	}
+	resp = serve("GET", http.Header{"If-None-Match": []string{"W/" + computed}})
+	if resp.code != http.StatusNotModified || resp.buf.Len() != 0 {
+		t.Fatalf("GET should return 304 without body, but got %d %s", resp.code, resp.buf.String())
+	}
+
+	resp = serve("HEAD", http.Header{}, "streamed")
+	if resp.code != 200 || resp.Header().Get("ETag") != "" {
+		t.Fatalf("HEAD should return 200 without computed ETags, but got %d %q", resp.code, resp.Header().Get("ETag"))
+	}
+	resp = serve("HEAD", http.Header{"If-None-Match": []string{`"v"`}}, "validated")
+	if resp.code != http.StatusNotModified || resp.buf.Len() != 0 {
+		t.Fatalf("HEAD should return 304 by validators, but got %d %s", resp.code, resp.buf.String())
+	}
+
+	resp = serve("PUT", http.Header{"If-Match": []string{`"0"`}})
+	if resp.code != http.StatusPreconditionFailed {
+		t.Fatalf("PUT should return 412, but got %d", resp.code)
+	}
+	resp = serve("PUT", http.Header{"If-Match": []string{`"1"`}})
+	if resp.code != http.StatusOK || resp.Header().Get("ETag") != `"2"` {
+		t.Fatalf("PUT should return 200 with new ETag, but got %d %q", resp.code, resp.Header().Get("ETag"))
+	}
+
+	// The Delete handler can't check preconditions.
+	for header, value := range map[string]string{
+		"If-Match":            `"2"`,
+		"If-Unmodified-Since": time.Now().UTC().Format(http.TimeFormat),
+	} {
+		resp = serve("DELETE", http.Header{header: []string{value}})
+		if resp.code != http.StatusPreconditionFailed || deleted {
+			t.Fatalf("DELETE with %s should return 412 without deleting, but got %d", header, resp.code)
+		}
+	}
+	resp = serve("DELETE", http.Header{})
+	if resp.code != http.StatusNoContent || !deleted {
+		t.Fatalf("DELETE should return 204, but got %d", resp.code)
+	}
+}
+
+func TestAutomaticOptions(t *testing.T) {
+	handle := func() (string, error) { return "", nil }
+	builder := NewBuilder()
+	err := builder.AddDescriptor(definition.Descriptor{
+		Path:     "/objects",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Definitions: []definition.Definition{
+			{Method: definition.Get, Function: handle, Results: definition.DataErrorResults("")},
+			{Method: definition.Create, Function: handle, Results: definition.DataErrorResults("")},
+		},
+		Children: []definition.Descriptor{{
+			Path: "/any",
+			Definitions: []definition.Definition{
+				{Method: definition.Any, Function: handle, Results: definition.DataErrorResults("")},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, allow := range map[string]string{
+		"/objects":     "GET, HEAD, OPTIONS, POST",
+		"/objects/any": "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS",
+	} {
+		u, _ := url.Parse(path)
+		req := (&http.Request{Method: http.MethodOptions, URL: u, Header: http.Header{}}).WithContext(context.Background())
+		resp := newRW()
+		s.ServeHTTP(resp, req)
+		if path == "/objects" && resp.code != http.StatusNoContent {
+			t.Fatalf("OPTIONS %s should return 204, but got %d", path, resp.code)
+		}
+		if got := resp.Header().Get("Allow"); got != allow {
+			t.Fatalf("Allow of %s should be %q, but got %q", path, allow, got)
+		}
+	}
+}
+
+func TestUnmatchedRequests(t *testing.T) {
+	builder := NewBuilder()
+	err := builder.AddDescriptor(definition.Descriptor{
+		Path: "/objects",
+		Definitions: []definition.Definition{
+			{
+				Method:   definition.Get,
+				Consumes: []string{definition.MIMEAll},
+				Produces: []string{definition.MIMEJSON, definition.MIMEXML},
+				Function: func() (string, error) { return "get", nil },
+				Results:  definition.DataErrorResults(""),
+			},
+			{
+				Method:     definition.Update,
+				Consumes:   []string{definition.MIMEJSON, definition.MIMEText},
+				Produces:   []string{definition.MIMEJSON},
+				Parameters: []definition.Parameter{definition.BodyParameterFor("")},
+				Function:   func(body string) (string, error) { return body, nil },
+				Results:    definition.DataErrorResults(""),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	units := []struct {
+		method  string
+		header  http.Header
+		code    int
+		allow   string
+		message string
+	}{
+		{"DELETE", http.Header{}, 405, "GET, HEAD, OPTIONS, PUT",
+			"method DELETE is not allowed, allowed methods: GET, HEAD, OPTIONS, PUT"},
+		{"PUT", http.Header{"Content-Type": []string{definition.MIMEXML}}, 415, "",
+			"unsupported media type application/xml, supported types: application/json, text/plain"},
+		{"GET", http.Header{"Accept": []string{definition.MIMEText}}, 406, "",
+			"not acceptable, acceptable types: application/json, application/xml"},
+		{"HEAD", http.Header{}, 200, "", ""},
+	}
+	for _, unit := range units {
+		u, _ := url.Parse("/objects")
+		req := (&http.Request{Method: unit.method, URL: u, Header: unit.header}).WithContext(context.Background())
+		resp := newRW()
+		s.ServeHTTP(resp, req)
+		if resp.code != unit.code {
+			t.Fatalf("%s should return %d, but got: %d %s", unit.method, unit.code, resp.code, resp.buf.String())
+		}
+		if allow := resp.Header().Get("Allow"); allow != unit.allow {
+			t.Fatalf("Allow header of %s should be %q, but got: %q", unit.method, unit.allow, allow)
+		}
+		if unit.message == "" {
+			if resp.buf.Len() != 0 {
+				t.Fatalf("Body of %s should be empty, but got: %s", unit.method, resp.buf.String())
+			}
+			continue
+		}
+		if !bytes.Contains(resp.buf.Bytes(), []byte(unit.message)) {
+			t.Fatalf("Message of %s should be %q, but got: %s", unit.method, unit.message, resp.buf.String())
+		}
+	}
+}
+
+func TestPathPatterns(t *testing.T) {
+	builder := NewBuilder()
+	builder.SetModifier(service.FirstContextParameter())
+	err := builder.AddDescriptor(definition.Descriptor{
+		Path:     "/records",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Children: []definition.Descriptor{
+			{
+				Path: "/{id:int}",
+				Definitions: []definition.Definition{{
+					Method:     definition.Get,
+					Parameters: []definition.Parameter{definition.PathParameterFor("id", "")},
+					Results:    definition.DataErrorResults(""),
+					Function: func(ctx context.Context, id int) (string, error) {
+						return fmt.Sprintf("id %d", id), nil
+					},
+				}},
+			},
+			{
+				Path: "/{date:date}",
+				Definitions: []definition.Definition{{
+					Method:     definition.Get,
+					Parameters: []definition.Parameter{definition.PathParameterFor("date", "")},
+					Results:    definition.DataErrorResults(""),
+					Function: func(ctx context.Context, date time.Time) (string, error) {
+						return "date " + date.Format("Jan 2 2006"), nil
+					},
+				}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]string{
+		"/records/12":         "id 12",
+		"/records/2020-01-02": "date Jan 2 2020",
+		"/records/abc":        "",
+	} {
+		u, _ := url.Parse(path)
+		req := (&http.Request{Method: "GET", URL: u, Header: http.Header{}}).WithContext(context.Background())
+		resp := newRW()
+		s.ServeHTTP(resp, req)
+		if expected == "" {
+			if resp.code != http.StatusNotFound {
+				t.Fatalf("%s should not be found, but got: %d", path, resp.code)
+			}
+			continue
+		}
+		if body := string(bytes.TrimSpace(resp.buf.Bytes())); resp.code != http.StatusOK || body != expected {
+			t.Fatalf("%s should return %s, but got: %d %s", path, expected, resp.code, body)
+		}
+	}
+}
+
+func TestPathConflicts(t *testing.T) {
+	handle := func() (string, error) { return "", nil }
+	get := []definition.Definition{{Method: definition.Get, Function: handle, Results: definition.DataErrorResults("")}}
+	post := []definition.Definition{{Method: definition.Create, Function: handle, Results: definition.DataErrorResults("")}}
+	units := []struct {
+		pathA    string
+		pathB    string
+		defsB    []definition.Definition
+		conflict bool
+	}{
+		{"/items/{id:int}", "/items/{name:slug}", get, true},
+		{"/items/{id:int}", "/items/{name}", get, true},
+		{"/items/{id:int}", "/items/{name:[a-z]+}", get, false},
+		{"/items/{id:int}", "/items/{name:[0-9a-z]+}", get, true},
+		{"/items/{id:int}", "/items/{id:uuid}", get, false},
+		{"/items/{id:int}", "/items/{name:slug}", post, false},
+		{"/items/{id:int}", "/items/new", get, false},
+		{"/items/{id:int}/a", "/items/{name:slug}/b", get, false},
+	}
+	for _, unit := range units {
+		builder := NewBuilder()
+		err := builder.AddDescriptor(definition.Descriptor{
+			Path:     "/",
+			Consumes: []string{definition.MIMEAll},
+			Produces: []string{definition.MIMEJSON},
+			Children: []definition.Descriptor{
+				{Path: unit.pathA, Definitions: get},
+				{Path: unit.pathB, Definitions: unit.defsB},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = builder.Build()
+		if conflict := conflictPaths.Derived(err); conflict != unit.conflict {
+			t.Fatalf("Conflict of %s and %s should be %v, but got: %v", unit.pathA, unit.pathB, unit.conflict, err)
+		}
+	}
+}
+
+func TestHostRouting(t *testing.T) {
+	reply := func(prefix string) func(ctx context.Context) (string, error) {
+		return func(ctx context.Context) (string, error) {
+			return prefix + service.HTTPContextFrom(ctx).Request().Header.Get("X-Seen"), nil
+		}
+	}
+	seen := func(ctx context.Context, chain definition.Chain) error {
+		req := service.HTTPContextFrom(ctx).Request()
+		req.Header.Set("X-Seen", req.Header.Get("X-Seen")+"+")
+		return chain.Continue(ctx)
+	}
+	get := func(f interface{}, params ...definition.Parameter) []definition.Definition {
+		return []definition.Definition{{Method: definition.Get, Function: f, Parameters: params, Results: definition.DataErrorResults("")}}
+	}
+	builder := NewBuilder()
+	builder.SetModifier(service.FirstContextParameter())
+	err := builder.AddDescriptor(
+		definition.Descriptor{
+			Path:        "/",
+			Consumes:    []string{definition.MIMEAll},
+			Produces:    []string{definition.MIMEText},
+			Middlewares: []definition.Middleware{seen},
+			Children: []definition.Descriptor{
+				{Path: "/items", Definitions: get(reply("default"))},
+				{Path: "/status", Definitions: get(reply("status"))},
+			},
+		},
+		definition.Descriptor{
+			Path:        "/items",
+			Host:        "admin.example.com",
+			Consumes:    []string{definition.MIMEAll},
+			Produces:    []string{definition.MIMEText},
+			Definitions: get(reply("admin")),
+		},
+		definition.Descriptor{
+			Path:        "/items",
+			Host:        "admin.example.com",
+			Headers:     map[string]string{"x-canary": "true"},
+			Consumes:    []string{definition.MIMEAll},
+			Produces:    []string{definition.MIMEText},
+			Definitions: get(reply("canary")),
+		},
+		definition.Descriptor{
+			Path:     "/items",
+			Host:     "{tenant:slug}.example.com",
+			Consumes: []string{definition.MIMEAll},
+			Produces: []string{definition.MIMEText},
+			Definitions: get(func(ctx context.Context, tenant string) (string, error) {
+				return "tenant " + tenant, nil
+			}, definition.Parameter{Source: definition.Host, Name: "tenant"}),
+		},
+		definition.Descriptor{
+			Path:        "/items",
+			Host:        "*.example.org",
+			Consumes:    []string{definition.MIMEAll},
+			Produces:    []string{definition.MIMEText},
+			Definitions: []definition.Definition{{Method: definition.Create, Function: reply("org"), Results: definition.DataErrorResults("")}},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	units := []struct {
+		method string
+		host   string
+		path   string
+		header http.Header
+		code   int
+		body   string
+	}{
+		{"GET", "localhost", "/items", http.Header{}, http.StatusOK, "default+"},
+		{"GET", "admin.example.com:8080", "/items", http.Header{}, http.StatusOK, "admin+"},
+		{"GET", "ADMIN.example.com", "/items", http.Header{"X-Canary": []string{"true"}}, http.StatusOK, "canary+"},
+		{"GET", "admin.example.com", "/status", http.Header{}, http.StatusOK, "status+"},
+		{"GET", "acme.example.com", "/items", http.Header{}, http.StatusOK, "tenant acme"},
+		{"GET", "a.b.example.com", "/items", http.Header{}, http.StatusOK, "default+"},
+		{"POST", "www.example.org", "/items", http.Header{}, http.StatusCreated, "org+"},
+		{"PUT", "www.example.org", "/items", http.Header{}, http.StatusMethodNotAllowed, ""},
+	}
+	for _, unit := range units {
+		u, _ := url.Parse(unit.path)
+		req := (&http.Request{Method: unit.method, Host: unit.host, URL: u, Header: unit.header}).WithContext(context.Background())
+		resp := newRW()
+		s.ServeHTTP(resp, req)
+		if resp.code != unit.code {
+			t.Fatalf("%s %s%s should return %d, but got: %d %s", unit.method, unit.host, unit.path, unit.code, resp.code, resp.buf.String())
+		}
+		if body := string(bytes.TrimSpace(resp.buf.Bytes())); unit.body != "" && body != unit.body {
+			t.Fatalf("%s %s%s should return %s, but got: %s", unit.method, unit.host, unit.path, unit.body, body)
+		}
+	}
+}
+
+func TestHostMiddlewares(t *testing.T) {
+	reply := func(prefix string) func(ctx context.Context) (string, error) {
+		return func(ctx context.Context) (string, error) {
+			return prefix + service.HTTPContextFrom(ctx).Request().Header.Get("X-Seen"), nil
+		}
+	}
+	mark := func(m string) definition.Middleware {
+		return func(ctx context.Context, chain definition.Chain) error {
+			req := service.HTTPContextFrom(ctx).Request()
+			req.Header.Set("X-Seen", req.Header.Get("X-Seen")+m)
+			return chain.Continue(ctx)
+		}
+	}
+	get := func(f interface{}) []definition.Definition {
+		return []definition.Definition{{Method: definition.Get, Function: f, Results: definition.DataErrorResults("")}}
+	}
+	builder := NewBuilder()
+	builder.SetModifier(service.FirstContextParameter())
+	err := builder.AddDescriptor(
+		definition.Descriptor{
+			Path:        "/",
+			Consumes:    []string{definition.MIMEAll},
+			Produces:    []string{definition.MIMEText},
+			Middlewares: []definition.Middleware{mark("+")},
+		},
+		definition.Descriptor{
+			Path:        "/",
+			Host:        "admin.example.com",
+			Consumes:    []string{definition.MIMEAll},
+			Produces:    []string{definition.MIMEText},
+			Middlewares: []definition.Middleware{mark("auth")},
+			Children: []definition.Descriptor{
+				{Path: "/items", Definitions: get(reply("admin"))},
+				{
+					Path:        "/items",
+					Headers:     map[string]string{"X-Canary": "true"},
+					Definitions: get(reply("canary")),
+				},
+				{
+					Path:    "/status",
+					Headers: map[string]string{"X-Canary": "*"},
+					Definitions: []definition.Definition{{
+						Method:   definition.Get,
+						Host:     "admin.example.com",
+						Headers:  map[string]string{"X-Debug": "true"},
+						Function: reply("debug"),
+						Results:  definition.DataErrorResults(""),
+					}},
+				},
+			},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, unit := range []struct {
+		path   string
+		header http.Header
+		body   string
+	}{
+		{"/items", http.Header{}, "admin+auth"},
+		{"/items", http.Header{"X-Canary": []string{"true"}}, "canary+auth"},
+		{"/status", http.Header{"X-Canary": []string{"1"}, "X-Debug": []string{"true"}}, "debug+auth"},
+	} {
+		u, _ := url.Parse(unit.path)
+		req := (&http.Request{Method: "GET", Host: "admin.example.com", URL: u, Header: unit.header}).WithContext(context.Background())
+		resp := newRW()
+		s.ServeHTTP(resp, req)
+		if body := string(bytes.TrimSpace(resp.buf.Bytes())); resp.code != http.StatusOK || body != unit.body {
+			t.Fatalf("%s %v should return %s, but got: %d %s", unit.path, unit.header, unit.body, resp.code, body)
+		}
+	}
+}
+
+func TestHostConflicts(t *testing.T) {
+	handle := func() (string, error) { return "", nil }
+	get := []definition.Definition{{Method: definition.Get, Function: handle, Results: definition.DataErrorResults("")}}
+	builder := NewBuilder()
+	err := builder.AddDescriptor(definition.Descriptor{
+		Path:     "/",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Children: []definition.Descriptor{
+			{Path: "/items/{id:int}", Definitions: get},
+			{Path: "/items/{name}", Host: "api.example.com", Definitions: get},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := builder.Build(); err != nil {
+		t.Fatalf("Paths of different hosts should not conflict: %v", err)
+	}
+	if err := builder.AddDescriptor(definition.Descriptor{Path: "/items/{id:int}", Host: "api.example.com", Definitions: get}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := builder.Build(); !conflictPaths.Derived(err) {
+		t.Fatalf("Paths of the same host should conflict, but got: %v", err)
+	}
+	if err := builder.AddDescriptor(definition.Descriptor{Host: "{a}.{a}.com", Definitions: get}); !invalidHost.Derived(err) {
+		t.Fatalf("Host with duplicated keys should be invalid, but got: %v", err)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	handle := func() (string, error) { return "", nil }
+	get := []definition.Definition{{Method: definition.Get, Function: handle, Results: definition.DataErrorResults("")}}
+	m := func(ctx context.Context, chain definition.Chain) error { return chain.Continue(ctx) }
+	builder := NewBuilder()
+	err := builder.AddDescriptor(
+		definition.Descriptor{
+			Path:        "/",
+			Consumes:    []string{definition.MIMEAll},
+			Produces:    []string{definition.MIMEJSON},
+			Middlewares: []definition.Middleware{m},
+			Children: []definition.Descriptor{
+				{Path: "/items/{id}", Definitions: get},
+				{Path: "/items/new", Definitions: get, Middlewares: []definition.Middleware{m}},
+			},
+		},
+		definition.Descriptor{
+			Path:        "/items/{id:int}",
+			Host:        "admin.example.com",
+			Consumes:    []string{definition.MIMEAll},
+			Produces:    []string{definition.MIMEJSON},
+			Definitions: get,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := s.(service.RouteLister).Routes()
+	expected := []struct {
+		route       string
+		middlewares int
+		shadowedBy  []string
+	}{
+		{"GET admin.example.com/items/{id:int}", 1, nil},
+		{"GET /items/new", 2, nil},
+		{"GET /items/{id}", 1, []string{"GET admin.example.com/items/{id:int}", "GET /items/new"}},
+	}
+	if len(routes) != len(expected) {
+		t.Fatalf("Unexpected routes: %+v", routes)
+	}
+	for i, r := range routes {
+		e := expected[i]
+		if r.String() != e.route || r.Middlewares != e.middlewares || !reflect.DeepEqual(r.ShadowedBy, e.shadowedBy) {
+			t.Fatalf("Route %d should be %+v, but got: %s %d %v", i, e, r.String(), r.Middlewares, r.ShadowedBy)
+		}
+		if !strings.Contains(r.Function, "TestRoutes") || !strings.HasSuffix(r.File, "builder_test.go") || r.Line <= 0 {
+			t.Fatalf("Unexpected function of route %s: %s %s#%d", r.String(), r.Function, r.File, r.Line)
+		}
+	}
+}
+
+func TestMount(t *testing.T) {
+	middlewares := 0
+	builder := NewBuilder()
+	err := builder.AddDescriptor(definition.Descriptor{
+		Path: "/api",
+		Children: []definition.Descriptor{
+			func() definition.Descriptor {
+				d := definition.Mount("/files", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					route := service.HTTPContextFrom(r.Context()).RoutePath()
+					_, _ = fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.Path, route)
+				}))
+				d.Middlewares = []definition.Middleware{
+					func(ctx context.Context, chain definition.Chain) error {
+						middlewares++
+						return chain.Continue(ctx)
+					},
+				}
+				return d
+			}(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		method      string
+		path        string
+		contentType string
+		body        string
+	}{
+		{http.MethodGet, "/api/files", "", "GET / /api/files"},
+		{http.MethodPut, "/api/files/a/b%20c.txt", "image/png", "PUT /a/b c.txt /api/files/{path:*}"},
+		{"PROPFIND", "/api/files/dir", "application/xml", "PROPFIND /dir /api/files/{path:*}"},
+	} {
+		u, _ := url.Parse(test.path)
+		req := (&http.Request{Method: test.method, URL: u, Header: http.Header{}}).WithContext(context.Background())
+		if test.contentType != "" {
+			req.Header.Set("Content-Type", test.contentType)
+		}
+		resp := newRW()
+		s.ServeHTTP(resp, req)
+		if got := resp.buf.String(); got != test.body {
+			t.Fatalf("%s %s should respond %q, but got %q", test.method, test.path, test.body, got)
+		}
+	}
+	if middlewares != 3 {
+		t.Fatalf("Middlewares should be executed 3 times, but got %d", middlewares)
+	}
+}
+
+func TestContextReuse(t *testing.T) {
+	builder := NewBuilder()
+	builder.(service.ContextReuser).SetContextReuse(true)
+	err := builder.AddDescriptor(definition.Descriptor{
+		Path:     "/files",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEText},
+		Definitions: []definition.Definition{{
+			Method: definition.Get,
+			Parameters: []definition.Parameter{
+				definition.PrefabParameterFor("context", ""),
+			},
+			Function: func(ctx context.Context) (string, error) {
+				_, ok := service.HTTPContextFrom(ctx).ValueContainer().Path("path")
+				return fmt.Sprint(ok), nil
+			},
+			Results: definition.DataErrorResults(""),
+		}},
+		Children: []definition.Descriptor{{
+			Path:     "/{path:*}",
+			Consumes: []string{definition.MIMEAll},
+			Produces: []string{definition.MIMEText},
+			Definitions: []definition.Definition{{
+				Method:     definition.Get,
+				Parameters: []definition.Parameter{definition.PathParameterFor("path", "")},
+				Function:   func(path string) (string, error) { return path, nil },
+				Results:    definition.DataErrorResults(""),
+			}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		path string
+		body string
+	}{
+		{"/files/a/b.txt", "a/b.txt"},
+		{"/files", "false"},
+		{"/files/c.txt", "c.txt"},
+	} {
+		u, _ := url.Parse(test.path)
+		resp := newRW()
+		s.ServeHTTP(resp, (&http.Request{Method: http.MethodGet, URL: u, Header: http.Header{}}).WithContext(context.Background()))
+		if resp.code != http.StatusOK || resp.buf.String() != test.body {
+			t.Fatalf("%s should respond %q, but got %d %q", test.path, test.body, resp.code, resp.buf.String())
+		}
+	}
+}
+
+func TestContextValues(t *testing.T) {
+	logger := &log.SilentLogger{}
+	override := log.NewPrefixLogger(logger, "[override] ")
+	var s service.Service
+	builder := NewBuilder()
+	builder.SetLogger(logger)
+	handle := func(ctx context.Context) (string, error) {
+		if service.ServiceFrom(ctx) != s {
+			t.Errorf("Service in context should be the server, but got %v", service.ServiceFrom(ctx))
+		}
+		return fmt.Sprint(service.LoggerFrom(ctx) == logger), nil
+	}
+	err := builder.AddDescriptor(definition.Descriptor{
+		Path:     "/",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEText},
+		Definitions: []definition.Definition{{
+			Method:     definition.Get,
+			Parameters: []definition.Parameter{definition.PrefabParameterFor("context", "")},
+			Function:   handle,
+			Results:    definition.DataErrorResults(""),
+		}},
+		Children: []definition.Descriptor{{
+			Path: "/override",
+			Middlewares: []definition.Middleware{func(ctx context.Context, chain definition.Chain) error {
+				return chain.Continue(service.WithLogger(ctx, override))
+			}},
+			Definitions: []definition.Definition{{
+				Method:     definition.Get,
+				Parameters: []definition.Parameter{definition.PrefabParameterFor("context", "")},
+				Function:   handle,
+				Results:    definition.DataErrorResults(""),
+			}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, body := range map[string]string{"/": "true", "/override": "false"} {
+		u, _ := url.Parse(path)
+		resp := newRW()
+		s.ServeHTTP(resp, (&http.Request{Method: http.MethodGet, URL: u, Header: http.Header{}}).WithContext(context.Background()))
+		if resp.code != http.StatusOK || resp.buf.String() != body {
+			t.Fatalf("%s should respond %q, but got %d %q", path, body, resp.code, resp.buf.String())
+		}
+	}
+}
+
+func BenchmarkServerRoutes(b *testing.B) {
+	builder := NewBuilder()
+	for i := 0; i < 256; i++ {
+		prefix := fmt.Sprintf("/api/v1/resources%d", i)
+		for _, path := range []string{
+			prefix,
+			prefix + "/{id}",
+			prefix + "/{id}/versions/{version:[0-9]+}.json",
+			prefix + "/{id}/files/{path:*}",
+		} {
+			err := builder.AddDescriptor(definition.Descriptor{
+				Path:     path,
+				Consumes: []string{definition.MIMEAll},
+				Produces: []string{definition.MIMEJSON},
+				Definitions: []definition.Definition{{
+					Method:   definition.Delete,
+					Function: func() error { return nil },
+					Results:  []definition.Result{definition.ErrorResult()},
+				}},
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	for _, reuse := range []bool{false, true} {
+		builder.(service.ContextReuser).SetContextReuse(reuse)
+		s, err := builder.Build()
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, path := range []string{
+			"/api/v1/resources17",
+			"/api/v1/resources128/nirvana",
+			"/api/v1/resources255/nirvana/versions/3.json",
+			"/api/v1/resources64/nirvana/files/docs/index.html",
+		} {
+			b.Run(fmt.Sprintf("reuse=%v%s", reuse, path), func(b *testing.B) {
+				u, _ := url.Parse(path)
+				req := (&http.Request{Method: http.MethodDelete, URL: u, Header: http.Header{}}).WithContext(context.Background())
+				resp := newRW()
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					s.ServeHTTP(resp, req)
+				}
+				if resp.code != http.StatusNoContent {
+					b.Fatalf("Response code should be 204, but got: %d", resp.code)
+				}
+			})
+		}
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	crashes := []*service.Crash{}
+	scoped := []*service.Crash{}
+	codes := []int{}
+	builder := NewBuilder()
+	builder.(service.CrashReportingBuilder).AddCrashReporter(service.CrashReporterFunc(func(ctx context.Context, crash *service.Crash) {
+		crashes = append(crashes, crash)
+	}))
+	err := builder.AddDescriptor(definition.Descriptor{
+		Path:     "/crashes",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Middlewares: []definition.Middleware{
+			func(ctx context.Context, chain definition.Chain) error {
+				service.AddCrashReporter(ctx, service.CrashReporterFunc(func(ctx context.Context, crash *service.Crash) {
+					scoped = append(scoped, crash)
+				}))
+				err := chain.Continue(ctx)
+				codes = append(codes, service.HTTPContextFrom(ctx).ResponseWriter().StatusCode())
+				return err
+			},
+		},
+		Definitions: []definition.Definition{{
+			Method:   definition.Get,
+			Function: func() (string, error) { panic("handler crashed") },
+			Results:  definition.DataErrorResults(""),
+		}},
+		Children: []definition.Descriptor{{
+			Path: "/middleware",
+			Middlewares: []definition.Middleware{
+				func(ctx context.Context, chain definition.Chain) error { panic("middleware crashed") },
+			},
+			Definitions: []definition.Definition{{
+				Method:   definition.Get,
+				Function: func() (string, error) { return "", nil },
+				Results:  definition.DataErrorResults(""),
+			}},
+		}},
+	}, definition.Descriptor{
+		Path:     "/unscoped",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Definitions: []definition.Definition{{
+			Method:   definition.Get,
+			Function: func() (string, error) { panic("handler crashed") },
+			Results:  definition.DataErrorResults(""),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, path := range []string{"/crashes", "/crashes/middleware", "/unscoped"} {
+		u, _ := url.Parse(path)
+		resp := newRW()
+		s.ServeHTTP(resp, &http.Request{Method: http.MethodGet, URL: u, Header: http.Header{}})
+		if resp.code != http.StatusInternalServerError || len(crashes) != i+1 {
+			t.Fatalf("Panic in %s should be recovered, but got %d", path, resp.code)
+		}
+		crash := crashes[i]
+		if crash.RoutePath != path || crash.Method != http.MethodGet || len(crash.Stack) <= 0 {
+			t.Fatalf("Crash of %s is not reported: %+v", path, crash)
+		}
+		msg := struct {
+			Reason string            `json:"reason"`
+			Data   map[string]string `json:"data"`
+		}{}
+		if err := json.Unmarshal(resp.buf.Bytes(), &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Reason != "Nirvana:Service:Crashed" || msg.Data["incident"] != crash.IncidentID {
+			t.Fatalf("Response of %s should contain incident %s, but got %s", path, crash.IncidentID, resp.buf.String())
+		}
+	}
+	// Reporters of middlewares only get crashes of their requests.
+	if len(scoped) != 2 || scoped[0] != crashes[0] || scoped[1] != crashes[1] {
+		t.Fatalf("Reporters of middlewares should get crashes of their requests, but got %v", scoped)
+	}
+	// The panic of the middleware unwinds the outer middleware.
+	if len(codes) != 1 || codes[0] != http.StatusInternalServerError {
+		t.Fatalf("Middlewares should get status code of the crash, but got %v", codes)
+	}
+}
+
+type envelopeItem struct {
+	Name string `json:"name" xml:"name"`
+}
+
+type envelopeToken string
+
+func TestEnvelope(t *testing.T) {
+	builder := NewBuilder()
+	builder.(service.EnvelopeBuilder).SetEnvelope(&service.StandardEnvelope{})
+	builder.SetModifier(service.FirstContextParameter())
+	err := builder.AddDescriptor(definition.Descriptor{
+		Path:     "/",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON, definition.MIMEXML, definition.MIMEText},
+		Children: []definition.Descriptor{
+			{
+				Path: "/data",
+				Definitions: []definition.Definition{{
+					Method: definition.Get,
+					Function: func(ctx context.Context) (*envelopeItem, error) {
+						return &envelopeItem{Name: "test"}, nil
+					},
+					Results: definition.DataErrorResults(""),
+				}},
+			},
+			{
+				Path: "/error",
+				Definitions: []definition.Definition{{
+					Method: definition.Get,
+					Function: func(ctx context.Context) (*envelopeItem, error) {
+						return nil, errors.NotFound.Build("Test:NotFound", "${name} not found").Error("test")
+					},
+					Results: definition.DataErrorResults(""),
+				}},
+			},
+			{
+				Path: "/raw",
+				Definitions: []definition.Definition{{
+					Method: definition.Get,
+					Function: func(ctx context.Context) (string, error) {
+						return "raw", nil
+					},
+					Results: definition.DataErrorResults(""),
+				}},
+			},
+			{
+				Path: "/token",
+				Definitions: []definition.Definition{{
+					Method: definition.Get,
+					Function: func(ctx context.Context) (envelopeToken, error) {
+						return "token", nil
+					},
+					Results: definition.DataErrorResults(""),
+				}},
+			},
+			{
+				Path: "/unwrapped",
+				Definitions: []definition.Definition{{
+					Method: definition.Get,
+					Function: func(ctx context.Context) (*envelopeItem, error) {
+						return &envelopeItem{Name: "test"}, nil
+					},
+					Results:    definition.DataErrorResults(""),
+					NoEnvelope: true,
+				}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		path   string
+		accept string
+		body   string
+	}{
+		{"/data", definition.MIMEJSON, `{"code":200,"message":"OK","data":{"name":"test"},"requestId":"id"}`},
+		{"/data", definition.MIMEXML, `<response><code>200</code><message>OK</message><data><name>test</name></data><requestId>id</requestId></response>`},
+		{"/error", definition.MIMEJSON, `{"code":404,"message":"test not found","data":{"reason":"Test:NotFound","message":"test not found","data":{"name":"test"}},"requestId":"id"}`},
+		{"/error", definition.MIMEText, `test not found`},
+		{"/raw", definition.MIMEJSON, `raw`},
+		{"/token", definition.MIMEJSON, `{"code":200,"message":"OK","data":"token","requestId":"id"}`},
+		{"/unwrapped", definition.MIMEJSON, `{"name":"test"}`},
+		{"/unknown", definition.MIMEJSON, `{"code":404,"message":"can't find router","data":{"reason":"Nirvana:Router:routerNotFound","message":"can't find router"},"requestId":"id"}`},
+	} {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		req.Header.Set("Accept", c.accept)
+		req.Header.Set("X-Request-Id", "id")
+		resp := httptest.NewRecorder()
+		s.ServeHTTP(resp, req)
+		if body := strings.TrimSpace(resp.Body.String()); body != c.body {
+			t.Fatalf("%s (%s) should respond %s, but got %d %s", c.path, c.accept, c.body, resp.Code, body)
+		}
+	}
+	etags := map[string]bool{}
+	for _, id := range []string{"first", "second"} {
+		req := httptest.NewRequest(http.MethodGet, "/data", nil)
+		req.Header.Set("Accept", definition.MIMEJSON)
+		req.Header.Set("X-Request-Id", id)
+		resp := httptest.NewRecorder()
+		s.ServeHTTP(resp, req)
+		etags[resp.Header().Get("ETag")] = true
+	}
+	if len(etags) != 1 {
+		t.Fatalf("ETags of enveloped data should not depend on request ids, but got %v", etags)
+	}
+
+	// Envelopes of builders don't affect other servers.
+	builder.(service.EnvelopeBuilder).SetEnvelope(nil)
+	plain, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for server, body := range map[service.Service]string{
+		s:     `{"code":200,"message":"OK","data":{"name":"test"}}`,
+		plain: `{"name":"test"}`,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/data", nil)
+		req.Header.Set("Accept", definition.MIMEJSON)
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, req)
+		if got := strings.TrimSpace(resp.Body.String()); got != body {
+			t.Fatalf("Server should respond %s, but got %s", body, got)
+		}
+	}
+}
+
+func TestProblemDetails(t *testing.T) {
+	builder := NewBuilder()
+	builder.SetModifier(service.FirstContextParameter())
+	err := builder.AddDescriptor(definition.Descriptor{
+		Path:     "/tests/{name}",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Definitions: []definition.Definition{{
+			Method:        definition.Get,
+			ErrorProduces: []string{definition.MIMEJSON, definition.MIMEProblemJSON},
+			Parameters: []definition.Parameter{
+				definition.PathParameterFor("name", ""),
+			},
+			Function: func(ctx context.Context, name string) (*envelopeItem, error) {
+				if name == "panic" {
+					panic("test")
+				}
+				return nil, errors.NotFound.Build("Test:NotFound", "${name} not found").Error(name)
+			},
+			Results: definition.DataErrorResults(""),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		path        string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"/tests/test", definition.MIMEProblemJSON + ", " + definition.MIMEJSON + ";q=0.9", definition.MIMEProblemJSON,
+			`{"detail":"test not found","instance":"/tests/test","name":"test","status":404,"title":"Not Found","type":"Test:NotFound"}`},
+		{"/tests/test", definition.MIMEJSON + ", " + definition.MIMEProblemJSON, definition.MIMEJSON,
+			`{"reason":"Test:NotFound","message":"test not found","data":{"name":"test"}}`},
+		{"/tests/panic", definition.MIMEProblemJSON + ", " + definition.MIMEJSON + ";q=0.9", definition.MIMEProblemJSON,
+			`"status":500,"title":"Internal Server Error","type":"Nirvana:Service:Crashed"}`},
+	} {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		req.Header.Set("Accept", c.accept)
+		resp := httptest.NewRecorder()
+		s.ServeHTTP(resp, req)
+		body := strings.TrimSpace(resp.Body.String())
+		if ct := resp.Header().Get("Content-Type"); ct != c.contentType || !strings.HasSuffix(body, c.body) {
+			t.Fatalf("%s (%s) should respond %s %s, but got %s %s", c.path, c.accept, c.contentType, c.body, ct, body)
+		}
+	}
+}
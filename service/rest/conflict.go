@@ -0,0 +1,181 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/rest/router"
+)
+
+// checkConflicts checks if there are two paths which match the same requests
+// with the same methods. Which one handles such requests is undefined.
+// Routes are matched in order, so paths in different routes never conflict.
+func (b *builder) checkConflicts() error {
+	for _, r := range b.sortedRoutes() {
+		paths := make([]string, 0, len(r.bindings))
+		for path, bd := range r.bindings {
+			if len(bd.definitions) > 0 {
+				paths = append(paths, path)
+			}
+		}
+		sort.Strings(paths)
+		for i, pathA := range paths {
+			for _, pathB := range paths[i+1:] {
+				if !overlap(pathA, pathB) {
+					continue
+				}
+				if method := commonMethod(r.bindings[pathA], r.bindings[pathB]); method != "" {
+					return conflictPaths.Error(r.host+pathA, r.host+pathB, method)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// commonMethod returns a method defined in both bindings.
+func commonMethod(a, b *binding) string {
+	methods := map[string]bool{}
+	for _, d := range a.definitions {
+		methods[methodOf(d)] = true
+	}
+	for _, d := range b.definitions {
+		method := methodOf(d)
+		if methods[method] || methods[string(definition.Any)] || method == string(definition.Any) {
+			return method
+		}
+	}
+	return ""
+}
+
+func methodOf(d definition.Definition) string {
+	if d.Method == definition.Any {
+		return string(definition.Any)
+	}
+	return service.HTTPMethodFor(d.Method)
+}
+
+// overlap checks if a request path may match both paths. Fixed segments take
+// precedence over expressions in the router, so they never overlap.
+func overlap(a, b string) bool {
+	as, bs := segments(a), segments(b)
+	if a == b || len(as) != len(bs) {
+		return false
+	}
+	for i := range as {
+		if !overlapSegment(as[i], bs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// segments splits a path by slashes out of braces.
+func segments(path string) []string {
+	result := []string{}
+	depth := 0
+	start := 0
+	for i, c := range path {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '/':
+			if depth == 0 {
+				result = append(result, path[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(result, path[start:])
+}
+
+func overlapSegment(a, b string) bool {
+	if a == b {
+		return true
+	}
+	expA, okA := expressionOf(a)
+	expB, okB := expressionOf(b)
+	if !okA || !okB {
+		// Fixed segments and compound segments only overlap with themselves.
+		return false
+	}
+	if expA == router.TailMatchTarget || expB == router.TailMatchTarget {
+		return expA == expB
+	}
+	if expA == router.FullMatchTarget || expB == router.FullMatchTarget || expA == expB {
+		return true
+	}
+	patternA, patternB := service.PatternFor(expA), service.PatternFor(expB)
+	switch {
+	case patternA != nil && patternB != nil:
+		return patternA.Overlap(patternB)
+	case patternA != nil:
+		return matchesExamples(expB, patternA)
+	case patternB != nil:
+		return matchesExamples(expA, patternB)
+	}
+	// Overlaps of custom expressions are unknown.
+	return false
+}
+
+// expressionOf returns the expression of a segment which only contains a key.
+func expressionOf(segment string) (string, bool) {
+	if !strings.HasPrefix(segment, "{") {
+		return "", false
+	}
+	depth := 0
+	for i, c := range segment {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 && i != len(segment)-1 {
+				return "", false
+			}
+		}
+	}
+	if depth != 0 {
+		return "", false
+	}
+	exp := segment[1 : len(segment)-1]
+	pos := strings.Index(exp, ":")
+	if pos < 0 {
+		return router.FullMatchTarget, true
+	}
+	return exp[pos+1:], true
+}
+
+func matchesExamples(exp string, p *service.Pattern) bool {
+	r, err := regexp.Compile("^(?:" + exp + ")$")
+	if err != nil {
+		return false
+	}
+	for _, example := range p.Examples {
+		if r.MatchString(example) {
+			return true
+		}
+	}
+	return false
+}
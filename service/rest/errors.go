@@ -21,8 +21,12 @@ import (
 )
 
 var (
-	noExecutorForMethod      = errors.MethodNotAllowed.Build("Nirvana:Service:NoExecutorForMethod", "method not allowed")
-	noExecutorForContentType = errors.UnsupportedMediaType.Build("Nirvana:Service:NoExecutorForContentType", "unsupported media type")
-	noExecutorToProduce      = errors.NotAcceptable.Build("Nirvana:Service:NoExecutorToProduce", "not acceptable")
+	noExecutorForMethod      = errors.MethodNotAllowed.Build("Nirvana:Service:NoExecutorForMethod", "method ${method} is not allowed, allowed methods: ${allow}")
+	noExecutorForContentType = errors.UnsupportedMediaType.Build("Nirvana:Service:NoExecutorForContentType", "unsupported media type ${type}, supported types: ${types}")
+	noExecutorToProduce      = errors.NotAcceptable.Build("Nirvana:Service:NoExecutorToProduce", "not acceptable, acceptable types: ${types}")
+	noExecutorForVersion     = errors.NotAcceptable.Build("Nirvana:Service:NoExecutorForVersion", "version ${version} is not acceptable")
 	noRouter                 = errors.InternalServerError.Build("Nirvana:Service:NoRouter", "no router to build service")
+	conflictPaths            = errors.InternalServerError.Build("Nirvana:Service:ConflictPaths", "path ${pathA} conflicts with ${pathB} for method ${method}")
+	invalidHost              = errors.InternalServerError.Build("Nirvana:Service:InvalidHost", "host ${host} is invalid")
+	noRouterForHost          = errors.NotFound.Build("Nirvana:Service:NoRouterForHost", "can't find router for host ${host}")
 )
@@ -0,0 +1,270 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"net"
+	"net/http"
+	"net/textproto"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/rest/router"
+)
+
+// route contains bindings of requests with the same host and headers.
+type route struct {
+	host     string
+	headers  map[string]string
+	matcher  *hostMatcher
+	bindings map[string]*binding
+}
+
+// routeKey generates a unique key for a host and headers.
+func routeKey(host string, headers map[string]string) string {
+	key := host
+	for _, name := range sortedKeys(headers) {
+		key += "\n" + name + ":" + headers[name]
+	}
+	return key
+}
+
+// mergeHeaders merges headers of a child to its parent's. Names of headers
+// are canonicalized.
+func mergeHeaders(parent, child map[string]string) map[string]string {
+	if len(child) <= 0 {
+		return parent
+	}
+	result := make(map[string]string, len(parent)+len(child))
+	for name, value := range parent {
+		result[name] = value
+	}
+	for name, value := range child {
+		result[textproto.CanonicalMIMEHeaderKey(name)] = value
+	}
+	return result
+}
+
+func sortedKeys(headers map[string]string) []string {
+	keys := make([]string, 0, len(headers))
+	for name := range headers {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// matches checks if the request has the host and headers of the route.
+// Values of host keys are set into the container.
+func (r *route) matches(host string, req *http.Request, c service.ValueContainer) bool {
+	for name, value := range r.headers {
+		values := req.Header[name]
+		if value == "*" {
+			if len(values) <= 0 || values[0] == "" {
+				return false
+			}
+			continue
+		}
+		found := false
+		for _, v := range values {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return r.matcher == nil || r.matcher.match(host, c)
+}
+
+// covers checks if all requests matched by the other route are matched by
+// the route as well. Middlewares of the route apply to the other in that case.
+func (r *route) covers(o *route) bool {
+	if r.host != "" && r.host != o.host {
+		return false
+	}
+	for name, value := range r.headers {
+		v, ok := o.headers[name]
+		if !ok || (value != "*" && v != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// precede checks if the route should be matched before the other. Routes
+// with hosts precede routes without hosts, and fixed hosts precede hosts
+// with wildcards or keys. Then routes with more headers win.
+func (r *route) precede(o *route) bool {
+	if (r.matcher == nil) != (o.matcher == nil) {
+		return r.matcher != nil
+	}
+	if r.matcher != nil {
+		if r.matcher.fixed != o.matcher.fixed {
+			return r.matcher.fixed
+		}
+		if r.matcher.weight != o.matcher.weight {
+			return r.matcher.weight > o.matcher.weight
+		}
+	}
+	if len(r.headers) != len(o.headers) {
+		return len(r.headers) > len(o.headers)
+	}
+	return routeKey(r.host, r.headers) < routeKey(o.host, o.headers)
+}
+
+// hostMatcher matches hosts of requests.
+type hostMatcher struct {
+	host string
+	// fixed is true if the host has no wildcards or keys.
+	fixed bool
+	// weight is the length of fixed parts of the host.
+	weight int
+	regexp *regexp.Regexp
+	// keys are keys of groups in the regexp.
+	keys map[string]string
+}
+
+// newHostMatcher creates a matcher for a host:
+//  api.example.com
+//  *.example.com
+//  {tenant}.example.com
+//  {tenant:[a-z]+}.example.com
+// A wildcard matches a part of the host without dots. Expressions can be
+// names of patterns.
+func newHostMatcher(host string) (*hostMatcher, error) {
+	host = strings.ToLower(host)
+	m := &hostMatcher{host: host, fixed: true, keys: map[string]string{}}
+	exp := ""
+	for i := 0; i < len(host); i++ {
+		switch c := host[i]; c {
+		case '*':
+			m.fixed = false
+			exp += `[^.]+`
+		case '{':
+			end := closingBrace(host, i)
+			if end < 0 {
+				return nil, invalidHost.Error(host)
+			}
+			key, keyExp := host[i+1:end], `[^.]+`
+			if pos := strings.Index(key, ":"); pos >= 0 {
+				key, keyExp = key[:pos], key[pos+1:]
+				if p := service.PatternFor(keyExp); p != nil {
+					keyExp = p.Expression
+				}
+			}
+			if key == "" || keyExp == "" {
+				return nil, invalidHost.Error(host)
+			}
+			for _, k := range m.keys {
+				if k == key {
+					return nil, invalidHost.Error(host)
+				}
+			}
+			group := "h" + strconv.Itoa(len(m.keys))
+			m.keys[group] = key
+			m.fixed = false
+			exp += "(?P<" + group + ">" + keyExp + ")"
+			i = end
+		case '}':
+			return nil, invalidHost.Error(host)
+		default:
+			m.weight++
+			exp += regexp.QuoteMeta(string(c))
+		}
+	}
+	if m.fixed {
+		return m, nil
+	}
+	r, err := regexp.Compile("^" + exp + "$")
+	if err != nil {
+		return nil, invalidHost.Error(host)
+	}
+	m.regexp = r
+	return m, nil
+}
+
+// closingBrace returns the position of the brace which closes the brace at start.
+func closingBrace(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// overlap checks if a host may match both matchers.
+func (m *hostMatcher) overlap(o *hostMatcher) bool {
+	switch {
+	case m.fixed && o.fixed:
+		return m.host == o.host
+	case m.fixed:
+		return o.regexp.MatchString(m.host)
+	case o.fixed:
+		return m.regexp.MatchString(o.host)
+	}
+	// Overlaps of expressions are unknown.
+	return true
+}
+
+func (m *hostMatcher) match(host string, c service.ValueContainer) bool {
+	if m.fixed {
+		return host == m.host
+	}
+	values := m.regexp.FindStringSubmatch(host)
+	if values == nil {
+		return false
+	}
+	hosts, ok := c.(service.HostValueContainer)
+	if !ok {
+		return true
+	}
+	for i, group := range m.regexp.SubexpNames() {
+		if key, ok := m.keys[group]; ok {
+			hosts.SetHost(key, values[i])
+		}
+	}
+	return true
+}
+
+// requestHost returns the host of a request without port.
+func requestHost(req *http.Request) string {
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// hostRouter is a router for a route.
+type hostRouter struct {
+	*route
+	root router.Router
+}
@@ -18,6 +18,9 @@ package rest
 
 import (
 	"context"
+	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/caicloud/nirvana/definition"
 	"github.com/caicloud/nirvana/service"
@@ -27,6 +30,10 @@ import (
 type inspector struct {
 	path      string
 	executors map[string][]executor.Executor
+	// routes describe definitions in the order of adding.
+	routes []service.Route
+	// observers are passed to executors.
+	observers []service.Observer
 }
 
 func newInspector(path string) *inspector {
@@ -46,7 +53,7 @@ func (i *inspector) addDefinition(d definition.Definition) error {
 	if method == "" {
 		return executor.DefinitionNoMethod.Error(d.Method, i.path)
 	}
-	c, err := executor.DefinitionToExecutor(i.path, d, 0)
+	c, err := executor.DefinitionToExecutor(i.path, d, 0, i.observers...)
 	if err != nil {
 		return err
 	}
@@ -54,6 +61,17 @@ func (i *inspector) addDefinition(d definition.Definition) error {
 		return err
 	}
 	i.executors[method] = append(i.executors[method], c)
+	route := service.Route{
+		Method:   method,
+		Path:     i.path,
+		Version:  executor.VersionOf(c),
+		Consumes: d.Consumes,
+		Produces: d.Produces,
+	}
+	if f, ok := c.(executor.FunctionalExecutor); ok {
+		route.Function, route.File, route.Line = f.Function()
+	}
+	i.routes = append(i.routes, route)
 	return nil
 }
 
@@ -64,6 +82,10 @@ func (i *inspector) conflictCheck(c executor.Executor, method string) error {
 	}
 	ctMap := map[string]bool{}
 	for _, extant := range cs {
+		if executor.VersionOf(extant) != executor.VersionOf(c) {
+			// Definitions with different versions never conflict.
+			continue
+		}
 		result := extant.ContentTypeMap()
 		for k, vs := range result {
 			for _, v := range vs {
@@ -93,11 +115,28 @@ func (i *inspector) Inspect(ctx context.Context) (executor.MiddlewareExecutor, e
 	if cs, ok := i.executors[req.Method]; ok && len(cs) > 0 {
 		executors = append(executors, cs...)
 	}
+	if len(executors) <= 0 && req.Method == http.MethodHead {
+		// Serve HEAD by GET definitions. Bodies of HEAD requests are discarded.
+		executors = append(executors, i.executors[http.MethodGet]...)
+	}
 	if cs, ok := i.executors[string(definition.Any)]; ok && len(cs) > 0 {
 		executors = append(executors, cs...)
 	}
+	if req.Method == http.MethodOptions {
+		// Middlewares (such as CORS) may get allowed methods from the header.
+		httpCtx.ResponseWriter().Header().Set("Allow", strings.Join(i.methods(), ", "))
+		if len(executors) <= 0 {
+			httpCtx.SetRoutePath(i.path)
+			return optionsExecutor{}, nil
+		}
+	}
+	if len(executors) <= 0 {
+		return nil, noExecutorForMethod.Error(req.Method, strings.Join(i.methods(), ", "))
+	}
+	version := service.RequestVersion(req)
+	executors = chooseVersion(executors, version)
 	if len(executors) <= 0 {
-		return nil, noExecutorForMethod.Error()
+		return nil, noExecutorForVersion.Error(version)
 	}
 	ct, err := service.ContentType(req)
 	if err != nil {
@@ -113,7 +152,13 @@ func (i *inspector) Inspect(ctx context.Context) (executor.MiddlewareExecutor, e
 		}
 	}
 	if accepted <= 0 {
-		return nil, noExecutorForContentType.Error()
+		consumes := []string{}
+		for _, c := range executors {
+			for consume := range c.ContentTypeMap() {
+				consumes = append(consumes, consume)
+			}
+		}
+		return nil, noExecutorForContentType.Error(ct, strings.Join(unique(consumes), ", "))
 	}
 	ats, err := service.AcceptTypes(req)
 	if err != nil {
@@ -135,8 +180,80 @@ func (i *inspector) Inspect(ctx context.Context) (executor.MiddlewareExecutor, e
 		}
 	}
 	if target == nil {
-		return nil, noExecutorToProduce.Error()
+		produces := []string{}
+		for _, c := range executors {
+			produces = append(produces, c.ContentTypeMap()[ct]...)
+		}
+		return nil, noExecutorToProduce.Error(strings.Join(unique(produces), ", "))
 	}
 	httpCtx.SetRoutePath(i.path)
+	if versioned, ok := httpCtx.(service.VersionedContext); ok {
+		versioned.SetVersion(executor.VersionOf(target))
+	}
 	return target, nil
 }
+
+// anyMethods are methods allowed by definitions with method Any.
+var anyMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions}
+
+// methods returns sorted HTTP methods allowed on the path. OPTIONS is always
+// allowed, and HEAD is allowed if GET is allowed.
+func (i *inspector) methods() []string {
+	if len(i.executors[string(definition.Any)]) > 0 {
+		return anyMethods
+	}
+	methods := []string{http.MethodOptions}
+	for method, cs := range i.executors {
+		if len(cs) > 0 && method != http.MethodOptions {
+			methods = append(methods, method)
+		}
+	}
+	if len(i.executors[http.MethodGet]) > 0 {
+		// HEAD is served by GET definitions.
+		methods = append(methods, http.MethodHead)
+	}
+	return unique(methods)
+}
+
+// unique sorts values and removes duplicated ones.
+func unique(values []string) []string {
+	sort.Strings(values)
+	result := values[:0]
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// optionsExecutor responds OPTIONS requests for paths without OPTIONS
+// definitions. Header "Allow" is set by the inspector.
+type optionsExecutor struct{}
+
+// Execute writes an empty response.
+func (e optionsExecutor) Execute(ctx context.Context) error {
+	resp := service.HTTPContextFrom(ctx).ResponseWriter()
+	if resp.HeaderWritable() {
+		resp.WriteHeader(http.StatusNoContent)
+	}
+	return nil
+}
+
+// chooseVersion filters executors by version. It follows the rules of
+// definition.ChooseVersion.
+func chooseVersion(executors []executor.Executor, version string) []executor.Executor {
+	versions := make([]string, len(executors))
+	for i, c := range executors {
+		versions[i] = executor.VersionOf(c)
+	}
+	version = definition.ChooseVersion(versions, version)
+	result := make([]executor.Executor, 0, len(executors))
+	for i, c := range executors {
+		if versions[i] == version {
+			result = append(result, c)
+		}
+	}
+	return result
+}
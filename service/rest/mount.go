@@ -0,0 +1,82 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/rest/router"
+)
+
+// mountKey is the path key of the subtree of a mounted handler.
+const mountKey = "path"
+
+// mountDefinitions returns definitions of the handler of a descriptor by
+// paths. One handles the path itself, the other handles paths under it.
+func mountDefinitions(path string, descriptor definition.Descriptor) map[string]definition.Definition {
+	serve := mountFunction(descriptor.Handler)
+	d := definition.Definition{
+		Method:      definition.Any,
+		Consumes:    []string{definition.MIMEAll},
+		Produces:    []string{definition.MIMEAll},
+		Summary:     "Mounted handler",
+		Description: descriptor.Description,
+		Function: func(ctx context.Context) {
+			serve(ctx, "")
+		},
+		Parameters: []definition.Parameter{
+			definition.PrefabParameterFor("context", "Context of the request"),
+		},
+	}
+	if d.Description == "" {
+		d.Description = fmt.Sprintf("All requests under the path are handled by %T.", descriptor.Handler)
+	}
+	subtree := d
+	subtree.Function = serve
+	subtree.Parameters = []definition.Parameter{
+		d.Parameters[0],
+		definition.PathParameterFor(mountKey, "Path in the subtree of the mounted handler"),
+	}
+	return map[string]definition.Definition{
+		path: d,
+		strings.TrimRight(path, "/") + "/{" + mountKey + ":" + router.TailMatchTarget + "}": subtree,
+	}
+}
+
+// mountFunction creates a definition function which passes requests to the
+// handler with the path in the subtree. Like http.StripPrefix, the request
+// is a copy and its context carries values of the context of nirvana.
+func mountFunction(h http.Handler) func(ctx context.Context, path string) {
+	return func(ctx context.Context, path string) {
+		httpCtx := service.HTTPContextFrom(ctx)
+		req := httpCtx.Request().WithContext(ctx)
+		u := *req.URL
+		u.RawPath = "/" + path
+		if unescaped, err := url.PathUnescape(path); err == nil {
+			path = unescaped
+		}
+		u.Path = "/" + path
+		req.URL = &u
+		h.ServeHTTP(httpCtx.ResponseWriter(), req)
+	}
+}
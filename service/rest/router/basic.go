@@ -71,7 +71,7 @@ func (h *handler) Merge(o *handler) error {
 // pack packs middlewares with the executor.
 func (h *handler) pack(e executor.MiddlewareExecutor) (executor.MiddlewareExecutor, error) {
 	if e == nil {
-		return nil, noExecutor.Error()
+		return nil, errNoExecutor
 	}
 	if len(h.middlewares) <= 0 {
 		return e, nil
@@ -82,7 +82,7 @@ func (h *handler) pack(e executor.MiddlewareExecutor) (executor.MiddlewareExecut
 // unionExecutor packs middlewares and own executor.
 func (h *handler) unionExecutor(ctx context.Context) (executor.MiddlewareExecutor, error) {
 	if h.inspector == nil {
-		return nil, noInspector.Error()
+		return nil, errNoInspector
 	}
 	e, err := h.inspector.Inspect(ctx)
 	if err != nil {
@@ -137,7 +137,7 @@ func (p *children) findStringRouter(char byte) Router {
 // the first executor which Inspect() returns true.
 func (p *children) Match(ctx context.Context, c Container, path string) (executor.MiddlewareExecutor, error) {
 	if len(path) <= 0 {
-		return nil, routerNotFound.Error()
+		return nil, errRouterNotFound
 	}
 
 	// Two routers may match same path:
@@ -150,7 +150,7 @@ func (p *children) Match(ctx context.Context, c Container, path string) (executo
 	// assigned with the error from second router.
 	// If there are multiple routers match a path, the error is from
 	// the last matched router.
-	resultError := routerNotFound.Error()
+	resultError := errRouterNotFound
 
 	// Match string routers
 	if len(p.stringRouters) > 0 {
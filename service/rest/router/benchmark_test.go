@@ -0,0 +1,105 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+// This file only uses APIs which exist in previous versions of the router,
+// so hack/bench-router.sh can copy it into a baseline revision and compare
+// the results.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/executor"
+)
+
+// benchmarkRoutes are 1024 routes with all kinds of router nodes.
+var benchmarkRoutes = func() []string {
+	routes := make([]string, 0, 1024)
+	for i := 0; i < 256; i++ {
+		prefix := fmt.Sprintf("/api/v1/resources%d", i)
+		routes = append(routes,
+			prefix,
+			prefix+"/{id}",
+			prefix+"/{id}/versions/{version:[0-9]+}.json",
+			prefix+"/{id}/files/{path:*}",
+		)
+	}
+	return routes
+}()
+
+// benchmarkPaths are paths to match in benchmarkRoutes.
+var benchmarkPaths = []struct {
+	path    string
+	matched bool
+}{
+	{"/api/v1/resources17", true},
+	{"/api/v1/resources128/nirvana", true},
+	{"/api/v1/resources255/nirvana/versions/3.json", true},
+	{"/api/v1/resources64/nirvana/files/docs/index.html", true},
+	{"/api/v1/resources255/nirvana/versions/latest.json", false},
+}
+
+// fixedInspector always returns the same executor.
+type fixedInspector struct {
+	executor executor.MiddlewareExecutor
+}
+
+func (fi fixedInspector) Inspect(c context.Context) (executor.MiddlewareExecutor, error) {
+	return fi.executor, nil
+}
+
+// benchmarkRouter builds a router of benchmarkRoutes.
+func benchmarkRouter(b *testing.B) Router {
+	var root Router
+	for _, path := range benchmarkRoutes {
+		router, leaf, err := Parse(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		leaf.SetInspector(fixedInspector{getExecs[0]})
+		if root == nil {
+			root = router
+		} else if root, err = root.Merge(router); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return root
+}
+
+// BenchmarkMatchRoutes matches paths in 1024 routes with a new http context
+// for each request.
+func BenchmarkMatchRoutes(b *testing.B) {
+	root := benchmarkRouter(b)
+	for _, bp := range benchmarkPaths {
+		path, matched := bp.path, bp.matched
+		b.Run(path, func(b *testing.B) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ctx := service.NewHTTPContext(nil, req)
+				if _, err := root.Match(ctx, ctx.ValueContainer(), path); (err == nil) != matched {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
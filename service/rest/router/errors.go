@@ -57,3 +57,17 @@ var (
 	// invalidRegexp means regexp is not notmative.
 	invalidRegexp = errors.UnprocessableEntity.Build("Nirvana:Router:invalidRegexp", "regexp ${regexp} does not have normative format")
 )
+
+// Errors returned by `Match` are created once. Building errors for every
+// unmatched node allocates a lot.
+var (
+	errRouterNotFound = routerNotFound.Error()
+	errNoInspector    = noInspector.Error()
+	errNoExecutor     = noExecutor.Error()
+)
+
+// Unmatched checks if an error returned by `Match` means that the router
+// can't handle the path. Other routers may handle the path.
+func Unmatched(err error) bool {
+	return routerNotFound.Derived(err) || noInspector.Derived(err) || noExecutor.Derived(err)
+}
@@ -43,6 +43,9 @@ type regexpNode struct {
 	exp string
 	// regexp is a regexp instance to match.
 	regexp *regexp.Regexp
+	// whole is true if the only key captures the whole segment. The
+	// value is the segment and submatches are not needed.
+	whole bool
 }
 
 // Target returns the matching target of the node.
@@ -67,9 +70,13 @@ func (n *regexpNode) Match(ctx context.Context, c Container, path string) (execu
 		index = len(path)
 	}
 	segment := path[:index]
-	result := n.regexp.FindStringSubmatch(segment)
-	if result == nil {
-		return nil, routerNotFound.Error()
+	var result []string
+	if n.whole {
+		if !n.regexp.MatchString(segment) {
+			return nil, errRouterNotFound
+		}
+	} else if result = n.regexp.FindStringSubmatch(segment); result == nil {
+		return nil, errRouterNotFound
 	}
 	// Match progeny
 	var e executor.MiddlewareExecutor
@@ -89,6 +96,10 @@ func (n *regexpNode) Match(ctx context.Context, c Container, path string) (execu
 	}
 
 	// Set values
+	if n.whole {
+		c.Set(n.indices[0].Key, segment)
+		return e, nil
+	}
 	for _, i := range n.indices {
 		c.Set(i.Key, result[i.Pos])
 	}
@@ -21,9 +21,11 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"regexp/syntax"
 	"strings"
 
 	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/service"
 	"github.com/caicloud/nirvana/service/executor"
 )
 
@@ -105,6 +107,9 @@ const (
 // A valid path should like:
 //  /segments/{segment}/resources/{resource}
 //  /segments/{segment:[a-z]{1,2}}.log/paths/{path:*}
+//  /segments/{segment:slug}/resources/{resource:int}
+// Names of patterns registered by service.RegisterPattern can be used as
+// expressions.
 func Parse(path string) (Router, Router, error) {
 	paths, err := Split(path)
 	if err != nil {
@@ -180,6 +185,9 @@ func segmentToRouter(seg *segment) (Router, error) {
 		if j != len(seg.keys) {
 			return nil, unmatchedSegmentKeys.Error(seg)
 		}
+		if exp, err := syntax.Parse(seg.match, syntax.Perl); err == nil && len(seg.keys) == 1 {
+			node.whole = exp.Op == syntax.OpCapture && exp.Name == seg.keys[0]
+		}
 		return node, nil
 
 	case Path:
@@ -316,6 +324,9 @@ func parseExpSegment(exp string) (*expSegment, error) {
 	} else {
 		seg.exp = exp[pos+1:]
 		seg.key = exp[:pos]
+		if p := service.PatternFor(seg.exp); p != nil {
+			seg.exp = p.Expression
+		}
 	}
 	return seg, nil
 }
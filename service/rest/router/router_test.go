@@ -19,11 +19,14 @@ package router
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 
 	"github.com/caicloud/nirvana/definition"
 	"github.com/caicloud/nirvana/errors"
+	"github.com/caicloud/nirvana/service"
 	"github.com/caicloud/nirvana/service/executor"
 )
 
@@ -678,3 +681,22 @@ func (ti TestInspector) Inspect(c context.Context) (executor.MiddlewareExecutor,
 	}
 	return nil, errUnmatched
 }
+
+// BenchmarkMatch matches paths in 1024 routes with pooled http contexts.
+func BenchmarkMatch(b *testing.B) {
+	root := benchmarkRouter(b)
+	for _, bp := range benchmarkPaths {
+		path, matched := bp.path, bp.matched
+		b.Run(path, func(b *testing.B) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ctx := service.AcquireHTTPContext(nil, req)
+				if _, err := root.Match(ctx, ctx.ValueContainer(), path); (err == nil) != matched {
+					b.Fatal(err)
+				}
+				service.ReleaseHTTPContext(ctx)
+			}
+		})
+	}
+}
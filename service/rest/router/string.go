@@ -50,7 +50,7 @@ func (n *stringNode) Kind() RouteKind {
 func (n *stringNode) Match(ctx context.Context, c Container, path string) (executor.MiddlewareExecutor, error) {
 	if n.prefix != "" && !strings.HasPrefix(path, n.prefix) {
 		// No match
-		return nil, routerNotFound.Error()
+		return nil, errRouterNotFound
 	}
 	if len(n.prefix) < len(path) {
 		// Match prefix
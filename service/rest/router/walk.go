@@ -0,0 +1,60 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+// WalkFunc is called for each router node. Parents are routers from the
+// root to the parent of the node.
+type WalkFunc func(parents []Router, r Router) error
+
+// Walk walks a router tree in the order of matching: a node is walked before
+// its children, string routers are walked before regexp routers, and path
+// routers are the last. If fn returns an error, Walk stops and returns it.
+func Walk(root Router, fn WalkFunc) error {
+	return walk(nil, root, fn)
+}
+
+func walk(parents []Router, r Router, fn WalkFunc) error {
+	if err := fn(parents, r); err != nil {
+		return err
+	}
+	var c *children
+	switch node := r.(type) {
+	case *stringNode:
+		c = &node.children
+	case *regexpNode:
+		c = &node.children
+	case *fullMatchRegexpNode:
+		c = &node.children
+	default:
+		return nil
+	}
+	parents = append(parents[:len(parents):len(parents)], r)
+	for _, cr := range c.stringRouters {
+		if err := walk(parents, cr.router, fn); err != nil {
+			return err
+		}
+	}
+	for _, child := range c.regexpRouters {
+		if err := walk(parents, child, fn); err != nil {
+			return err
+		}
+	}
+	if c.pathRouter != nil {
+		return walk(parents, c.pathRouter, fn)
+	}
+	return nil
+}
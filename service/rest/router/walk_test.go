@@ -0,0 +1,58 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package router
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/caicloud/nirvana/definition"
+)
+
+func TestWalk(t *testing.T) {
+	m := func(ctx context.Context, c definition.Chain) error { return c.Continue(ctx) }
+	rds := []TestRouterData{
+		{"/apps/{path:*}", []*TestExecutor{{"GET", 1}}, nil},
+		{"/apps/{id}", []*TestExecutor{{"GET", 2}}, nil},
+		{"/apps/new", []*TestExecutor{{"GET", 3}}, []definition.Middleware{m}},
+		{"/apps", []*TestExecutor{{"GET", 4}}, []definition.Middleware{m}},
+	}
+	results := []int{}
+	middlewares := []int{}
+	err := Walk(makeRouter(t, rds), func(parents []Router, r Router) error {
+		if r.Inspector() == nil {
+			return nil
+		}
+		results = append(results, r.Inspector().(TestInspector)[0].Result)
+		count := len(r.Middlewares())
+		for _, p := range parents {
+			count += len(p.Middlewares())
+		}
+		middlewares = append(middlewares, count)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results, []int{4, 3, 2, 1}) {
+		t.Fatalf("Routers should be walked in the order of matching, but got: %v", results)
+	}
+	if !reflect.DeepEqual(middlewares, []int{1, 2, 1, 1}) {
+		t.Fatalf("Unexpected middlewares: %v", middlewares)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/rest/router"
+)
+
+// routeTable lists routes of routers in the order of matching.
+func routeTable(routers []*hostRouter) ([]service.Route, error) {
+	table := []service.Route{}
+	// owners are routers of routes in the table.
+	owners := []*hostRouter{}
+	for _, hr := range routers {
+		err := router.Walk(hr.root, func(parents []router.Router, r router.Router) error {
+			ins, ok := r.Inspector().(*inspector)
+			if !ok {
+				return nil
+			}
+			count := len(r.Middlewares())
+			for _, p := range parents {
+				count += len(p.Middlewares())
+			}
+			for _, route := range ins.routes {
+				route.Host = hr.host
+				route.Headers = hr.headers
+				route.Middlewares = count
+				table = append(table, route)
+				owners = append(owners, hr)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i := range table {
+		for j := range table[:i] {
+			if shadow(owners[j], owners[i], &table[j], &table[i]) {
+				table[i].ShadowedBy = append(table[i].ShadowedBy, table[j].String())
+			} else if owners[i] == owners[j] && shadow(owners[i], owners[j], &table[i], &table[j]) {
+				table[j].ShadowedBy = append(table[j].ShadowedBy, table[i].String())
+			}
+		}
+	}
+	return table, nil
+}
+
+// shadow checks if route a handles some requests of route b before b.
+// Router ra precedes rb or they are the same router.
+func shadow(ra, rb *hostRouter, a, b *service.Route) bool {
+	if a.Method != b.Method && a.Method != string(definition.Any) && b.Method != string(definition.Any) {
+		return false
+	}
+	if a.Version != "" && b.Version != "" && a.Version != b.Version {
+		return false
+	}
+	if ra != rb {
+		if ra.matcher != nil && rb.matcher != nil && !ra.matcher.overlap(rb.matcher) {
+			return false
+		}
+		return intersect(a.Path, b.Path)
+	}
+	if a.Path == b.Path || !intersect(a.Path, b.Path) {
+		return false
+	}
+	// Fixed segments take precedence over expressions in a router.
+	as, bs := segments(a.Path), segments(b.Path)
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			return !strings.Contains(as[i], "{") && strings.Contains(bs[i], "{")
+		}
+	}
+	return false
+}
+
+// intersect checks if a request path may match both paths.
+func intersect(a, b string) bool {
+	as, bs := segments(a), segments(b)
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		expA, _ := expressionOf(as[i])
+		expB, _ := expressionOf(bs[i])
+		if expA == router.TailMatchTarget || expB == router.TailMatchTarget {
+			return true
+		}
+		if !intersectSegment(as[i], bs[i]) {
+			return false
+		}
+	}
+	return len(as) == len(bs)
+}
+
+func intersectSegment(a, b string) bool {
+	if a == b {
+		return true
+	}
+	expA, okA := expressionOf(a)
+	expB, okB := expressionOf(b)
+	switch {
+	case okA && okB:
+		return overlapSegment(a, b)
+	case okA && !strings.Contains(b, "{"):
+		return matchesFixed(expA, b)
+	case okB && !strings.Contains(a, "{"):
+		return matchesFixed(expB, a)
+	}
+	// Compound segments only intersect with themselves.
+	return false
+}
+
+// matchesFixed checks if an expression matches a fixed segment.
+func matchesFixed(exp string, segment string) bool {
+	if exp == router.FullMatchTarget {
+		return segment != ""
+	}
+	if p := service.PatternFor(exp); p != nil {
+		exp = p.Expression
+	}
+	r, err := regexp.Compile("^(?:" + exp + ")$")
+	return err == nil && r.MatchString(segment)
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package rpc
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"reflect"
@@ -42,6 +43,14 @@ type builder struct {
 	modifier service.DefinitionModifier
 	filters  []service.Filter
 	logger   log.Logger
+	// reuse is true if http contexts are reused.
+	reuse bool
+	// observers observe phases of executing definitions.
+	observers []service.Observer
+	// reporters get crashes of all requests.
+	reporters []service.CrashReporter
+	// envelope wraps bodies of responses.
+	envelope service.Envelope
 }
 
 // NewBuilder creates a service builder.
@@ -52,6 +61,11 @@ func NewBuilder() service.Builder {
 	}
 }
 
+var _ service.ContextReuser = &builder{}
+var _ service.ObservableBuilder = &builder{}
+var _ service.CrashReportingBuilder = &builder{}
+var _ service.EnvelopeBuilder = &builder{}
+
 // Filters returns all request filters.
 func (b *builder) Filters() []service.Filter {
 	result := make([]service.Filter, len(b.filters))
@@ -78,6 +92,52 @@ func (b *builder) SetLogger(logger log.Logger) {
 	}
 }
 
+// ContextReuse returns whether http contexts are reused.
+func (b *builder) ContextReuse() bool {
+	return b.reuse
+}
+
+// SetContextReuse sets whether http contexts are reused by a pool.
+func (b *builder) SetContextReuse(reuse bool) {
+	b.reuse = reuse
+}
+
+// Observers returns all executor observers.
+func (b *builder) Observers() []service.Observer {
+	result := make([]service.Observer, len(b.observers))
+	copy(result, b.observers)
+	return result
+}
+
+// AddObserver adds observers to observe phases of executing definitions.
+func (b *builder) AddObserver(observers ...service.Observer) {
+	b.observers = append(b.observers, observers...)
+}
+
+// CrashReporters returns all crash reporters.
+func (b *builder) CrashReporters() []service.CrashReporter {
+	result := make([]service.CrashReporter, len(b.reporters))
+	copy(result, b.reporters)
+	return result
+}
+
+// AddCrashReporter adds crash reporters which get crashes of all requests
+// served by the service.
+func (b *builder) AddCrashReporter(reporters ...service.CrashReporter) {
+	b.reporters = append(b.reporters, reporters...)
+}
+
+// Envelope returns the envelope of responses.
+func (b *builder) Envelope() service.Envelope {
+	return b.envelope
+}
+
+// SetEnvelope sets the envelope of all responses. Definitions can disable
+// it by NoEnvelope. A nil envelope disables envelopes.
+func (b *builder) SetEnvelope(e service.Envelope) {
+	b.envelope = e
+}
+
 // Modifier returns modifier of builder.
 func (b *builder) Modifier() service.DefinitionModifier {
 	return b.modifier
@@ -189,7 +249,7 @@ func (b *builder) Build() (service.Service, error) {
 		if b.modifier != nil {
 			b.modifier(&bd.definition)
 		}
-		bd.executor, err = executor.DefinitionToExecutor(path, bd.definition, http.StatusOK)
+		bd.executor, err = executor.DefinitionToExecutor(path, bd.definition, http.StatusOK, b.observers...)
 		if err != nil {
 			return nil, err
 		}
@@ -200,6 +260,9 @@ func (b *builder) Build() (service.Service, error) {
 		filters:   b.filters,
 		logger:    b.logger,
 		producers: service.AllProducers(),
+		reuse:     b.reuse,
+		reporters: b.CrashReporters(),
+		envelope:  b.envelope,
 	}
 	return s, nil
 }
@@ -209,6 +272,12 @@ type server struct {
 	filters   []service.Filter
 	logger    log.Logger
 	producers []service.Producer
+	// reuse is true if http contexts are reused.
+	reuse bool
+	// reporters get crashes of all requests.
+	reporters []service.CrashReporter
+	// envelope wraps bodies of responses.
+	envelope service.Envelope
 }
 
 func (s *server) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
@@ -217,7 +286,17 @@ func (s *server) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 			return
 		}
 	}
-	ctx := service.NewHTTPContext(resp, req)
+	var ctx *service.HTTPCtx
+	if s.reuse {
+		ctx = service.AcquireHTTPContext(resp, req)
+		defer service.ReleaseHTTPContext(ctx)
+	} else {
+		ctx = service.NewHTTPContext(resp, req)
+	}
+	ctx.SetLogger(s.logger)
+	ctx.SetService(s)
+	ctx.AddCrashReporter(s.reporters...)
+	ctx.SetEnvelope(s.envelope)
 
 	action := req.URL.Query().Get("Action")
 	version := req.URL.Query().Get("Version")
@@ -231,7 +310,7 @@ func (s *server) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 	}
 
 	ctx.SetRoutePath(path)
-	err := executor.NewMiddlewareExecutor(e.middlewares, e.executor).Execute(ctx)
+	err := s.execute(ctx, executor.NewMiddlewareExecutor(e.middlewares, e.executor))
 	if err == nil && ctx.ResponseWriter().HeaderWritable() {
 		err = service.InvalidService.Error()
 	}
@@ -245,3 +324,14 @@ func (s *server) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 		}
 	}
 }
+
+// execute executes an executor. Panics out of executors (e.g. from
+// middlewares) are converted to errors.
+func (s *server) execute(ctx context.Context, e executor.MiddlewareExecutor) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = service.Recover(ctx, r)
+		}
+	}()
+	return e.Execute(ctx)
+}
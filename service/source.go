@@ -18,12 +18,16 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/errors"
+	"github.com/caicloud/nirvana/operators/validator"
 )
 
 // ParameterGenerator is used to generate object for a parameter.
@@ -38,6 +42,7 @@ type ParameterGenerator interface {
 
 var generators = map[definition.Source]ParameterGenerator{
 	definition.Path:   &PathParameterGenerator{},
+	definition.Host:   &HostParameterGenerator{},
 	definition.Query:  &QueryParameterGenerator{},
 	definition.Header: &HeaderParameterGenerator{},
 	definition.Form:   &FormParameterGenerator{},
@@ -110,6 +115,43 @@ func (g *PathParameterGenerator) Generate(ctx context.Context, vc ValueContainer
 	return nil, nil
 }
 
+// HostParameterGenerator is used to generate object by value from host.
+type HostParameterGenerator struct{}
+
+// Source returns the source generated by current generator.
+func (g *HostParameterGenerator) Source() definition.Source { return definition.Host }
+
+// Validate validates whether defaultValue and target type is valid.
+func (g *HostParameterGenerator) Validate(name string, defaultValue interface{}, target reflect.Type) error {
+	if name == "" {
+		return noName.Error(g.Source())
+	}
+	if err := assignable(defaultValue, target); err != nil {
+		return err
+	}
+	if err := convertible(target); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Generate generates an object by data from value container.
+func (g *HostParameterGenerator) Generate(ctx context.Context, vc ValueContainer, consumers []Consumer,
+	name string, target reflect.Type) (interface{}, error) {
+	hosts, ok := vc.(HostValueContainer)
+	if !ok {
+		return nil, nil
+	}
+	data, ok := hosts.Host(name)
+	if !ok || len(data) <= 0 {
+		return nil, nil
+	}
+	if converter := ConverterFor(target); converter != nil {
+		return converter(ctx, []string{data})
+	}
+	return nil, nil
+}
+
 // QueryParameterGenerator is used to generate object by value from query string.
 type QueryParameterGenerator struct{}
 
@@ -380,15 +422,202 @@ func (g *PrefabParameterGenerator) Generate(ctx context.Context, vc ValueContain
 }
 
 // AutoParameterGenerator generates an object from a struct type. The fields in a struct can have tag.
-// Tag name is "source". Its value format is "Source,Name".
+// Tag name is "source". Its value format is "Source,Name[,Key=Value...]".
 //
 // ex.
 // type Example struct {
-//     Start       int    `source:"Query,start"`
+//     Start       int    `source:"Query,start,default=0"`
 //     ContentType string `source:"Header,Content-Type"`
 // }
+//
+// A field with source "Auto" is a nested group. Its type must be a struct, a pointer to
+// struct, or a slice of them. The name of a group is the prefix of query and form keys
+// in the group. Elements of a slice group are read from indexed keys. A pointer group
+// is only allocated when at least one of its fields is present in the request.
+//
+// ex.
+// type Filter struct {
+//     Name string `source:"Query,name"`
+// }
+// type Example struct {
+//     Filter  *Filter  `source:"Auto,filter"`  // Query "filter.name"
+//     Filters []Filter `source:"Auto,filters"` // Query "filters[0].name", "filters[1].name", ...
+// }
+//
+// Config "fields" of a group sets the source of fields without tag. Their names are
+// from json tags or field names. A blank field sets the config for the top level struct.
+//
+// ex.
+// type Example struct {
+//     _     struct{} `source:"Auto,,fields=Query"`
+//     Start int      `json:"start"` // Query "start"
+//     Body  *Object  `source:"Body"`
+// }
+//
+// Config "validate" of the top level struct validates the generated struct by
+// "validate" tags with the operators/validator package. It's not necessary if a
+// validator operator is already attached to the parameter.
+//
+// ex.
+// type Example struct {
+//     _     struct{} `source:"Auto,,validate"`
+//     Limit int      `source:"Query,limit" validate:"max=100"`
+// }
 type AutoParameterGenerator struct{}
 
+// maxAutoSliceLength limits the length of a slice group.
+const maxAutoSliceLength = 1000
+
+// AutoGroup describes a group of fields in an auto parameter. Generators of
+// API docs use it to get keys of fields as AutoParameterGenerator does.
+type AutoGroup struct {
+	// Prefix is the prefix of query and form keys.
+	Prefix string
+	// Fields is the source of fields without tag.
+	Fields definition.Source
+	// Indexed indicates that the group is an element of a slice group.
+	Indexed bool
+	// Validate indicates that the generated struct is validated by its
+	// "validate" tags. It only takes effect in the top level struct.
+	Validate bool
+}
+
+// Key returns the full key of a field in the group.
+func (g *AutoGroup) Key(source definition.Source, name string) string {
+	if g.Prefix == "" || (source != definition.Query && source != definition.Form) {
+		return name
+	}
+	return g.Prefix + "." + name
+}
+
+// Configure returns a copy of the group with the config of a tag applied.
+func (g *AutoGroup) Configure(params AutoParameterConfig) AutoGroup {
+	group := *g
+	if fields, ok := params.Get(AutoParameterConfigKeyFields); ok {
+		group.Fields = definition.Source(strings.Title(strings.ToLower(fields)))
+	}
+	if _, ok := params.Get(AutoParameterConfigKeyValidate); ok {
+		group.Validate = true
+	}
+	return group
+}
+
+// Child returns the nested group of a field tagged as auto with name.
+func (g *AutoGroup) Child(name string, params AutoParameterConfig) AutoGroup {
+	child := AutoGroup{
+		Prefix:  g.Key(definition.Query, name),
+		Fields:  g.Fields,
+		Indexed: g.Indexed,
+	}
+	return child.Configure(params)
+}
+
+// element returns the group of the index-th element of a slice group.
+func (g *AutoGroup) element(index int) AutoGroup {
+	return AutoGroup{
+		Prefix:  fmt.Sprintf("%s[%d]", g.Prefix, index),
+		Fields:  g.Fields,
+		Indexed: true,
+	}
+}
+
+// autoField describes how to generate a struct field.
+type autoField struct {
+	source definition.Source
+	name   string
+	params AutoParameterConfig
+	// group is not nil if the field is a nested group.
+	group *AutoGroup
+}
+
+// autoGroupFor creates the group for typ. Config of the blank field is applied.
+func autoGroupFor(parent AutoGroup, typ reflect.Type) (AutoGroup, error) {
+	group := parent
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("source")
+		if field.Name != "_" || tag == "" {
+			continue
+		}
+		_, _, params, err := ParseAutoParameterTag(tag)
+		if err != nil {
+			return group, err
+		}
+		group = group.Configure(params)
+	}
+	return group, nil
+}
+
+// autoFieldFor parses a struct field. It returns nil if the field should be ignored.
+func autoFieldFor(group *AutoGroup, field reflect.StructField) (*autoField, error) {
+	if field.Name == "_" {
+		return nil, nil
+	}
+	tag := field.Tag.Get("source")
+	if tag != "" {
+		source, name, params, err := ParseAutoParameterTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if source != definition.Auto {
+			if group.Indexed && source != definition.Query && source != definition.Form {
+				return nil, invalidIndexedField.Error(field.Name, source)
+			}
+			return &autoField{source, group.Key(source, name), params, nil}, nil
+		}
+		if !isAutoGroupType(field.Type, true) {
+			return nil, invalidAutoGroup.Error(field.Name, field.Type)
+		}
+		child := group.Child(name, params)
+		if field.Type.Kind() == reflect.Slice && child.Prefix == "" {
+			return nil, noName.Error(definition.Auto)
+		}
+		return &autoField{definition.Auto, name, params, &child}, nil
+	}
+	exported := field.PkgPath == "" || field.Anonymous
+	if group.Fields != "" && exported && (ConverterFor(field.Type) != nil || !isAutoGroupType(field.Type, false)) {
+		name, ok := JSONNameOf(field.Name, field.Tag)
+		if !ok {
+			// Fields ignored by json are not read from requests either.
+			return nil, nil
+		}
+		return &autoField{group.Fields, group.Key(group.Fields, name), AutoParameterConfig{}, nil}, nil
+	}
+	if field.Type.Kind() == reflect.Struct {
+		// Untagged structs share the group of their parent. Pointers to
+		// structs are only read if they are tagged.
+		child := *group
+		return &autoField{definition.Auto, "", AutoParameterConfig{}, &child}, nil
+	}
+	return nil, nil
+}
+
+// isAutoGroupType checks if typ can be a nested group. Slices are only
+// allowed for tagged groups.
+func isAutoGroupType(typ reflect.Type, slice bool) bool {
+	if slice && typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Struct
+}
+
+// JSONNameOf returns the name of a field in json tag. If there is no json
+// tag, the field name is returned. It returns false if the field is ignored
+// by json tag "-".
+func JSONNameOf(name string, tag reflect.StructTag) (string, bool) {
+	value := tag.Get("json")
+	if value == "-" {
+		return "", false
+	}
+	if n := strings.TrimSpace(strings.Split(value, ",")[0]); n != "" {
+		return n, true
+	}
+	return name, true
+}
+
 // Source returns the source generated by current generator.
 func (g *AutoParameterGenerator) Source() definition.Source { return definition.Auto }
 
@@ -401,36 +630,64 @@ func (g *AutoParameterGenerator) Validate(name string, defaultValue interface{},
 	if target.Kind() != reflect.Struct && !(target.Kind() == reflect.Ptr && target.Elem().Kind() == reflect.Struct) {
 		return invalidAutoParameter.Error(target)
 	}
-	f := func(index []int, field reflect.StructField) error {
-		source, name, params, err := ParseAutoParameterTag(field.Tag.Get("source"))
+	if target.Kind() == reflect.Ptr {
+		target = target.Elem()
+	}
+	return g.validate(AutoGroup{}, target, map[reflect.Type]bool{})
+}
+
+func (g *AutoParameterGenerator) validate(parent AutoGroup, typ reflect.Type, visiting map[reflect.Type]bool) error {
+	if visiting[typ] {
+		return nil
+	}
+	visiting[typ] = true
+	defer delete(visiting, typ)
+	group, err := autoGroupFor(parent, typ)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		f, err := autoFieldFor(&group, field)
 		if err != nil {
 			return err
 		}
-		generator := ParameterGeneratorFor(source)
+		if f == nil {
+			continue
+		}
+		if f.group != nil {
+			child := *f.group
+			elem := field.Type
+			if elem.Kind() == reflect.Slice {
+				elem = elem.Elem()
+				child = child.element(0)
+			}
+			if elem.Kind() == reflect.Ptr {
+				elem = elem.Elem()
+			}
+			if err := g.validate(child, elem, visiting); err != nil {
+				return err
+			}
+			continue
+		}
+		generator := ParameterGeneratorFor(f.source)
 		if generator == nil {
-			return NoParameterGenerator.Error(source)
+			return NoParameterGenerator.Error(f.source)
 		}
-
 		var value interface{}
-		defaultValue, exist := params.Get(AutoParameterConfigKeyDefaultValue)
-		if exist {
+		if defaultValue, exist := f.params.Get(AutoParameterConfigKeyDefaultValue); exist {
 			if c := ConverterFor(field.Type); c != nil {
-				var err error
 				value, err = c(context.Background(), []string{defaultValue})
 				if err != nil {
 					return err
 				}
 			}
 		}
-
-		return generator.Validate(name, value, field.Type)
-	}
-	if target.Kind() == reflect.Struct {
-		err = g.enum([]int{}, target, f)
-	} else {
-		err = g.enum([]int{}, target.Elem(), f)
+		if err := generator.Validate(f.name, value, field.Type); err != nil {
+			return err
+		}
 	}
-	return err
+	return nil
 }
 
 // Generate generates an object by data from value container.
@@ -444,29 +701,140 @@ func (g *AutoParameterGenerator) Generate(ctx context.Context, vc ValueContainer
 		result = reflect.New(target.Elem())
 		value = result.Elem()
 	}
-	if err := g.generate(ctx, vc, consumers, value); err != nil {
+	// errs collects errors of fields if errors are aggregated.
+	var errs *[]errors.FieldError
+	if AggregateErrorsFrom(ctx) {
+		errs = &[]errors.FieldError{}
+	}
+	if _, err := g.generate(ctx, vc, consumers, AutoGroup{}, value, map[reflect.Type]bool{}, errs); err != nil {
+		return nil, err
+	}
+	group, err := autoGroupFor(AutoGroup{}, value.Type())
+	if err != nil {
 		return nil, err
 	}
+	if group.Validate {
+		if err := validator.ValidateStruct(ctx, value.Addr().Interface()); err != nil {
+			if errs == nil {
+				return nil, err
+			}
+			for _, fe := range errors.FieldErrorsOf("", "", err) {
+				if field, source, ok := requestFieldOf(value.Type(), fe.Field); ok {
+					fe.Field, fe.Source = field, string(source)
+				}
+				*errs = append(*errs, fe)
+			}
+		}
+	}
+	if errs != nil && len(*errs) > 0 {
+		return nil, errors.WithFields(InvalidFields.Error(len(*errs)), *errs...)
+	}
 	return result.Interface(), nil
 }
 
-func (g *AutoParameterGenerator) generate(ctx context.Context, vc ValueContainer, consumers []Consumer, value reflect.Value) error {
-	f := func(index []int, field reflect.StructField) error {
-		source, name, params, err := ParseAutoParameterTag(field.Tag.Get("source"))
+// requestFieldOf maps a struct path in validation errors (such as "Filters[0].Name")
+// to the key and source of the field in the request. It returns false if the path
+// is not a field generated by the auto generator.
+func requestFieldOf(typ reflect.Type, path string) (string, definition.Source, bool) {
+	group := AutoGroup{}
+	for path != "" {
+		seg, rest := path, ""
+		if pos := strings.Index(path, "."); pos >= 0 {
+			seg, rest = path[:pos], path[pos+1:]
+		}
+		name, index := seg, -1
+		if pos := strings.Index(seg, "["); pos >= 0 && strings.HasSuffix(seg, "]") {
+			i, err := strconv.Atoi(seg[pos+1 : len(seg)-1])
+			if err != nil {
+				return "", "", false
+			}
+			name, index = seg[:pos], i
+		}
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct {
+			return "", "", false
+		}
+		g, err := autoGroupFor(group, typ)
 		if err != nil {
-			return err
+			return "", "", false
+		}
+		field, ok := typ.FieldByName(name)
+		if !ok {
+			return "", "", false
+		}
+		f, err := autoFieldFor(&g, field)
+		if err != nil || f == nil {
+			return "", "", false
+		}
+		if f.group == nil {
+			if rest != "" {
+				return "", "", false
+			}
+			// Keep the index of an element in a slice field.
+			return f.name + seg[len(name):], f.source, true
 		}
-		generator := ParameterGeneratorFor(source)
+		group, typ = *f.group, field.Type
+		if index >= 0 {
+			if typ.Kind() != reflect.Slice {
+				return "", "", false
+			}
+			group, typ = group.element(index), typ.Elem()
+		}
+		path = rest
+	}
+	return "", "", false
+}
+
+// generate fills fields of value. It returns true if any field is present in the request.
+// If errs is not nil, errors of fields are appended to it and other fields are still filled.
+func (g *AutoParameterGenerator) generate(ctx context.Context, vc ValueContainer, consumers []Consumer,
+	parent AutoGroup, value reflect.Value, visiting map[reflect.Type]bool, errs *[]errors.FieldError) (bool, error) {
+	typ := value.Type()
+	if visiting[typ] {
+		return false, nil
+	}
+	visiting[typ] = true
+	defer delete(visiting, typ)
+	group, err := autoGroupFor(parent, typ)
+	if err != nil {
+		return false, err
+	}
+	present := false
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		f, err := autoFieldFor(&group, field)
+		if err != nil {
+			return false, err
+		}
+		if f == nil {
+			continue
+		}
+		if f.group != nil {
+			ok, err := g.generateGroup(ctx, vc, consumers, *f.group, value.Field(i), visiting, errs)
+			if err != nil {
+				return false, err
+			}
+			present = present || ok
+			continue
+		}
+		generator := ParameterGeneratorFor(f.source)
 		if generator == nil {
-			return NoParameterGenerator.Error(source)
+			return false, NoParameterGenerator.Error(f.source)
 		}
-		ins, err := generator.Generate(ctx, vc, consumers, name, field.Type)
+		ins, err := generator.Generate(ctx, vc, consumers, f.name, field.Type)
 		if err != nil {
-			return err
+			if errs == nil {
+				return false, err
+			}
+			*errs = append(*errs, errors.FieldErrorsOf(f.name, string(f.source), err)...)
+			present = true
+			continue
 		}
-
-		defaultValue, exist := params.Get(AutoParameterConfigKeyDefaultValue)
-		if ins == nil && exist {
+		if ins != nil {
+			present = true
+		} else if defaultValue, exist := f.params.Get(AutoParameterConfigKeyDefaultValue); exist {
 			if c := ConverterFor(field.Type); c != nil {
 				// After passing the validation phase, here will never return an error
 				ins, _ = c(ctx, []string{defaultValue}) // #nosec
@@ -474,28 +842,45 @@ func (g *AutoParameterGenerator) generate(ctx context.Context, vc ValueContainer
 		}
 
 		if ins != nil {
-			value.FieldByIndex(index).Set(reflect.ValueOf(ins))
+			value.Field(i).Set(reflect.ValueOf(ins))
 		}
-
-		return nil
 	}
-	return g.enum([]int{}, value.Type(), f)
+	return present, nil
 }
 
-func (g *AutoParameterGenerator) enum(index []int, typ reflect.Type, f func(index []int, field reflect.StructField) error) error {
-	var err error
-	for i := 0; i < typ.NumField(); i++ {
-		field := typ.Field(i)
-		if field.Tag.Get("source") != "" {
-			err = f(append(index, i), field)
-		} else if field.Type.Kind() == reflect.Struct {
-			err = g.enum(append(index, i), field.Type, f)
+// generateGroup fills a nested group. The value may be a struct, a pointer to struct or a slice.
+func (g *AutoParameterGenerator) generateGroup(ctx context.Context, vc ValueContainer, consumers []Consumer,
+	group AutoGroup, value reflect.Value, visiting map[reflect.Type]bool, errs *[]errors.FieldError) (bool, error) {
+	switch value.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(value.Type().Elem())
+		present, err := g.generate(ctx, vc, consumers, group, elem.Elem(), visiting, errs)
+		if err != nil || !present {
+			return false, err
 		}
-		if err != nil {
-			return err
+		value.Set(elem)
+		return true, nil
+	case reflect.Slice:
+		slice := reflect.MakeSlice(value.Type(), 0, 0)
+		for i := 0; i < maxAutoSliceLength; i++ {
+			elem := reflect.New(value.Type().Elem()).Elem()
+			present, err := g.generateGroup(ctx, vc, consumers, group.element(i), elem, visiting, errs)
+			if err != nil {
+				return false, err
+			}
+			if !present {
+				break
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		if slice.Len() <= 0 {
+			return false, nil
 		}
+		value.Set(slice)
+		return true, nil
+	default:
+		return g.generate(ctx, vc, consumers, group, value, visiting, errs)
 	}
-	return nil
 }
 
 // AutoParameterConfig contains configs of AutoParameter.
@@ -509,6 +894,10 @@ const (
 	AutoParameterConfigKeyDefaultValue AutoParameterConfigKey = "default"
 	// AutoParameterConfigKeyOptional is the key of optional tag.
 	AutoParameterConfigKeyOptional AutoParameterConfigKey = "optional"
+	// AutoParameterConfigKeyFields is the key of the source for fields without tag in a group.
+	AutoParameterConfigKeyFields AutoParameterConfigKey = "fields"
+	// AutoParameterConfigKeyValidate is the key to validate the generated struct.
+	AutoParameterConfigKeyValidate AutoParameterConfigKey = "validate"
 )
 
 // Get gets value of a config key.
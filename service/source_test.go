@@ -20,10 +20,15 @@ import (
 	"context"
 	"io"
 	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/errors"
+	"github.com/caicloud/nirvana/log"
 )
 
 type vc struct{}
@@ -43,6 +48,15 @@ func (v *vc) Path(key string) (string, bool) {
 	return v.Get(key)
 }
 
+func (v *vc) SetHost(key, value string) {}
+
+func (v *vc) Host(key string) (string, bool) {
+	if key == testKey {
+		return "host", true
+	}
+	return "", false
+}
+
 func (v *vc) Query(key string) ([]string, bool) {
 	if key == testKey {
 		return []string{"query"}, true
@@ -118,6 +132,23 @@ func TestPathParameterGenerator(t *testing.T) {
 	}
 }
 
+func TestHostParameterGenerator(t *testing.T) {
+	g := &HostParameterGenerator{}
+	if g.Source() != definition.Host {
+		t.Fatalf("HostParameterGenerator has a wrong source: %s", g.Source())
+	}
+	if err := g.Validate("test", "default", reflect.TypeOf("")); err != nil {
+		t.Fatal(err)
+	}
+	result, err := g.Generate(context.Background(), &vc{}, AllConsumers(), "test", reflect.TypeOf(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual("host", result) {
+		t.Fatalf("HostParameterGenerator values is not equal: %+v, %+v", "host", result)
+	}
+}
+
 func TestQueryParameterGenerator(t *testing.T) {
 	g := &QueryParameterGenerator{}
 	if g.Source() != definition.Query {
@@ -240,6 +271,69 @@ func TestPrefabParameterGenerator(t *testing.T) {
 	}
 }
 
+func TestBuiltInPrefabs(t *testing.T) {
+	g := &PrefabParameterGenerator{}
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:3456"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 10.0.0.2")
+	ctx := NewHTTPContext(httptest.NewRecorder(), req)
+	ctx.Context = WithLogger(ctx.Context, &log.SilentLogger{})
+	ctx.Context = WithRequestID(ctx.Context, "abc")
+
+	cases := []struct {
+		name   string
+		target reflect.Type
+	}{
+		{"request", reflect.TypeOf((*http.Request)(nil))},
+		{"responseWriter", reflect.TypeOf((*http.ResponseWriter)(nil)).Elem()},
+		{"logger", reflect.TypeOf((*log.Logger)(nil)).Elem()},
+		{"clientIP", reflect.TypeOf("")},
+		{"requestID", reflect.TypeOf("")},
+	}
+	for _, c := range cases {
+		if err := g.Validate(c.name, nil, c.target); err != nil {
+			t.Fatalf("Prefab %s: %v", c.name, err)
+		}
+		result, err := g.Generate(ctx, ctx.ValueContainer(), nil, c.name, c.target)
+		if err != nil {
+			t.Fatalf("Prefab %s: %v", c.name, err)
+		}
+		if !reflect.TypeOf(result).AssignableTo(c.target) {
+			t.Fatalf("Prefab %s returns a wrong type: %s", c.name, reflect.TypeOf(result))
+		}
+	}
+	if err := g.Validate("request", nil, reflect.TypeOf("")); err == nil {
+		t.Fatal("Validate should return an error for unassignable type")
+	}
+	if id, _ := g.Generate(ctx, ctx.ValueContainer(), nil, "requestID", reflect.TypeOf("")); id != "abc" {
+		t.Fatalf("Request id should be abc, but got: %v", id)
+	}
+	for _, id := range []string{"", "bad\r\nid", "bad id", strings.Repeat("a", 129)} {
+		if RequestIDFrom(WithRequestID(context.Background(), id)) != "" {
+			t.Fatalf("Invalid request id %q should not be stored", id)
+		}
+	}
+	if logger, _ := g.Generate(ctx, ctx.ValueContainer(), nil, "logger", reflect.TypeOf((*log.Logger)(nil)).Elem()); logger != LoggerFrom(ctx) {
+		t.Fatalf("Logger should be the request-scoped logger, but got: %v", logger)
+	}
+	if _, ok := LoggerFrom(ctx).(*log.SilentLogger); ok {
+		t.Fatal("Logger should be scoped to the request id")
+	}
+	if ip, _ := g.Generate(ctx, ctx.ValueContainer(), nil, "clientIP", reflect.TypeOf("")); ip != "10.0.0.1" {
+		t.Fatalf("Untrusted proxy headers should be ignored, but got: %v", ip)
+	}
+	prefab, err := NewClientIPPrefab("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip := prefab.ClientIP(req); ip != "1.2.3.4" {
+		t.Fatalf("Client IP should be 1.2.3.4, but got: %s", ip)
+	}
+	if _, err := NewClientIPPrefab("invalid"); err == nil {
+		t.Fatal("NewClientIPPrefab should return an error for invalid proxy")
+	}
+}
+
 type as struct {
 	Hello     string          `source:"path, hello, default=world"`
 	IsDefault bool            `source:"path,isDefault, default=true,test=10"`
@@ -296,3 +390,153 @@ func TestInvalidAutoParameter(t *testing.T) {
 
 	t.Log(err)
 }
+
+type queryVC struct {
+	vc
+	query map[string][]string
+}
+
+func (v *queryVC) Query(key string) ([]string, bool) {
+	data, ok := v.query[key]
+	return data, ok
+}
+
+type filter struct {
+	Name  string `source:"Query,name"`
+	Value string `source:"Query,value,default=*"`
+}
+
+type nestedAs struct {
+	_       struct{} `source:"Auto,,fields=Query,validate"`
+	Start   int      `json:"start"`
+	Limit   int      `validate:"max=100"`
+	Secret  string   `json:"-"`
+	Owner   *filter  `source:"Auto,owner"`
+	Missing *filter  `source:"Auto,missing"`
+	Filters []filter `source:"Auto,filters"`
+}
+
+func TestNestedAutoParameter(t *testing.T) {
+	g := &AutoParameterGenerator{}
+	target := reflect.TypeOf(nestedAs{})
+	if err := g.Validate("test", nil, target); err != nil {
+		t.Fatal(err)
+	}
+	v := &queryVC{query: map[string][]string{
+		"start":            {"10"},
+		"Limit":            {"20"},
+		"Secret":           {"ignored"},
+		"-":                {"ignored"},
+		"owner.name":       {"alice"},
+		"filters[0].name":  {"a"},
+		"filters[1].name":  {"b"},
+		"filters[1].value": {"c"},
+		"filters[3].name":  {"ignored"},
+	}}
+	result, err := g.Generate(context.Background(), v, AllConsumers(), "test", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := result.(nestedAs)
+	if r.Start != 10 || r.Limit != 20 || r.Secret != "" {
+		t.Fatalf("Unexpected fields: %+v", r)
+	}
+	if r.Owner == nil || r.Owner.Name != "alice" || r.Owner.Value != "*" || r.Missing != nil {
+		t.Fatalf("Unexpected pointer groups: %+v", r)
+	}
+	expected := []filter{{"a", "*"}, {"b", "c"}}
+	if !reflect.DeepEqual(r.Filters, expected) {
+		t.Fatalf("Unexpected slice group: %+v", r.Filters)
+	}
+
+	v.query["Limit"] = []string{"200"}
+	if _, err := g.Generate(context.Background(), v, AllConsumers(), "test", target); err == nil {
+		t.Fatal("Generate should return a validation error")
+	}
+}
+
+type unvalidatedAs struct {
+	Limit  int     `source:"Query,limit" validate:"max=100"`
+	Nested struct {
+		Name string `source:"Query,name"`
+	}
+	Owner *filter
+}
+
+func TestAutoParameterOptIns(t *testing.T) {
+	g := &AutoParameterGenerator{}
+	target := reflect.TypeOf(unvalidatedAs{})
+	if err := g.Validate("test", nil, target); err != nil {
+		t.Fatal(err)
+	}
+	v := &queryVC{query: map[string][]string{
+		"limit": {"200"},
+		"name":  {"alice"},
+	}}
+	result, err := g.Generate(context.Background(), v, AllConsumers(), "test", target)
+	if err != nil {
+		t.Fatalf("Struct without config validate should not be validated: %v", err)
+	}
+	r := result.(unvalidatedAs)
+	if r.Limit != 200 || r.Nested.Name != "alice" {
+		t.Fatalf("Unexpected fields: %+v", r)
+	}
+	if r.Owner != nil {
+		t.Fatalf("Untagged pointer should not be generated: %+v", r.Owner)
+	}
+}
+
+func TestAggregatedAutoParameterErrors(t *testing.T) {
+	g := &AutoParameterGenerator{}
+	target := reflect.TypeOf(nestedAs{})
+	v := &queryVC{query: map[string][]string{
+		"start":           {"x"},
+		"Limit":           {"200"},
+		"filters[1].name": {"b"},
+	}}
+	_, err := g.Generate(WithAggregateErrors(context.Background()), v, AllConsumers(), "test", target)
+	if err == nil {
+		t.Fatal("Generate should return aggregated errors")
+	}
+	var fields []string
+	for _, f := range errors.FieldsOf(err) {
+		fields = append(fields, f.Field)
+	}
+	if expected := []string{"start", "Limit"}; !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("Errors of fields %v should be aggregated, but got %v: %v", expected, fields, err)
+	}
+
+	for path, key := range map[string]string{
+		"Limit":           "Limit",
+		"Owner.Name":      "owner.name",
+		"Filters[1].Name": "filters[1].name",
+		"Filters[x].Name": "",
+		"Missing":         "",
+	} {
+		field, source, ok := requestFieldOf(target, path)
+		if ok != (key != "") || field != key || ok && source != definition.Query {
+			t.Fatalf("Struct path %s should be mapped to %q, but got %q (%s)", path, key, field, source)
+		}
+	}
+}
+
+func TestInvalidNestedAutoParameter(t *testing.T) {
+	g := &AutoParameterGenerator{}
+	for _, target := range []reflect.Type{
+		reflect.TypeOf(struct {
+			Value int `source:"Auto,value"`
+		}{}),
+		reflect.TypeOf(struct {
+			Filters []filter `source:"Auto"`
+		}{}),
+		reflect.TypeOf(struct {
+			Filters []struct {
+				Value string `source:"Header,value"`
+			} `source:"Auto,filters"`
+		}{}),
+	} {
+		if err := g.Validate("test", nil, target); err == nil {
+			t.Fatalf("Validate should return an error for %v", target)
+		}
+	}
+}
@@ -18,6 +18,7 @@ package service
 
 import (
 	"net/http"
+	"sort"
 
 	"github.com/caicloud/nirvana/definition"
 	"github.com/caicloud/nirvana/log"
@@ -57,6 +58,52 @@ type Builder interface {
 	Build() (Service, error)
 }
 
+// ContextReuser is implemented by builders which can reuse http contexts.
+// It's not a part of Builder, so that other implementations of Builder
+// don't have to support it.
+type ContextReuser interface {
+	// ContextReuse returns whether http contexts are reused.
+	ContextReuse() bool
+	// SetContextReuse sets whether http contexts are reused by a pool. Reused
+	// contexts save allocations, but contexts derived from them must not be
+	// used after requests are served. For instance, http clients may cancel
+	// contexts of outgoing requests asynchronously.
+	SetContextReuse(reuse bool)
+}
+
+// ObservableBuilder is implemented by builders which support executor
+// observers. It's not a part of Builder, so that other implementations of
+// Builder don't have to support it.
+type ObservableBuilder interface {
+	// Observers returns all executor observers.
+	Observers() []Observer
+	// AddObserver adds observers to observe phases of executing definitions.
+	AddObserver(observers ...Observer)
+}
+
+// CrashReportingBuilder is implemented by builders which report crashes of
+// their services. It's not a part of Builder, so that other implementations
+// of Builder don't have to support it.
+type CrashReportingBuilder interface {
+	// CrashReporters returns all crash reporters.
+	CrashReporters() []CrashReporter
+	// AddCrashReporter adds crash reporters which get crashes of all
+	// requests served by the service.
+	AddCrashReporter(reporters ...CrashReporter)
+}
+
+// EnvelopeBuilder is implemented by builders which wrap bodies of responses
+// by envelopes. It's not a part of Builder, so that other implementations of
+// Builder don't have to support it.
+type EnvelopeBuilder interface {
+	// Envelope returns the envelope of responses. It returns nil if
+	// responses are not wrapped.
+	Envelope() Envelope
+	// SetEnvelope sets the envelope of all responses. Definitions can
+	// disable it by NoEnvelope. A nil envelope disables envelopes.
+	SetEnvelope(e Envelope)
+}
+
 // Service handles HTTP requests.
 //
 // Workflow:
@@ -77,3 +124,60 @@ type Builder interface {
 type Service interface {
 	http.Handler
 }
+
+// Route describes a route of a service.
+type Route struct {
+	// Host is the host of requests. Empty means all hosts.
+	Host string `json:"host,omitempty"`
+	// Headers are headers which requests must have.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Method is the HTTP method of the route.
+	Method string `json:"method"`
+	// Path is the abstract path of the route.
+	Path string `json:"path"`
+	// Version is the version of the route.
+	Version string `json:"version,omitempty"`
+	// Consumes are content types which the route can consume.
+	Consumes []string `json:"consumes"`
+	// Produces are content types which the route can produce.
+	Produces []string `json:"produces"`
+	// Middlewares is the number of middlewares executed before the handler.
+	Middlewares int `json:"middlewares"`
+	// Function is the full name of the handler function.
+	Function string `json:"function"`
+	// File and Line are the position of the handler function.
+	File string `json:"file"`
+	Line int    `json:"line"`
+	// ShadowedBy contains routes which handle some requests of the route
+	// before it.
+	ShadowedBy []string `json:"shadowedBy,omitempty"`
+}
+
+// String returns a short description of the route:
+//  GET api.example.com/applications/{id} (v2)
+func (r *Route) String() string {
+	s := r.Method + " " + r.Host + r.Path
+	for _, name := range sortedHeaders(r.Headers) {
+		s += " [" + name + ": " + r.Headers[name] + "]"
+	}
+	if r.Version != "" {
+		s += " (" + r.Version + ")"
+	}
+	return s
+}
+
+func sortedHeaders(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RouteLister is an optional interface of Service. It lists routes in the
+// order of matching.
+type RouteLister interface {
+	// Routes returns all routes of the service.
+	Routes() []Route
+}
@@ -105,6 +105,9 @@ var (
 	NoParameterGenerator = errors.InternalServerError.Build("Nirvana:Service:NoParameterGenerator", "no parameter generator for source ${source}")
 	// NoProducerToWrite represents no producer to write error.
 	NoProducerToWrite = errors.NotAcceptable.Build("Nirvana:Service:noProducerToWrite", "can't find producer for accept types ${types}")
+	// InvalidFields means fields of a request are invalid. Errors of fields
+	// can be got by errors.FieldsOf.
+	InvalidFields = errors.BadRequest.Build("Nirvana:Service:InvalidFields", "${count} fields of the request are invalid")
 )
 
 var (
@@ -120,9 +123,21 @@ var (
 	noPrefab               = errors.InternalServerError.Build("Nirvana:Service:noPrefab", "no prefab named ${name}")
 	invalidAutoParameter   = errors.InternalServerError.Build("Nirvana:Service:invalidAutoParameter", "${type} is not a struct or a pointer to struct")
 	invalidFieldTag        = errors.InternalServerError.Build("Nirvana:Service:invalidFieldTag", "filed tag ${tag} is invalid")
+	invalidAutoGroup       = errors.InternalServerError.Build("Nirvana:Service:invalidAutoGroup", "field ${field} with type ${type} can't be a nested group")
+	invalidIndexedField    = errors.InternalServerError.Build("Nirvana:Service:invalidIndexedField", "field ${field} in a slice group must be from Query or Form but got ${source}")
 	noName                 = errors.InternalServerError.Build("Nirvana:Service:noName", "${source} must have a name")
 	invalidTypeForConsumer = errors.InternalServerError.Build("Nirvana:Service:invalidTypeForConsumer", "consumer ${content} can't consume data for type ${type}")
 	invalidTypeForProducer = errors.InternalServerError.Build("Nirvana:Service:invalidTypeForProducer", "producer ${content} can't produce data for type ${type}")
 	unassignableType       = errors.InternalServerError.Build("Nirvana:Service:unassignableType", "type ${typeA} can't assign to ${typeB}")
 	noConverter            = errors.InternalServerError.Build("Nirvana:Service:unassignableType", "no converter for type ${type}")
+	invalidValidatorsType  = errors.InternalServerError.Build("Nirvana:Service:invalidValidatorsType", "type ${type} can't be used as validators")
+	preconditionFailed     = errors.PreconditionFailed.Build("Nirvana:Service:PreconditionFailed", "precondition in header ${header} failed")
+	invalidTrustedProxy    = errors.InternalServerError.Build("Nirvana:Service:invalidTrustedProxy", "${proxy} is not a valid IP or CIDR")
+	invalidPatch           = errors.BadRequest.Build("Nirvana:Service:InvalidPatch", "invalid ${type} document: ${reason}")
+	unappliablePatch       = errors.UnprocessableEntity.Build("Nirvana:Service:UnappliablePatch", "can't apply patch: ${reason}")
+	patchTestFailed        = errors.Conflict.Build("Nirvana:Service:PatchTestFailed", "patch test operation failed at ${path}")
+	invalidPatchTarget     = errors.InternalServerError.Build("Nirvana:Service:invalidPatchTarget", "patch can't be applied to type ${type}")
+	invalidPatternName     = errors.InternalServerError.Build("Nirvana:Service:invalidPatternName", "pattern name ${name} is invalid")
+	invalidPattern         = errors.InternalServerError.Build("Nirvana:Service:invalidPattern", "pattern ${name} has invalid expression ${expression} or examples")
+	invalidPatternType     = errors.InternalServerError.Build("Nirvana:Service:invalidPatternType", "pattern ${name} must have both type and converter or neither")
 )
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"reflect"
 
+	"github.com/caicloud/nirvana/definition"
 	"github.com/caicloud/nirvana/service"
 	builderutil "github.com/caicloud/nirvana/service/builder"
 	"github.com/caicloud/nirvana/utils/project"
@@ -31,6 +32,18 @@ type Definitions struct {
 	Definitions map[string][]Definition
 	// Types contains all types used by definitions.
 	Types map[TypeName]*Type
+	// Envelope describes the envelope of response bodies. It's nil if
+	// responses are not wrapped.
+	Envelope *Envelope
+}
+
+// Envelope describes bodies wrapped by service.Envelope.
+type Envelope struct {
+	// Type is the type of wrapped bodies.
+	Type TypeName
+	// DataField is the json name of the field which contains data or
+	// error messages.
+	DataField string
 }
 
 // Subset returns a subset required by a definition filter.
@@ -44,15 +57,76 @@ func (d *Definitions) Subset(require func(path string, def *Definition) bool) *D
 		}
 	}
 	if len(definitions) > 0 {
-		result := &Definitions{definitions, map[TypeName]*Type{}}
+		result := &Definitions{definitions, map[TypeName]*Type{}, d.Envelope}
 		d.complete(result)
 		return result
 	}
 	return nil
 }
 
+// Paginated checks if a type is a struct which embeds pagination.Page, or
+// a pointer to such a struct.
+func (d *Definitions) Paginated(name TypeName) bool {
+	typ := d.Types[name]
+	if typ != nil && typ.Kind == reflect.Ptr {
+		typ = d.Types[typ.Elem]
+	}
+	if typ == nil || typ.Kind != reflect.Struct {
+		return false
+	}
+	for _, field := range typ.Fields {
+		if field.Anonymous {
+			if fieldType := d.Types[field.Type]; fieldType != nil && fieldType.RawTypeName() == PaginationPageTypeName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsPatch checks if a type is service.Patch or a pointer to it.
+func (d *Definitions) IsPatch(name TypeName) bool {
+	typ := d.Types[name]
+	if typ != nil && typ.Kind == reflect.Ptr {
+		typ = d.Types[typ.Elem]
+	}
+	return typ != nil && typ.RawTypeName() == PatchTypeName
+}
+
+// BodyConsumes returns content types of the request body of a definition.
+// If the body is a patch, only content types of patches are returned, so
+// that API docs and clients don't send patches in other content types.
+func (d *Definitions) BodyConsumes(def *Definition) []string {
+	for _, p := range def.Parameters {
+		if p.Source == definition.Body && d.IsPatch(p.Type) {
+			if consumes := patchConsumes(def.Consumes); len(consumes) > 0 {
+				return consumes
+			}
+		}
+	}
+	return def.Consumes
+}
+
+// patchConsumes returns content types of patches in consumes. Both types of
+// patches are returned if consumes contains definition.MIMEAll.
+func patchConsumes(consumes []string) []string {
+	result := []string{}
+	for _, ct := range consumes {
+		switch ct {
+		case definition.MIMEMergePatch, definition.MIMEJSONPatch:
+			result = append(result, ct)
+		case definition.MIMEAll:
+			return []string{definition.MIMEMergePatch, definition.MIMEJSONPatch}
+		}
+	}
+	return result
+}
+
 // complete fills types for a new definitions. target definitions must be a subset of this definitions.
 func (d *Definitions) complete(definitions *Definitions) {
+	if definitions.Envelope != nil {
+		d.fillTypes(definitions.Types, definitions.Envelope.Type)
+	}
 	for _, defs := range definitions.Definitions {
 		for _, def := range defs {
 			d.fillTypes(definitions.Types, def.Function)
@@ -106,6 +180,7 @@ func (d *Definitions) fillTypes(types map[TypeName]*Type, name TypeName) {
 // Container contains informations to generate APIs.
 type Container struct {
 	modifiers     service.DefinitionModifiers
+	envelope      service.Envelope
 	descriptors   []interface{}
 	typeContainer *TypeContainer
 	analyzer      *Analyzer
@@ -128,6 +203,12 @@ func (ac *Container) AddModifier(modifiers ...service.DefinitionModifier) {
 	ac.modifiers = append(ac.modifiers, modifiers...)
 }
 
+// SetEnvelope sets the envelope of responses. Definitions describe bodies
+// wrapped by it.
+func (ac *Container) SetEnvelope(e service.Envelope) {
+	ac.envelope = e
+}
+
 // AddDescriptor add descriptors to container.
 func (ac *Container) AddDescriptor(descriptors ...interface{}) {
 	ac.descriptors = append(ac.descriptors, descriptors...)
@@ -145,9 +226,51 @@ func (ac *Container) Generate(apiStyle string) (*Definitions, error) {
 	if err != nil {
 		return nil, err
 	}
+	var envelope *Envelope
+	if e := ac.envelope; e != nil {
+		typ, field := e.Schema()
+		envelope = &Envelope{Type: ac.typeContainer.NameOf(typ), DataField: field}
+	}
 	err = ac.typeContainer.Complete(ac.analyzer)
 	return &Definitions{
 		Definitions: result,
 		Types:       ac.typeContainer.Types(),
+		Envelope:    envelope,
 	}, err
 }
+
+// Routes builds a service and returns its routes in the order of matching.
+func (ac *Container) Routes(apiStyle string) ([]service.Route, error) {
+	builder := builderutil.New(service.APIStyle(apiStyle))
+	builder.SetModifier(ac.modifiers.Combine())
+	if err := builder.AddDescriptor(ac.descriptors...); err != nil {
+		return nil, err
+	}
+	s, err := builder.Build()
+	if err != nil {
+		return nil, err
+	}
+	lister, ok := s.(service.RouteLister)
+	if !ok {
+		return nil, fmt.Errorf("API style %s can't list routes", apiStyle)
+	}
+	return lister.Routes(), nil
+}
+
+// Functions returns types of definition functions.
+func (ac *Container) Functions(apiStyle string) ([]reflect.Type, error) {
+	builder := builderutil.New(service.APIStyle(apiStyle))
+	builder.SetModifier(ac.modifiers.Combine())
+	if err := builder.AddDescriptor(ac.descriptors...); err != nil {
+		return nil, err
+	}
+	functions := []reflect.Type{}
+	for _, defs := range builder.Definitions() {
+		for _, d := range defs {
+			if d.Function != nil {
+				functions = append(functions, reflect.TypeOf(d.Function))
+			}
+		}
+	}
+	return functions, nil
+}
@@ -30,6 +30,8 @@ const (
 	CommentsOptionDescriptors = "descriptors"
 	// CommentsOptionModifiers is the option name of modifiers.
 	CommentsOptionModifiers = "modifiers"
+	// CommentsOptionEnvelope is the option name of the envelope.
+	CommentsOptionEnvelope = "envelope"
 )
 
 // Comments is parsed from go comments.
@@ -39,7 +41,7 @@ type Comments struct {
 }
 
 var optionsRegexp = regexp.MustCompile(`^[ \t]*\+nirvana:api[ \t]*=(.*)$`)
-var options = []string{CommentsOptionDescriptors, CommentsOptionModifiers}
+var options = []string{CommentsOptionDescriptors, CommentsOptionModifiers, CommentsOptionEnvelope}
 
 // ParseComments parses comments and extracts nirvana options.
 func ParseComments(comments string) *Comments {
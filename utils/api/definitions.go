@@ -19,8 +19,10 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"reflect"
 
 	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/operators/fields"
 	"github.com/caicloud/nirvana/service"
 )
 
@@ -50,6 +52,9 @@ type Result struct {
 	Description string
 	// Type is result object type.
 	Type TypeName
+	// Raw is true if data of the result is written as it is, without
+	// envelopes. See service.RawType.
+	Raw bool
 }
 
 // Definition is complete version of def.Definition.
@@ -60,6 +65,12 @@ type Definition struct {
 	HTTPMethod string
 	// HTTPCode is http success code.
 	HTTPCode int
+	// Version is the normalized version of the API handler.
+	Version string
+	// Host is the host of requests which the handler handles.
+	Host string
+	// Headers are headers which requests must have.
+	Headers map[string]string
 	// Summary is a brief of this definition.
 	Summary string
 	// Description describes the API handler.
@@ -86,6 +97,8 @@ type Definition struct {
 	Results []Result
 	// Example is the example value.
 	Example interface{}
+	// NoEnvelope is true if responses are not wrapped by the envelope.
+	NoEnvelope bool
 }
 
 // NewDefinition creates openapi.Definition from definition.Definition.
@@ -99,6 +112,9 @@ func NewDefinition(tc *TypeContainer, d *definition.Definition, apiStyle service
 		Method:        d.Method,
 		HTTPMethod:    service.HTTPMethodFor(d.Method),
 		HTTPCode:      code,
+		Version:       definition.NormalizeVersion(d.Version),
+		Host:          d.Host,
+		Headers:       d.Headers,
 		Summary:       d.Summary,
 		Description:   d.Description,
 		Tags:          d.Tags,
@@ -107,6 +123,7 @@ func NewDefinition(tc *TypeContainer, d *definition.Definition, apiStyle service
 		ErrorProduces: d.ErrorProduces,
 		Function:      tc.NameOfInstance(d.Function),
 		Example:       d.Example,
+		NoEnvelope:    d.NoEnvelope,
 	}
 	if d.Method == definition.Any {
 		cd.HTTPMethod = string(definition.Any)
@@ -140,8 +157,27 @@ func NewDefinition(tc *TypeContainer, d *definition.Definition, apiStyle service
 			Description: r.Description,
 			Type:        functionType.Out[i].Type,
 		}
-		if len(r.Operators) > 0 {
-			result.Type = tc.NameOf(r.Operators[len(r.Operators)-1].Out())
+		typ := reflect.TypeOf(d.Function).Out(i)
+		// Operators with interface outputs (such as field selectors) don't
+		// change the type of results.
+		for j := len(r.Operators) - 1; j >= 0; j-- {
+			if out := r.Operators[j].Out(); out.Kind() != reflect.Interface {
+				result.Type = tc.NameOf(out)
+				typ = out
+				break
+			}
+		}
+		result.Raw = service.RawType(typ)
+		for _, op := range r.Operators {
+			if selector, ok := op.(fields.Selector); ok {
+				cd.Parameters = append(cd.Parameters, Parameter{
+					Source:      definition.Query,
+					Name:        selector.Query(),
+					Description: "comma separated field paths to return, such as name,spec.replicas",
+					Type:        tc.NameOf(reflect.TypeOf("")),
+					Optional:    true,
+				})
+			}
 		}
 		cd.Results = append(cd.Results, result)
 	}
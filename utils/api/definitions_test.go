@@ -17,10 +17,13 @@ limitations under the License.
 package api
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/operators/fields"
+	"github.com/caicloud/nirvana/pagination"
 	"github.com/caicloud/nirvana/service"
 )
 
@@ -112,3 +115,73 @@ func TestNewDefinitionShouldNotPanic(t *testing.T) {
 		})
 	}
 }
+
+type application struct {
+	Name string `json:"name"`
+}
+
+func TestNewDefinitionWithFieldSelector(t *testing.T) {
+	tc := NewTypeContainer()
+	d, err := NewDefinition(tc, &definition.Definition{
+		Method:     definition.Get,
+		Function:   func() (*application, error) { return nil, nil },
+		Parameters: []definition.Parameter{},
+		Results: []definition.Result{
+			{Destination: definition.Data, Operators: []definition.Operator{fields.Operator()}},
+			definition.ErrorResult(),
+		},
+	}, service.APIStyleREST)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Parameters) != 1 || d.Parameters[0].Source != definition.Query ||
+		d.Parameters[0].Name != fields.DefaultQuery || !d.Parameters[0].Optional {
+		t.Fatalf("Unexpected parameters: %+v", d.Parameters)
+	}
+	if typ := tc.Type(d.Results[0].Type); typ.Kind != reflect.Ptr || typ.Elem != tc.NameOf(reflect.TypeOf(application{})) {
+		t.Fatalf("Unexpected result type: %+v", typ)
+	}
+}
+
+type pagedItems struct {
+	pagination.Page
+	Items []string
+}
+
+func TestPaginated(t *testing.T) {
+	container := NewTypeContainer()
+	paged := container.NameOf(reflect.TypeOf(&pagedItems{}))
+	plain := container.NameOf(reflect.TypeOf([]string{}))
+	definitions := &Definitions{Types: container.Types()}
+	if !definitions.Paginated(paged) || definitions.Paginated(plain) {
+		t.Fatal("Only structs embedding pagination.Page are paginated")
+	}
+	if name := container.Type(container.NameOf(reflect.TypeOf(pagination.Options{}))).RawTypeName(); name != PaginationOptionsTypeName {
+		t.Fatalf("TypeNameOf should match raw type names, but got %s and %s", name, PaginationOptionsTypeName)
+	}
+}
+
+func TestBodyConsumes(t *testing.T) {
+	container := NewTypeContainer()
+	patch := container.NameOf(reflect.TypeOf(&service.Patch{}))
+	plain := container.NameOf(reflect.TypeOf(""))
+	definitions := &Definitions{Types: container.Types()}
+	for _, test := range []struct {
+		body     TypeName
+		consumes []string
+		expected []string
+	}{
+		{patch, []string{definition.MIMEAll}, []string{definition.MIMEMergePatch, definition.MIMEJSONPatch}},
+		{patch, []string{definition.MIMEJSON, definition.MIMEJSONPatch}, []string{definition.MIMEJSONPatch}},
+		{patch, []string{definition.MIMEJSON}, []string{definition.MIMEJSON}},
+		{plain, []string{definition.MIMEAll}, []string{definition.MIMEAll}},
+	} {
+		def := &Definition{
+			Consumes:   test.consumes,
+			Parameters: []Parameter{{Source: definition.Body, Type: test.body}},
+		}
+		if consumes := definitions.BodyConsumes(def); !reflect.DeepEqual(consumes, test.expected) {
+			t.Fatalf("Body %s should be consumed as %v, but got %v", test.body, test.expected, consumes)
+		}
+	}
+}
@@ -24,6 +24,9 @@ import (
 	"strings"
 	"sync"
 	"unsafe"
+
+	"github.com/caicloud/nirvana/pagination"
+	"github.com/caicloud/nirvana/service"
 )
 
 // TypeName is unique name for go types.
@@ -32,6 +35,29 @@ type TypeName string
 // TypeNameInvalid indicates an invalid type name.
 const TypeNameInvalid = ""
 
+// TypeNameOf returns the name of a named type. It's the same as the raw
+// type name of the type in a TypeContainer.
+func TypeNameOf(typ reflect.Type) TypeName {
+	if typ.PkgPath() == "" {
+		return TypeName(typ.Name())
+	}
+	return TypeName(typ.PkgPath() + "." + typ.Name())
+}
+
+// PaginationOptionsTypeName and PaginationPageTypeName are the type names of
+// pagination.Options and pagination.Page.
+var (
+	PaginationOptionsTypeName = TypeNameOf(reflect.TypeOf(pagination.Options{}))
+	PaginationPageTypeName    = TypeNameOf(reflect.TypeOf(pagination.Page{}))
+)
+
+// PatchTypeName is the type name of service.Patch.
+var PatchTypeName = TypeNameOf(reflect.TypeOf(service.Patch{}))
+
+// OperationTypeName is the type name of operations.Operation. Generators use
+// it to find long-running operations without importing the plugin.
+const OperationTypeName TypeName = "github.com/caicloud/nirvana/plugins/operations.Operation"
+
 // StructField describes a field of a struct.
 type StructField struct {
 	// Name is the field name.
@@ -52,13 +52,50 @@ func NewAPIBuilder(root string, paths ...string) *APIBuilder {
 
 // Build builds api definitions.
 func (b *APIBuilder) Build() (*api.Definitions, error) {
+	definitions := &api.Definitions{}
+	if err := b.run("definitions", definitions); err != nil {
+		return nil, err
+	}
+	return definitions, nil
+}
+
+// BuildRoutes builds the service and returns its routes in the order of matching.
+func (b *APIBuilder) BuildRoutes() ([]service.Route, error) {
+	routes := []service.Route{}
+	if err := b.run("routes", &routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// Adapters contains generated adapters of definition functions.
+type Adapters struct {
+	// Code is the go file which registers adapters.
+	Code string `json:"code"`
+	// Skipped contains function types which can't be adapted.
+	Skipped []string `json:"skipped"`
+}
+
+// BuildAdapters generates typed adapters of definition functions in package pkg.
+func (b *APIBuilder) BuildAdapters(pkg string) (*Adapters, error) {
+	adapters := &Adapters{}
+	if err := b.run("adapters", adapters, pkg); err != nil {
+		return nil, err
+	}
+	return adapters, nil
+}
+
+// run generates a main file to output the target and decodes the output into v.
+// args are passed to the generator of the target.
+func (b *APIBuilder) run(target string, v interface{}, args ...string) error {
 	analyzer, err := api.NewAnalyzer(b.root, b.paths...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	descriptors := make([]function, 0)
 	modifiers := make([]function, 0)
+	var envelope *function
 
 	apiStyle := string(service.APIStyleREST)
 
@@ -73,7 +110,7 @@ func (b *APIBuilder) Build() (*api.Definitions, error) {
 					if descriptorFunc != "" {
 						f, err := getFunction(analyzer, pkg, descriptorFunc)
 						if err != nil {
-							return nil, err
+							return err
 						}
 						descriptors = append(descriptors, *f)
 					}
@@ -81,10 +118,21 @@ func (b *APIBuilder) Build() (*api.Definitions, error) {
 					if modifierFunc != "" {
 						f, err := getFunction(analyzer, pkg, modifierFunc)
 						if err != nil {
-							return nil, err
+							return err
 						}
 						modifiers = append(modifiers, *f)
 					}
+					envelopeFunc := tag.Get("envelope")
+					if envelopeFunc != "" {
+						f, err := getFunction(analyzer, pkg, envelopeFunc)
+						if err != nil {
+							return err
+						}
+						if f.Array {
+							return fmt.Errorf("%s.%s should return an envelope", pkg, envelopeFunc)
+						}
+						envelope = f
+					}
 					style := tag.Get("style")
 					if style != "" {
 						apiStyle = style
@@ -94,9 +142,9 @@ func (b *APIBuilder) Build() (*api.Definitions, error) {
 		}
 	}
 	if len(descriptors) <= 0 {
-		return nil, fmt.Errorf("can't find descriptors from %v", b.paths)
+		return fmt.Errorf("can't find descriptors from %v", b.paths)
 	}
-	return b.runMain(descriptors, modifiers, b.root, b.paths, apiStyle)
+	return b.runMain(descriptors, modifiers, envelope, b.root, b.paths, apiStyle, target, args, v)
 }
 
 type function struct {
@@ -141,39 +189,35 @@ func getFunction(analyzer *api.Analyzer, pkg, name string) (*function, error) {
 	return f, nil
 }
 
-func (b *APIBuilder) runMain(descriptors, modifiers []function, root string, paths []string, apiStyle string) (*api.Definitions, error) {
+func (b *APIBuilder) runMain(descriptors, modifiers []function, envelope *function, root string, paths []string, apiStyle string, target string, args []string, v interface{}) error {
 	tempDir, err := ioutil.TempDir(root, "nirvana-generated")
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer func() {
 		// Clean temp dir.
 		err := os.RemoveAll(tempDir)
 		_ = err
 	}()
-	data, err := b.file(descriptors, modifiers, root, paths, apiStyle)
+	data, err := b.file(descriptors, modifiers, envelope, root, paths, apiStyle, target, args)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	path := filepath.Join(tempDir, "main.go")
 	if err := ioutil.WriteFile(path, data, 0664); err != nil {
-		return nil, err
+		return err
 	}
 	cmd := exec.Command("go", "run", path)
 	cmd.Stderr = os.Stderr
 	buf := bytes.NewBuffer(nil)
 	cmd.Stdout = buf
 	if err := cmd.Run(); err != nil {
-		return nil, err
+		return err
 	}
-	definitions := &api.Definitions{}
-	if err := json.NewDecoder(buf).Decode(definitions); err != nil {
-		return nil, err
-	}
-	return definitions, nil
+	return json.NewDecoder(buf).Decode(v)
 }
 
-func (b *APIBuilder) file(descriptors, modifiers []function, root string, paths []string, apiStyle string) ([]byte, error) {
+func (b *APIBuilder) file(descriptors, modifiers []function, envelope *function, root string, paths []string, apiStyle string, target string, args []string) ([]byte, error) {
 	const tpl = `
 package main
 
@@ -187,8 +231,14 @@ import (
 	{{ range $i,$d := .descriptors }}
 	d{{ $i }} "{{ $d.Pkg }}"
 	{{ end }}
+	{{ if .envelope }}
+	e "{{ .envelope.Pkg }}"
+	{{ end }}
 
 	"github.com/caicloud/nirvana/utils/api"
+	{{- if eq .target "adapters" }}
+	"github.com/caicloud/nirvana/utils/generators/adapter"
+	{{- end }}
 	"github.com/caicloud/nirvana/log"
 )
 
@@ -200,20 +250,44 @@ func main() {
 	{{ range $i,$m := .modifiers }}
 	container.AddModifier(m{{ $i }}.{{ $m.Name }}(){{ if $m.Array }}...{{ end }})
 	{{ end }}
+	{{ if .envelope }}
+	container.SetEnvelope(e.{{ .envelope.Name }}())
+	{{ end }}
 	{{ range $i,$d := .descriptors }}
 	container.AddDescriptor(d{{ $i }}.{{ $d.Name }}(){{ if $d.Array }}...{{ end }})
 	{{ end }}
-	definitions, err := container.Generate({{ .apiStyle }})
-	if definitions == nil {
+	{{ if eq .target "routes" }}
+	result, err := container.Routes({{ .apiStyle }})
+	if err != nil {
 		log.Fatal(err)
 	}
-	data, err := json.Marshal(definitions)
+	{{ else if eq .target "adapters" }}
+	functions, err := container.Functions({{ .apiStyle }})
+	if err != nil {
+		log.Fatal(err)
+	}
+	code, skipped, err := adapter.NewGenerator({{ range .args }}{{ . }}, {{ end }}functions).Generate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	result := map[string]interface{}{"code": string(code), "skipped": skipped}
+	{{ else }}
+	result, err := container.Generate({{ .apiStyle }})
+	if result == nil {
+		log.Fatal(err)
+	}
+	{{ end }}
+	data, err := json.Marshal(result)
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Printf("%s", data)
 }
 `
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = strconv.Quote(arg)
+	}
 	tmpl, err := template.New("main.go").Parse(tpl)
 	if err != nil {
 		return nil, err
@@ -222,9 +296,12 @@ func main() {
 	if err := tmpl.Execute(buf, map[string]interface{}{
 		"modifiers":   modifiers,
 		"descriptors": descriptors,
+		"envelope":    envelope,
 		"root":        strconv.Quote(root),
 		"paths":       paths,
 		"apiStyle":    strconv.Quote(apiStyle),
+		"target":      target,
+		"args":        quoted,
 	}); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,292 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// executorPkg is the package which adapters are registered to.
+const executorPkg = "github.com/caicloud/nirvana/service/executor"
+
+// Generator is for generating typed adapters of definition functions.
+type Generator struct {
+	pkg       string
+	functions []reflect.Type
+	// aliases contains aliases of imported packages by package paths.
+	aliases map[string]string
+	// names contains package paths by aliases.
+	names map[string]string
+}
+
+// NewGenerator creates an adapter generator. pkg is the package name of
+// generated code. functions are types of definition functions.
+func NewGenerator(pkg string, functions []reflect.Type) *Generator {
+	return &Generator{
+		pkg:       pkg,
+		functions: functions,
+		aliases:   map[string]string{},
+		names:     map[string]string{},
+	}
+}
+
+// adapter describes the adapter of a function type.
+type adapter struct {
+	// Type is the go expression of the function type.
+	Type    string
+	Params  []string
+	Results int
+}
+
+// Generate generates a go file which registers adapters in function init.
+// Functions which can't be referred by other packages are skipped, such as
+// variadic functions and functions with unexported types. The result
+// contains names of skipped functions.
+func (g *Generator) Generate() ([]byte, []string, error) {
+	g.alias("reflect")
+	g.alias(executorPkg)
+	adapters := []adapter{}
+	skipped := []string{}
+	seen := map[reflect.Type]bool{}
+	for _, typ := range g.functions {
+		if typ.Kind() != reflect.Func || seen[typ] {
+			continue
+		}
+		seen[typ] = true
+		if typ.IsVariadic() || !g.referable(typ) {
+			skipped = append(skipped, typ.String())
+			continue
+		}
+		// It never fails because the function type is referable.
+		expr, _ := g.expression(typ)
+		a := adapter{Type: expr, Results: typ.NumOut()}
+		for i := 0; i < typ.NumIn(); i++ {
+			param, _ := g.expression(typ.In(i))
+			a.Params = append(a.Params, param)
+		}
+		adapters = append(adapters, a)
+	}
+	sort.Slice(adapters, func(i, j int) bool {
+		return adapters[i].Type < adapters[j].Type
+	})
+	sort.Strings(skipped)
+
+	pkgs := make([]string, 0, len(g.aliases))
+	for pkg := range g.aliases {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	imports := make([]string, len(pkgs))
+	for i, pkg := range pkgs {
+		imports[i] = fmt.Sprintf("%q", pkg)
+		if alias := g.aliases[pkg]; alias != path.Base(pkg) {
+			imports[i] = alias + " " + imports[i]
+		}
+	}
+
+	tmpl, err := template.New("adapters").Funcs(template.FuncMap{
+		"results": func(n int) []string {
+			names := make([]string, n)
+			for i := range names {
+				names[i] = fmt.Sprintf("r%d", i)
+			}
+			return names
+		},
+		"join": strings.Join,
+	}).Parse(`// Code generated by nirvana adapters. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+{{- range .Imports }}
+	{{ . }}
+{{- end }}
+)
+
+func init() {
+{{- range .Adapters }}
+	executor.RegisterAdapter(reflect.TypeOf(({{ .Type }})(nil)), func(f interface{}) executor.Adapter {
+		fn := f.({{ .Type }})
+		return func(args []interface{}) []interface{} {
+		{{- range $i, $p := .Params }}
+			a{{ $i }}, _ := args[{{ $i }}].({{ $p }})
+		{{- end }}
+		{{- $results := results .Results }}
+			{{ if $results }}{{ join $results ", " }} := {{ end }}fn({{ range $i, $p := .Params }}{{ if $i }}, {{ end }}a{{ $i }}{{ end }})
+			return {{ if $results }}[]interface{}{ {{- join $results ", " -}} }{{ else }}nil{{ end }}
+		}
+	})
+{{- end }}
+}
+`)
+	if err != nil {
+		return nil, nil, err
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := tmpl.Execute(buf, map[string]interface{}{
+		"Package":  g.pkg,
+		"Imports":  imports,
+		"Adapters": adapters,
+	}); err != nil {
+		return nil, nil, err
+	}
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	return code, skipped, nil
+}
+
+// referable checks if a type can be referred by generated code. Packages
+// are not imported by the check.
+func (g *Generator) referable(typ reflect.Type) bool {
+	aliases, names := g.aliases, g.names
+	defer func() {
+		g.aliases, g.names = aliases, names
+	}()
+	g.aliases, g.names = map[string]string{}, map[string]string{}
+	_, err := g.expression(typ)
+	return err == nil
+}
+
+// expression returns the go expression of a type. Packages of named types
+// are imported.
+func (g *Generator) expression(typ reflect.Type) (string, error) {
+	if typ.Name() != "" {
+		if typ.PkgPath() == "" {
+			// Predeclared types.
+			return typ.Name(), nil
+		}
+		if typ.PkgPath() == "main" || !ast.IsExported(typ.Name()) {
+			return "", fmt.Errorf("type %s can't be referred", typ.String())
+		}
+		return g.alias(typ.PkgPath()) + "." + typ.Name(), nil
+	}
+	switch typ.Kind() {
+	case reflect.Ptr:
+		elem, err := g.expression(typ.Elem())
+		return "*" + elem, err
+	case reflect.Slice:
+		elem, err := g.expression(typ.Elem())
+		return "[]" + elem, err
+	case reflect.Array:
+		elem, err := g.expression(typ.Elem())
+		return fmt.Sprintf("[%d]%s", typ.Len(), elem), err
+	case reflect.Map:
+		key, err := g.expression(typ.Key())
+		if err != nil {
+			return "", err
+		}
+		elem, err := g.expression(typ.Elem())
+		return fmt.Sprintf("map[%s]%s", key, elem), err
+	case reflect.Chan:
+		elem, err := g.expression(typ.Elem())
+		switch typ.ChanDir() {
+		case reflect.RecvDir:
+			return "<-chan " + elem, err
+		case reflect.SendDir:
+			return "chan<- " + elem, err
+		}
+		return "chan " + elem, err
+	case reflect.Func:
+		return g.function(typ)
+	case reflect.Interface:
+		if typ.NumMethod() == 0 {
+			return "interface{}", nil
+		}
+	case reflect.Struct:
+		if typ.NumField() == 0 {
+			return "struct{}", nil
+		}
+	}
+	return "", fmt.Errorf("type %s can't be referred", typ.String())
+}
+
+// function returns the go expression of a function type.
+func (g *Generator) function(typ reflect.Type) (string, error) {
+	params := make([]string, typ.NumIn())
+	for i := range params {
+		param, err := g.expression(typ.In(i))
+		if err != nil {
+			return "", err
+		}
+		if typ.IsVariadic() && i == len(params)-1 {
+			param = "..." + strings.TrimPrefix(param, "[]")
+		}
+		params[i] = param
+	}
+	results := make([]string, typ.NumOut())
+	for i := range results {
+		result, err := g.expression(typ.Out(i))
+		if err != nil {
+			return "", err
+		}
+		results[i] = result
+	}
+	expr := "func(" + strings.Join(params, ", ") + ")"
+	switch len(results) {
+	case 0:
+	case 1:
+		expr += " " + results[0]
+	default:
+		expr += " (" + strings.Join(results, ", ") + ")"
+	}
+	return expr, nil
+}
+
+// alias returns the alias of an imported package.
+func (g *Generator) alias(pkg string) string {
+	if alias, ok := g.aliases[pkg]; ok {
+		return alias
+	}
+	base := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, path.Base(pkg))
+	if base == "" || base[0] >= '0' && base[0] <= '9' {
+		base = "pkg" + base
+	}
+	alias := base
+	for i := 1; g.names[alias] != "" || alias == g.pkg || reserved(alias); i++ {
+		alias = fmt.Sprintf("%s%d", base, i)
+	}
+	g.aliases[pkg] = alias
+	g.names[alias] = pkg
+	return alias
+}
+
+// reserved checks if a name is used by variables in generated code.
+func reserved(name string) bool {
+	switch name {
+	case "f", "fn", "args":
+		return true
+	}
+	if len(name) > 1 && (name[0] == 'a' || name[0] == 'r') {
+		return strings.Trim(name[1:], "0123456789") == ""
+	}
+	return false
+}
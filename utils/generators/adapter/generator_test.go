@@ -0,0 +1,83 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caicloud/nirvana/definition"
+)
+
+type hidden struct{}
+
+func TestGenerate(t *testing.T) {
+	functions := []reflect.Type{
+		reflect.TypeOf(func(context.Context, string) (string, error) { return "", nil }),
+		reflect.TypeOf(func(context.Context, *time.Time, map[string][]int) ([]definition.Method, error) { return nil, nil }),
+		reflect.TypeOf(func(context.Context) {}),
+		reflect.TypeOf(func(context.Context) {}),
+		reflect.TypeOf(func(context.Context, ...string) {}),
+		reflect.TypeOf(func(context.Context) (*hidden, error) { return nil, nil }),
+	}
+	code, skipped, err := NewGenerator("adapters", functions).Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"package adapters\n",
+		`"github.com/caicloud/nirvana/definition"`,
+		`"time"`,
+		"reflect.TypeOf((func(context.Context, string) (string, error))(nil))",
+		"a1, _ := args[1].(*time.Time)",
+		"a2, _ := args[2].(map[string][]int)",
+		"r0, r1 := fn(a0, a1, a2)",
+		"return []interface{}{r0, r1}",
+	} {
+		if !strings.Contains(string(code), s) {
+			t.Fatalf("Generated code should contain %q, but got:\n%s", s, code)
+		}
+	}
+	if n := strings.Count(string(code), "executor.RegisterAdapter("); n != 3 {
+		t.Fatalf("There should be 3 adapters, but got %d:\n%s", n, code)
+	}
+	if len(skipped) != 2 {
+		t.Fatalf("Variadic functions and functions with unexported types should be skipped, but got %v", skipped)
+	}
+}
+
+func TestAlias(t *testing.T) {
+	g := NewGenerator("adapters", nil)
+	for _, test := range []struct {
+		pkg   string
+		alias string
+	}{
+		{"github.com/a/executor", "executor"},
+		{"github.com/b/executor", "executor1"},
+		{"gopkg.in/yaml.v2", "yamlv2"},
+		{"github.com/c/args", "args1"},
+		{"github.com/d/adapters", "adapters1"},
+		{"github.com/e/go-openapi", "goopenapi"},
+	} {
+		if got := g.alias(test.pkg); got != test.alias {
+			t.Fatalf("Alias of %s should be %s, but got %s", test.pkg, test.alias, got)
+		}
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"strings"
 	"text/template"
 
+	"github.com/caicloud/nirvana/definition"
 	"github.com/caicloud/nirvana/utils/api"
 	"github.com/caicloud/nirvana/utils/generators/utils"
 	"github.com/caicloud/nirvana/utils/project"
@@ -107,6 +108,16 @@ type Interface interface {
 	{{ .Name }}(ctx context.Context{{- if eq .Method "Any" }}, method string, responseCode int{{- end }}{{ range .Parameters }},{{ .ProposedName }} {{ .Typ }}{{- end }}) (
 	{{- range .Results }}{{ .ProposedName }} {{ .Typ }}, {{ end }}err error)
 {{- end }}
+{{- range .Functions }}
+{{- if .Pager }}
+	// {{ .Name }}Pages calls {{ .Name }} for each page until {{ .Pager.Callback }} returns false or there is no more page.
+	{{ .Name }}Pages(ctx context.Context{{ range .Parameters }},{{ .ProposedName }} {{ .Typ }}{{- end }}, {{ .Pager.Callback }} func({{ range .Results }}{{ .Typ }}{{ end }}) bool) error
+{{- end }}
+{{- end }}
+{{- if .WaitType }}
+	// Wait waits for a long-running operation until it is done.
+	Wait(ctx context.Context, operation {{ .WaitType }}) ({{ .WaitType }}, error)
+{{- end }}
 }
 
 // Client for version {{ .Version }}.
@@ -132,6 +143,13 @@ func MustNewClient(cfg *rest.Config) *Client {
 	return client
 }
 
+{{ if .WaitType }}
+// Wait waits for a long-running operation until it is done.
+func (c *Client) Wait(ctx context.Context, operation {{ .WaitType }}) ({{ .WaitType }}, error) {
+	return {{ .WaitPackage }}.Wait(ctx, c.rest, operation)
+}
+{{ end }}
+
 {{ range .Functions }}
 {{ .Comments -}}
 func (c *Client) {{ .Name }}(ctx context.Context{{- if eq .Method "Any" }}, method string, responseCode int{{- end }}{{ range .Parameters }},{{ .ProposedName }} {{ .Typ }}{{- end }}) (
@@ -142,6 +160,12 @@ func (c *Client) {{ .Name }}(ctx context.Context{{- if eq .Method "Any" }}, meth
     {{- end }}
     {{- end }}
 	err = c.rest.Request({{- if eq .Method "Any" }}method, responseCode{{- else }}"{{ .Method }}", {{ .Code }}{{- end }}, "{{ .Path }}").
+	{{ if .Version }}
+	Header("{{ $.VersionHeader }}", "{{ .Version }}").
+	{{ end }}
+	{{ if .Envelope }}
+	Envelope("{{ .Envelope }}").
+	{{ end }}
 	{{ range .Parameters }}
 	{{ $param := .ProposedName }}
 	{{ if not .Extensions }}
@@ -162,16 +186,45 @@ func (c *Client) {{ .Name }}(ctx context.Context{{- if eq .Method "Any" }}, meth
 	Do(ctx)
 	return 
 }
+
+{{ if .Pager }}
+// {{ .Name }}Pages calls {{ .Name }} for each page until {{ .Pager.Callback }} returns false or there is no more page.
+func (c *Client) {{ .Name }}Pages(ctx context.Context{{ range .Parameters }},{{ .ProposedName }} {{ .Typ }}{{- end }}, {{ .Pager.Callback }} func({{ range .Results }}{{ .Typ }}{{ end }}) bool) error {
+	for {
+		{{ .Pager.Page }}, err := c.{{ .Name }}(ctx{{ range .Parameters }}, {{ .ProposedName }}{{- end }})
+		if err != nil {
+			return err
+		}
+		if !{{ .Pager.Callback }}({{ .Pager.Page }}) || {{ .Pager.Page }}.Next == nil {
+			return nil
+		}
+		{{ .Pager.Parameter }} = {{ if not .Pager.Pointer }}*{{ end }}{{ .Pager.Page }}.Next
+	}
+}
+{{ end }}
 {{ end }}
 		`)
 	if err != nil {
 		return nil, err
 	}
+	// Generate a wait helper if any function returns a long-running operation.
+	waitType, waitPackage := "", ""
+	for _, fn := range functions {
+		for _, r := range fn.Results {
+			if r.Operation && waitType == "" {
+				waitType = r.Typ
+				waitPackage = strings.Split(strings.TrimPrefix(r.Typ, "*"), ".")[0]
+			}
+		}
+	}
 	err = template.Execute(buf, map[string]interface{}{
-		"Version":   version,
-		"Rest":      g.rest,
-		"Functions": functions,
-		"Imports":   imports,
+		"WaitType":      waitType,
+		"WaitPackage":   waitPackage,
+		"Version":       version,
+		"VersionHeader": definition.HeaderAcceptVersion,
+		"Rest":          g.rest,
+		"Functions":     functions,
+		"Imports":       imports,
 	})
 	if err != nil {
 		return nil, err
@@ -0,0 +1,191 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package golang
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/pagination"
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/builder"
+	"github.com/caicloud/nirvana/utils/api"
+	"github.com/caicloud/nirvana/utils/project"
+)
+
+// ItemList is a page of items.
+type ItemList struct {
+	pagination.Page
+	Items []string `json:"items"`
+}
+
+func TestPaginatedFunctions(t *testing.T) {
+	b := builder.New(service.APIStyleREST)
+	b.SetModifier(service.FirstContextParameter())
+	err := b.AddDescriptor(definition.Descriptor{
+		Path:     "/api/v1/items",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Definitions: []definition.Definition{{
+			Method:     definition.List,
+			Summary:    "List Items",
+			Parameters: []definition.Parameter{pagination.Parameter()},
+			Results:    definition.DataErrorResults("items"),
+			Function: func(ctx context.Context, options *pagination.Options) (*ItemList, error) {
+				return nil, nil
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := api.NewTypeContainer()
+	paths, err := api.NewPathDefinitions(container, b.Definitions(), service.APIStyleREST)
+	if err != nil {
+		t.Fatal(err)
+	}
+	definitions := &api.Definitions{Definitions: paths, Types: container.Types()}
+	config := &project.Config{
+		Versions: []project.Version{{
+			Name:      "v1",
+			PathRules: []project.PathRule{{Prefix: "/api/v1"}},
+		}},
+	}
+	codes, err := NewGenerator(config, definitions, "github.com/caicloud/nirvana/rest", "client", "github.com/caicloud/nirvana").Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(codes["v1/client"])
+	for _, expected := range []string{
+		"ListItemsPages(ctx context.Context, paginationOptions *pagination.Options, fn func(*golang.ItemList) bool) error",
+		"paginationOptions = page.Next",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Fatalf("Generated code does not contain %q:\n%s", expected, code)
+		}
+	}
+}
+
+func TestEnvelopedFunctions(t *testing.T) {
+	b := builder.New(service.APIStyleREST)
+	b.SetModifier(service.FirstContextParameter())
+	err := b.AddDescriptor(definition.Descriptor{
+		Path:     "/api/v1/items",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Definitions: []definition.Definition{
+			{
+				Method:  definition.List,
+				Summary: "List Items",
+				Results: definition.DataErrorResults("items"),
+				Function: func(ctx context.Context) ([]string, error) {
+					return nil, nil
+				},
+			},
+			{
+				Method:     definition.Create,
+				Summary:    "Create Item",
+				Results:    definition.DataErrorResults("item"),
+				NoEnvelope: true,
+				Function: func(ctx context.Context) (string, error) {
+					return "", nil
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := api.NewTypeContainer()
+	paths, err := api.NewPathDefinitions(container, b.Definitions(), service.APIStyleREST)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope := &api.Envelope{Type: container.NameOf(reflect.TypeOf(service.StandardBody{})), DataField: "data"}
+	definitions := &api.Definitions{Definitions: paths, Types: container.Types(), Envelope: envelope}
+	config := &project.Config{
+		Versions: []project.Version{{
+			Name:      "v1",
+			PathRules: []project.PathRule{{Prefix: "/api/v1"}},
+		}},
+	}
+	codes, err := NewGenerator(config, definitions, "github.com/caicloud/nirvana/rest", "client", "github.com/caicloud/nirvana").Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(codes["v1/client"])
+	if count := strings.Count(code, `Envelope("data")`); count != 1 {
+		t.Fatalf("Only responses of ListItems should be unwrapped, but got %d:\n%s", count, code)
+	}
+}
+
+func TestPatchFunctions(t *testing.T) {
+	b := builder.New(service.APIStyleREST)
+	b.SetModifier(service.FirstContextParameter())
+	err := b.AddDescriptor(definition.Descriptor{
+		Path:     "/api/v1/items/{item}",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Definitions: []definition.Definition{
+			{
+				Method:     definition.Patch,
+				Summary:    "Patch Item",
+				Parameters: []definition.Parameter{definition.PathParameterFor("item", ""), definition.BodyParameterFor("")},
+				Results:    definition.DataErrorResults("item"),
+				Function: func(ctx context.Context, item string, patch *service.Patch) (string, error) {
+					return "", nil
+				},
+			},
+			{
+				Method:     definition.Update,
+				Summary:    "Update Item",
+				Parameters: []definition.Parameter{definition.PathParameterFor("item", ""), definition.BodyParameterFor("")},
+				Results:    definition.DataErrorResults("item"),
+				Function: func(ctx context.Context, item string, body string) (string, error) {
+					return "", nil
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := api.NewTypeContainer()
+	paths, err := api.NewPathDefinitions(container, b.Definitions(), service.APIStyleREST)
+	if err != nil {
+		t.Fatal(err)
+	}
+	definitions := &api.Definitions{Definitions: paths, Types: container.Types()}
+	config := &project.Config{
+		Versions: []project.Version{{
+			Name:      "v1",
+			PathRules: []project.PathRule{{Prefix: "/api/v1"}},
+		}},
+	}
+	codes, err := NewGenerator(config, definitions, "github.com/caicloud/nirvana/rest", "client", "github.com/caicloud/nirvana").Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(codes["v1/client"])
+	// Only the patch is sent as a JSON merge patch.
+	if count := strings.Count(code, `Body("`+definition.MIMEMergePatch+`"`); count != 1 {
+		t.Fatalf("Only the body of PatchItem should be a patch, but got %d:\n%s", count, code)
+	}
+}
@@ -52,16 +52,35 @@ type functionResult struct {
 	ProposedName string
 	Typ          string
 	Creator      string
+	// Operation is true if the result is a long-running operation.
+	Operation bool
+}
+
+// functionPager describes how to walk all pages of a paginated function.
+type functionPager struct {
+	// Parameter is the proposed name of the pagination options parameter.
+	Parameter string
+	// Pointer is true if the parameter is a pointer.
+	Pointer bool
+	// Page and Callback are the names of the page variable and the callback.
+	Page     string
+	Callback string
 }
 
 type function struct {
 	Path       string
 	Method     string
 	Code       int
+	Version    string
 	Name       string
 	Comments   string
 	Parameters []functionParameter
 	Results    []functionResult
+	// Pager is not nil if the function takes pagination options and returns a page.
+	Pager *functionPager
+	// Envelope is the field which contains data or error messages in wrapped
+	// bodies. It's empty if responses are not wrapped.
+	Envelope string
 }
 
 // helper provides methods to help to generate codes.
@@ -159,9 +178,13 @@ func (h *helper) Functions() ([]function, []string) {
 	for path, defs := range h.definitions.Definitions {
 		for _, def := range defs {
 			fn := function{
-				Path:   path,
-				Method: def.HTTPMethod,
-				Code:   def.HTTPCode,
+				Path:    path,
+				Method:  def.HTTPMethod,
+				Code:    def.HTTPCode,
+				Version: def.Version,
+			}
+			if h.definitions.Envelope != nil && !def.NoEnvelope {
+				fn.Envelope = h.definitions.Envelope.DataField
 			}
 			// The priority of summary is higher than original function name.
 			if def.Summary != "" {
@@ -200,7 +223,8 @@ func (h *helper) Functions() ([]function, []string) {
 
 			// If there is no specified consumer, defaults to application/json.
 			firstNonEmptyConsume := definition.MIMEJSON
-			for _, consume := range def.Consumes {
+			// Patches are sent in content types of patches.
+			for _, consume := range h.definitions.BodyConsumes(&def) {
 				if consume != "" {
 					firstNonEmptyConsume = consume
 					break
@@ -208,8 +232,8 @@ func (h *helper) Functions() ([]function, []string) {
 			}
 
 			for _, param := range def.Parameters {
-				if param.Source == definition.Prefab {
-					// Ignore prefabs.
+				if param.Source == definition.Prefab || param.Source == definition.Host {
+					// Ignore prefabs. Hosts are decided by endpoints of clients.
 					continue
 				}
 				p := functionParameter{
@@ -228,8 +252,8 @@ func (h *helper) Functions() ([]function, []string) {
 					h.enumFields(param.Type, "",
 						func(key string, tag string, field api.StructField) {
 							source, name, _, err := service.ParseAutoParameterTag(tag)
-							if err != nil {
-								// Ignore invalid source tag.
+							if err != nil || source == definition.Prefab || source == definition.Auto || source == definition.Host {
+								// Ignore invalid source tag, prefabs, nested groups and hosts.
 								return
 							}
 							extension := parameterExtension{
@@ -244,11 +268,22 @@ func (h *helper) Functions() ([]function, []string) {
 							p.Extensions = append(p.Extensions, extension)
 						})
 				}
+				if typ := h.definitions.Types[param.Type]; param.Source == definition.Auto && typ != nil {
+					pointer := typ.Kind == reflect.Ptr
+					if pointer {
+						typ = h.definitions.Types[typ.Elem]
+					}
+					if typ != nil && typ.RawTypeName() == api.PaginationOptionsTypeName {
+						fn.Pager = &functionPager{Parameter: p.ProposedName, Pointer: pointer}
+					}
+				}
 				fn.Parameters = append(fn.Parameters, p)
 			}
+			paginated := true
 			for _, result := range def.Results {
-				if strings.Contains(string(result.Destination), string(definition.Error)) {
-					// Ignore errors
+				if strings.Contains(string(result.Destination), string(definition.Error)) ||
+					result.Destination == definition.Validators {
+					// Ignore errors and validators. Validators are in response headers.
 					continue
 				}
 				r := functionResult{
@@ -261,8 +296,22 @@ func (h *helper) Functions() ([]function, []string) {
 				types = append(types, typ)
 				if typ.Kind == reflect.Ptr {
 					r.Creator = fmt.Sprintf("new(%s)", h.namer.Name(typ.Elem))
+					if elem := h.definitions.Types[typ.Elem]; elem != nil && elem.RawTypeName() == api.OperationTypeName {
+						r.Operation = true
+					}
 				}
 				fn.Results = append(fn.Results, r)
+				if !h.definitions.Paginated(result.Type) {
+					paginated = false
+				}
+			}
+			if fn.Pager != nil && (len(fn.Results) != 1 || !paginated || fn.Method == string(definition.Any)) {
+				// Only functions with a single page result can be walked.
+				fn.Pager = nil
+			}
+			if fn.Pager != nil {
+				fn.Pager.Page = sigNames.proposeName("page", "")
+				fn.Pager.Callback = sigNames.proposeName("fn", "")
 			}
 			functions = append(functions, fn)
 		}
@@ -22,10 +22,13 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/pagination"
 	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/rest/router"
 	"github.com/caicloud/nirvana/utils/api"
 	"github.com/caicloud/nirvana/utils/project"
 
@@ -40,6 +43,7 @@ var defaultSourceMapping = map[definition.Source]string{
 	definition.File:   "formData",
 	definition.Body:   "body",
 	definition.Prefab: "",
+	definition.Host:   "",
 }
 
 var defaultDestinationMapping = map[definition.Destination]string{
@@ -90,10 +94,40 @@ func NewGenerator(
 	return g
 }
 
-// Generate generates swagger specifications.
+// Generate generates swagger specifications. If definitions have versions,
+// an additional document is generated for each version. If definitions have
+// hosts, additional documents are generated for each host.
 func (g *Generator) Generate() (map[string]spec.Swagger, error) {
 	g.parseSchemas()
-	g.parsePaths()
+	mediaVersions := g.mediaVersions()
+	hosts := g.hosts()
+	paths := make(map[string]map[string]*spec.PathItem, (len(mediaVersions)+1)*(len(hosts)+1))
+	for _, host := range append([]string{""}, hosts...) {
+		for _, mv := range append([]string{""}, mediaVersions...) {
+			g.paths = map[string]*spec.PathItem{}
+			g.parsePaths(host, mv)
+			paths[host+" "+mv] = g.paths
+		}
+	}
+	// buildHosts builds documents for hosts.
+	buildHosts := func(swaggers map[string]spec.Swagger, filename, title, version, description string,
+		schemes []string, basePath string, contact *project.Contact, rules []project.PathRule) {
+		for _, host := range hosts {
+			for _, mv := range append([]string{""}, mediaVersions...) {
+				g.paths = paths[host+" "+mv]
+				name, info := filename+"."+hostFilename(host), fmt.Sprintf("%s (%s)", version, host)
+				if mv != "" {
+					name, info = name+"."+mv, fmt.Sprintf("%s (%s, %s)", version, host, mv)
+				}
+				swagger := g.buildSwaggerInfo(
+					title, info, description,
+					schemes, host, basePath, contact,
+					rules,
+				)
+				swaggers[name] = *swagger
+			}
+		}
+	}
 
 	swaggers := make(map[string]spec.Swagger, len(g.config.Versions))
 	for _, version := range g.config.Versions {
@@ -119,31 +153,141 @@ func (g *Generator) Generate() (map[string]spec.Swagger, error) {
 			basePath = g.config.BasePath
 		}
 
-		swagger := g.buildSwaggerInfo(
-			title, version.Name, description,
-			schemes, host, basePath, contact,
-			version.PathRules,
-		)
 		var filename string
 		if version.Module != "" {
 			filename = strings.ToLower(version.Module) + "." + strings.ToLower(version.Name)
 		} else {
 			filename = strings.ToLower(version.Name)
 		}
+		g.paths = paths[" "]
+		swagger := g.buildSwaggerInfo(
+			title, version.Name, description,
+			schemes, host, basePath, contact,
+			version.PathRules,
+		)
 		swaggers[filename] = *swagger
+		for _, mv := range mediaVersions {
+			g.paths = paths[" "+mv]
+			swagger := g.buildSwaggerInfo(
+				title, fmt.Sprintf("%s (%s)", version.Name, mv), description,
+				schemes, host, basePath, contact,
+				version.PathRules,
+			)
+			swaggers[filename+"."+mv] = *swagger
+		}
+		buildHosts(swaggers, filename, title, version.Name, description,
+			schemes, basePath, contact, version.PathRules)
 	}
 
 	if len(swaggers) <= 0 {
+		g.paths = paths[" "]
 		swagger := g.buildSwaggerInfo(
 			g.config.Project, "unknown", g.config.Description,
 			g.config.Schemes, g.config.Host, g.config.BasePath, g.config.Contact,
 			nil,
 		)
 		swaggers["unknown"] = *swagger
+		buildHosts(swaggers, "unknown", g.config.Project, "unknown", g.config.Description,
+			g.config.Schemes, g.config.BasePath, g.config.Contact, nil)
 	}
 	return swaggers, nil
 }
 
+// mediaVersions returns sorted versions of all definitions.
+func (g *Generator) mediaVersions() []string {
+	versions := []string{}
+	exists := map[string]bool{}
+	for _, defs := range g.apis.Definitions {
+		for _, def := range defs {
+			if def.Version != "" && !exists[def.Version] {
+				exists[def.Version] = true
+				versions = append(versions, def.Version)
+			}
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return definition.CompareVersions(versions[i], versions[j]) < 0
+	})
+	return versions
+}
+
+// hosts returns sorted hosts of all definitions.
+func (g *Generator) hosts() []string {
+	hosts := []string{}
+	exists := map[string]bool{}
+	for _, defs := range g.apis.Definitions {
+		for _, def := range defs {
+			if def.Host != "" && !exists[def.Host] {
+				exists[def.Host] = true
+				hosts = append(hosts, def.Host)
+			}
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// hostFilename replaces wildcards and keys in a host for file names.
+//  {tenant}.example.com -> _tenant_.example.com
+func hostFilename(host string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, host)
+}
+
+// chooseHost chooses definitions which handle requests for the host. It
+// follows the rules of the rest service: definitions of the host take
+// precedence over definitions without hosts.
+func chooseHost(defs []api.Definition, host string) []api.Definition {
+	methods := map[string]bool{}
+	if host != "" {
+		for _, def := range defs {
+			if def.Host == host {
+				methods[def.HTTPMethod] = true
+			}
+		}
+	}
+	result := make([]api.Definition, 0, len(defs))
+	for _, def := range defs {
+		if def.Host == host || (def.Host == "" && !methods[def.HTTPMethod]) {
+			result = append(result, def)
+		}
+	}
+	return result
+}
+
+// chooseVersion chooses definitions which handle requests for the version.
+// Definitions of every method are chosen by definition.ChooseVersion as the
+// rest service does.
+func chooseVersion(defs []api.Definition, version string) []api.Definition {
+	methods := map[string][]api.Definition{}
+	for _, def := range defs {
+		methods[def.HTTPMethod] = append(methods[def.HTTPMethod], def)
+	}
+	result := make([]api.Definition, 0, len(defs))
+	for _, def := range defs {
+		candidates, ok := methods[def.HTTPMethod]
+		if !ok {
+			continue
+		}
+		delete(methods, def.HTTPMethod)
+		versions := make([]string, len(candidates))
+		for i, c := range candidates {
+			versions[i] = c.Version
+		}
+		chosen := definition.ChooseVersion(versions, version)
+		for _, c := range candidates {
+			if c.Version == chosen {
+				result = append(result, c)
+			}
+		}
+	}
+	return result
+}
+
 func (g *Generator) buildSwaggerInfo(
 	title, version, description string,
 	schemes []string,
@@ -230,6 +374,12 @@ func (g *Generator) schemaForType(typ *api.Type) *spec.Schema {
 			if typ.TypeName() == "time.Time" {
 				schema = spec.DateTimeProperty()
 				schema.Title = "Time"
+			} else if typ.RawTypeName() == api.PatchTypeName {
+				// A patch is a document which depends on content type.
+				schema = &spec.Schema{}
+				schema.Title = typ.Name
+				schema.Description = fmt.Sprintf("A JSON merge patch (%s) or a list of JSON patch operations (%s).",
+					definition.MIMEMergePatch, definition.MIMEJSONPatch)
 			} else {
 				schema = g.schemaForStruct(typ)
 			}
@@ -353,11 +503,13 @@ func (g *Generator) copySchema(source *spec.Schema) *spec.Schema {
 	return &dest
 }
 
-func (g *Generator) parsePaths() {
+func (g *Generator) parsePaths(host, version string) {
 	for path, defs := range g.apis.Definitions {
+		path, expressions := pathTemplate(path)
 		operations := map[string][]*spec.Operation{}
-		for _, def := range defs {
+		for _, def := range chooseVersion(chooseHost(defs, host), version) {
 			op := g.operationFor(&def)
+			setPathPatterns(op, expressions)
 			ops := operations[def.HTTPMethod]
 			ops = append(ops, op)
 			operations[def.HTTPMethod] = ops
@@ -404,10 +556,51 @@ func (g *Generator) parsePaths() {
 	}
 }
 
+// pathTemplate removes expressions from path keys, and returns expressions
+// of the keys.
+//  /applications/{id:int}/files/{path:*} -> /applications/{id}/files/{path}
+func pathTemplate(path string) (string, map[string]string) {
+	expressions := service.PathExpressions(path)
+	segments, err := router.Split(path)
+	if err != nil || len(expressions) <= 0 {
+		return path, expressions
+	}
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") {
+			if index := strings.Index(segment, ":"); index > 0 {
+				segments[i] = segment[:index] + "}"
+			}
+		}
+	}
+	return strings.Join(segments, ""), expressions
+}
+
+// setPathPatterns sets patterns and formats of path parameters by expressions
+// of path keys. Named patterns are replaced by their expressions.
+func setPathPatterns(op *spec.Operation, expressions map[string]string) {
+	for i := range op.Parameters {
+		param := &op.Parameters[i]
+		exp, ok := expressions[param.Name]
+		if param.In != "path" || !ok || exp == router.TailMatchTarget {
+			continue
+		}
+		if p := service.PatternFor(exp); p != nil {
+			exp = p.Expression
+			if p.Format != "" {
+				param.Format = p.Format
+			}
+		}
+		if strings.Contains(exp, "|") {
+			exp = "(?:" + exp + ")"
+		}
+		param.Pattern = "^" + exp + "$"
+	}
+}
+
 func (g *Generator) operationFor(def *api.Definition) *spec.Operation {
 	operation := &spec.Operation{}
 	consumes := map[string]bool{}
-	for _, c := range def.Consumes {
+	for _, c := range g.apis.BodyConsumes(def) {
 		if !consumes[c] {
 			consumes[c] = true
 			operation.Consumes = append(operation.Consumes, c)
@@ -459,13 +652,39 @@ func (g *Generator) operationFor(def *api.Definition) *spec.Operation {
 			operation.Parameters = append(operation.Parameters, parameters...)
 		}
 	}
+	if def.Version != "" {
+		parameter := spec.HeaderParam(definition.HeaderAcceptVersion).Typed("string", "")
+		parameter.Description = "Version of the API. It also can be set by vendor media types in Accept or Content-Type."
+		parameter.WithDefault(def.Version).WithEnum(def.Version)
+		operation.Parameters = append(operation.Parameters, *parameter)
+	}
+	names := make([]string, 0, len(def.Headers))
+	for name := range def.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// Requests without the header are handled by other definitions.
+		parameter := spec.HeaderParam(name).Typed("string", "")
+		parameter.Required = true
+		if value := def.Headers[name]; value != "*" {
+			parameter.WithEnum(value)
+		}
+		operation.Parameters = append(operation.Parameters, *parameter)
+	}
 	operation.Responses = &spec.Responses{
 		ResponsesProps: spec.ResponsesProps{
 			StatusCodeResponses: map[int]spec.Response{
-				def.HTTPCode: *g.generateResponse(def.Results, def.Example),
+				def.HTTPCode: *g.generateResponse(def.HTTPCode, def.Results, def.Example, !def.NoEnvelope),
 			},
 		},
 	}
+	if g.apis.Envelope != nil && !def.NoEnvelope {
+		// Errors are wrapped by the envelope.
+		response := spec.NewResponse().WithDescription("Error")
+		response.Schema = g.wrap(nil)
+		operation.Responses.Default = response
+	}
 	return operation
 }
 
@@ -538,7 +757,16 @@ func (g *Generator) generateAutoParameter(typ api.TypeName) []spec.Parameter {
 	if structType.Kind != reflect.Struct {
 		return nil
 	}
-	return g.enum(structType)
+	parameters := g.enum(structType)
+	if structType.RawTypeName() == api.PaginationOptionsTypeName {
+		// Offsets and limits of all list definitions are documented consistently.
+		for i := range parameters {
+			if parameters[i].Name == "offset" || parameters[i].Name == "limit" {
+				parameters[i].WithMinimum(0, false)
+			}
+		}
+	}
+	return parameters
 }
 
 var converters = map[string]service.Converter{
@@ -578,37 +806,100 @@ var converters = map[string]service.Converter{
 	"[]string":   service.ConvertToStringSlice,
 }
 
+// groupElem returns the struct type of a nested group. Slices are only
+// allowed for tagged groups.
+func (g *Generator) groupElem(name api.TypeName, slice bool) (*api.Type, bool) {
+	typ, ok := g.apis.Types[name]
+	if ok && slice && typ.Kind == reflect.Slice {
+		typ, ok = g.apis.Types[typ.Elem]
+	}
+	if ok && typ.Kind == reflect.Ptr {
+		typ, ok = g.apis.Types[typ.Elem]
+	}
+	if !ok || typ.Kind != reflect.Struct {
+		return nil, false
+	}
+	return typ, true
+}
+
 func (g *Generator) enum(typ *api.Type) []spec.Parameter {
+	return g.enumGroup(typ, service.AutoGroup{}, map[*api.Type]bool{})
+}
+
+func (g *Generator) enumGroup(typ *api.Type, group service.AutoGroup, visiting map[*api.Type]bool) []spec.Parameter {
+	if visiting[typ] {
+		return nil
+	}
+	visiting[typ] = true
+	defer delete(visiting, typ)
+	for _, field := range typ.Fields {
+		if field.Name != "_" {
+			continue
+		}
+		if _, _, apc, err := service.ParseAutoParameterTag(field.Tag.Get("source")); err == nil {
+			group = group.Configure(apc)
+		}
+	}
 	results := make([]spec.Parameter, 0, len(typ.Fields))
 	for _, field := range typ.Fields {
+		if field.Name == "_" {
+			continue
+		}
 		tag := field.Tag.Get("source")
 		parameters := []spec.Parameter(nil)
 		if tag != "" {
 			source, name, apc, err := service.ParseAutoParameterTag(tag)
-			rawDefaultValue, defaultExist := apc.Get(service.AutoParameterConfigKeyDefaultValue)
-			var defaultValue []byte
-			if c := converters[string(field.Type)]; defaultExist && c != nil {
-				// we don't find a good way to handle the default value of non-basic types,
-				// so for now the default value of those types are always empty
-				v, _ := c(context.TODO(), []string{rawDefaultValue})
-				defaultValue, _ = json.Marshal(v)
+			if err != nil {
+				continue
 			}
-			_, optional := apc.Get(service.AutoParameterConfigKeyOptional)
-
-			if err == nil {
+			if source == definition.Auto {
+				elem, ok := g.groupElem(field.Type, true)
+				if !ok {
+					continue
+				}
+				child := group.Child(name, apc)
+				if g.apis.Types[field.Type].Kind == reflect.Slice {
+					// Elements of a slice group are read from indexed keys.
+					child.Prefix += "[n]"
+					child.Indexed = true
+				}
+				parameters = g.enumGroup(elem, child, visiting)
+			} else {
+				rawDefaultValue, defaultExist := apc.Get(service.AutoParameterConfigKeyDefaultValue)
+				var defaultValue []byte
+				if c := converters[string(field.Type)]; defaultExist && c != nil {
+					// we don't find a good way to handle the default value of non-basic types,
+					// so for now the default value of those types are always empty
+					v, _ := c(context.TODO(), []string{rawDefaultValue})
+					defaultValue, _ = json.Marshal(v)
+				}
+				_, optional := apc.Get(service.AutoParameterConfigKeyOptional)
 				parameters = g.generateParameter(&api.Parameter{
 					Source:      source,
-					Name:        name,
+					Name:        group.Key(source, name),
 					Description: g.escapeNewline(field.Comments),
 					Type:        field.Type,
 					Default:     defaultValue,
-					Optional:    optional || defaultExist,
+					Optional:    optional || defaultExist || group.Indexed,
 				})
 			}
 		} else {
-			fieldType, ok := g.apis.Types[field.Type]
-			if ok && fieldType.Kind == reflect.Struct {
-				parameters = g.enum(fieldType)
+			elem, isGroup := g.groupElem(field.Type, false)
+			exported := field.PkgPath == "" || field.Anonymous
+			if group.Fields != "" && exported && (converters[string(field.Type)] != nil || !isGroup) {
+				name, ok := service.JSONNameOf(field.Name, field.Tag)
+				if !ok {
+					continue
+				}
+				parameters = g.generateParameter(&api.Parameter{
+					Source:      group.Fields,
+					Name:        group.Key(group.Fields, name),
+					Description: g.escapeNewline(field.Comments),
+					Type:        field.Type,
+					Optional:    true,
+				})
+			} else if isGroup {
+				parameters = g.enumGroup(elem, group, visiting)
 			}
 		}
 		if len(parameters) > 0 {
@@ -632,26 +923,57 @@ func parseDestination(d definition.Destination) definition.Destination {
 	}
 }
 
-func (g *Generator) generateResponse(results []api.Result, example interface{}) *spec.Response {
+// generateResponse generates the success response. If enveloped is true,
+// the body is wrapped by the envelope of responses.
+func (g *Generator) generateResponse(code int, results []api.Result, example interface{}, enveloped bool) *spec.Response {
 	response := &spec.Response{}
 	for _, result := range results {
 		switch g.destinationMapping[parseDestination(result.Destination)] {
 		case "body":
 			response.Description = g.escapeNewline(result.Description)
 			schema := g.schemaForTypeName(result.Type)
+			if enveloped && g.apis.Envelope != nil && schema != nil && !result.Raw {
+				schema = g.wrap(schema)
+			}
 			// responses.xx.schema should NOT have additional properties
 			// additionalProperty: title
 			schema.Title = ""
 			response.Schema = schema
+			if g.apis.Paginated(result.Type) {
+				response.AddHeader(pagination.HeaderLink,
+					spec.ResponseHeader().Typed("string", "").WithDescription("Links to the first, previous, next and last pages (RFC 5988)"))
+				response.AddHeader(pagination.HeaderTotalCount,
+					spec.ResponseHeader().Typed("integer", "").WithDescription("Total number of items if it's known"))
+			}
 		}
 	}
 	response.AddExample("application/json", example)
 	if response.Schema == nil && response.Description == "" {
-		response.Description = "No Content"
+		// Responses of handlers without data (such as mounted http handlers)
+		// are opaque.
+		response.Description = http.StatusText(code)
 	}
 	return response
 }
 
+// wrap returns the schema of bodies wrapped by the envelope. The data field
+// of the envelope is described by data if it's not nil.
+func (g *Generator) wrap(data *spec.Schema) *spec.Schema {
+	envelope := g.schemaForTypeName(g.apis.Envelope.Type)
+	if envelope == nil {
+		return data
+	}
+	envelope.Title = ""
+	if data == nil {
+		return envelope
+	}
+	field := &spec.Schema{}
+	field.SetProperty(g.apis.Envelope.DataField, *data)
+	schema := &spec.Schema{}
+	schema.AllOf = []spec.Schema{*envelope, *field}
+	return schema
+}
+
 func (g *Generator) escapeNewline(content string) string {
 	return strings.Replace(strings.TrimSpace(content), "\n", "<br/>", -1)
 }
@@ -0,0 +1,293 @@
+/*
+Copyright 2020 Caicloud Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package swagger
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/caicloud/nirvana/definition"
+	"github.com/caicloud/nirvana/service"
+	"github.com/caicloud/nirvana/service/builder"
+	"github.com/caicloud/nirvana/utils/api"
+	"github.com/caicloud/nirvana/utils/project"
+
+	"github.com/go-openapi/spec"
+)
+
+func TestPathPatterns(t *testing.T) {
+	path, expressions := pathTemplate("/apps/{app:slug}/records/{id:int}/days/{day:date}/{name}.{ext:[a-z]+}/{path:*}")
+	if path != "/apps/{app}/records/{id}/days/{day}/{name}.{ext}/{path}" {
+		t.Fatalf("Unexpected path template: %s", path)
+	}
+	op := &spec.Operation{}
+	for _, name := range []string{"app", "id", "day", "name", "ext", "path"} {
+		op.Parameters = append(op.Parameters, *spec.PathParam(name).Typed("string", ""))
+	}
+	setPathPatterns(op, expressions)
+	expected := []struct {
+		pattern string
+		format  string
+	}{
+		{"^[a-z0-9]+(?:-[a-z0-9]+)*$", ""},
+		{"^-?[0-9]+$", ""},
+		{"^[0-9]{4}-[0-9]{2}-[0-9]{2}$", "date"},
+		{"", ""},
+		{"^[a-z]+$", ""},
+		{"", ""},
+	}
+	for i, param := range op.Parameters {
+		if param.Pattern != expected[i].pattern || param.Format != expected[i].format {
+			t.Fatalf("Unexpected pattern of %s: %q %q", param.Name, param.Pattern, param.Format)
+		}
+	}
+}
+
+func TestChooseHost(t *testing.T) {
+	defs := []api.Definition{
+		{HTTPMethod: http.MethodGet, Summary: "default"},
+		{HTTPMethod: http.MethodPost, Summary: "default"},
+		{HTTPMethod: http.MethodGet, Host: "admin.example.com", Summary: "admin"},
+		{HTTPMethod: http.MethodGet, Host: "{tenant}.example.com", Summary: "tenant"},
+	}
+	for host, expected := range map[string][]string{
+		"":                     {"GET default", "POST default"},
+		"admin.example.com":    {"POST default", "GET admin"},
+		"{tenant}.example.com": {"POST default", "GET tenant"},
+	} {
+		chosen := []string{}
+		for _, def := range chooseHost(defs, host) {
+			chosen = append(chosen, def.HTTPMethod+" "+def.Summary)
+		}
+		if !reflect.DeepEqual(chosen, expected) {
+			t.Fatalf("Definitions for host %q should be %v, but got: %v", host, expected, chosen)
+		}
+	}
+	if name := hostFilename("{tenant}.example.com"); name != "_tenant_.example.com" {
+		t.Fatalf("Unexpected file name: %s", name)
+	}
+}
+
+// envelopeToken is a named string. It's marshaled and wrapped like other
+// types, unlike raw strings.
+type envelopeToken string
+
+func TestEnvelope(t *testing.T) {
+	b := builder.New(service.APIStyleREST)
+	b.SetModifier(service.FirstContextParameter())
+	err := b.AddDescriptor(definition.Descriptor{
+		Path:     "/api/v1/token",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Definitions: []definition.Definition{
+			{
+				Method:  definition.Get,
+				Results: definition.DataErrorResults("token"),
+				Function: func(ctx context.Context) (envelopeToken, error) {
+					return "", nil
+				},
+			},
+		},
+	}, definition.Descriptor{
+		Path:     "/api/v1/raw",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Definitions: []definition.Definition{
+			{
+				Method:  definition.Get,
+				Results: definition.DataErrorResults("raw"),
+				Function: func(ctx context.Context) (string, error) {
+					return "", nil
+				},
+			},
+		},
+	}, definition.Descriptor{
+		Path:     "/api/v1/items",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Definitions: []definition.Definition{
+			{
+				Method:  definition.List,
+				Results: definition.DataErrorResults("items"),
+				Function: func(ctx context.Context) ([]int, error) {
+					return nil, nil
+				},
+			},
+			{
+				Method:     definition.Create,
+				Results:    definition.DataErrorResults("item"),
+				NoEnvelope: true,
+				Function: func(ctx context.Context) (int, error) {
+					return 0, nil
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := api.NewTypeContainer()
+	paths, err := api.NewPathDefinitions(container, b.Definitions(), service.APIStyleREST)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope := &api.Envelope{Type: container.NameOf(reflect.TypeOf(service.StandardBody{})), DataField: "data"}
+	definitions := &api.Definitions{Definitions: paths, Types: container.Types(), Envelope: envelope}
+	swaggers, err := NewDefaultGenerator(&project.Config{}, definitions).Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(swaggers) <= 0 {
+		t.Fatal("No swagger is generated")
+	}
+	for _, s := range swaggers {
+		item := s.Paths.Paths["/api/v1/items"]
+		list := item.Get.Responses.StatusCodeResponses[http.StatusOK].Schema
+		if list == nil || len(list.AllOf) != 2 || list.AllOf[1].Properties["data"].Type[0] != "array" {
+			t.Fatalf("Responses of listing should be wrapped, but got %+v", list)
+		}
+		if item.Get.Responses.Default == nil {
+			t.Fatalf("Errors of listing should be described by the envelope")
+		}
+		create := item.Post.Responses.StatusCodeResponses[http.StatusCreated].Schema
+		if create == nil || len(create.AllOf) != 0 || item.Post.Responses.Default != nil {
+			t.Fatalf("Responses of creating should not be wrapped, but got %+v", create)
+		}
+		token := s.Paths.Paths["/api/v1/token"].Get.Responses.StatusCodeResponses[http.StatusOK].Schema
+		if token == nil || len(token.AllOf) != 2 {
+			t.Fatalf("Responses of named strings should be wrapped, but got %+v", token)
+		}
+		raw := s.Paths.Paths["/api/v1/raw"].Get.Responses.StatusCodeResponses[http.StatusOK].Schema
+		if raw == nil || len(raw.AllOf) != 0 {
+			t.Fatalf("Responses of raw strings should not be wrapped, but got %+v", raw)
+		}
+	}
+}
+
+type autoOwner struct {
+	Name string `source:"Query,name"`
+}
+
+type autoOptions struct {
+	_      struct{}   `source:"Auto,,fields=Query"`
+	Start  int        `json:"start"`
+	Limit  int        `json:",omitempty"`
+	Secret string     `json:"-"`
+	Owner  *autoOwner `source:"Auto,owner"`
+}
+
+func TestAutoParameter(t *testing.T) {
+	b := builder.New(service.APIStyleREST)
+	b.SetModifier(service.FirstContextParameter())
+	err := b.AddDescriptor(definition.Descriptor{
+		Path:     "/api/v1/items",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Definitions: []definition.Definition{
+			{
+				Method:     definition.List,
+				Parameters: []definition.Parameter{definition.AutoParameterFor("")},
+				Results:    definition.DataErrorResults("items"),
+				Function: func(ctx context.Context, options autoOptions) ([]int, error) {
+					return nil, nil
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := api.NewTypeContainer()
+	paths, err := api.NewPathDefinitions(container, b.Definitions(), service.APIStyleREST)
+	if err != nil {
+		t.Fatal(err)
+	}
+	definitions := &api.Definitions{Definitions: paths, Types: container.Types()}
+	swaggers, err := NewDefaultGenerator(&project.Config{}, definitions).Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(swaggers) <= 0 {
+		t.Fatal("No swagger is generated")
+	}
+	for _, s := range swaggers {
+		names := []string{}
+		for _, param := range s.Paths.Paths["/api/v1/items"].Get.Parameters {
+			names = append(names, param.In+":"+param.Name)
+		}
+		// Keys are the same as keys read by service.AutoParameterGenerator.
+		expected := []string{"query:start", "query:Limit", "query:owner.name"}
+		if !reflect.DeepEqual(names, expected) {
+			t.Fatalf("Parameters should be %v, but got %v", expected, names)
+		}
+	}
+}
+
+func TestPatchConsumes(t *testing.T) {
+	b := builder.New(service.APIStyleREST)
+	b.SetModifier(service.FirstContextParameter())
+	err := b.AddDescriptor(definition.Descriptor{
+		Path:     "/api/v1/items",
+		Consumes: []string{definition.MIMEAll},
+		Produces: []string{definition.MIMEJSON},
+		Definitions: []definition.Definition{
+			{
+				Method:     definition.Patch,
+				Parameters: []definition.Parameter{definition.BodyParameterFor("")},
+				Results:    definition.DataErrorResults("item"),
+				Function: func(ctx context.Context, patch *service.Patch) (string, error) {
+					return "", nil
+				},
+			},
+			{
+				Method:     definition.Update,
+				Parameters: []definition.Parameter{definition.BodyParameterFor("")},
+				Results:    definition.DataErrorResults("item"),
+				Function: func(ctx context.Context, body string) (string, error) {
+					return "", nil
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := api.NewTypeContainer()
+	paths, err := api.NewPathDefinitions(container, b.Definitions(), service.APIStyleREST)
+	if err != nil {
+		t.Fatal(err)
+	}
+	definitions := &api.Definitions{Definitions: paths, Types: container.Types()}
+	swaggers, err := NewDefaultGenerator(&project.Config{}, definitions).Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(swaggers) <= 0 {
+		t.Fatal("No swagger is generated")
+	}
+	for _, s := range swaggers {
+		item := s.Paths.Paths["/api/v1/items"]
+		if expected := []string{definition.MIMEMergePatch, definition.MIMEJSONPatch}; !reflect.DeepEqual(item.Patch.Consumes, expected) {
+			t.Fatalf("Patch should consume %v, but got %v", expected, item.Patch.Consumes)
+		}
+		if expected := []string{definition.MIMEAll}; !reflect.DeepEqual(item.Put.Consumes, expected) {
+			t.Fatalf("Update should consume %v, but got %v", expected, item.Put.Consumes)
+		}
+	}
+}